@@ -0,0 +1,66 @@
+package bass
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+// handleResourceAction serves actions on a resource item, addressed as "{name}:{action}".
+func (h *Handler) handleResourceAction() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		i := strings.LastIndex(r.PathValue("name"), ":")
+		if i < 0 {
+			w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusMethodNotAllowed),
+				problem.WithTitle("Method Not Allowed"),
+				withReason(apierrors.ReasonMethodNotAllowed),
+			))
+
+			return
+		}
+
+		name, action := r.PathValue("name")[:i], r.PathValue("name")[i+1:]
+
+		// actions write directly, so they are not available for protected resource types. Failures to resolve the
+		// resource type are left to the action.
+		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), r.PathValue("packageName"), r.PathValue("resourceTypePlural"))
+		if err == nil && resourceTypeDefinition.Approval != nil {
+			respond.Done(w, r, approvalRequired(ApprovalRequiredError{
+				PackageName:  resourceTypeDefinition.Package,
+				ResourceType: resourceTypeDefinition.ResourceType,
+			}))
+
+			return
+		}
+
+		// held items can't be modified by actions either. Clones leave them as they are.
+		if err == nil && action != "clone" && !h.checkLegalHoldWrite(w, r, r.PathValue("packageName"), resourceTypeDefinition.ResourceType, name) {
+			return
+		}
+
+		switch action {
+		case "increment":
+			h.handleIncrementResource(w, r, name)
+		case "clone":
+			h.handleCloneResource(w, r, name)
+		case "publish":
+			h.handlePublishResource(w, r, name)
+		case "rename":
+			h.handleRenameResource(w, r, name)
+		case "appendUnique":
+			h.handleArrayValueResource(w, r, name, appendUnique)
+		case "removeValue":
+			h.handleArrayValueResource(w, r, name, removeValue)
+		case "redeliver":
+			h.handleRedeliverWebhook(w, r, name)
+		default:
+			respond.Done(w, r, problem.NotFound(fmt.Sprintf("unknown action %q", action), withReason(apierrors.ReasonNotFound)))
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	Activity        = apiv1.Activity
+	ActivityRequest = apiv1.ActivityRequest
+)
+
+// activityParentLabel labels activity entries with their parent.
+const activityParentLabel = "bass.activity/parent"
+
+func activityResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "Activity.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "Activity",
+		Plural:       "Activities",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"parent": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"packageName":  map[string]any{"type": "string"},
+								"resourceType": map[string]any{"type": "string"},
+								"name":         map[string]any{"type": "string"},
+							},
+							"required": []any{"packageName", "resourceType", "name"},
+						},
+						"kind": map[string]any{"type": "string", "minLength": 1},
+						"text": map[string]any{"type": "string"},
+						"data": map[string]any{"type": "object"},
+					},
+					"required": []any{"parent", "kind"},
+				},
+			},
+		},
+	}
+}
+
+// resourceLabelValue refers to an item in labels of items attached to it, as "{packageName}/{resourceType}/{name}".
+func resourceLabelValue(packageName, resourceType, name string) string {
+	return packageName + "/" + resourceType + "/" + name
+}
+
+// getActivityParent resolves the resource type and checks the existence of the parent item of an activity request.
+// It responds with the failure and returns nil if the parent is not found.
+func (h *Handler) getActivityParent(w http.ResponseWriter, r *http.Request) *Resource {
+	packageName := r.PathValue("packageName")
+	resourceTypePlural := r.PathValue("resourceTypePlural")
+	name := r.PathValue("name")
+
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return nil
+	}
+
+	parent, err := h.repoGet(r.Context(), packageName, resourceTypeDefinition.ResourceType, name)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource", "error", err)
+
+		var resourceNotFoundError ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &resourceNotFoundError):
+			respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return nil
+	}
+
+	return parent
+}
+
+// handleListActivity lists the activity entries of an item in creation order.
+func (h *Handler) handleListActivity() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parent := h.getActivityParent(w, r)
+		if parent == nil {
+			return
+		}
+
+		res, err := h.listActivity(r.Context(), parent.Metadata.PackageName, parent.Metadata.ResourceType, parent.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to list activity", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		respond.Done(w, r, res)
+	}
+}
+
+func (h *Handler) listActivity(ctx context.Context, packageName, resourceType, name string) (ResourceList, error) {
+	res, err := h.repoListByLabels(ctx, corePackageName, "v1", "Activity", map[string]string{
+		activityParentLabel: resourceLabelValue(packageName, resourceType, name),
+	})
+	if err != nil {
+		return res, err
+	}
+
+	slices.SortStableFunc(res.Items, func(a, b *Resource) int {
+		return a.Metadata.CreatedAt.Compare(b.Metadata.CreatedAt)
+	})
+
+	return res, nil
+}
+
+// handleCreateActivity adds an entry to the activity of an item.
+func (h *Handler) handleCreateActivity() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ActivityRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		parent := h.getActivityParent(w, r)
+		if parent == nil {
+			return
+		}
+
+		properties := map[string]any{
+			"parent": map[string]any{
+				"packageName":  parent.Metadata.PackageName,
+				"resourceType": parent.Metadata.ResourceType,
+				"name":         parent.Metadata.Name,
+			},
+			"kind": req.Kind,
+		}
+
+		if req.Text != "" {
+			properties["text"] = req.Text
+		}
+
+		if req.Data != nil {
+			properties["data"] = req.Data
+		}
+
+		item := Resource{
+			Metadata: Metadata{
+				UID:          uuid.NewString(),
+				PackageName:  corePackageName,
+				APIVersion:   "v1",
+				ResourceType: "Activity",
+				Name:         uuid.NewString(),
+				Labels: map[string]string{
+					activityParentLabel: resourceLabelValue(parent.Metadata.PackageName, parent.Metadata.ResourceType, parent.Metadata.Name),
+				},
+				ResourceVersion: "",
+				CreatedAt:       h.now(),
+				UpdatedAt:       h.now(),
+			},
+			Properties: properties,
+		}
+
+		if !h.validateResourceItem(w, r, activityResourceTypeDefinition(), &item) {
+			return
+		}
+
+		err = h.repo.Create(r.Context(), &item)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to create activity", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		h.resourceWritten(r.Context(), OperationCreate, &item)
+
+		w.WriteHeader(http.StatusCreated)
+		respond.Done(w, r, item)
+	}
+}
+
+// deleteActivity deletes the activity entries of a deleted item.
+func (h *Handler) deleteActivity(ctx context.Context, packageName, resourceType, name string) {
+	list, err := h.listActivity(ctx, packageName, resourceType, name)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list activity of deleted resource", "error", err)
+
+		return
+	}
+
+	for _, item := range list.Items {
+		err = h.repoDelete(ctx, corePackageName, "Activity", item.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to delete activity of deleted resource", "error", err)
+
+			continue
+		}
+
+		h.resourceWritten(ctx, OperationDelete, item)
+	}
+}
@@ -0,0 +1,16 @@
+package v1
+
+// Activity is a timeline entry of a resource, like a comment or a state change.
+type Activity struct {
+	Metadata Metadata          `json:"metadata"`
+	Parent   ResourceReference `json:"parent"`
+	Kind     string            `json:"kind"`
+	Text     string            `json:"text,omitempty"`
+	Data     map[string]any    `json:"data,omitempty"`
+}
+
+type ActivityRequest struct {
+	Kind string         `json:"kind"`
+	Text string         `json:"text,omitempty"`
+	Data map[string]any `json:"data,omitempty"`
+}
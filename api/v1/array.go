@@ -0,0 +1,7 @@
+package v1
+
+type ArrayValueRequest struct {
+	// Path is a JSON pointer to an array property, e.g. "/properties/tags".
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
@@ -0,0 +1,37 @@
+package v1
+
+type AutomationActionType string
+
+const (
+	AutomationActionCreateResource AutomationActionType = "createResource"
+	AutomationActionPatchResource  AutomationActionType = "patchResource"
+	AutomationActionWebhook        AutomationActionType = "webhook"
+	AutomationActionNotify         AutomationActionType = "notify"
+)
+
+// Automation runs actions on writes matching its trigger and filter. The filter is a Go text template executed with
+// the operation and the item as data, like the templates of the actions; the automation runs if it renders "true".
+type Automation struct {
+	Metadata Metadata           `json:"metadata"`
+	Trigger  *ResourceTrigger   `json:"trigger"`
+	Filter   string             `json:"filter,omitempty"`
+	Actions  []AutomationAction `json:"actions"`
+}
+
+// AutomationAction is a step of an automation. Its type selects the fields it uses: createResource and patchResource
+// write the item Name of the resource type, with Properties, or merge them into its properties; webhook posts the
+// operation and the item to URL; notify notifies Channel with Title and Text. Name, Title, Text, and the string values
+// of Properties are templates. A property value that is a single field reference, like "{{.item.total}}", keeps the
+// type of the field.
+type AutomationAction struct {
+	Type               AutomationActionType `json:"type"`
+	PackageName        string               `json:"packageName,omitempty"`
+	APIVersion         string               `json:"apiVersion,omitempty"`
+	ResourceTypePlural string               `json:"resourceTypePlural,omitempty"`
+	Name               string               `json:"name,omitempty"`
+	Properties         map[string]any       `json:"properties,omitempty"`
+	URL                string               `json:"url,omitempty"`
+	Channel            string               `json:"channel,omitempty"`
+	Title              string               `json:"title,omitempty"`
+	Text               string               `json:"text,omitempty"`
+}
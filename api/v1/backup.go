@@ -0,0 +1,40 @@
+package v1
+
+import "time"
+
+// BackupType tells whether a backup holds all resources or the changes since the previous backup.
+type BackupType string
+
+const (
+	BackupFull        BackupType = "full"
+	BackupIncremental BackupType = "incremental"
+)
+
+// Backup describes a backup written to the backup target.
+type Backup struct {
+	Name      string     `json:"name"`
+	Type      BackupType `json:"type"`
+	CreatedAt time.Time  `json:"createdAt"`
+	// Base is the name of the full backup an incremental backup builds on.
+	Base string `json:"base,omitempty"`
+	// Count is the number of resources in the backup.
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+	// Checksum is the SHA-256 of the backup, as "sha256:{hex}", to verify its integrity before restoring it.
+	Checksum string `json:"checksum"`
+}
+
+type BackupList struct {
+	Items []Backup `json:"items"`
+}
+
+// BackupData is the content of a backup. Incremental backups hold the resources written since the previous backup,
+// and the resources deleted since then as "{packageName}/{resourceType}/{name}".
+type BackupData struct {
+	Name      string      `json:"name"`
+	Type      BackupType  `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Base      string      `json:"base,omitempty"`
+	Items     []*Resource `json:"items"`
+	Deleted   []string    `json:"deleted,omitempty"`
+}
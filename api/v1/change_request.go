@@ -0,0 +1,37 @@
+package v1
+
+import "time"
+
+// ApprovalPolicy protects a resource type. Writes to its items are held as change requests until approved.
+type ApprovalPolicy struct {
+	// Approvals is the number of distinct approvers a change needs.
+	Approvals int `json:"approvals"`
+}
+
+type ChangeRequestStatus string
+
+const (
+	ChangeRequestStatusPending ChangeRequestStatus = "pending"
+	ChangeRequestStatusApplied ChangeRequestStatus = "applied"
+	ChangeRequestStatusFailed  ChangeRequestStatus = "failed"
+)
+
+// ChangeRequest is a write to a protected resource type waiting for approvals.
+type ChangeRequest struct {
+	Metadata          Metadata                `json:"metadata"`
+	Operation         TransactionOperation    `json:"operation"`
+	RequiredApprovals int                     `json:"requiredApprovals"`
+	Approvals         []ChangeRequestApproval `json:"approvals,omitempty"`
+	Status            ChangeRequestStatus     `json:"status"`
+	// Error tells why a failed change couldn't be applied.
+	Error string `json:"error,omitempty"`
+}
+
+type ChangeRequestApproval struct {
+	Approver string    `json:"approver"`
+	Time     time.Time `json:"time"`
+}
+
+type ApproveRequest struct {
+	Approver string `json:"approver"`
+}
@@ -0,0 +1,9 @@
+package v1
+
+import "encoding/json/jsontext"
+
+type CloneRequest struct {
+	NewName string `json:"newName"`
+	// Patch is an optional JSON merge patch applied to the copy, e.g. {"title": "Copy of template"}.
+	Patch jsontext.Value `json:"patch,omitempty"`
+}
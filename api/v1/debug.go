@@ -0,0 +1,25 @@
+package v1
+
+// DebugState is a snapshot of the runtime and the in-memory state of a server, for troubleshooting.
+type DebugState struct {
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heapAllocBytes"`
+	GCCycles       uint32 `json:"gcCycles"`
+	// SchemaDefinitions are the names of the schema definitions in the cache.
+	SchemaDefinitions []string    `json:"schemaDefinitions"`
+	Views             []DebugView `json:"views"`
+	WatchSubscribers  int         `json:"watchSubscribers"`
+	// Queues are the depths of the queues of background work by name.
+	Queues map[string]DebugQueue `json:"queues"`
+}
+
+// DebugView is a materialized view.
+type DebugView struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+type DebugQueue struct {
+	Length   int `json:"length"`
+	Capacity int `json:"capacity"`
+}
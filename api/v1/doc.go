@@ -0,0 +1,6 @@
+// Package v1 contains the version 1 wire types of the bass API.
+//
+// Types in this package are stable: fields are only ever added, never renamed or removed, and their JSON
+// representation is kept backward compatible. It has no dependency on the HTTP handler or the repositories, so
+// clients can import it on its own.
+package v1
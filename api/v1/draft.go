@@ -0,0 +1,18 @@
+package v1
+
+// Draft is the unpublished next state of an item of a resource type with drafts. The item may not exist yet.
+type Draft struct {
+	Metadata   Metadata          `json:"metadata"`
+	Parent     ResourceReference `json:"parent"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Properties map[string]any    `json:"properties,omitempty"`
+}
+
+// PublishedVersion is an immutable snapshot of an item as published from a draft. Versions count up from 1.
+type PublishedVersion struct {
+	Metadata   Metadata          `json:"metadata"`
+	Parent     ResourceReference `json:"parent"`
+	Version    int               `json:"version"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Properties map[string]any    `json:"properties,omitempty"`
+}
@@ -0,0 +1,20 @@
+package v1
+
+// EmailTemplate renders emails with Go templates. The subject and text are text templates, and the html is an HTML
+// template.
+type EmailTemplate struct {
+	Metadata Metadata `json:"metadata"`
+	Subject  string   `json:"subject"`
+	Text     string   `json:"text,omitempty"`
+	HTML     string   `json:"html,omitempty"`
+	// To holds templates of the recipient addresses.
+	To []string `json:"to,omitempty"`
+	// Trigger sends the email on writes of matching items, with the operation and the item as template data.
+	Trigger *ResourceTrigger `json:"trigger,omitempty"`
+}
+
+// EmailSendRequest sends an email from a template. Without To, the recipients of the template are used.
+type EmailSendRequest struct {
+	To   []string       `json:"to,omitempty"`
+	Data map[string]any `json:"data,omitempty"`
+}
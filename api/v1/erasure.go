@@ -0,0 +1,25 @@
+package v1
+
+// ErasureAction is how items linked to a data subject are erased.
+type ErasureAction string
+
+const (
+	// ErasureActionDelete deletes the items.
+	ErasureActionDelete ErasureAction = "delete"
+	// ErasureActionAnonymize removes the data subject properties of the items, and keeps the rest.
+	ErasureActionAnonymize ErasureAction = "anonymize"
+)
+
+// ErasureReport records an erasure of the data of a data subject.
+type ErasureReport struct {
+	Metadata Metadata `json:"metadata"`
+	// SubjectHash is the hex encoded SHA-256 hash of the subject, to tell erasures of a subject apart without keeping
+	// the subject.
+	SubjectHash string              `json:"subjectHash"`
+	Deleted     []ResourceReference `json:"deleted"`
+	Anonymized  []ResourceReference `json:"anonymized"`
+	// Held are the items under legal hold, which are kept as they are.
+	Held []ResourceReference `json:"held"`
+	// Error is why the erasure failed, if it did. The items reported were erased before it failed.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,11 @@
+package v1
+
+import "time"
+
+// LoggedEvent is a write of a resource at its position in the event log.
+type LoggedEvent struct {
+	Position int64         `json:"position"`
+	Type     OperationType `json:"type"`
+	At       time.Time     `json:"at"`
+	Item     *Resource     `json:"item"`
+}
@@ -0,0 +1,37 @@
+package v1
+
+type FeatureFlag struct {
+	Metadata Metadata `json:"metadata"`
+	// Enabled switches the flag off for everyone when false.
+	Enabled bool              `json:"enabled"`
+	Rules   []FeatureFlagRule `json:"rules,omitempty"`
+	// Percentage of the subjects not matching any rule the flag is on for, from 0 to 100.
+	Percentage int `json:"percentage,omitempty"`
+}
+
+// FeatureFlagRule turns a flag on for subjects whose attribute has one of the values.
+type FeatureFlagRule struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+}
+
+type FeatureFlagEvaluationRequest struct {
+	// Key identifies the subject, e.g. a user ID. Percentage rollouts are stable per key.
+	Key        string            `json:"key"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type FeatureFlagReason string
+
+const (
+	FeatureFlagReasonDisabled FeatureFlagReason = "disabled"
+	FeatureFlagReasonRule     FeatureFlagReason = "rule"
+	FeatureFlagReasonRollout  FeatureFlagReason = "rollout"
+	FeatureFlagReasonDefault  FeatureFlagReason = "default"
+)
+
+type FeatureFlagEvaluation struct {
+	Name    string            `json:"name"`
+	Enabled bool              `json:"enabled"`
+	Reason  FeatureFlagReason `json:"reason"`
+}
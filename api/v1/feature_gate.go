@@ -0,0 +1,8 @@
+package v1
+
+// FeatureGate reports the stage of an optional feature, and whether it is enabled.
+type FeatureGate struct {
+	Name    string `json:"name"`
+	Stage   string `json:"stage"`
+	Enabled bool   `json:"enabled"`
+}
@@ -0,0 +1,7 @@
+package v1
+
+type IncrementRequest struct {
+	// Path is a JSON pointer to a numeric property, e.g. "/properties/count". A missing property counts from zero.
+	Path  string  `json:"path"`
+	Delta float64 `json:"delta"`
+}
@@ -0,0 +1,23 @@
+package v1
+
+// LegalHold keeps a resource from being deleted or modified, e.g. while it is evidence in litigation, until it is
+// released.
+type LegalHold struct {
+	Metadata Metadata          `json:"metadata"`
+	Resource ResourceReference `json:"resource"`
+	Reason   string            `json:"reason"`
+	// PlacedBy identifies who placed the hold, e.g. a compliance officer.
+	PlacedBy string `json:"placedBy,omitempty"`
+}
+
+// LegalHoldRequest places a legal hold on a resource.
+type LegalHoldRequest struct {
+	Reason   string `json:"reason"`
+	PlacedBy string `json:"placedBy,omitempty"`
+}
+
+// LegalHoldInventory reports the legal holds in place.
+type LegalHoldInventory struct {
+	Count int         `json:"count"`
+	Holds []LegalHold `json:"holds"`
+}
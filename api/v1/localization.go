@@ -0,0 +1,17 @@
+package v1
+
+// LocalizationPolicy makes properties of a resource type translatable.
+type LocalizationPolicy struct {
+	// Properties names the properties locales may overlay.
+	Properties []string `json:"properties"`
+	// Fallbacks maps a locale to the locales tried after it, before its parent locales, like "de" for "de-AT".
+	Fallbacks map[string][]string `json:"fallbacks,omitempty"`
+}
+
+// Localization overlays properties of an item in a locale.
+type Localization struct {
+	Metadata   Metadata          `json:"metadata"`
+	Parent     ResourceReference `json:"parent"`
+	Locale     string            `json:"locale"`
+	Properties map[string]any    `json:"properties"`
+}
@@ -0,0 +1,9 @@
+package v1
+
+// Maintenance is the maintenance mode of the server. In read-only mode, writes are rejected while reads continue.
+type Maintenance struct {
+	ReadOnly bool `json:"readOnly"`
+	// RetryAfter is the number of seconds clients are advised to wait before retrying rejected writes.
+	RetryAfter int    `json:"retryAfter,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
@@ -0,0 +1,24 @@
+package v1
+
+import "time"
+
+type Metadata struct {
+	UID             string            `json:"uid"`
+	PackageName     string            `json:"packageName"`
+	APIVersion      string            `json:"apiVersion"`
+	ResourceType    string            `json:"resourceType"`
+	Name            string            `json:"name"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	UpdatedAt       time.Time         `json:"updatedAt"`
+}
+
+type ListMetadata struct {
+	PackageName  string `json:"packageName"`
+	APIVersion   string `json:"apiVersion"`
+	ResourceType string `json:"resourceType"`
+	// Continue is the token of the next page of a paginated list, given back in the continue query parameter. It is
+	// empty on the last page.
+	Continue string `json:"continue,omitempty"`
+}
@@ -0,0 +1,35 @@
+package v1
+
+// ModerationAction is what happens to a write whose content a moderation policy finds objectionable.
+type ModerationAction string
+
+const (
+	// ModerationActionReject rejects the write.
+	ModerationActionReject ModerationAction = "reject"
+	// ModerationActionQuarantine holds the write as a pending change request until a moderator approves it.
+	ModerationActionQuarantine ModerationAction = "quarantine"
+	// ModerationActionFlag applies the write, labeling the item as flagged for review.
+	ModerationActionFlag ModerationAction = "flag"
+)
+
+// ModerationPolicy moderates the content of properties of a resource type as its items are written.
+type ModerationPolicy struct {
+	// Properties names the properties to moderate. Their strings are checked, including nested ones.
+	Properties []string `json:"properties"`
+	// BannedWords are matched as whole words, ignoring case.
+	BannedWords []string `json:"bannedWords,omitempty"`
+	// Patterns are regular expressions the content must not match.
+	Patterns []string `json:"patterns,omitempty"`
+	// Classifiers name the classifiers of the server to check the content with, e.g. external moderation services.
+	Classifiers []string         `json:"classifiers,omitempty"`
+	Action      ModerationAction `json:"action"`
+}
+
+// ModerationFinding is a reason a moderation policy objects to content.
+type ModerationFinding struct {
+	Property string `json:"property"`
+	// Rule is what matched: "bannedWord", "pattern", or the name of a classifier.
+	Rule string `json:"rule"`
+	// Category is the banned word, the pattern, or the category given by the classifier.
+	Category string `json:"category"`
+}
@@ -0,0 +1,34 @@
+package v1
+
+// NotificationChannel is a destination of notifications. Its type selects the notifier delivering to it: "email" and
+// "sms" channels deliver to the recipients in To, "slack", "discord", and "webhook" channels post to URL.
+type NotificationChannel struct {
+	Metadata Metadata `json:"metadata"`
+	Type     string   `json:"type"`
+	To       []string `json:"to,omitempty"`
+	URL      string   `json:"url,omitempty"`
+}
+
+// NotificationRule routes notifications of writes matching its trigger to channels. The title and text are Go text
+// templates, executed with the operation and the item as data. ChannelTemplates override them for some of the
+// channels, e.g. to format the message for a chat.
+type NotificationRule struct {
+	Metadata         Metadata                        `json:"metadata"`
+	Trigger          *ResourceTrigger                `json:"trigger"`
+	Channels         []string                        `json:"channels"`
+	Title            string                          `json:"title"`
+	Text             string                          `json:"text,omitempty"`
+	ChannelTemplates map[string]NotificationTemplate `json:"channelTemplates,omitempty"`
+}
+
+// NotificationTemplate is the title and text templates of the notifications of a rule to a channel.
+type NotificationTemplate struct {
+	Title string `json:"title"`
+	Text  string `json:"text,omitempty"`
+}
+
+// NotifyRequest sends a notification to a channel.
+type NotifyRequest struct {
+	Title string `json:"title"`
+	Text  string `json:"text,omitempty"`
+}
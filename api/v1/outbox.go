@@ -0,0 +1,11 @@
+package v1
+
+import "time"
+
+// OutboxEvent is a write of a resource recorded in the outbox of the repository, in the same transaction as the write.
+type OutboxEvent struct {
+	ID   int64         `json:"id"`
+	Type OperationType `json:"type"`
+	At   time.Time     `json:"at"`
+	Item *Resource     `json:"item"`
+}
@@ -0,0 +1,5 @@
+package v1
+
+type Package struct {
+	Metadata Metadata `json:"metadata"`
+}
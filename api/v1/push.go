@@ -0,0 +1,35 @@
+package v1
+
+// DeviceToken registers a device of a subject, e.g. a user, to receive push notifications on a platform.
+type DeviceToken struct {
+	Metadata Metadata `json:"metadata"`
+	// Platform is "apns" or "fcm".
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+	Subject  string `json:"subject,omitempty"`
+}
+
+// PushTemplate renders push notifications with Go text templates, and sends them to the devices of a subject.
+type PushTemplate struct {
+	Metadata Metadata `json:"metadata"`
+	Title    string   `json:"title"`
+	Body     string   `json:"body,omitempty"`
+	// Subject is a template of the subject whose devices receive the notification.
+	Subject string `json:"subject,omitempty"`
+	// Data holds templates of custom key-value pairs delivered to the app.
+	Data map[string]string `json:"data,omitempty"`
+	// Trigger sends the notification on writes of matching items, with the operation and the item as template data.
+	Trigger *ResourceTrigger `json:"trigger,omitempty"`
+}
+
+// PushSendRequest sends a push notification from a template. Without Subject, the subject of the template is used.
+type PushSendRequest struct {
+	Subject string         `json:"subject,omitempty"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// PushSendResponse reports the number of devices a push notification was sent to, and failed to be sent to.
+type PushSendResponse struct {
+	Sent   int `json:"sent"`
+	Failed int `json:"failed"`
+}
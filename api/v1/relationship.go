@@ -0,0 +1,15 @@
+package v1
+
+type ResourceReference struct {
+	PackageName  string `json:"packageName"`
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name"`
+}
+
+// Relationship is a typed, directed edge from one resource to another, e.g. a user being "member-of" a team.
+type Relationship struct {
+	Metadata Metadata          `json:"metadata"`
+	Edge     string            `json:"edge"`
+	From     ResourceReference `json:"from"`
+	To       ResourceReference `json:"to"`
+}
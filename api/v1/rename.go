@@ -0,0 +1,10 @@
+package v1
+
+type RenameRequest struct {
+	NewName string `json:"newName,omitempty"`
+	// NewPackageName moves the resource to another package having the same resource type. Empty keeps the package.
+	NewPackageName string `json:"newPackageName,omitempty"`
+	// UpdateReferences points relationships referencing the resource to its new name. Otherwise, renaming a
+	// referenced resource is rejected.
+	UpdateReferences bool `json:"updateReferences,omitempty"`
+}
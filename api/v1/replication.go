@@ -0,0 +1,21 @@
+package v1
+
+import "time"
+
+// ConflictPolicy decides how replicas handle replicated writes of items written later locally.
+type ConflictPolicy string
+
+const (
+	// ConflictLastWriterWins keeps the write made last, ignoring stale replicated writes.
+	ConflictLastWriterWins ConflictPolicy = "lastWriterWins"
+	// ConflictReject rejects stale replicated writes, and replicated creates of existing items, as conflicts.
+	ConflictReject ConflictPolicy = "reject"
+)
+
+// ReplicationEvent is a write forwarded to a replica.
+type ReplicationEvent struct {
+	Type           OperationType  `json:"type"`
+	At             time.Time      `json:"at"`
+	Item           *Resource      `json:"item"`
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy"`
+}
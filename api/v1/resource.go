@@ -0,0 +1,398 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+type Resource struct { //nolint:recvcheck // MarshalJSONTo takes values too, which handlers respond with
+	Metadata   Metadata       `json:"metadata"`
+	Properties map[string]any `json:",inline"`
+	// raw holds the properties as decoded. Properties hold their values, and raw only keeps the order of their keys,
+	// and the spelling of the values still equal to them, like the digits of big numbers, for encoding.
+	raw jsontext.Value
+}
+
+// resource has the fields of Resource without its methods, for the default encoding.
+type resource Resource
+
+// UnmarshalJSONFrom decodes the resource and keeps the raw properties.
+func (r *Resource) UnmarshalJSONFrom(dec *jsontext.Decoder) error {
+	value, err := dec.ReadValue()
+	if err != nil {
+		return fmt.Errorf("failed to read resource: %w", err)
+	}
+
+	var res resource
+
+	err = json.Unmarshal(value, &res)
+	if err != nil {
+		return fmt.Errorf("failed to decode resource: %w", err)
+	}
+
+	raw, err := rawProperties(value)
+	if err != nil {
+		return err
+	}
+
+	*r = Resource(res)
+	r.raw = raw
+
+	return nil
+}
+
+// MarshalJSONTo encodes the resource from its properties. Keys decoded with the resource keep their order, and values
+// still equal to the decoded ones keep their spelling. Other keys follow, sorted.
+func (r Resource) MarshalJSONTo(enc *jsontext.Encoder) error {
+	if r.raw == nil {
+		err := json.MarshalEncode(enc, resource(r))
+		if err != nil {
+			return fmt.Errorf("failed to encode resource: %w", err)
+		}
+
+		return nil
+	}
+
+	err := enc.WriteToken(jsontext.BeginObject)
+	if err != nil {
+		return fmt.Errorf("failed to encode resource: %w", err)
+	}
+
+	err = enc.WriteToken(jsontext.String("metadata"))
+	if err != nil {
+		return fmt.Errorf("failed to encode resource: %w", err)
+	}
+
+	err = json.MarshalEncode(enc, r.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode resource metadata: %w", err)
+	}
+
+	err = encodeMembers(enc, r.Properties, r.raw)
+	if err != nil {
+		return err
+	}
+
+	err = enc.WriteToken(jsontext.EndObject)
+	if err != nil {
+		return fmt.Errorf("failed to encode resource: %w", err)
+	}
+
+	return nil
+}
+
+// encodeMembers encodes the members of object, in the order of the keys of the JSON object raw first.
+func encodeMembers(enc *jsontext.Encoder, object map[string]any, raw jsontext.Value) error {
+	rawMembers := decodeMembers(raw)
+
+	keys := make([]string, 0, len(object))
+
+	for _, member := range rawMembers {
+		if _, ok := object[member.name]; ok && !slices.Contains(keys, member.name) {
+			keys = append(keys, member.name)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if key == "metadata" {
+			continue
+		}
+
+		err := enc.WriteToken(jsontext.String(key))
+		if err != nil {
+			return fmt.Errorf("failed to encode property %q: %w", key, err)
+		}
+
+		var rawValue jsontext.Value
+
+		for _, member := range rawMembers {
+			if member.name == key {
+				rawValue = member.value
+
+				break
+			}
+		}
+
+		err = encodeValue(enc, object[key], rawValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeValue encodes value, as the JSON value raw if it is equal to it, or with the order of keys of raw.
+func encodeValue(enc *jsontext.Encoder, value any, raw jsontext.Value) error {
+	switch value := value.(type) {
+	case map[string]any:
+		return encodeObject(enc, value, raw)
+	case []any:
+		return encodeArray(enc, value, raw)
+	case float64:
+		if raw.Kind() == '0' {
+			number, err := strconv.ParseFloat(string(raw), 64)
+			if err == nil && number == value {
+				return writeRaw(enc, raw)
+			}
+		}
+	case string:
+		if raw.Kind() == '"' {
+			unquoted, err := jsontext.AppendUnquote(nil, raw)
+			if err == nil && string(unquoted) == value {
+				return writeRaw(enc, raw)
+			}
+		}
+	}
+
+	err := json.MarshalEncode(enc, value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	return nil
+}
+
+func encodeObject(enc *jsontext.Encoder, object map[string]any, raw jsontext.Value) error {
+	err := enc.WriteToken(jsontext.BeginObject)
+	if err != nil {
+		return fmt.Errorf("failed to encode object: %w", err)
+	}
+
+	err = encodeMembers(enc, object, raw)
+	if err != nil {
+		return err
+	}
+
+	err = enc.WriteToken(jsontext.EndObject)
+	if err != nil {
+		return fmt.Errorf("failed to encode object: %w", err)
+	}
+
+	return nil
+}
+
+func encodeArray(enc *jsontext.Encoder, array []any, raw jsontext.Value) error {
+	rawElements := decodeElements(raw)
+
+	err := enc.WriteToken(jsontext.BeginArray)
+	if err != nil {
+		return fmt.Errorf("failed to encode array: %w", err)
+	}
+
+	for i, element := range array {
+		var rawElement jsontext.Value
+		if i < len(rawElements) {
+			rawElement = rawElements[i]
+		}
+
+		err = encodeValue(enc, element, rawElement)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = enc.WriteToken(jsontext.EndArray)
+	if err != nil {
+		return fmt.Errorf("failed to encode array: %w", err)
+	}
+
+	return nil
+}
+
+func writeRaw(enc *jsontext.Encoder, raw jsontext.Value) error {
+	err := enc.WriteValue(raw)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	return nil
+}
+
+type rawMember struct {
+	name  string
+	value jsontext.Value
+}
+
+// decodeMembers returns the members of the JSON object raw in order, or nil if raw isn't an object.
+func decodeMembers(raw jsontext.Value) []rawMember {
+	if raw.Kind() != '{' {
+		return nil
+	}
+
+	dec := jsontext.NewDecoder(bytes.NewReader(raw))
+
+	_, err := dec.ReadToken()
+	if err != nil {
+		return nil
+	}
+
+	var res []rawMember
+
+	for dec.PeekKind() == '"' {
+		token, err := dec.ReadToken()
+		if err != nil {
+			return res
+		}
+
+		name := token.String()
+
+		value, err := dec.ReadValue()
+		if err != nil {
+			return res
+		}
+
+		res = append(res, rawMember{name: name, value: value.Clone()})
+	}
+
+	return res
+}
+
+// decodeElements returns the elements of the JSON array raw in order, or nil if raw isn't an array.
+func decodeElements(raw jsontext.Value) []jsontext.Value {
+	if raw.Kind() != '[' {
+		return nil
+	}
+
+	dec := jsontext.NewDecoder(bytes.NewReader(raw))
+
+	_, err := dec.ReadToken()
+	if err != nil {
+		return nil
+	}
+
+	var res []jsontext.Value
+
+	for dec.PeekKind() != ']' && dec.PeekKind() != 0 {
+		value, err := dec.ReadValue()
+		if err != nil {
+			return res
+		}
+
+		res = append(res, value.Clone())
+	}
+
+	return res
+}
+
+// rawProperties returns the members of a resource object except its metadata, as an object in their original order.
+func rawProperties(value jsontext.Value) (jsontext.Value, error) {
+	dec := jsontext.NewDecoder(bytes.NewReader(value))
+
+	var buf bytes.Buffer
+
+	enc := jsontext.NewEncoder(&buf)
+
+	_, err := dec.ReadToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource: %w", err)
+	}
+
+	err = enc.WriteToken(jsontext.BeginObject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write properties: %w", err)
+	}
+
+	for dec.PeekKind() != jsontext.EndObject.Kind() {
+		token, err := dec.ReadToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read resource: %w", err)
+		}
+
+		name := token.String()
+
+		member, err := dec.ReadValue()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read resource: %w", err)
+		}
+
+		if name == "metadata" {
+			continue
+		}
+
+		err = enc.WriteToken(jsontext.String(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to write properties: %w", err)
+		}
+
+		err = enc.WriteValue(member)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write properties: %w", err)
+		}
+	}
+
+	err = enc.WriteToken(jsontext.EndObject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write properties: %w", err)
+	}
+
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// DeepCopy returns a copy of the resource that shares no mutable state with the original.
+func (r *Resource) DeepCopy() *Resource {
+	if r == nil {
+		return nil
+	}
+
+	res := &Resource{
+		Metadata:   r.Metadata,
+		Properties: nil,
+		raw:        r.raw.Clone(),
+	}
+
+	if r.Metadata.Labels != nil {
+		res.Metadata.Labels = maps.Clone(r.Metadata.Labels)
+	}
+
+	if r.Properties != nil {
+		res.Properties = deepCopyValue(r.Properties).(map[string]any)
+	}
+
+	return res
+}
+
+// SetRaw sets the properties of the resource as decoded to the JSON object raw, whose key order and spelling of values
+// encoding keeps where they still match Properties.
+func (r *Resource) SetRaw(raw jsontext.Value) {
+	r.raw = raw
+}
+
+func deepCopyValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(v))
+
+		for key, value := range v {
+			res[key] = deepCopyValue(value)
+		}
+
+		return res
+	case []any:
+		res := make([]any, len(v))
+
+		for i, value := range v {
+			res[i] = deepCopyValue(value)
+		}
+
+		return res
+	default:
+		return v
+	}
+}
+
+type ResourceList struct {
+	Metadata ListMetadata `json:"metadata"`
+	Items    []*Resource  `json:"items"`
+}
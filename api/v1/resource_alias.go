@@ -0,0 +1,12 @@
+package v1
+
+import "time"
+
+// ResourceAlias keeps the old name of a renamed resource, redirecting requests for it to the new name until it
+// expires.
+type ResourceAlias struct {
+	Metadata  Metadata          `json:"metadata"`
+	From      ResourceReference `json:"from"`
+	To        ResourceReference `json:"to"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
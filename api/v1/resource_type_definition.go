@@ -0,0 +1,41 @@
+package v1
+
+type ResourceTypeDefinition struct {
+	Metadata     Metadata                        `json:"metadata"`
+	Package      string                          `json:"package"`
+	Versions     []ResourceTypeDefinitionVersion `json:"versions"`
+	ResourceType string                          `json:"resourceType"`
+	Plural       string                          `json:"plural"`
+	Singular     string                          `json:"singular,omitempty"`
+	ShortNames   []string                        `json:"shortNames,omitempty"`
+	Categories   []string                        `json:"categories,omitempty"`
+	TimeSeries   *TimeSeries                     `json:"timeSeries,omitempty"`
+	StateMachine *StateMachine                   `json:"stateMachine,omitempty"`
+	Approval     *ApprovalPolicy                 `json:"approval,omitempty"`
+	Drafts       bool                            `json:"drafts,omitempty"`
+	Localization *LocalizationPolicy             `json:"localization,omitempty"`
+	Moderation   *ModerationPolicy               `json:"moderation,omitempty"`
+	// Erasure is how items are erased for their data subjects, marked by "x-data-subject": true in the schema.
+	// Empty deletes them.
+	Erasure ErasureAction `json:"erasure,omitempty"`
+}
+
+// TimeSeries marks a resource type as time series. Its items are immutable, ordered by a time property, and expire
+// after the retention.
+type TimeSeries struct {
+	// TimeProperty names the RFC 3339 timestamp property of the items.
+	TimeProperty string `json:"timeProperty"`
+	// Retention is a duration, like "720h", after which items are no longer served. Empty keeps items forever.
+	Retention string `json:"retention,omitempty"`
+}
+
+type ResourceTypeDefinitionVersion struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	// Deprecated marks the version as deprecated. Requests using it get Deprecation and Warning headers, with the
+	// deprecation warning if given.
+	Deprecated         bool   `json:"deprecated,omitempty"`
+	DeprecationWarning string `json:"deprecationWarning,omitempty"`
+	// Sunset is the RFC 3339 time the version is planned to be removed at, sent in the Sunset header.
+	Sunset string `json:"sunset,omitempty"`
+}
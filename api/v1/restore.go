@@ -0,0 +1,18 @@
+package v1
+
+import "time"
+
+// RestoreResult reports the writes a point in time restore of a package made.
+type RestoreResult struct {
+	PackageName string    `json:"packageName"`
+	Target      string    `json:"target"`
+	At          time.Time `json:"at"`
+	Created     int       `json:"created"`
+	Updated     int       `json:"updated"`
+	Deleted     int       `json:"deleted"`
+	// Unrecoverable lists the items, as "{resourceType}/{name}", whose state at the time is unknown because they were
+	// last written before the revision history started. They are left as they are.
+	Unrecoverable []string `json:"unrecoverable"`
+	// Held lists the items, as "{resourceType}/{name}", under legal hold, which restores in place leave as they are.
+	Held []string `json:"held,omitempty"`
+}
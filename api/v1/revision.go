@@ -0,0 +1,23 @@
+package v1
+
+import "time"
+
+// RevisionDiff is the change of an item between two of its revisions, as a JSON Patch from the encoding of the item
+// at From to its encoding at To.
+type RevisionDiff struct {
+	From  RevisionInfo         `json:"from"`
+	To    RevisionInfo         `json:"to"`
+	Patch []JSONPatchOperation `json:"patch"`
+}
+
+// RevisionInfo tells how a revision of an item was written, when, by whom, and through what.
+type RevisionInfo struct {
+	ResourceVersion string        `json:"resourceVersion"`
+	Type            OperationType `json:"type"`
+	At              time.Time     `json:"at"`
+	// By is the name of the API key of the write, or empty if it had none.
+	By string `json:"by,omitempty"`
+	// Origin is the request path of the write, or the handler actor making it: automation, sync, restore,
+	// replication, or erasure.
+	Origin string `json:"origin,omitempty"`
+}
@@ -0,0 +1,8 @@
+package v1
+
+// SchemaDefinition is a JSON schema shared by resource type schemas, e.g. of an address or an amount of money.
+// Schemas refer to it with {"$ref": "schemadefinitions/{name}"}.
+type SchemaDefinition struct {
+	Metadata Metadata       `json:"metadata"`
+	Schema   map[string]any `json:"schema"`
+}
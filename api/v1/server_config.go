@@ -0,0 +1,10 @@
+package v1
+
+// ServerConfig holds server settings applied at runtime, without restarting the process.
+type ServerConfig struct {
+	Metadata Metadata `json:"metadata"`
+	// LogLevel is "debug", "info", "warn", or "error".
+	LogLevel string `json:"logLevel,omitempty"`
+	// Features enables or disables optional features by name, overriding the features the server started with.
+	Features map[string]bool `json:"features,omitempty"`
+}
@@ -0,0 +1,18 @@
+package v1
+
+type Setting struct {
+	Metadata Metadata `json:"metadata"`
+	// Schema is the JSON schema the value and its environment overlays must match. No schema accepts any value.
+	Schema map[string]any `json:"schema,omitempty"`
+	Value  any            `json:"value"`
+	// Environments overlays the value per environment name.
+	Environments map[string]any `json:"environments,omitempty"`
+}
+
+// SettingValue is the effective value of a setting in an environment.
+type SettingValue struct {
+	Name            string `json:"name"`
+	Environment     string `json:"environment,omitempty"`
+	Value           any    `json:"value"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
@@ -0,0 +1,9 @@
+package v1
+
+// StateMachine restricts how the state property of the items of a resource type changes on update.
+type StateMachine struct {
+	// Property names the string property holding the state.
+	Property string `json:"property"`
+	// Transitions maps each state to the states it may change to. Changes not listed are rejected.
+	Transitions map[string][]string `json:"transitions"`
+}
@@ -0,0 +1,46 @@
+package v1
+
+// SyncRequest exchanges the changes a client made offline to the items of a package for the changes of the server
+// since the last sync of the client.
+type SyncRequest struct {
+	PackageName string `json:"packageName"`
+	// SyncToken is the token of the last sync response, or empty for the first sync.
+	SyncToken string       `json:"syncToken,omitempty"`
+	Changes   []SyncChange `json:"changes"`
+}
+
+// SyncChange is a change a client made offline. Updates and deletes of items changed on the server since their base
+// resource version are conflicts; without a base resource version, they overwrite the item.
+type SyncChange struct {
+	Op                  OperationType     `json:"op"`
+	APIVersion          string            `json:"apiVersion"`
+	ResourceTypePlural  string            `json:"resourceTypePlural"`
+	Name                string            `json:"name"`
+	Labels              map[string]string `json:"labels,omitempty"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+	BaseResourceVersion string            `json:"baseResourceVersion,omitempty"`
+}
+
+type SyncResponse struct {
+	// SyncToken is the token for the next sync.
+	SyncToken string       `json:"syncToken"`
+	Results   []SyncResult `json:"results"`
+	// Changes are the writes of the server since the sync token, except the ones of the request, oldest first. The
+	// first sync gets all items as creates.
+	Changes []SyncServerChange `json:"changes"`
+}
+
+// SyncResult is the outcome of a change of the client. Conflicting changes aren't applied; Current is the item as it
+// is on the server, if it exists, for the client to resolve the conflict and sync again.
+type SyncResult struct {
+	Status   int       `json:"status"`
+	Item     *Resource `json:"item,omitempty"`
+	Conflict bool      `json:"conflict,omitempty"`
+	Current  *Resource `json:"current,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type SyncServerChange struct {
+	Type OperationType `json:"type"`
+	Item *Resource     `json:"item"`
+}
@@ -0,0 +1,20 @@
+package v1
+
+import "time"
+
+// TimeSeriesBucket aggregates the numeric property of the items of a time series in an interval.
+type TimeSeriesBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	Sum   float64   `json:"sum"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+}
+
+type TimeSeriesBucketList struct {
+	Metadata ListMetadata       `json:"metadata"`
+	Property string             `json:"property"`
+	Interval string             `json:"interval"`
+	Buckets  []TimeSeriesBucket `json:"buckets"`
+}
@@ -0,0 +1,41 @@
+package v1
+
+type OperationType string
+
+const (
+	OperationCreate OperationType = "create"
+	OperationUpdate OperationType = "update"
+	OperationDelete OperationType = "delete"
+)
+
+// Precondition must hold for the current state of a resource for an operation to be applied. Empty fields are not
+// checked.
+type Precondition struct {
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type TransactionRequest struct {
+	Operations []TransactionOperation `json:"operations"`
+}
+
+type TransactionOperation struct {
+	Op                 OperationType     `json:"op"`
+	PackageName        string            `json:"packageName"`
+	APIVersion         string            `json:"apiVersion"`
+	ResourceTypePlural string            `json:"resourceTypePlural"`
+	Name               string            `json:"name"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Properties         map[string]any    `json:"properties,omitempty"`
+	Precondition       Precondition      `json:"precondition,omitzero"`
+}
+
+type TransactionResponse struct {
+	Results []TransactionResult `json:"results"`
+}
+
+type TransactionResult struct {
+	Status int       `json:"status"`
+	Item   *Resource `json:"item,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
@@ -0,0 +1,8 @@
+package v1
+
+// ResourceTrigger matches writes of items of a resource type. Without operations, it matches all of them.
+type ResourceTrigger struct {
+	PackageName  string          `json:"packageName"`
+	ResourceType string          `json:"resourceType"`
+	Operations   []OperationType `json:"operations,omitempty"`
+}
@@ -0,0 +1,22 @@
+package v1
+
+// Usage reports the storage used per package and resource type.
+type Usage struct {
+	Count    int            `json:"count"`
+	Bytes    int64          `json:"bytes"`
+	Packages []PackageUsage `json:"packages"`
+}
+
+type PackageUsage struct {
+	PackageName   string              `json:"packageName"`
+	Count         int                 `json:"count"`
+	Bytes         int64               `json:"bytes"`
+	ResourceTypes []ResourceTypeUsage `json:"resourceTypes"`
+}
+
+// ResourceTypeUsage is the number of stored resources of a resource type and the approximate bytes they take.
+type ResourceTypeUsage struct {
+	ResourceType string `json:"resourceType"`
+	Count        int    `json:"count"`
+	Bytes        int64  `json:"bytes"`
+}
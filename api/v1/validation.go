@@ -0,0 +1,12 @@
+package v1
+
+// ValidationError is a violation of the schema of a resource type by a resource item.
+type ValidationError struct {
+	// Pointer is the RFC 6901 JSON pointer to the offending field of the item, e.g. "/properties/address/city".
+	Pointer string `json:"pointer"`
+	// Keyword is the violated JSON schema keyword, e.g. "required" or "maxLength".
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+	// Params holds the expected constraints of the keyword, e.g. {"max": 10} for "maxLength".
+	Params map[string]any `json:"params,omitempty"`
+}
@@ -0,0 +1,47 @@
+package v1
+
+type ViewAggregateFunction string
+
+const (
+	ViewAggregateCount ViewAggregateFunction = "count"
+	ViewAggregateSum   ViewAggregateFunction = "sum"
+	ViewAggregateAvg   ViewAggregateFunction = "avg"
+	ViewAggregateMin   ViewAggregateFunction = "min"
+	ViewAggregateMax   ViewAggregateFunction = "max"
+)
+
+// View is a projection or an aggregation of the items of resource types of a package. A projection has a row per
+// item with its name, resource type, and Fields, property paths by the name of the column. An aggregation has a row
+// per value of the GroupBy property path, or a single row without it, with its Aggregates by the name of the column.
+type View struct {
+	Metadata      Metadata                 `json:"metadata"`
+	Package       string                   `json:"package"`
+	ResourceTypes []string                 `json:"resourceTypes"`
+	Fields        map[string]string        `json:"fields,omitempty"`
+	GroupBy       string                   `json:"groupBy,omitempty"`
+	Aggregates    map[string]ViewAggregate `json:"aggregates,omitempty"`
+	Privacy       *ViewPrivacy             `json:"privacy,omitempty"`
+}
+
+// ViewPrivacy protects the items of an aggregation, so its rows can be exposed to end users without revealing
+// individual items. Groups of fewer than MinGroupSize items are left out. With Epsilon, aggregates get Laplace noise
+// making them differentially private, spending Epsilon per aggregate; smaller values add more noise. The noise only
+// changes with the items of a group, so reading a view again doesn't average it out.
+type ViewPrivacy struct {
+	MinGroupSize int     `json:"minGroupSize,omitempty"`
+	Epsilon      float64 `json:"epsilon,omitempty"`
+}
+
+// ViewAggregate aggregates the numeric values of the property at Field in a group of items. Count counts the items
+// and needs no field. Bound clamps values to [-Bound, Bound] in views with noise, which sums and averages need to scale
+// their noise.
+type ViewAggregate struct {
+	Function ViewAggregateFunction `json:"function"`
+	Field    string                `json:"field,omitempty"`
+	Bound    float64               `json:"bound,omitempty"`
+}
+
+type ViewResult struct {
+	Name string           `json:"name"`
+	Rows []map[string]any `json:"rows"`
+}
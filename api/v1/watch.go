@@ -0,0 +1,25 @@
+package v1
+
+import "encoding/json/jsontext"
+
+// WatchEvent is a write of a resource streamed to watchers. Delta watches get updates as a JSON Patch from the item as
+// last streamed, and deletes by package, resource type, and name, instead of the item. Position is the position of the
+// write in the event log, if the handler keeps one, to resume the watch from; it is zero for the items a watch starts
+// with.
+type WatchEvent struct {
+	Type            OperationType        `json:"type"`
+	Position        int64                `json:"position,omitempty"`
+	Item            *Resource            `json:"item,omitempty"`
+	PackageName     string               `json:"packageName,omitempty"`
+	ResourceType    string               `json:"resourceType,omitempty"`
+	Name            string               `json:"name,omitempty"`
+	ResourceVersion string               `json:"resourceVersion,omitempty"`
+	Patch           []JSONPatchOperation `json:"patch,omitempty"`
+}
+
+// JSONPatchOperation is an operation of a JSON Patch (RFC 6902).
+type JSONPatchOperation struct {
+	Op    string         `json:"op"`
+	Path  string         `json:"path"`
+	Value jsontext.Value `json:"value,omitzero"`
+}
@@ -0,0 +1,23 @@
+package v1
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an attempt to deliver a notification to a webhook channel. StatusCode is the status the receiving
+// server responded with, if it responded.
+type WebhookDelivery struct {
+	Metadata   Metadata              `json:"metadata"`
+	Channel    string                `json:"channel"`
+	URL        string                `json:"url"`
+	Payload    map[string]any        `json:"payload"`
+	Status     WebhookDeliveryStatus `json:"status"`
+	StatusCode int                   `json:"statusCode,omitempty"`
+	LatencyMS  int64                 `json:"latencyMs"`
+	Error      string                `json:"error,omitempty"`
+	// RedeliveryOf is the name of the delivery this one redelivers.
+	RedeliveryOf string `json:"redeliveryOf,omitempty"`
+}
@@ -0,0 +1,424 @@
+package bass
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+// APIKeyHeader carries the API key of a request. Clients that can't set headers, like EventSource for watches, may
+// send it as the "apiKey" query parameter instead.
+const APIKeyHeader = "X-Bass-Api-Key" //nolint:gosec // header name, not a credential
+
+// DefaultPublishableRateLimit is the number of requests per minute allowed to publishable keys without RateLimit.
+const DefaultPublishableRateLimit = 600
+
+type APIKeyType string
+
+const (
+	// APIKeyPublishable keys are meant to be embedded in browser and mobile apps. Without permissions, they can only
+	// read their package, and they are always rate limited.
+	APIKeyPublishable APIKeyType = "publishable"
+	// APIKeySecret keys are meant for servers. Without permissions, they can do anything in their package.
+	APIKeySecret APIKeyType = "secret"
+)
+
+// Verbs of API key permissions.
+const (
+	APIKeyVerbGet    = "get"
+	APIKeyVerbList   = "list"
+	APIKeyVerbWatch  = "watch"
+	APIKeyVerbCreate = "create"
+	APIKeyVerbUpdate = "update"
+	APIKeyVerbDelete = "delete"
+	// APIKeyVerbLegalHold allows placing and releasing legal holds, e.g. for compliance officers. Keys only have it
+	// when their permissions grant it.
+	APIKeyVerbLegalHold = "legalhold"
+)
+
+// APIKey grants access to the resources of a package.
+type APIKey struct {
+	// Name identifies the key in logs, e.g. the app using it.
+	Name string
+	// Key is the secret value clients send.
+	Key         string
+	Type        APIKeyType
+	PackageName string
+	// Permissions restrict the key to some verbs of some resource types. Empty uses the defaults of the type.
+	Permissions []APIKeyPermission
+	// RateLimit is the number of requests per minute allowed to the key. Zero defaults to
+	// DefaultPublishableRateLimit for publishable keys, and no limit for secret keys.
+	RateLimit int
+}
+
+// APIKeyPermission allows verbs, e.g. APIKeyVerbGet, on resource types, by plural. Empty resource types allow the
+// verbs on all resource types of the package, including package wide watches.
+type APIKeyPermission struct {
+	ResourceTypes []string
+	Verbs         []string
+}
+
+type APIKeyError struct {
+	Reason string
+}
+
+func (err APIKeyError) Error() string {
+	return "API key is invalid: " + err.Reason
+}
+
+type APIKeyPermissionError struct {
+	Name               string
+	Verb               string
+	PackageName        string
+	ResourceTypePlural string
+}
+
+func (err APIKeyPermissionError) Error() string {
+	if err.ResourceTypePlural == "" {
+		return fmt.Sprintf("API key %q is not allowed to %s package %q", err.Name, err.Verb, err.PackageName)
+	}
+
+	return fmt.Sprintf("API key %q is not allowed to %s resource type %q of package %q", err.Name, err.Verb, err.ResourceTypePlural, err.PackageName)
+}
+
+type apiKeyContextKey struct{}
+
+// APIKeyNameFromContext returns the name of the API key of a request, e.g. to resolve the meter subject, or an empty
+// string if the request has no API key.
+func APIKeyNameFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(apiKeyContextKey{}).(APIKey)
+
+	return key.Name
+}
+
+// permissions returns the permissions of the key, or the defaults of its type.
+func (key APIKey) permissions() []APIKeyPermission {
+	if len(key.Permissions) > 0 {
+		return key.Permissions
+	}
+
+	if key.Type == APIKeySecret {
+		return []APIKeyPermission{{ResourceTypes: nil, Verbs: []string{APIKeyVerbGet, APIKeyVerbList, APIKeyVerbWatch, APIKeyVerbCreate, APIKeyVerbUpdate, APIKeyVerbDelete}}}
+	}
+
+	return []APIKeyPermission{{ResourceTypes: nil, Verbs: []string{APIKeyVerbGet, APIKeyVerbList, APIKeyVerbWatch}}}
+}
+
+func (key APIKey) rateLimit() int {
+	if key.RateLimit == 0 && key.Type == APIKeyPublishable {
+		return DefaultPublishableRateLimit
+	}
+
+	return key.RateLimit
+}
+
+// apiKeyLimiter counts the requests of API keys in fixed windows of a minute.
+type apiKeyLimiter struct {
+	mu      sync.Mutex
+	windows map[string]apiKeyWindow
+}
+
+type apiKeyWindow struct {
+	start time.Time
+	count int
+}
+
+func newAPIKeyLimiter() *apiKeyLimiter {
+	return &apiKeyLimiter{
+		mu:      sync.Mutex{},
+		windows: make(map[string]apiKeyWindow),
+	}
+}
+
+// allow counts a request of the key at now, and reports whether it is within the limit, or else how long until the
+// next window.
+func (limiter *apiKeyLimiter) allow(name string, limit int, now time.Time) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	window := limiter.windows[name]
+	if now.Sub(window.start) >= time.Minute {
+		window = apiKeyWindow{start: now.Truncate(time.Minute), count: 0}
+	}
+
+	if window.count >= limit {
+		return false, window.start.Add(time.Minute).Sub(now)
+	}
+
+	window.count++
+	limiter.windows[name] = window
+
+	return true, 0
+}
+
+// apiKey returns the configured key matching the key of a request.
+func (h *Handler) apiKey(r *http.Request) (APIKey, error) {
+	value := r.Header.Get(APIKeyHeader)
+	if value == "" {
+		value = r.URL.Query().Get("apiKey")
+	}
+
+	if value == "" {
+		return APIKey{}, APIKeyError{Reason: "missing " + APIKeyHeader + " header"}
+	}
+
+	for _, key := range h.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(value)) == 1 {
+			return key, nil
+		}
+	}
+
+	return APIKey{}, APIKeyError{Reason: "unknown key"}
+}
+
+// apiKeyVerb returns the verb of a request to the API.
+func apiKeyVerb(r *http.Request) string {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case strings.HasSuffix(r.Pattern, "/watch"):
+			return APIKeyVerbWatch
+		case name == "":
+			return APIKeyVerbList
+		default:
+			return APIKeyVerbGet
+		}
+	case http.MethodPost:
+		if name == "" {
+			return APIKeyVerbCreate
+		}
+
+		// Actions and sub-resources of items change them.
+		return APIKeyVerbUpdate
+	case http.MethodDelete:
+		if strings.HasSuffix(r.Pattern, "/{name}") {
+			return APIKeyVerbDelete
+		}
+
+		return APIKeyVerbUpdate
+	default:
+		return APIKeyVerbUpdate
+	}
+}
+
+// checkAPIKeyPermission checks that key allows the request. Requests not scoped to a single package, like
+// transactions and watches of all packages, aren't allowed to API keys. Syncs name their package in their body, so
+// they are checked by the sync handler.
+func (h *Handler) checkAPIKeyPermission(r *http.Request, key APIKey) error {
+	if r.Pattern == http.MethodPost+" "+h.basePath+"/sync" {
+		return nil
+	}
+
+	packageName := r.PathValue("packageName")
+	resourceTypePlural := r.PathValue("resourceTypePlural")
+
+	if packageName == "" {
+		// Routes of core resource types name them literally, as "/api/core/v1/{plural}/...".
+		segments := strings.Split(strings.TrimPrefix(r.URL.Path, h.basePath+"/api/"), "/")
+		if len(segments) > 2 && segments[0] == corePackageName {
+			packageName, resourceTypePlural = segments[0], segments[2]
+		}
+	}
+
+	return h.checkAPIKeyAccess(r.Context(), key, apiKeyVerb(r), packageName, resourceTypePlural)
+}
+
+// checkAPIKeyAccess checks that key allows verb on a resource type of a package, by its plural, singular, or short
+// name. An empty resource type stands for all resource types of the package.
+func (h *Handler) checkAPIKeyAccess(ctx context.Context, key APIKey, verb, packageName, resourceTypePlural string) error {
+	denied := APIKeyPermissionError{Name: key.Name, Verb: verb, PackageName: packageName, ResourceTypePlural: resourceTypePlural}
+
+	if packageName == "" || packageName != key.PackageName {
+		return denied
+	}
+
+	plural := ""
+
+	for _, permission := range key.permissions() {
+		if !slices.Contains(permission.Verbs, verb) {
+			continue
+		}
+
+		if len(permission.ResourceTypes) == 0 {
+			return nil
+		}
+
+		if resourceTypePlural == "" {
+			continue
+		}
+
+		// The segment may be a singular or short name, so it is resolved to the plural once.
+		if plural == "" {
+			resourceTypeDefinition, err := h.getResourceTypeDefinition(ctx, packageName, resourceTypePlural)
+			if err != nil {
+				var resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
+				if errors.As(err, &resourceTypeDefinitionNotFoundError) {
+					return denied
+				}
+
+				return err
+			}
+
+			plural = strings.ToLower(resourceTypeDefinition.Plural)
+		}
+
+		if slices.Contains(permission.ResourceTypes, plural) {
+			return nil
+		}
+	}
+
+	return denied
+}
+
+// authenticateAPIKey returns the API key of a request and counts it against its rate limit. It responds with the
+// failure and returns false if the key is missing, unknown, or over its limit.
+func (h *Handler) authenticateAPIKey(w http.ResponseWriter, r *http.Request) (APIKey, bool) {
+	key, err := h.apiKey(r)
+	if err != nil {
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusUnauthorized),
+			problem.WithTitle("Unauthorized"),
+			problem.WithDetail(err.Error()),
+			withReason(apierrors.ReasonUnauthorized),
+		))
+
+		return APIKey{}, false
+	}
+
+	if limit := key.rateLimit(); limit > 0 {
+		allowed, retryAfter := h.apiKeyLimiter.allow(key.Name, limit, h.now())
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusTooManyRequests),
+				problem.WithTitle("Too Many Requests"),
+				problem.WithDetail(fmt.Sprintf("API key %q exceeded its rate limit of %d requests per minute", key.Name, limit)),
+				withReason(apierrors.ReasonQuotaExceeded),
+			))
+
+			return APIKey{}, false
+		}
+	}
+
+	return key, true
+}
+
+// respondAPIKeyDenied responds with the failure of a permission check of an API key.
+func (h *Handler) respondAPIKeyDenied(w http.ResponseWriter, r *http.Request, key APIKey, err error) {
+	h.logger.ErrorContext(r.Context(), "API key access denied", "key", key.Name, "error", err)
+
+	var apiKeyPermissionError APIKeyPermissionError
+
+	switch {
+	case errors.As(err, &apiKeyPermissionError):
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusForbidden),
+			problem.WithTitle("Forbidden"),
+			problem.WithDetail(apiKeyPermissionError.Error()),
+			withReason(apierrors.ReasonAccessDenied),
+		))
+	default:
+		respond.Done(w, r, serverError(err))
+	}
+}
+
+// withAPIKeys requires requests to carry an API key allowing them, once API keys are configured, and rate limits
+// them.
+func (h *Handler) withAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(h.apiKeys) == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		key, ok := h.authenticateAPIKey(w, r)
+		if !ok {
+			return
+		}
+
+		err := h.checkAPIKeyPermission(r, key)
+		if err != nil {
+			h.respondAPIKeyDenied(w, r, key, err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	})
+}
+
+// withAdminAPIKeys requires requests to the admin API to carry an admin key, a secret key of the core package, once
+// API keys are configured.
+func (h *Handler) withAdminAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(h.apiKeys) == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		key, ok := h.authenticateAPIKey(w, r)
+		if !ok {
+			return
+		}
+
+		if key.Type != APIKeySecret || key.PackageName != corePackageName {
+			h.respondAPIKeyDenied(w, r, key, APIKeyPermissionError{Name: key.Name, Verb: "administer", PackageName: corePackageName, ResourceTypePlural: ""})
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	})
+}
+
+// withLegalHoldAPIKeys requires placing and releasing legal holds to carry a secret key granted APIKeyVerbLegalHold on
+// the resource type of the item, once API keys are configured. Secret keys of the core package granted it on all
+// resource types may hold items of any package.
+func (h *Handler) withLegalHoldAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(h.apiKeys) == 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		key, ok := h.authenticateAPIKey(w, r)
+		if !ok {
+			return
+		}
+
+		packageName, resourceTypePlural := r.PathValue("packageName"), r.PathValue("resourceTypePlural")
+		if key.PackageName == corePackageName {
+			packageName, resourceTypePlural = corePackageName, ""
+		}
+
+		err := h.checkAPIKeyAccess(r.Context(), key, APIKeyVerbLegalHold, packageName, resourceTypePlural)
+		if err == nil && key.Type != APIKeySecret {
+			err = APIKeyPermissionError{Name: key.Name, Verb: APIKeyVerbLegalHold, PackageName: packageName, ResourceTypePlural: resourceTypePlural}
+		}
+
+		if err != nil {
+			h.respondAPIKeyDenied(w, r, key, err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	})
+}
@@ -0,0 +1,119 @@
+// Package apierrors is the catalog of the errors of the bass API.
+//
+// Every error response of the API is a problem details object (RFC 9457) with a type URI and a code extension
+// identifying the failure mode, so clients can branch on them instead of parsing titles or details. Types and codes
+// are stable: they are only ever added, never renamed or removed. Like the wire types, this package has no dependency
+// on the HTTP handler, so clients can import it on its own.
+package apierrors
+
+import "net/http"
+
+// Reason is the machine-readable code of a failure mode, given in the "code" extension of problem details.
+type Reason string
+
+// Reasons of the bass API.
+const (
+	// ReasonBadRequest means the request is malformed, e.g. its body can't be decoded or a parameter is missing.
+	ReasonBadRequest Reason = "BadRequest"
+	// ReasonInvalid means a resource item, or a value in the request, doesn't match its schema or constraints.
+	ReasonInvalid Reason = "Invalid"
+	// ReasonPaginationRequired means a list is too large to be responded at once, and must be requested in pages.
+	ReasonPaginationRequired Reason = "PaginationRequired"
+	// ReasonUnknownFields means a resource item has properties its schema doesn't declare.
+	ReasonUnknownFields Reason = "UnknownFields"
+	// ReasonNotFound means a package, resource type, resource item, or endpoint doesn't exist.
+	ReasonNotFound Reason = "NotFound"
+	// ReasonAlreadyExists means a resource item with the same name already exists.
+	ReasonAlreadyExists Reason = "AlreadyExists"
+	// ReasonConflict means the request conflicts with the current state, e.g. a stale resource version.
+	ReasonConflict Reason = "Conflict"
+	// ReasonPreconditionFailed means a precondition of the request doesn't hold.
+	ReasonPreconditionFailed Reason = "PreconditionFailed"
+	// ReasonMethodNotAllowed means the operation isn't allowed on the resource type, e.g. updating immutable items.
+	ReasonMethodNotAllowed Reason = "MethodNotAllowed"
+	// ReasonInvalidTransition means a state machine doesn't allow the requested transition.
+	ReasonInvalidTransition Reason = "InvalidTransition"
+	// ReasonContentRejected means a moderation policy objects to the content of a resource item.
+	ReasonContentRejected Reason = "ContentRejected"
+	// ReasonLegalHold means the resource item is under legal hold, and can't be deleted or modified until it is
+	// released.
+	ReasonLegalHold Reason = "LegalHold"
+	// ReasonApprovalRequired means the change must be made with an approved change request.
+	ReasonApprovalRequired Reason = "ApprovalRequired"
+	// ReasonAccessDenied means a policy, e.g. a network policy, denies the request.
+	ReasonAccessDenied Reason = "AccessDenied"
+	// ReasonUnauthorized means the request isn't authenticated, e.g. its signature is invalid.
+	ReasonUnauthorized Reason = "Unauthorized"
+	// ReasonRequestTooLarge means the request body exceeds the limit of the server.
+	ReasonRequestTooLarge Reason = "RequestTooLarge"
+	// ReasonSyncTokenExpired means a sync token is invalid or no longer valid, and the client must sync from scratch.
+	ReasonSyncTokenExpired Reason = "SyncTokenExpired"
+	// ReasonEventsExpired means the events a watch resumes from are no longer retained, and the watch must start over.
+	ReasonEventsExpired Reason = "EventsExpired"
+	// ReasonUnsupportedMediaType means the content type of the request isn't supported.
+	ReasonUnsupportedMediaType Reason = "UnsupportedMediaType"
+	// ReasonQuotaExceeded means a quota or rate limit of the client is exhausted.
+	ReasonQuotaExceeded Reason = "QuotaExceeded"
+	// ReasonNotImplemented means the server isn't configured with the capability the request needs.
+	ReasonNotImplemented Reason = "NotImplemented"
+	// ReasonDeliveryFailed means an upstream provider, e.g. of emails or notifications, failed.
+	ReasonDeliveryFailed Reason = "DeliveryFailed"
+	// ReasonUnavailable means the server doesn't accept the request for now, e.g. in maintenance.
+	ReasonUnavailable Reason = "Unavailable"
+	// ReasonTimeout means the request didn't complete within the time limit.
+	ReasonTimeout Reason = "Timeout"
+	// ReasonInternal means an unexpected failure of the server.
+	ReasonInternal Reason = "Internal"
+)
+
+// TypePrefix prefixes the reasons in problem type URIs.
+const TypePrefix = "urn:bass:problem:"
+
+// Type returns the problem type URI of the reason, e.g. "urn:bass:problem:NotFound".
+func (reason Reason) Type() string {
+	return TypePrefix + string(reason)
+}
+
+// Status returns the HTTP status code responses with the reason have, or 500 for unknown reasons.
+func (reason Reason) Status() int {
+	switch reason {
+	case ReasonBadRequest, ReasonInvalid, ReasonUnknownFields, ReasonPaginationRequired:
+		return http.StatusBadRequest
+	case ReasonNotFound:
+		return http.StatusNotFound
+	case ReasonAlreadyExists, ReasonConflict:
+		return http.StatusConflict
+	case ReasonPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case ReasonMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case ReasonInvalidTransition, ReasonContentRejected:
+		return http.StatusUnprocessableEntity
+	case ReasonApprovalRequired, ReasonAccessDenied:
+		return http.StatusForbidden
+	case ReasonUnauthorized:
+		return http.StatusUnauthorized
+	case ReasonLegalHold:
+		return http.StatusLocked
+	case ReasonSyncTokenExpired, ReasonEventsExpired:
+		return http.StatusGone
+	case ReasonRequestTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case ReasonUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case ReasonQuotaExceeded:
+		return http.StatusTooManyRequests
+	case ReasonNotImplemented:
+		return http.StatusNotImplemented
+	case ReasonDeliveryFailed:
+		return http.StatusBadGateway
+	case ReasonUnavailable:
+		return http.StatusServiceUnavailable
+	case ReasonTimeout:
+		return http.StatusGatewayTimeout
+	case ReasonInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusInternalServerError
+	}
+}
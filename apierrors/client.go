@@ -0,0 +1,130 @@
+package apierrors
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// StatusError is an error response of the bass API, decoded from its problem details.
+type StatusError struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Code   Reason `json:"code"`
+	// Extensions holds the other members of the problem details, e.g. "errors" of invalid resource items.
+	Extensions map[string]any `json:",unknown"` //nolint:tagliatelle // members of the problem details object itself
+}
+
+func (err *StatusError) Error() string {
+	if err.Detail == "" {
+		return fmt.Sprintf("%d %s", err.Status, err.Title)
+	}
+
+	return fmt.Sprintf("%d %s: %s", err.Status, err.Title, err.Detail)
+}
+
+// FromResponse returns the error of a response of the bass API, or nil if its status isn't an error status. The body
+// of problem details responses is read into a *StatusError; other error responses, e.g. of proxies, only set its
+// status and title.
+func FromResponse(res *http.Response) error {
+	if res.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	statusError := &StatusError{
+		Type:       "",
+		Title:      http.StatusText(res.StatusCode),
+		Status:     res.StatusCode,
+		Detail:     "",
+		Code:       "",
+		Extensions: nil,
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" {
+		return statusError
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	err = json.Unmarshal(body, statusError)
+	if err != nil {
+		return fmt.Errorf("failed to decode problem details: %w", err)
+	}
+
+	code, ok := strings.CutPrefix(statusError.Type, TypePrefix)
+	if statusError.Code == "" && ok {
+		statusError.Code = Reason(code)
+	}
+
+	return statusError
+}
+
+// ReasonFrom returns the reason of a *StatusError in the chain of err, or an empty reason if there is none or it has
+// no code.
+func ReasonFrom(err error) Reason {
+	var statusError *StatusError
+	if !errors.As(err, &statusError) {
+		return ""
+	}
+
+	return statusError.Code
+}
+
+// hasReason reports whether err has the reason, or, for responses without code, the status of the reason.
+func hasReason(err error, reason Reason) bool {
+	var statusError *StatusError
+	if !errors.As(err, &statusError) {
+		return false
+	}
+
+	if statusError.Code == "" {
+		return statusError.Status == reason.Status()
+	}
+
+	return statusError.Code == reason
+}
+
+// IsNotFound reports whether err means the requested resource doesn't exist.
+func IsNotFound(err error) bool {
+	return hasReason(err, ReasonNotFound)
+}
+
+// IsAlreadyExists reports whether err means a resource item with the same name already exists.
+func IsAlreadyExists(err error) bool {
+	return hasReason(err, ReasonAlreadyExists)
+}
+
+// IsConflict reports whether err means the request conflicts with the current state, e.g. a stale resource version.
+func IsConflict(err error) bool {
+	return hasReason(err, ReasonConflict)
+}
+
+// IsInvalid reports whether err means the resource item doesn't match its schema or constraints.
+func IsInvalid(err error) bool {
+	return hasReason(err, ReasonInvalid)
+}
+
+// IsPreconditionFailed reports whether err means a precondition of the request doesn't hold.
+func IsPreconditionFailed(err error) bool {
+	return hasReason(err, ReasonPreconditionFailed)
+}
+
+// IsForbidden reports whether err means the request is denied, by a policy or for requiring approval.
+func IsForbidden(err error) bool {
+	return hasReason(err, ReasonAccessDenied) || hasReason(err, ReasonApprovalRequired)
+}
+
+// IsTimeout reports whether err means the request didn't complete within the time limit.
+func IsTimeout(err error) bool {
+	return hasReason(err, ReasonTimeout)
+}
@@ -0,0 +1,198 @@
+package bass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// archivedLabel labels the stubs of archived resources with the cold storage key of their properties.
+const archivedLabel = "bass.archive/key"
+
+// ColdStorage keeps the properties of archived resources, e.g. as objects in S3 or rows of Parquet files, by key.
+type ColdStorage interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) (data []byte, err error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Archiving configures the tiering of resources unmodified for a while to cold storage, to keep the repository small.
+// Archived resources are replaced by stubs without properties, labeled with archivedLabel, and are rehydrated when
+// they are read. Lists include the stubs as they are.
+type Archiving struct {
+	Storage ColdStorage
+	// After is how long resources must be unmodified to be archived.
+	After time.Duration
+}
+
+// Archive moves the properties of resources unmodified for the archiving period to cold storage, and returns how many
+// resources it archived. Resources of the core package are never archived. It is meant to be called periodically.
+func (h *Handler) Archive(ctx context.Context) (int, error) {
+	if h.archiving == nil {
+		return 0, nil
+	}
+
+	list, err := h.repoList(ctx, corePackageName, "v1", "ResourceTypeDefinition")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list resource type definitions: %w", err)
+	}
+
+	archived := 0
+
+	for _, definition := range list.Items {
+		resourceTypeDefinition, err := resourceTypeDefinitionFromResource(definition)
+		if err != nil {
+			return archived, err
+		}
+
+		if resourceTypeDefinition.Package == corePackageName {
+			continue
+		}
+
+		n, err := h.archiveResourceType(ctx, resourceTypeDefinition)
+		archived += n
+
+		if err != nil {
+			return archived, err
+		}
+	}
+
+	return archived, nil
+}
+
+func (h *Handler) archiveResourceType(ctx context.Context, resourceTypeDefinition *ResourceTypeDefinition) (int, error) {
+	list, err := h.repoList(ctx, resourceTypeDefinition.Package, resourceTypeDefinition.Versions[0].Name, resourceTypeDefinition.ResourceType)
+	if err != nil {
+		return 0, err
+	}
+
+	before := h.now().Add(-h.archiving.After)
+	archived := 0
+
+	for _, item := range list.Items {
+		if _, ok := item.Metadata.Labels[archivedLabel]; ok || !item.Metadata.UpdatedAt.Before(before) {
+			continue
+		}
+
+		err := h.archiveResource(ctx, item)
+		if err != nil {
+			var resourceVersionConflictError ResourceVersionConflictError
+			if errors.As(err, &resourceVersionConflictError) {
+				// Modified since it was listed, so it isn't stale anymore.
+				continue
+			}
+
+			return archived, err
+		}
+
+		archived++
+	}
+
+	return archived, nil
+}
+
+// archiveResource puts the properties of item in cold storage and replaces it with a stub, unless it has been modified
+// since it was read.
+func (h *Handler) archiveResource(ctx context.Context, item *Resource) error {
+	data, err := json.Marshal(item.Properties)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource properties: %w", err)
+	}
+
+	key := item.Metadata.UID
+
+	err = h.archiving.Storage.Put(ctx, key, data)
+	if err != nil {
+		return fmt.Errorf("failed to put resource in cold storage: %w", err)
+	}
+
+	stub := &Resource{Metadata: item.Metadata, Properties: map[string]any{}}
+	stub.Metadata.Labels = maps.Clone(item.Metadata.Labels)
+
+	if stub.Metadata.Labels == nil {
+		stub.Metadata.Labels = make(map[string]string)
+	}
+
+	stub.Metadata.Labels[archivedLabel] = key
+
+	err = h.repo.Update(ctx, stub)
+	if err != nil {
+		return fmt.Errorf("failed to replace resource with stub: %w", err)
+	}
+
+	return nil
+}
+
+// rehydrate restores the properties of item from cold storage if it is an archived stub, and stores it back.
+func (h *Handler) rehydrate(ctx context.Context, item *Resource) (*Resource, error) {
+	key, ok := item.Metadata.Labels[archivedLabel]
+	if !ok || h.archiving == nil {
+		return item, nil
+	}
+
+	data, err := h.archiving.Storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource from cold storage: %w", err)
+	}
+
+	var properties map[string]any
+
+	err = json.Unmarshal(data, &properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal archived resource properties: %w", err)
+	}
+
+	item.Properties = properties
+	item.SetRaw(jsontext.Value(data))
+
+	delete(item.Metadata.Labels, archivedLabel)
+
+	err = h.repo.Update(ctx, item)
+	if err != nil {
+		var resourceVersionConflictError ResourceVersionConflictError
+		if errors.As(err, &resourceVersionConflictError) {
+			// Rehydrated or modified concurrently.
+			return h.repoGet(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+		}
+
+		return nil, fmt.Errorf("failed to restore archived resource: %w", err)
+	}
+
+	err = h.archiving.Storage.Delete(ctx, key)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to delete resource from cold storage", "key", key, "error", err)
+	}
+
+	return item, nil
+}
+
+// rehydrateRaw is rehydrate for JSON encoded resources. Only encodings that may be archived stubs are decoded.
+func (h *Handler) rehydrateRaw(ctx context.Context, raw jsontext.Value) (jsontext.Value, error) {
+	if h.archiving == nil || !bytes.Contains(raw, []byte(archivedLabel)) {
+		return raw, nil
+	}
+
+	var item Resource
+
+	err := json.Unmarshal(raw, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource: %w", err)
+	}
+
+	rehydrated, err := h.rehydrate(ctx, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := json.Marshal(rehydrated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource: %w", err)
+	}
+
+	return res, nil
+}
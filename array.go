@@ -0,0 +1,90 @@
+package bass
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type ArrayValueRequest = apiv1.ArrayValueRequest
+
+// handleArrayValueResource applies fn to an array property of an item atomically in the repository. A missing
+// array is treated as empty.
+func (h *Handler) handleArrayValueResource(w http.ResponseWriter, r *http.Request, name string, fn func(values []any, value any) []any) {
+	packageName := r.PathValue("packageName")
+	resourceTypePlural := r.PathValue("resourceTypePlural")
+
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return
+	}
+
+	var req ArrayValueRequest
+
+	err = json.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+		default:
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+		}
+
+		return
+	}
+
+	segments, err := parsePropertyPath(req.Path)
+	if err != nil {
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return
+	}
+
+	h.updateResourceFunc(w, r, resourceTypeDefinition, name, func(item *Resource) error {
+		parent, key, err := propertyParent(item.Properties, req.Path, segments)
+		if err != nil {
+			return err
+		}
+
+		var values []any
+
+		if value, ok := parent[key]; ok {
+			values, ok = value.([]any)
+			if !ok {
+				return PropertyPathError{Path: req.Path, Reason: "property is not an array"}
+			}
+		}
+
+		parent[key] = fn(values, req.Value)
+
+		return nil
+	})
+}
+
+// appendUnique appends value to values, unless values already contain it.
+func appendUnique(values []any, value any) []any {
+	if slices.ContainsFunc(values, func(v any) bool { return reflect.DeepEqual(v, value) }) {
+		return values
+	}
+
+	return append(values, value)
+}
+
+// removeValue removes all occurrences of value from values.
+func removeValue(values []any, value any) []any {
+	return slices.DeleteFunc(values, func(v any) bool { return reflect.DeepEqual(v, value) })
+}
@@ -0,0 +1,461 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+)
+
+type (
+	Automation           = apiv1.Automation
+	AutomationAction     = apiv1.AutomationAction
+	AutomationActionType = apiv1.AutomationActionType
+)
+
+const (
+	AutomationActionCreateResource = apiv1.AutomationActionCreateResource
+	AutomationActionPatchResource  = apiv1.AutomationActionPatchResource
+	AutomationActionWebhook        = apiv1.AutomationActionWebhook
+	AutomationActionNotify         = apiv1.AutomationActionNotify
+)
+
+// maxAutomationDepth is how many automations may run in a chain of writes made by automations, to stop automations
+// triggering each other forever.
+const maxAutomationDepth = 5
+
+type automationDepthContextKey struct{}
+
+func automationDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(automationDepthContextKey{}).(int)
+
+	return depth
+}
+
+func automationResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "Automation.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "Automation",
+		Plural:       "Automations",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"trigger": resourceTriggerSchema(),
+						"filter":  map[string]any{"type": "string"},
+						"actions": map[string]any{
+							"type":     "array",
+							"minItems": 1,
+							"items": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"type": map[string]any{
+										"type": "string",
+										"enum": []any{AutomationActionCreateResource, AutomationActionPatchResource, AutomationActionWebhook, AutomationActionNotify},
+									},
+									"packageName":        map[string]any{"type": "string"},
+									"apiVersion":         map[string]any{"type": "string"},
+									"resourceTypePlural": map[string]any{"type": "string"},
+									"name":               map[string]any{"type": "string"},
+									"properties":         map[string]any{"type": "object"},
+									"url":                map[string]any{"type": "string", "format": "uri"},
+									"channel":            map[string]any{"type": "string"},
+									"title":              map[string]any{"type": "string"},
+									"text":               map[string]any{"type": "string"},
+								},
+								"required": []any{"type"},
+							},
+						},
+					},
+					"required": []any{"trigger", "actions"},
+				},
+			},
+		},
+	}
+}
+
+func automationFromResource(item *Resource) (*Automation, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal automation properties: %w", err)
+	}
+
+	var automation Automation
+
+	err = json.Unmarshal(b, &automation)
+	if err != nil {
+		return nil, fmt.Errorf("automation %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	automation.Metadata = item.Metadata
+
+	return &automation, nil
+}
+
+// checkAutomation checks that the actions of an automation item have the fields of their type, and parses its
+// templates.
+func checkAutomation(item *Resource) error {
+	automation, err := automationFromResource(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = executeTextTemplate("filter", automation.Filter, nil)
+
+	err = templateParseError(err)
+	if err != nil {
+		return err
+	}
+
+	for i, action := range automation.Actions {
+		var missing []string
+
+		switch action.Type {
+		case AutomationActionCreateResource:
+			missing = missingFields(map[string]string{"packageName": action.PackageName, "apiVersion": action.APIVersion, "resourceTypePlural": action.ResourceTypePlural})
+		case AutomationActionPatchResource:
+			missing = missingFields(map[string]string{"packageName": action.PackageName, "apiVersion": action.APIVersion, "resourceTypePlural": action.ResourceTypePlural, "name": action.Name})
+		case AutomationActionWebhook:
+			missing = missingFields(map[string]string{"url": action.URL})
+		case AutomationActionNotify:
+			missing = missingFields(map[string]string{"channel": action.Channel, "title": action.Title})
+		}
+
+		if len(missing) > 0 {
+			return ResourceInvalidError{Errors: []ValidationError{{
+				Pointer: "/properties/actions/" + strconv.Itoa(i),
+				Keyword: "required",
+				Message: fmt.Sprintf("%s action needs %s", action.Type, strings.Join(missing, ", ")),
+				Params:  map[string]any{"missing": missing},
+			}}}
+		}
+
+		_, err = renderAutomationAction(action, nil)
+
+		err = templateParseError(err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// missingFields returns the names of the empty fields, sorted.
+func missingFields(fields map[string]string) []string {
+	var missing []string
+
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		if fields[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// renderAutomationAction executes the templates of an action with data.
+func renderAutomationAction(action AutomationAction, data map[string]any) (AutomationAction, error) {
+	var err error
+
+	action.Name, err = executeTextTemplate("name", action.Name, data)
+	if err != nil {
+		return AutomationAction{}, err
+	}
+
+	action.Title, err = executeTextTemplate("title", action.Title, data)
+	if err != nil {
+		return AutomationAction{}, err
+	}
+
+	action.Text, err = executeTextTemplate("text", action.Text, data)
+	if err != nil {
+		return AutomationAction{}, err
+	}
+
+	properties, err := renderTemplateValue("properties", action.Properties, data)
+	if err != nil {
+		return AutomationAction{}, err
+	}
+
+	action.Properties, _ = properties.(map[string]any)
+
+	return action, nil
+}
+
+// renderTemplateValue executes the strings in value as templates with data. Strings that are a single field reference
+// are replaced with the value of the field, keeping its type.
+func renderTemplateValue(field string, value any, data map[string]any) (any, error) {
+	switch value := value.(type) {
+	case string:
+		if fieldValue, ok := templateFieldValue(value, data); ok {
+			return fieldValue, nil
+		}
+
+		return executeTextTemplate(field, value, data)
+	case map[string]any:
+		res := make(map[string]any, len(value))
+
+		for key, v := range value {
+			rendered, err := renderTemplateValue(field+"/"+jsonPointerToken(key), v, data)
+			if err != nil {
+				return nil, err
+			}
+
+			res[key] = rendered
+		}
+
+		return res, nil
+	case []any:
+		res := make([]any, len(value))
+
+		for i, v := range value {
+			rendered, err := renderTemplateValue(field+"/"+strconv.Itoa(i), v, data)
+			if err != nil {
+				return nil, err
+			}
+
+			res[i] = rendered
+		}
+
+		return res, nil
+	default:
+		return value, nil
+	}
+}
+
+// templateFieldValue returns the value of the field text refers to, if it is a template of a single field reference
+// like "{{.item.total}}". Missing fields are nil.
+func templateFieldValue(text string, data map[string]any) (any, bool) {
+	trees, err := parse.Parse("value", text, "", "")
+	if err != nil {
+		return nil, false
+	}
+
+	root := trees["value"].Root
+	if len(root.Nodes) != 1 {
+		return nil, false
+	}
+
+	action, ok := root.Nodes[0].(*parse.ActionNode)
+	if !ok || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) != 1 || len(action.Pipe.Cmds[0].Args) != 1 {
+		return nil, false
+	}
+
+	fieldNode, ok := action.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok {
+		return nil, false
+	}
+
+	var value any = data
+
+	for _, ident := range fieldNode.Ident {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, true
+		}
+
+		value = m[ident]
+	}
+
+	return value, true
+}
+
+// runAutomations runs the automations triggered by a write. Each automation runs its actions in order, and stops at
+// the first failure, which is logged.
+func (h *Handler) runAutomations(ctx context.Context, operation OperationType, item *Resource) {
+	depth := automationDepth(ctx)
+	if depth >= maxAutomationDepth {
+		h.logger.ErrorContext(ctx, "automation chain is too deep, not running automations", "package", item.Metadata.PackageName, "resourceType", item.Metadata.ResourceType, "name", item.Metadata.Name)
+
+		return
+	}
+
+	list, err := h.repoList(ctx, corePackageName, "v1", "Automation")
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list automations", "error", err)
+
+		return
+	}
+
+	ctx = contextWithWriteOrigin(context.WithValue(ctx, automationDepthContextKey{}, depth+1), originAutomation)
+
+	var data map[string]any
+
+	for _, automationItem := range list.Items {
+		automation, err := automationFromResource(automationItem)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to parse automation", "error", err)
+
+			continue
+		}
+
+		if !triggerMatches(automation.Trigger, operation, item) {
+			continue
+		}
+
+		if data == nil {
+			data, err = triggerData(operation, item)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to prepare automation data", "error", err)
+
+				return
+			}
+		}
+
+		err = h.runAutomation(ctx, automation, data)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to run automation", "automation", automationItem.Metadata.Name, "error", err)
+		}
+	}
+}
+
+func (h *Handler) runAutomation(ctx context.Context, automation *Automation, data map[string]any) error {
+	if automation.Filter != "" {
+		filter, err := executeTextTemplate("filter", automation.Filter, data)
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(filter) != "true" {
+			return nil
+		}
+	}
+
+	for i, action := range automation.Actions {
+		action, err := renderAutomationAction(action, data)
+		if err != nil {
+			return fmt.Errorf("failed to render action %d: %w", i, err)
+		}
+
+		err = h.runAutomationAction(ctx, action, data)
+		if err != nil {
+			return fmt.Errorf("action %d failed: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) runAutomationAction(ctx context.Context, action AutomationAction, data map[string]any) error {
+	switch action.Type {
+	case AutomationActionCreateResource:
+		return h.automationCreateResource(ctx, action)
+	case AutomationActionPatchResource:
+		return h.automationPatchResource(ctx, action)
+	case AutomationActionWebhook:
+		return postJSON(ctx, http.DefaultClient, action.URL, "", data)
+	case AutomationActionNotify:
+		return h.notifyChannel(ctx, action.Channel, Notification{Title: action.Title, Text: action.Text, Data: data})
+	default:
+		return fmt.Errorf("unknown action type %q", action.Type)
+	}
+}
+
+// automationCreateResource creates an item with the properties of the action, named by a random UUID if the action
+// doesn't name it.
+func (h *Handler) automationCreateResource(ctx context.Context, action AutomationAction) error {
+	if action.Name == "" {
+		action.Name = uuid.NewString()
+	}
+
+	op, err := h.transactionOperation(ctx, TransactionOperation{
+		Op:                 OperationCreate,
+		PackageName:        action.PackageName,
+		APIVersion:         action.APIVersion,
+		ResourceTypePlural: action.ResourceTypePlural,
+		Name:               action.Name,
+		Labels:             nil,
+		Properties:         action.Properties,
+		Precondition:       Precondition{UID: "", ResourceVersion: ""},
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	err = h.repo.Create(ctx, op.Item)
+	if err != nil {
+		return fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	h.resourceWritten(ctx, OperationCreate, op.Item)
+
+	return nil
+}
+
+// automationPatchResource merges the properties of the action into the properties of an item, as a JSON merge patch.
+func (h *Handler) automationPatchResource(ctx context.Context, action AutomationAction) error {
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(ctx, action.PackageName, action.ResourceTypePlural)
+	if err != nil {
+		return err
+	}
+
+	current, err := h.repoGet(ctx, action.PackageName, resourceTypeDefinition.ResourceType, action.Name)
+	if err != nil {
+		return err
+	}
+
+	original, err := json.Marshal(current.Properties)
+	if err != nil {
+		return fmt.Errorf("failed to marshal properties: %w", err)
+	}
+
+	patch, err := json.Marshal(action.Properties)
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	modified, err := jsonpatch.MergePatch(original, patch)
+	if err != nil {
+		return fmt.Errorf("failed to apply merge patch: %w", err)
+	}
+
+	var properties map[string]any
+
+	err = json.Unmarshal(modified, &properties)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal patched properties: %w", err)
+	}
+
+	op, err := h.transactionOperation(ctx, TransactionOperation{
+		Op:                 OperationUpdate,
+		PackageName:        action.PackageName,
+		APIVersion:         action.APIVersion,
+		ResourceTypePlural: action.ResourceTypePlural,
+		Name:               action.Name,
+		Labels:             current.Metadata.Labels,
+		Properties:         properties,
+		Precondition:       Precondition{UID: "", ResourceVersion: ""},
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	op.Item.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+
+	err = h.repo.Update(ctx, op.Item)
+	if err != nil {
+		return fmt.Errorf("failed to update resource: %w", err)
+	}
+
+	h.resourceWritten(ctx, OperationUpdate, op.Item)
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package bass
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	Backup     = apiv1.Backup
+	BackupList = apiv1.BackupList
+	BackupData = apiv1.BackupData
+	BackupType = apiv1.BackupType
+)
+
+const (
+	BackupFull        = apiv1.BackupFull
+	BackupIncremental = apiv1.BackupIncremental
+)
+
+// BackupTarget stores backups by name, e.g. as objects in a bucket.
+type BackupTarget interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Delete(ctx context.Context, name string) error
+}
+
+// BackupPolicy configures the backups of all resources taken by Handler.RunBackups.
+type BackupPolicy struct {
+	Target BackupTarget
+	// FullInterval is the time between full backups.
+	FullInterval time.Duration
+	// IncrementalInterval is the time between incremental backups in between full ones. Zero disables them.
+	IncrementalInterval time.Duration
+	// Retention is the number of full backups kept, with the incremental backups building on them. Zero keeps all.
+	Retention int
+}
+
+var ErrBackupsUnsupported = errors.New("backups need a repository implementing ResourceCounter")
+
+// backupCatalog keeps the backups written since the handler started, oldest first, and the resources of the last
+// one, to find deletions for the next incremental backup.
+type backupCatalog struct {
+	mu      sync.Mutex
+	backups []Backup
+	keys    map[string]struct{}
+}
+
+func newBackupCatalog() *backupCatalog {
+	return &backupCatalog{
+		mu:      sync.Mutex{},
+		backups: nil,
+		keys:    nil,
+	}
+}
+
+func (catalog *backupCatalog) list() []Backup {
+	catalog.mu.Lock()
+	defer catalog.mu.Unlock()
+
+	return slices.Clone(catalog.backups)
+}
+
+// RunBackups takes full and incremental backups as the backup policy schedules them, until ctx is done. Failed
+// backups are logged and retried on the next tick.
+func (h *Handler) RunBackups(ctx context.Context) {
+	if h.backupPolicy == nil {
+		return
+	}
+
+	interval := h.backupPolicy.FullInterval
+	if h.backupPolicy.IncrementalInterval > 0 {
+		interval = min(interval, h.backupPolicy.IncrementalInterval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		backupType, due := h.backupDue()
+		if due {
+			_, err := h.Backup(ctx, backupType)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to back up resources", "type", backupType, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backupDue returns the type of the backup due now, if any.
+func (h *Handler) backupDue() (BackupType, bool) {
+	backups := h.backups.list()
+
+	lastFull := -1
+
+	for i, backup := range slices.Backward(backups) {
+		if backup.Type == BackupFull {
+			lastFull = i
+
+			break
+		}
+	}
+
+	if lastFull < 0 {
+		return BackupFull, true
+	}
+
+	now := h.now()
+
+	if !now.Before(backups[lastFull].CreatedAt.Add(h.backupPolicy.FullInterval)) {
+		return BackupFull, true
+	}
+
+	if h.backupPolicy.IncrementalInterval > 0 && !now.Before(backups[len(backups)-1].CreatedAt.Add(h.backupPolicy.IncrementalInterval)) {
+		return BackupIncremental, true
+	}
+
+	return "", false
+}
+
+// Backup writes a backup of all resources to the backup target, and removes the backups beyond the retention. An
+// incremental backup without a previous backup to build on is taken as a full one. Without a backup policy, it does
+// nothing.
+func (h *Handler) Backup(ctx context.Context, backupType BackupType) (Backup, error) {
+	if h.backupPolicy == nil {
+		return Backup{}, nil
+	}
+
+	items, err := h.listAllResources(ctx)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	h.backups.mu.Lock()
+	defer h.backups.mu.Unlock()
+
+	data := BackupData{Name: "", Type: backupType, CreatedAt: h.now(), Base: "", Items: items, Deleted: nil}
+
+	if len(h.backups.backups) == 0 {
+		data.Type = BackupFull
+	}
+
+	keys := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		keys[resourceLabelValue(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)] = struct{}{}
+	}
+
+	if data.Type == BackupIncremental {
+		previous := h.backups.backups[len(h.backups.backups)-1]
+
+		data.Base = cmp.Or(previous.Base, previous.Name)
+		data.Items = slices.DeleteFunc(items, func(item *Resource) bool {
+			return !item.Metadata.UpdatedAt.After(previous.CreatedAt)
+		})
+
+		for key := range h.backups.keys {
+			if _, ok := keys[key]; !ok {
+				data.Deleted = append(data.Deleted, key)
+			}
+		}
+
+		slices.Sort(data.Deleted)
+	}
+
+	data.Name = fmt.Sprintf("bass-%s-%s.json", data.Type, data.CreatedAt.UTC().Format("20060102T150405.000Z"))
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to marshal backup: %w", err)
+	}
+
+	err = h.backupPolicy.Target.Put(ctx, data.Name, b)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to put backup: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	backup := Backup{
+		Name:      data.Name,
+		Type:      data.Type,
+		CreatedAt: data.CreatedAt,
+		Base:      data.Base,
+		Count:     len(data.Items),
+		Bytes:     int64(len(b)),
+		Checksum:  "sha256:" + hex.EncodeToString(sum[:]),
+	}
+
+	h.backups.backups = append(h.backups.backups, backup)
+	h.backups.keys = keys
+
+	h.pruneBackups(ctx)
+
+	return backup, nil
+}
+
+// pruneBackups removes the oldest full backups beyond the retention, with the incremental backups building on them.
+// The catalog must be locked.
+func (h *Handler) pruneBackups(ctx context.Context) {
+	if h.backupPolicy.Retention <= 0 {
+		return
+	}
+
+	var fulls []string
+
+	for _, backup := range h.backups.backups {
+		if backup.Type == BackupFull {
+			fulls = append(fulls, backup.Name)
+		}
+	}
+
+	if len(fulls) <= h.backupPolicy.Retention {
+		return
+	}
+
+	expired := fulls[:len(fulls)-h.backupPolicy.Retention]
+
+	h.backups.backups = slices.DeleteFunc(h.backups.backups, func(backup Backup) bool {
+		if !slices.Contains(expired, backup.Name) && !slices.Contains(expired, backup.Base) {
+			return false
+		}
+
+		err := h.backupPolicy.Target.Delete(ctx, backup.Name)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to delete expired backup", "name", backup.Name, "error", err)
+
+			return false
+		}
+
+		return true
+	})
+}
+
+// listAllResources lists the resources of all packages and resource types, ordered by package, resource type, and
+// name.
+func (h *Handler) listAllResources(ctx context.Context) ([]*Resource, error) {
+	counter, ok := h.repo.(ResourceCounter)
+	if !ok {
+		return nil, ErrBackupsUnsupported
+	}
+
+	counts, err := counter.CountResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count resources: %w", err)
+	}
+
+	slices.SortFunc(counts, func(a, b ResourceCount) int {
+		return cmp.Or(cmp.Compare(a.PackageName, b.PackageName), cmp.Compare(a.ResourceType, b.ResourceType))
+	})
+
+	var items []*Resource
+
+	for _, count := range counts {
+		list, err := h.repoList(ctx, count.PackageName, "", count.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		slices.SortFunc(list.Items, func(a, b *Resource) int {
+			return cmp.Compare(a.Metadata.Name, b.Metadata.Name)
+		})
+
+		items = append(items, list.Items...)
+	}
+
+	return items, nil
+}
+
+// handleListBackups lists the backups taken since the handler started, oldest first.
+func (h *Handler) handleListBackups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.backupPolicy == nil {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("backups are not configured"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		backups := h.backups.list()
+		if backups == nil {
+			backups = make([]Backup, 0)
+		}
+
+		respond.Done(w, r, BackupList{Items: backups})
+	}
+}
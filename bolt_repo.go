@@ -0,0 +1,426 @@
+package bass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"strconv"
+
+	"go.etcd.io/bbolt"
+)
+
+// boltResourcesBucket is the root bucket of the resources of a BoltRepo. Its sequence is the resource version.
+const boltResourcesBucket = "resources"
+
+// BoltRepo is a ResourcesRepository persisting resources in a single bbolt database file.
+//
+// Resources are stored in nested buckets of packages and resource types, keyed by name, as their JSON encoding.
+// Every write is a bbolt transaction, so it is durable once it returns, and transactions of several writes are
+// atomic.
+type BoltRepo struct {
+	db *bbolt.DB
+}
+
+var (
+	_ ResourcesRepository     = (*BoltRepo)(nil)
+	_ TransactionalRepository = (*BoltRepo)(nil)
+	_ ResourceCounter         = (*BoltRepo)(nil)
+	_ StatsRepository         = (*BoltRepo)(nil)
+	_ AtomicUpdater           = (*BoltRepo)(nil)
+	_ RawGetter               = (*BoltRepo)(nil)
+)
+
+// NewBoltRepo returns a repository storing resources in db. The caller owns db, and closes it once the repository is
+// no longer used.
+func NewBoltRepo(db *bbolt.DB) (*BoltRepo, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltResourcesBucket))
+
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resources bucket: %w", err)
+	}
+
+	return &BoltRepo{db: db}, nil
+}
+
+func (repo *BoltRepo) List(_ context.Context, packageName, apiVersion, resourceType string) (ResourceList, error) {
+	items := make([]*Resource, 0)
+
+	err := repo.db.View(func(tx *bbolt.Tx) error {
+		bucket := boltTxn{tx: tx}.bucket(packageName, resourceType)
+		if bucket == nil {
+			return nil
+		}
+
+		// Keys are iterated in byte order, so items are sorted by name.
+		return bucket.ForEach(func(_, value []byte) error {
+			item, err := decodeBoltResource(value)
+			if err != nil {
+				return err
+			}
+
+			items = append(items, item)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return ResourceList{}, fmt.Errorf("failed to list resources: %w", err)
+	}
+
+	return newMemResourceList(packageName, apiVersion, resourceType, items), nil
+}
+
+func (repo *BoltRepo) Create(_ context.Context, item *Resource) error {
+	return repo.update(func(txn boltTxn) error {
+		return txn.create(item)
+	})
+}
+
+func (repo *BoltRepo) Get(_ context.Context, packageName, resourceType, name string) (*Resource, error) {
+	var item *Resource
+
+	err := repo.db.View(func(tx *bbolt.Tx) error {
+		var err error
+
+		item, err = boltTxn{tx: tx}.get(packageName, resourceType, name)
+
+		return err
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // errors of get are typed already
+	}
+
+	return item, nil
+}
+
+// GetRaw returns the JSON encoding of the resource, as it is stored.
+func (repo *BoltRepo) GetRaw(_ context.Context, packageName, resourceType, name string) (jsontext.Value, error) {
+	var raw jsontext.Value
+
+	err := repo.db.View(func(tx *bbolt.Tx) error {
+		value, err := boltTxn{tx: tx}.value(packageName, resourceType, name)
+		if err != nil {
+			return err
+		}
+
+		// Values are only valid during the transaction.
+		raw = bytes.Clone(value)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // errors of value are typed already
+	}
+
+	return raw, nil
+}
+
+func (repo *BoltRepo) Update(_ context.Context, item *Resource) error {
+	return repo.update(func(txn boltTxn) error {
+		return txn.update(item, Precondition{})
+	})
+}
+
+func (repo *BoltRepo) Delete(_ context.Context, packageName, resourceType, name string) error {
+	return repo.update(func(txn boltTxn) error {
+		return txn.delete(packageName, resourceType, name, Precondition{})
+	})
+}
+
+func (repo *BoltRepo) UpdateFunc(_ context.Context, packageName, resourceType, name string, fn func(item *Resource) error) (*Resource, error) {
+	var item *Resource
+
+	err := repo.update(func(txn boltTxn) error {
+		var err error
+
+		item, err = txn.get(packageName, resourceType, name)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion := item.Metadata.ResourceVersion
+
+		err = fn(item)
+		if err != nil {
+			return err
+		}
+
+		return txn.update(item, Precondition{UID: "", ResourceVersion: resourceVersion})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (repo *BoltRepo) Transact(_ context.Context, ops []Operation) error {
+	return repo.update(func(txn boltTxn) error {
+		for i, op := range ops {
+			err := txn.apply(op)
+			if err != nil {
+				return TransactionError{Index: i, Err: err}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *BoltRepo) CountResources(_ context.Context) ([]ResourceCount, error) {
+	stats, err := repo.stats()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]ResourceCount, 0, len(stats))
+
+	for _, s := range stats {
+		counts = append(counts, ResourceCount{PackageName: s.PackageName, ResourceType: s.ResourceType, Count: s.Count})
+	}
+
+	return counts, nil
+}
+
+// Stats approximates the bytes of resources with the size of their JSON encoding.
+func (repo *BoltRepo) Stats(_ context.Context) ([]ResourceTypeStats, error) {
+	return repo.stats()
+}
+
+func (repo *BoltRepo) stats() ([]ResourceTypeStats, error) {
+	stats := make([]ResourceTypeStats, 0)
+
+	err := repo.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(boltResourcesBucket)).ForEachBucket(func(packageName []byte) error {
+			packageBucket := tx.Bucket([]byte(boltResourcesBucket)).Bucket(packageName)
+
+			return packageBucket.ForEachBucket(func(resourceType []byte) error {
+				s := ResourceTypeStats{PackageName: string(packageName), ResourceType: string(resourceType), Count: 0, Bytes: 0}
+
+				err := packageBucket.Bucket(resourceType).ForEach(func(_, value []byte) error {
+					s.Count++
+					s.Bytes += int64(len(value))
+
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("failed to scan resource type bucket: %w", err)
+				}
+
+				stats = append(stats, s)
+
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// update runs fn in a read-write transaction, which is committed if fn succeeds.
+func (repo *BoltRepo) update(fn func(txn boltTxn) error) error {
+	return repo.db.Update(func(tx *bbolt.Tx) error { //nolint:wrapcheck // errors of fn are typed already
+		return fn(boltTxn{tx: tx})
+	})
+}
+
+type boltTxn struct {
+	tx *bbolt.Tx
+}
+
+// bucket returns the bucket of the items of a resource type, or nil if it has none.
+func (txn boltTxn) bucket(packageName, resourceType string) *bbolt.Bucket {
+	packageBucket := txn.tx.Bucket([]byte(boltResourcesBucket)).Bucket([]byte(packageName))
+	if packageBucket == nil {
+		return nil
+	}
+
+	return packageBucket.Bucket([]byte(resourceType))
+}
+
+func (txn boltTxn) value(packageName, resourceType, name string) ([]byte, error) {
+	var value []byte
+
+	if bucket := txn.bucket(packageName, resourceType); bucket != nil {
+		value = bucket.Get([]byte(name))
+	}
+
+	if value == nil {
+		return nil, ResourceNotFoundError{
+			PackageName:  packageName,
+			ResourceType: resourceType,
+			Name:         name,
+		}
+	}
+
+	return value, nil
+}
+
+func (txn boltTxn) get(packageName, resourceType, name string) (*Resource, error) {
+	value, err := txn.value(packageName, resourceType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeBoltResource(value)
+}
+
+func (txn boltTxn) nextResourceVersion() (string, error) {
+	seq, err := txn.tx.Bucket([]byte(boltResourcesBucket)).NextSequence()
+	if err != nil {
+		return "", fmt.Errorf("failed to get next resource version: %w", err)
+	}
+
+	return strconv.FormatUint(seq, 10), nil
+}
+
+func (txn boltTxn) apply(op Operation) error {
+	switch op.Type {
+	case OperationCreate:
+		return txn.create(op.Item)
+	case OperationUpdate:
+		return txn.update(op.Item, op.Precondition)
+	case OperationDelete:
+		return txn.delete(op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name, op.Precondition)
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+}
+
+func (txn boltTxn) create(item *Resource) error {
+	_, err := txn.value(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err == nil {
+		return ResourceExistsError{
+			PackageName:  item.Metadata.PackageName,
+			ResourceType: item.Metadata.ResourceType,
+			Name:         item.Metadata.Name,
+		}
+	}
+
+	item.Metadata.ResourceVersion, err = txn.nextResourceVersion()
+	if err != nil {
+		return err
+	}
+
+	return txn.put(item)
+}
+
+// update replaces a stored item. The UID and creation time of the stored item are kept. An item carrying a resource
+// version must match the stored one.
+func (txn boltTxn) update(item *Resource, precondition Precondition) error {
+	current, err := txn.get(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	err = CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	if item.Metadata.ResourceVersion != "" && item.Metadata.ResourceVersion != current.Metadata.ResourceVersion {
+		return ResourceVersionConflictError{
+			PackageName:     item.Metadata.PackageName,
+			ResourceType:    item.Metadata.ResourceType,
+			Name:            item.Metadata.Name,
+			ResourceVersion: item.Metadata.ResourceVersion,
+		}
+	}
+
+	item.Metadata.UID = current.Metadata.UID
+	item.Metadata.CreatedAt = current.Metadata.CreatedAt
+
+	item.Metadata.ResourceVersion, err = txn.nextResourceVersion()
+	if err != nil {
+		return err
+	}
+
+	return txn.put(item)
+}
+
+// delete removes a stored item, and the buckets of its resource type and package once they are empty.
+func (txn boltTxn) delete(packageName, resourceType, name string, precondition Precondition) error {
+	current, err := txn.get(packageName, resourceType, name)
+	if err != nil {
+		return err
+	}
+
+	err = CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	packageBucket := txn.tx.Bucket([]byte(boltResourcesBucket)).Bucket([]byte(packageName))
+	bucket := packageBucket.Bucket([]byte(resourceType))
+
+	err = bucket.Delete([]byte(name))
+	if err != nil {
+		return fmt.Errorf("failed to delete resource: %w", err)
+	}
+
+	if k, _ := bucket.Cursor().First(); k != nil {
+		return nil
+	}
+
+	err = packageBucket.DeleteBucket([]byte(resourceType))
+	if err != nil {
+		return fmt.Errorf("failed to delete resource type bucket: %w", err)
+	}
+
+	if k, _ := packageBucket.Cursor().First(); k != nil {
+		return nil
+	}
+
+	err = txn.tx.Bucket([]byte(boltResourcesBucket)).DeleteBucket([]byte(packageName))
+	if err != nil {
+		return fmt.Errorf("failed to delete package bucket: %w", err)
+	}
+
+	return nil
+}
+
+// put stores the JSON encoding of the item, creating the buckets of its package and resource type as needed.
+func (txn boltTxn) put(item *Resource) error {
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource: %w", err)
+	}
+
+	packageBucket, err := txn.tx.Bucket([]byte(boltResourcesBucket)).CreateBucketIfNotExists([]byte(item.Metadata.PackageName))
+	if err != nil {
+		return fmt.Errorf("failed to create package bucket: %w", err)
+	}
+
+	bucket, err := packageBucket.CreateBucketIfNotExists([]byte(item.Metadata.ResourceType))
+	if err != nil {
+		return fmt.Errorf("failed to create resource type bucket: %w", err)
+	}
+
+	err = bucket.Put([]byte(item.Metadata.Name), encoded)
+	if err != nil {
+		return fmt.Errorf("failed to put resource: %w", err)
+	}
+
+	return nil
+}
+
+// decodeBoltResource decodes a stored resource. Values are only valid during their transaction, so they are copied
+// first, as the raw properties of the resource keep referring to them.
+func decodeBoltResource(value []byte) (*Resource, error) {
+	var item Resource
+
+	err := json.Unmarshal(bytes.Clone(value), &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource: %w", err)
+	}
+
+	return &item, nil
+}
@@ -0,0 +1,115 @@
+package bass_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/nasermirzaei89/bass"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func newBoltRepo(t *testing.T, path string) *bass.BoltRepo {
+	t.Helper()
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{NoSync: true})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := bass.NewBoltRepo(db)
+	require.NoError(t, err)
+
+	return repo
+}
+
+func TestBoltRepo(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "bass.db")
+
+	db, err := bbolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+
+	repo, err := bass.NewBoltRepo(db)
+	require.NoError(t, err)
+
+	// writes are persisted
+	{
+		require.NoError(t, repo.Create(ctx, &bass.Resource{
+			Metadata:   bass.Metadata{UID: "uid1", PackageName: "test", APIVersion: "v1", ResourceType: "Foo", Name: "foo1"},
+			Properties: map[string]any{"n": 1.0},
+		}))
+
+		require.NoError(t, repo.Transact(ctx, []bass.Operation{
+			{Type: bass.OperationCreate, Item: &bass.Resource{Metadata: bass.Metadata{PackageName: "test", ResourceType: "Foo", Name: "foo2"}, Properties: map[string]any{}}},
+			{Type: bass.OperationUpdate, Item: &bass.Resource{Metadata: bass.Metadata{PackageName: "test", ResourceType: "Foo", Name: "foo1"}, Properties: map[string]any{"n": 2.0}}},
+		}))
+
+		require.NoError(t, db.Close())
+
+		repo = newBoltRepo(t, path)
+
+		item, err := repo.Get(ctx, "test", "Foo", "foo1")
+		require.NoError(t, err)
+
+		assert.Equal(t, "uid1", item.Metadata.UID)
+		assert.Equal(t, "3", item.Metadata.ResourceVersion)
+		assert.Equal(t, map[string]any{"n": 2.0}, item.Properties)
+
+		raw, err := repo.GetRaw(ctx, "test", "Foo", "foo1")
+		require.NoError(t, err)
+
+		assert.Contains(t, string(raw), `"n":2`)
+	}
+
+	// failed transactions write nothing
+	{
+		err := repo.Transact(ctx, []bass.Operation{
+			{Type: bass.OperationCreate, Item: &bass.Resource{Metadata: bass.Metadata{PackageName: "test", ResourceType: "Foo", Name: "foo3"}, Properties: map[string]any{}}},
+			{Type: bass.OperationCreate, Item: &bass.Resource{Metadata: bass.Metadata{PackageName: "test", ResourceType: "Foo", Name: "foo1"}, Properties: map[string]any{}}},
+		})
+
+		var transactionError bass.TransactionError
+
+		require.ErrorAs(t, err, &transactionError)
+		assert.Equal(t, 1, transactionError.Index)
+
+		var resourceExistsError bass.ResourceExistsError
+
+		require.ErrorAs(t, err, &resourceExistsError)
+
+		_, err = repo.Get(ctx, "test", "Foo", "foo3")
+
+		var resourceNotFoundError bass.ResourceNotFoundError
+
+		require.ErrorAs(t, err, &resourceNotFoundError)
+	}
+
+	// empty buckets are dropped
+	{
+		require.NoError(t, repo.Delete(ctx, "test", "Foo", "foo1"))
+		require.NoError(t, repo.Delete(ctx, "test", "Foo", "foo2"))
+
+		counts, err := repo.CountResources(ctx)
+		require.NoError(t, err)
+
+		assert.Empty(t, counts)
+	}
+}
+
+func TestBoltRepoModel(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	n := 0
+
+	checkRepositoryModel(t, func() bass.ResourcesRepository {
+		n++
+
+		return newBoltRepo(t, filepath.Join(dir, strconv.Itoa(n)+".db"))
+	})
+}
@@ -0,0 +1,404 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	ApprovalPolicy        = apiv1.ApprovalPolicy
+	ChangeRequestStatus   = apiv1.ChangeRequestStatus
+	ChangeRequest         = apiv1.ChangeRequest
+	ChangeRequestApproval = apiv1.ChangeRequestApproval
+	ApproveRequest        = apiv1.ApproveRequest
+)
+
+const (
+	ChangeRequestStatusPending = apiv1.ChangeRequestStatusPending
+	ChangeRequestStatusApplied = apiv1.ChangeRequestStatusApplied
+	ChangeRequestStatusFailed  = apiv1.ChangeRequestStatusFailed
+)
+
+// ChangeRequestNotifier is notified when a change request is created, approved, applied, or fails.
+type ChangeRequestNotifier interface {
+	NotifyChangeRequest(ctx context.Context, changeRequest *ChangeRequest)
+}
+
+type ApprovalRequiredError struct {
+	PackageName  string
+	ResourceType string
+}
+
+func (err ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("changes to resources of resource type %q and package %q require approval", err.ResourceType, err.PackageName)
+}
+
+func approvalRequired(err ApprovalRequiredError) problem.Problem {
+	return problem.CustomError(
+		problem.WithStatus(http.StatusForbidden),
+		problem.WithTitle("Forbidden"),
+		problem.WithDetail(err.Error()),
+		withReason(apierrors.ReasonApprovalRequired),
+	)
+}
+
+type ChangeRequestClosedError struct {
+	Name   string
+	Status ChangeRequestStatus
+}
+
+func (err ChangeRequestClosedError) Error() string {
+	return fmt.Sprintf("change request %q is %s", err.Name, err.Status)
+}
+
+func changeRequestResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "ChangeRequest.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "ChangeRequest",
+		Plural:       "ChangeRequests",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"operation": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"op":                 map[string]any{"type": "string", "enum": []any{"create", "update", "delete"}},
+								"packageName":        map[string]any{"type": "string"},
+								"apiVersion":         map[string]any{"type": "string"},
+								"resourceTypePlural": map[string]any{"type": "string"},
+								"name":               map[string]any{"type": "string"},
+								"labels":             map[string]any{"type": "object"},
+								"properties":         map[string]any{"type": "object"},
+								"precondition":       map[string]any{"type": "object"},
+							},
+							"required": []any{"op", "packageName", "apiVersion", "resourceTypePlural", "name"},
+						},
+						"requiredApprovals": map[string]any{"type": "integer", "minimum": 1},
+						"approvals": map[string]any{
+							"type": "array",
+							"items": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"approver": map[string]any{"type": "string"},
+									"time":     map[string]any{"type": "string"},
+								},
+								"required": []any{"approver", "time"},
+							},
+						},
+						"status": map[string]any{"type": "string", "enum": []any{"pending", "applied", "failed"}},
+						"error":  map[string]any{"type": "string"},
+					},
+					"required": []any{"operation", "requiredApprovals", "status"},
+				},
+			},
+		},
+	}
+}
+
+func approvalPolicyFromProperties(name string, properties map[string]any) (*ApprovalPolicy, error) {
+	value, ok := properties["approval"]
+	if !ok {
+		return nil, nil //nolint:nilnil // not protected
+	}
+
+	approvalMap, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("resource type %q has invalid approval property", name)
+	}
+
+	approvals, ok := approvalMap["approvals"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("resource type %q has invalid approvals property", name)
+	}
+
+	return &ApprovalPolicy{Approvals: int(approvals)}, nil
+}
+
+func changeRequestFromResource(item *Resource) (*ChangeRequest, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal change request properties: %w", err)
+	}
+
+	var changeRequest ChangeRequest
+
+	err = json.Unmarshal(b, &changeRequest)
+	if err != nil {
+		return nil, fmt.Errorf("change request %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	changeRequest.Metadata = item.Metadata
+
+	return &changeRequest, nil
+}
+
+// changeRequestItem returns a copy of item holding the change request as properties.
+func (h *Handler) changeRequestItem(item *Resource, changeRequest *ChangeRequest) (*Resource, error) {
+	b, err := json.Marshal(changeRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal change request: %w", err)
+	}
+
+	res := item.DeepCopy()
+	res.Properties = nil
+
+	err = json.Unmarshal(b, &res.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal change request properties: %w", err)
+	}
+
+	delete(res.Properties, "metadata")
+
+	res.Metadata.UpdatedAt = h.now()
+
+	return res, nil
+}
+
+func (h *Handler) notifyChangeRequest(ctx context.Context, item *Resource) {
+	if h.changeRequestNotifier == nil {
+		return
+	}
+
+	changeRequest, err := changeRequestFromResource(item)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to parse change request", "error", err)
+
+		return
+	}
+
+	h.changeRequestNotifier.NotifyChangeRequest(ctx, changeRequest)
+}
+
+// requestChange holds a write to an item of a protected resource type as a pending change request, and responds
+// with the change request.
+func (h *Handler) requestChange(w http.ResponseWriter, r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, opType OperationType, item *Resource, precondition Precondition) {
+	h.holdChange(w, r, resourceTypeDefinition.Approval.Approvals, nil, opType, item, precondition)
+}
+
+// holdChange holds a write as a pending change request needing the approvals, labeled with labels, and responds with
+// the change request.
+func (h *Handler) holdChange(w http.ResponseWriter, r *http.Request, requiredApprovals int, labels map[string]string, opType OperationType, item *Resource, precondition Precondition) {
+	now := h.now()
+
+	changeRequest := &ChangeRequest{
+		Metadata: Metadata{
+			UID:          uuid.NewString(),
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ChangeRequest",
+			Name:         uuid.NewString(),
+			Labels:       labels,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		Operation: TransactionOperation{
+			Op:                 opType,
+			PackageName:        r.PathValue("packageName"),
+			APIVersion:         r.PathValue("apiVersion"),
+			ResourceTypePlural: r.PathValue("resourceTypePlural"),
+			Name:               item.Metadata.Name,
+			Labels:             item.Metadata.Labels,
+			Properties:         item.Properties,
+			Precondition:       precondition,
+		},
+		RequiredApprovals: requiredApprovals,
+		Approvals:         nil,
+		Status:            ChangeRequestStatusPending,
+		Error:             "",
+	}
+
+	changeRequestItem, err := h.changeRequestItem(&Resource{Metadata: changeRequest.Metadata}, changeRequest)
+	if err == nil {
+		err = h.repo.Create(r.Context(), changeRequestItem)
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to create change request", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return
+	}
+
+	h.resourceWritten(r.Context(), OperationCreate, changeRequestItem)
+	h.notifyChangeRequest(r.Context(), changeRequestItem)
+
+	w.Header().Set("Location", h.resourcePath(corePackageName, "v1", "changerequests", changeRequestItem.Metadata.Name))
+	w.WriteHeader(http.StatusAccepted)
+	respond.Done(w, r, changeRequestItem)
+}
+
+// handleApproveChangeRequest records an approval of a pending change request. The approval that completes the
+// required approvals applies the change.
+func (h *Handler) handleApproveChangeRequest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		transactionalRepo, ok := h.repo.(TransactionalRepository)
+		if !ok {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("repository does not support transactions"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		var req ApproveRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		if req.Approver == "" {
+			respond.Done(w, r, problem.BadRequest("approval without approver", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		item, err := h.repoGet(r.Context(), corePackageName, "ChangeRequest", r.PathValue("name"))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get change request", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		item, ops, err := h.approveChangeRequest(r.Context(), transactionalRepo, item, req.Approver)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to approve change request", "error", err)
+
+			var (
+				changeRequestClosedError ChangeRequestClosedError
+				transactionError         TransactionError
+			)
+
+			switch {
+			case errors.As(err, &changeRequestClosedError):
+				respond.Done(w, r, problem.Conflict(changeRequestClosedError.Error(), withReason(apierrors.ReasonConflict)))
+			case errors.As(err, &transactionError):
+				respond.Done(w, r, problem.Conflict(transactionError.Error(), withReason(apierrors.ReasonConflict)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		for _, op := range ops {
+			h.resourceWritten(r.Context(), op.Type, op.Item)
+
+			if op.Type == OperationDelete {
+				h.deleteActivity(r.Context(), op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name)
+				h.deleteLocalizations(r.Context(), op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name)
+			}
+		}
+
+		if len(ops) > 0 {
+			h.notifyChangeRequest(r.Context(), item)
+		}
+
+		respond.Done(w, r, item)
+	}
+}
+
+// approveChangeRequest adds the approval to the change request item. Once the change request has the required
+// approvals, its operation is applied in the same transaction; if the operation fails, the change request is marked
+// failed instead. It returns the updated item and the applied operations, none if the approver already approved.
+func (h *Handler) approveChangeRequest(ctx context.Context, repo TransactionalRepository, item *Resource, approver string) (*Resource, []Operation, error) {
+	changeRequest, err := changeRequestFromResource(item)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if changeRequest.Status != ChangeRequestStatusPending {
+		return nil, nil, ChangeRequestClosedError{Name: item.Metadata.Name, Status: changeRequest.Status}
+	}
+
+	if slices.ContainsFunc(changeRequest.Approvals, func(approval ChangeRequestApproval) bool { return approval.Approver == approver }) {
+		return item, nil, nil
+	}
+
+	changeRequest.Approvals = append(changeRequest.Approvals, ChangeRequestApproval{Approver: approver, Time: h.now()})
+
+	ops := make([]Operation, 1, 2) //nolint:mnd // the change request and its operation
+
+	if len(changeRequest.Approvals) >= changeRequest.RequiredApprovals {
+		op, err := h.transactionOperation(ctx, changeRequest.Operation, true)
+		if err != nil {
+			changeRequest.Status = ChangeRequestStatusFailed
+			changeRequest.Error = err.Error()
+		} else {
+			ops = append(ops, op)
+			changeRequest.Status = ChangeRequestStatusApplied
+		}
+	}
+
+	for {
+		updated, err := h.changeRequestItem(item, changeRequest)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ops[0] = Operation{
+			Type:         OperationUpdate,
+			Item:         updated,
+			Precondition: Precondition{UID: "", ResourceVersion: item.Metadata.ResourceVersion},
+		}
+
+		err = repo.Transact(ctx, ops)
+
+		var transactionError TransactionError
+		if errors.As(err, &transactionError) && transactionError.Index > 0 {
+			changeRequest.Status = ChangeRequestStatusFailed
+			changeRequest.Error = transactionError.Err.Error()
+			ops = ops[:1]
+
+			continue
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to update change request: %w", err)
+		}
+
+		return updated, ops, nil
+	}
+}
@@ -0,0 +1,139 @@
+package bass
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type CloneRequest = apiv1.CloneRequest
+
+// handleCloneResource creates a copy of an item under a new name, optionally applying a JSON merge patch to it.
+func (h *Handler) handleCloneResource(w http.ResponseWriter, r *http.Request, name string) {
+	packageName := r.PathValue("packageName")
+	apiVersion := r.PathValue("apiVersion")
+	resourceTypePlural := r.PathValue("resourceTypePlural")
+
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return
+	}
+
+	var req CloneRequest
+
+	err = json.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+		default:
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+		}
+
+		return
+	}
+
+	if req.NewName == "" {
+		respond.Done(w, r, problem.BadRequest("clone without new name", withReason(apierrors.ReasonBadRequest)))
+
+		return
+	}
+
+	source, err := h.repoGet(r.Context(), packageName, resourceTypeDefinition.ResourceType, name)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource", "error", err)
+
+		var resourceNotFoundError ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &resourceNotFoundError):
+			h.resourceNotFound(w, r, resourceNotFoundError)
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	item := *source
+
+	if len(req.Patch) > 0 {
+		original, err := json.Marshal(source)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to marshal source item", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		modified, err := jsonpatch.MergePatch(original, req.Patch)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to apply merge patch", "error", err)
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		item = Resource{}
+
+		err = json.Unmarshal(modified, &item)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to unmarshal patched item", "error", err)
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+	}
+
+	if !h.validateResourceItem(w, r, resourceTypeDefinition, &item) {
+		return
+	}
+
+	item.Metadata = Metadata{
+		UID:             uuid.NewString(),
+		PackageName:     packageName,
+		APIVersion:      apiVersion,
+		ResourceType:    resourceTypeDefinition.ResourceType,
+		Name:            req.NewName,
+		Labels:          item.Metadata.Labels,
+		ResourceVersion: "",
+		CreatedAt:       h.now(),
+		UpdatedAt:       h.now(),
+	}
+
+	err = h.repo.Create(r.Context(), &item)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to create resource", "error", err)
+
+		var resourceExistsError ResourceExistsError
+
+		switch {
+		case errors.As(err, &resourceExistsError):
+			respond.Done(w, r, problem.Conflict(resourceExistsError.Error(), withReason(apierrors.ReasonAlreadyExists)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	w.Header().Set("Location", h.resourcePath(packageName, apiVersion, resourceTypePlural, item.Metadata.Name))
+	h.resourceWritten(r.Context(), OperationCreate, &item)
+
+	w.WriteHeader(http.StatusCreated)
+	respond.Done(w, r, item)
+}
@@ -2,20 +2,128 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/nasermirzaei89/bass"
+	"github.com/redis/go-redis/v9"
+	"go.etcd.io/bbolt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	// dbOpenTimeout bounds waiting for the lock of a database file another process holds.
+	dbOpenTimeout = 10 * time.Second
+	dbFileMode    = 0o600
+	// etcdDialTimeout bounds connecting to the etcd cluster.
+	etcdDialTimeout = 10 * time.Second
 )
 
 func main() {
-	repo := bass.NewMemRepo()
-	h := bass.NewHandler(repo)
+	logLevel := new(slog.LevelVar)
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
 
-	err := http.ListenAndServe(":8080", h) //nolint:gosec
+	repo, err := newRepo()
 	if err != nil {
-		slog.ErrorContext(context.Background(), "error on listen and serve http", "error", err)
+		logger.ErrorContext(context.Background(), "failed to open repository", "error", err)
 		os.Exit(1)
 	}
+
+	h := bass.NewHandler(repo, bass.WithLogger(logger), bass.WithLogLevelVar(logLevel))
+
+	// Reload the server config on SIGHUP, to pick up changes written through other replicas.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	go func() {
+		for range reload {
+			err := h.ReloadServerConfig(context.Background())
+			if err != nil {
+				logger.ErrorContext(context.Background(), "failed to reload server config", "error", err)
+			}
+		}
+	}()
+
+	// Serve HTTP/2 over TLS when BASS_TLS_CERT_FILE and BASS_TLS_KEY_FILE are set, negotiated with ALPN, and h2c (HTTP/2
+	// without TLS) otherwise, for plaintext internal deployments.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	server := &http.Server{
+		Addr:              ":8080",
+		Handler:           h,
+		Protocols:         protocols,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	certFile, keyFile := os.Getenv("BASS_TLS_CERT_FILE"), os.Getenv("BASS_TLS_KEY_FILE")
+	if certFile != "" && keyFile != "" {
+		err = server.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if err != nil {
+		logger.ErrorContext(context.Background(), "error on listen and serve http", "error", err)
+		os.Exit(1)
+	}
+}
+
+// newRepo returns the repository of the server: a bbolt file at BASS_DB_PATH, Redis at BASS_REDIS_URL, with resources
+// expiring after BASS_REDIS_TTL if set, etcd at the comma separated BASS_ETCD_ENDPOINTS, or else memory.
+func newRepo() (bass.ResourcesRepository, error) { //nolint:ireturn // the repository is configured at runtime
+	if path := os.Getenv("BASS_DB_PATH"); path != "" {
+		db, err := bbolt.Open(path, dbFileMode, &bbolt.Options{Timeout: dbOpenTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database %q: %w", path, err)
+		}
+
+		// The server runs until the process exits, which releases the database. Its writes are committed already.
+		repo, err := bass.NewBoltRepo(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open bolt repository: %w", err)
+		}
+
+		return repo, nil
+	}
+
+	if redisURL := os.Getenv("BASS_REDIS_URL"); redisURL != "" {
+		options, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse redis url: %w", err)
+		}
+
+		var opts []bass.RedisRepoOption
+
+		if value := os.Getenv("BASS_REDIS_TTL"); value != "" {
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse redis ttl: %w", err)
+			}
+
+			opts = append(opts, bass.WithRedisRepoTTL(ttl))
+		}
+
+		return bass.NewRedisRepo(redis.NewClient(options), opts...), nil
+	}
+
+	if endpoints := os.Getenv("BASS_ETCD_ENDPOINTS"); endpoints != "" {
+		client, err := clientv3.New(clientv3.Config{Endpoints: strings.Split(endpoints, ","), DialTimeout: etcdDialTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create etcd client: %w", err)
+		}
+
+		return bass.NewEtcdRepo(client), nil
+	}
+
+	return bass.NewMemRepo(), nil
 }
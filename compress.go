@@ -0,0 +1,117 @@
+package bass
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingZstd = "zstd"
+	encodingGzip = "gzip"
+)
+
+// compressResponse compresses the response of next with zstd or gzip, as negotiated by the Accept-Encoding header
+// of the request. zstd is preferred when the client accepts both equally.
+func (h *Handler) compressResponse(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.featureEnabled(FeatureCompression) {
+			next(w, r)
+
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+
+		var (
+			cw  io.WriteCloser
+			err error
+		)
+
+		switch encoding {
+		case encodingZstd:
+			cw, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		case encodingGzip:
+			cw = gzip.NewWriter(w)
+		default:
+			next(w, r)
+
+			return
+		}
+
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to create compressing writer", "error", err)
+			next(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Del("Content-Length")
+
+		next(&compressResponseWriter{ResponseWriter: w, writer: cw}, r)
+
+		err = cw.Close()
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to close compressing writer", "error", err)
+		}
+	}
+}
+
+type compressResponseWriter struct {
+	http.ResponseWriter
+
+	writer io.Writer
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	return w.writer.Write(b) //nolint:wrapcheck
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// negotiateEncoding picks the supported content coding with the highest quality in the Accept-Encoding header, or
+// an empty string for identity.
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		quality := 1.0
+
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				quality = parsed
+			}
+		}
+
+		qualities[coding] = quality
+	}
+
+	best := ""
+	bestQuality := 0.0
+
+	for _, coding := range []string{encodingZstd, encodingGzip} {
+		quality, ok := qualities[coding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+
+		if ok && quality > bestQuality {
+			best = coding
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,81 @@
+package bass
+
+import (
+	"cmp"
+	"maps"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	DebugState = apiv1.DebugState
+	DebugView  = apiv1.DebugView
+	DebugQueue = apiv1.DebugQueue
+)
+
+func (cache *schemaDefinitionCache) names() []string {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(cache.schemas))
+}
+
+func (cache *viewCache) debugViews() []DebugView {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	views := make([]DebugView, 0, len(cache.views))
+	for name, m := range cache.views {
+		views = append(views, DebugView{Name: name, Rows: len(m.rows)})
+	}
+
+	slices.SortFunc(views, func(a, b DebugView) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return views
+}
+
+func (hub *watchHub) count() int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	return len(hub.subscribers)
+}
+
+// registerDebugRoutes serves the profiles of net/http/pprof on "/debug/pprof/", and the in-memory state of the
+// server on "GET /debug/state".
+func (h *Handler) registerDebugRoutes() {
+	// The index serves named profiles by the path below "/debug/pprof/", so it needs it without the base path.
+	h.mux.Handle("GET "+h.basePath+"/debug/pprof/", h.withNetworkPolicy(http.StripPrefix(h.basePath, http.HandlerFunc(pprof.Index))))
+	h.mux.Handle("GET "+h.basePath+"/debug/pprof/cmdline", h.withNetworkPolicy(http.HandlerFunc(pprof.Cmdline)))
+	h.mux.Handle("GET "+h.basePath+"/debug/pprof/profile", h.withNetworkPolicy(http.HandlerFunc(pprof.Profile)))
+	h.mux.Handle("GET "+h.basePath+"/debug/pprof/symbol", h.withNetworkPolicy(http.HandlerFunc(pprof.Symbol)))
+	h.mux.Handle("GET "+h.basePath+"/debug/pprof/trace", h.withNetworkPolicy(http.HandlerFunc(pprof.Trace)))
+	h.mux.Handle("GET "+h.basePath+"/debug/state", h.withNetworkPolicy(h.handleDebugState()))
+}
+
+func (h *Handler) handleDebugState() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var memStats runtime.MemStats
+
+		runtime.ReadMemStats(&memStats)
+
+		respond.Done(w, r, DebugState{
+			Goroutines:        runtime.NumGoroutine(),
+			HeapAllocBytes:    memStats.HeapAlloc,
+			GCCycles:          memStats.NumGC,
+			SchemaDefinitions: h.schemaDefinitions.names(),
+			Views:             h.views.debugViews(),
+			WatchSubscribers:  h.watchHub.count(),
+			Queues: map[string]DebugQueue{
+				"replication": {Length: len(h.replicationQueue), Capacity: cap(h.replicationQueue)},
+			},
+		})
+	}
+}
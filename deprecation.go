@@ -0,0 +1,102 @@
+package bass
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of deprecated usage counted by the metrics.
+const (
+	deprecatedUsageAPIVersion = "apiVersion"
+	deprecatedUsageProperty   = "property"
+)
+
+// warningHeaderValue formats a miscellaneous persistent warning (code 299) of an RFC 7234 Warning header.
+func warningHeaderValue(text string) string {
+	return `299 - "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+// withDeprecationWarnings sets Deprecation, Sunset, and Warning headers on requests using a deprecated version of a
+// resource type. Failures to resolve the resource type are left to next.
+func (h *Handler) withDeprecationWarnings(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), r.PathValue("packageName"), r.PathValue("resourceTypePlural"))
+		if err != nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		apiVersion := r.PathValue("apiVersion")
+
+		i := slices.IndexFunc(resourceTypeDefinition.Versions, func(version ResourceTypeDefinitionVersion) bool {
+			return version.Name == apiVersion
+		})
+		if i < 0 || !resourceTypeDefinition.Versions[i].Deprecated {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		version := resourceTypeDefinition.Versions[i]
+
+		text := fmt.Sprintf("%s of %s/%s is deprecated", apiVersion, resourceTypeDefinition.Package, resourceTypeDefinition.Plural)
+		if version.DeprecationWarning != "" {
+			text += ": " + version.DeprecationWarning
+		}
+
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Warning", warningHeaderValue(text))
+
+		sunset, err := time.Parse(time.RFC3339, version.Sunset)
+		if err == nil {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+
+		h.metrics.recordDeprecatedUsage(resourceTypeDefinition.Package, resourceTypeDefinition.ResourceType, deprecatedUsageAPIVersion, apiVersion)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// warnDeprecatedProperties sets a Warning header for every property of the item its schema declares deprecated.
+func (h *Handler) warnDeprecatedProperties(w http.ResponseWriter, resourceTypeDefinition *ResourceTypeDefinition, item *Resource) {
+	for _, path := range deprecatedProperties(resourceTypeDefinition.Versions[0].Schema, item.Properties, "/properties") {
+		w.Header().Add("Warning", warningHeaderValue(fmt.Sprintf("property %s is deprecated", path)))
+		h.metrics.recordDeprecatedUsage(resourceTypeDefinition.Package, resourceTypeDefinition.ResourceType, deprecatedUsageProperty, path)
+	}
+}
+
+// deprecatedProperties returns the paths of the properties of object, and of nested objects, that have
+// "deprecated": true in schema, in order.
+func deprecatedProperties(schema map[string]any, object map[string]any, prefix string) []string {
+	declared, ok := schema["properties"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var paths []string
+
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		propertySchema, ok := declared[key].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		path := prefix + "/" + escapeJSONPointer(key)
+
+		if deprecated, _ := propertySchema["deprecated"].(bool); deprecated {
+			paths = append(paths, path)
+		}
+
+		if nested, ok := object[key].(map[string]any); ok {
+			paths = append(paths, deprecatedProperties(propertySchema, nested, path)...)
+		}
+	}
+
+	return paths
+}
@@ -0,0 +1,532 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	Draft            = apiv1.Draft
+	PublishedVersion = apiv1.PublishedVersion
+)
+
+const (
+	// draftParentLabel labels drafts with the item they are a draft of.
+	draftParentLabel = "bass.draft/parent"
+	// publishedVersionParentLabel labels published versions with their item.
+	publishedVersionParentLabel = "bass.publishedversion/parent"
+)
+
+type DraftsDisabledError struct {
+	PackageName  string
+	ResourceType string
+}
+
+func (err DraftsDisabledError) Error() string {
+	return fmt.Sprintf("resource type %q of package %q doesn't have drafts", err.ResourceType, err.PackageName)
+}
+
+func draftResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "Draft.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "Draft",
+		Plural:       "Drafts",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"parent":     resourceReferenceSchema(),
+						"labels":     map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+						"properties": map[string]any{"type": "object"},
+					},
+					"required": []any{"parent"},
+				},
+			},
+		},
+	}
+}
+
+func publishedVersionResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "PublishedVersion.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "PublishedVersion",
+		Plural:       "PublishedVersions",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"parent":     resourceReferenceSchema(),
+						"version":    map[string]any{"type": "integer", "minimum": 1},
+						"labels":     map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+						"properties": map[string]any{"type": "object"},
+					},
+					"required": []any{"parent", "version"},
+				},
+			},
+		},
+	}
+}
+
+// draftName names the draft of an item. Each item has at most one draft.
+func draftName(packageName, resourceType, name string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(resourceLabelValue(packageName, resourceType, name))).String()
+}
+
+// publishedVersionName names a published version of an item, so publishing the same version twice conflicts.
+func publishedVersionName(packageName, resourceType, name string, version int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(resourceLabelValue(packageName, resourceType, name)+"/"+strconv.Itoa(version))).String()
+}
+
+func draftFromResource(item *Resource) (*Draft, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal draft properties: %w", err)
+	}
+
+	var draft Draft
+
+	err = json.Unmarshal(b, &draft)
+	if err != nil {
+		return nil, fmt.Errorf("draft %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	draft.Metadata = item.Metadata
+
+	return &draft, nil
+}
+
+// getDraftsResourceTypeDefinition resolves the resource type of a draft request. It responds with the failure and
+// returns nil if the resource type doesn't have drafts.
+func (h *Handler) getDraftsResourceTypeDefinition(w http.ResponseWriter, r *http.Request) *ResourceTypeDefinition {
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), r.PathValue("packageName"), r.PathValue("resourceTypePlural"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return nil
+	}
+
+	if !resourceTypeDefinition.Drafts {
+		respond.Done(w, r, problem.BadRequest(DraftsDisabledError{
+			PackageName:  resourceTypeDefinition.Package,
+			ResourceType: resourceTypeDefinition.ResourceType,
+		}.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return nil
+	}
+
+	return resourceTypeDefinition
+}
+
+func (h *Handler) handleGetDraft() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resourceTypeDefinition := h.getDraftsResourceTypeDefinition(w, r)
+		if resourceTypeDefinition == nil {
+			return
+		}
+
+		item, err := h.repoGet(r.Context(), corePackageName, "Draft", draftName(r.PathValue("packageName"), resourceTypeDefinition.ResourceType, r.PathValue("name")))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get draft", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		if !privilegedRead(r) {
+			item = anonymizedSnapshot(resourceTypeDefinition, item)
+		}
+
+		respond.Done(w, r, item)
+	}
+}
+
+// handlePutDraft creates or replaces the draft of an item. The body is the item as it should be published. Drafts are
+// only validated against the resource type schema on publishing.
+func (h *Handler) handlePutDraft() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := r.PathValue("packageName")
+		name := r.PathValue("name")
+
+		resourceTypeDefinition := h.getDraftsResourceTypeDefinition(w, r)
+		if resourceTypeDefinition == nil {
+			return
+		}
+
+		var body Resource
+
+		err := json.UnmarshalDecode(jsontext.NewDecoder(r.Body), &body)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		properties := map[string]any{
+			"parent": map[string]any{
+				"packageName":  packageName,
+				"resourceType": resourceTypeDefinition.ResourceType,
+				"name":         name,
+			},
+		}
+
+		if body.Metadata.Labels != nil {
+			labels := make(map[string]any, len(body.Metadata.Labels))
+			for key, value := range body.Metadata.Labels {
+				labels[key] = value
+			}
+
+			properties["labels"] = labels
+		}
+
+		if body.Properties != nil {
+			properties["properties"] = body.Properties
+		}
+
+		item := Resource{
+			Metadata: Metadata{
+				UID:          uuid.NewString(),
+				PackageName:  corePackageName,
+				APIVersion:   "v1",
+				ResourceType: "Draft",
+				Name:         draftName(packageName, resourceTypeDefinition.ResourceType, name),
+				Labels: map[string]string{
+					draftParentLabel: resourceLabelValue(packageName, resourceTypeDefinition.ResourceType, name),
+				},
+				ResourceVersion: body.Metadata.ResourceVersion,
+				CreatedAt:       h.now(),
+				UpdatedAt:       h.now(),
+			},
+			Properties: properties,
+		}
+
+		if !h.validateResourceItem(w, r, draftResourceTypeDefinition(), &item) {
+			return
+		}
+
+		operation := OperationUpdate
+
+		err = h.repo.Update(r.Context(), &item)
+
+		var resourceNotFoundError ResourceNotFoundError
+		if errors.As(err, &resourceNotFoundError) && item.Metadata.ResourceVersion == "" {
+			operation = OperationCreate
+			err = h.repo.Create(r.Context(), &item)
+		}
+
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to write draft", "error", err)
+
+			var (
+				resourceExistsError          ResourceExistsError
+				resourceVersionConflictError ResourceVersionConflictError
+			)
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			case errors.As(err, &resourceExistsError):
+				respond.Done(w, r, problem.Conflict(resourceExistsError.Error(), withReason(apierrors.ReasonAlreadyExists)))
+			case errors.As(err, &resourceVersionConflictError):
+				respond.Done(w, r, problem.Conflict(resourceVersionConflictError.Error(), withReason(apierrors.ReasonConflict)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		h.resourceWritten(r.Context(), operation, &item)
+
+		if operation == OperationCreate {
+			w.WriteHeader(http.StatusCreated)
+		}
+
+		respond.Done(w, r, item)
+	}
+}
+
+func (h *Handler) handleDeleteDraft() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resourceTypeDefinition := h.getDraftsResourceTypeDefinition(w, r)
+		if resourceTypeDefinition == nil {
+			return
+		}
+
+		item := &Resource{
+			Metadata: Metadata{
+				PackageName:  corePackageName,
+				APIVersion:   "v1",
+				ResourceType: "Draft",
+				Name:         draftName(r.PathValue("packageName"), resourceTypeDefinition.ResourceType, r.PathValue("name")),
+			},
+			Properties: nil,
+		}
+
+		err := h.repoDelete(r.Context(), corePackageName, "Draft", item.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to delete draft", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		h.resourceWritten(r.Context(), OperationDelete, item)
+
+		respond.Done(w, r, nil)
+	}
+}
+
+// handleListPublishedVersions lists the published versions of an item, oldest first.
+func (h *Handler) handleListPublishedVersions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resourceTypeDefinition := h.getDraftsResourceTypeDefinition(w, r)
+		if resourceTypeDefinition == nil {
+			return
+		}
+
+		res, err := h.listPublishedVersions(r.Context(), r.PathValue("packageName"), resourceTypeDefinition.ResourceType, r.PathValue("name"))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to list published versions", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		if !privilegedRead(r) {
+			for i, item := range res.Items {
+				res.Items[i] = anonymizedSnapshot(resourceTypeDefinition, item)
+			}
+		}
+
+		respond.Done(w, r, res)
+	}
+}
+
+func (h *Handler) listPublishedVersions(ctx context.Context, packageName, resourceType, name string) (ResourceList, error) {
+	res, err := h.repoListByLabels(ctx, corePackageName, "v1", "PublishedVersion", map[string]string{
+		publishedVersionParentLabel: resourceLabelValue(packageName, resourceType, name),
+	})
+	if err != nil {
+		return res, err
+	}
+
+	slices.SortStableFunc(res.Items, func(a, b *Resource) int {
+		versionA, _ := a.Properties["version"].(float64)
+		versionB, _ := b.Properties["version"].(float64)
+
+		return int(versionA - versionB)
+	})
+
+	return res, nil
+}
+
+// handlePublishResource promotes the draft of an item. In one transaction, the item is created or replaced with the
+// draft, the draft is deleted, and a new published version is recorded.
+func (h *Handler) handlePublishResource(w http.ResponseWriter, r *http.Request, name string) {
+	transactionalRepo, ok := h.repo.(TransactionalRepository)
+	if !ok {
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusNotImplemented),
+			problem.WithTitle("Not Implemented"),
+			problem.WithDetail("repository does not support transactions"),
+			withReason(apierrors.ReasonNotImplemented),
+		))
+
+		return
+	}
+
+	resourceTypeDefinition := h.getDraftsResourceTypeDefinition(w, r)
+	if resourceTypeDefinition == nil {
+		return
+	}
+
+	ops, err := h.publishOperations(r, resourceTypeDefinition, name)
+	if err == nil {
+		err = transactionalRepo.Transact(r.Context(), ops)
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to publish resource", "error", err)
+
+		var (
+			resourceNotFoundError   ResourceNotFoundError
+			transactionError        TransactionError
+			unknownFieldsError      UnknownFieldsError
+			resourceInvalidError    ResourceInvalidError
+			stateTransitionError    StateTransitionError
+			preconditionFailedError PreconditionFailedError
+		)
+
+		switch {
+		case errors.As(err, &unknownFieldsError):
+			respond.Done(w, r, problem.BadRequest(unknownFieldsError.Error(), problem.WithExtension("fields", unknownFieldsError.Fields), withReason(apierrors.ReasonUnknownFields)))
+		case errors.As(err, &resourceInvalidError):
+			respond.Done(w, r, problem.BadRequest(resourceInvalidError.Error(), problem.WithExtension("errors", resourceInvalidError.Errors), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &stateTransitionError):
+			respond.Done(w, r, stateTransitionInvalid(stateTransitionError))
+		case errors.As(err, &transactionError), errors.As(err, &preconditionFailedError):
+			respond.Done(w, r, problem.Conflict(err.Error(), withReason(apierrors.ReasonConflict)))
+		case errors.As(err, &resourceNotFoundError):
+			respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	for _, op := range ops {
+		h.resourceWritten(r.Context(), op.Type, op.Item)
+	}
+
+	respond.Done(w, r, ops[0].Item)
+}
+
+// publishOperations builds the transaction publishing the draft of an item.
+func (h *Handler) publishOperations(r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, name string) ([]Operation, error) {
+	packageName := r.PathValue("packageName")
+	resourceType := resourceTypeDefinition.ResourceType
+
+	draftItem, err := h.repoGet(r.Context(), corePackageName, "Draft", draftName(packageName, resourceType, name))
+	if err != nil {
+		return nil, err
+	}
+
+	draft, err := draftFromResource(draftItem)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &Resource{
+		Metadata: Metadata{
+			UID:          uuid.NewString(),
+			PackageName:  packageName,
+			APIVersion:   r.PathValue("apiVersion"),
+			ResourceType: resourceType,
+			Name:         name,
+			Labels:       draft.Labels,
+			CreatedAt:    h.now(),
+			UpdatedAt:    h.now(),
+		},
+		Properties: draft.Properties,
+	}
+
+	err = h.checkResourceItem(r.Context(), resourceTypeDefinition, item)
+	if err != nil {
+		return nil, err
+	}
+
+	op := Operation{Type: OperationCreate, Item: item, Precondition: Precondition{UID: "", ResourceVersion: ""}}
+
+	current, err := h.repoGet(r.Context(), packageName, resourceType, name)
+
+	var resourceNotFoundError ResourceNotFoundError
+
+	switch {
+	case err == nil:
+		err = checkTransition(resourceTypeDefinition, current, item)
+		if err != nil {
+			return nil, err
+		}
+
+		op = Operation{Type: OperationUpdate, Item: item, Precondition: Precondition{UID: "", ResourceVersion: current.Metadata.ResourceVersion}}
+	case !errors.As(err, &resourceNotFoundError):
+		return nil, err
+	}
+
+	versions, err := h.listPublishedVersions(r.Context(), packageName, resourceType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	version := len(versions.Items) + 1
+
+	versionProperties := map[string]any{
+		"parent":  draftItem.Properties["parent"],
+		"version": version,
+	}
+
+	if item.Metadata.Labels != nil {
+		versionProperties["labels"] = draftItem.Properties["labels"]
+	}
+
+	if item.Properties != nil {
+		versionProperties["properties"] = item.Properties
+	}
+
+	versionItem := &Resource{
+		Metadata: Metadata{
+			UID:          uuid.NewString(),
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "PublishedVersion",
+			Name:         publishedVersionName(packageName, resourceType, name, version),
+			Labels: map[string]string{
+				publishedVersionParentLabel: resourceLabelValue(packageName, resourceType, name),
+			},
+			CreatedAt: h.now(),
+			UpdatedAt: h.now(),
+		},
+		Properties: versionProperties,
+	}
+
+	return []Operation{
+		op,
+		{Type: OperationCreate, Item: versionItem, Precondition: Precondition{UID: "", ResourceVersion: ""}},
+		{Type: OperationDelete, Item: draftItem, Precondition: Precondition{UID: draftItem.Metadata.UID, ResourceVersion: draftItem.Metadata.ResourceVersion}},
+	}, nil
+}
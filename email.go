@@ -0,0 +1,412 @@
+package bass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	EmailTemplate    = apiv1.EmailTemplate
+	EmailSendRequest = apiv1.EmailSendRequest
+)
+
+// Email is a rendered email, ready to be sent.
+type Email struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text,omitempty"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// EmailSender delivers emails, e.g. over SMTP or through the API of an email provider.
+type EmailSender interface {
+	SendEmail(ctx context.Context, email Email) error
+}
+
+func emailTemplateResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "EmailTemplate.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "EmailTemplate",
+		Plural:       "EmailTemplates",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"subject": map[string]any{"type": "string", "minLength": 1},
+						"text":    map[string]any{"type": "string"},
+						"html":    map[string]any{"type": "string"},
+						"to":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						"trigger": resourceTriggerSchema(),
+					},
+					"required": []any{"subject"},
+				},
+			},
+		},
+	}
+}
+
+func emailTemplateFromResource(item *Resource) (*EmailTemplate, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal email template properties: %w", err)
+	}
+
+	var emailTemplate EmailTemplate
+
+	err = json.Unmarshal(b, &emailTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("email template %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	emailTemplate.Metadata = item.Metadata
+
+	return &emailTemplate, nil
+}
+
+// checkEmailTemplate parses the templates of an email template item.
+func checkEmailTemplate(item *Resource) error {
+	emailTemplate, err := emailTemplateFromResource(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = renderEmail(emailTemplate, nil, nil)
+
+	return templateParseError(err)
+}
+
+// renderEmail executes the templates of an email template with data. Recipients are rendered from the template unless
+// given.
+func renderEmail(emailTemplate *EmailTemplate, to []string, data any) (Email, error) {
+	email := Email{To: to, Subject: "", Text: "", HTML: ""}
+
+	var err error
+
+	email.Subject, err = executeTextTemplate("subject", emailTemplate.Subject, data)
+	if err != nil {
+		return Email{}, err
+	}
+
+	email.Text, err = executeTextTemplate("text", emailTemplate.Text, data)
+	if err != nil {
+		return Email{}, err
+	}
+
+	if to == nil {
+		for _, text := range emailTemplate.To {
+			address, err := executeTextTemplate("to", text, data)
+			if err != nil {
+				return Email{}, err
+			}
+
+			email.To = append(email.To, address)
+		}
+	}
+
+	t, err := htmltemplate.New("html").Option("missingkey=zero").Parse(emailTemplate.HTML)
+	if err != nil {
+		return Email{}, TemplateError{Field: "html", Err: fmt.Errorf("%w: %w", errTemplateParse, err)}
+	}
+
+	var buf strings.Builder
+
+	err = t.Execute(&buf, data)
+	if err != nil {
+		return Email{}, TemplateError{Field: "html", Err: err}
+	}
+
+	email.HTML = buf.String()
+
+	return email, nil
+}
+
+// triggerEmails sends the emails of the templates triggered by a write. Failures are logged.
+func (h *Handler) triggerEmails(ctx context.Context, operation OperationType, item *Resource) {
+	list, err := h.repoList(ctx, corePackageName, "v1", "EmailTemplate")
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list email templates", "error", err)
+
+		return
+	}
+
+	var data map[string]any
+
+	for _, templateItem := range list.Items {
+		emailTemplate, err := emailTemplateFromResource(templateItem)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to parse email template", "error", err)
+
+			continue
+		}
+
+		if !triggerMatches(emailTemplate.Trigger, operation, item) {
+			continue
+		}
+
+		if data == nil {
+			data, err = triggerData(operation, item)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to prepare email template data", "error", err)
+
+				return
+			}
+		}
+
+		email, err := renderEmail(emailTemplate, nil, data)
+		if err == nil {
+			err = h.emailSender.SendEmail(ctx, email)
+		}
+
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to send triggered email", "template", templateItem.Metadata.Name, "error", err)
+		}
+	}
+}
+
+// handleSendEmail sends an email rendered from a template with the data of the request.
+func (h *Handler) handleSendEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.emailSender == nil {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("no email sender is configured"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		var req EmailSendRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		item, err := h.repoGet(r.Context(), corePackageName, "EmailTemplate", r.PathValue("name"))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get email template", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		emailTemplate, err := emailTemplateFromResource(item)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to parse email template", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		email, err := renderEmail(emailTemplate, req.To, req.Data)
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		if len(email.To) == 0 {
+			respond.Done(w, r, problem.BadRequest("email without recipients", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		err = h.emailSender.SendEmail(r.Context(), email)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to send email", "error", err)
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusBadGateway),
+				problem.WithTitle("Bad Gateway"),
+				problem.WithDetail(err.Error()),
+				withReason(apierrors.ReasonDeliveryFailed),
+			))
+
+			return
+		}
+
+		respond.Done(w, r, nil)
+	}
+}
+
+// SMTPEmailSender sends emails through an SMTP server.
+type SMTPEmailSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+var _ EmailSender = (*SMTPEmailSender)(nil)
+
+// NewSMTPEmailSender returns a sender using the SMTP server at addr, as "host:port", with auth, which may be nil.
+func NewSMTPEmailSender(addr string, auth smtp.Auth, from string) *SMTPEmailSender {
+	return &SMTPEmailSender{addr: addr, auth: auth, from: from}
+}
+
+func (s *SMTPEmailSender) SendEmail(_ context.Context, email Email) error {
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	to := make([]string, 0, len(email.To))
+
+	for _, address := range email.To {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return fmt.Errorf("invalid recipient address %q: %w", address, err)
+		}
+
+		to = append(to, parsed.Address)
+	}
+
+	message, err := smtpMessage(from.String(), email)
+	if err != nil {
+		return err
+	}
+
+	err = smtp.SendMail(s.addr, s.auth, from.Address, to, message)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// smtpMessage encodes an email as a MIME message, with a text and an HTML alternative if both are given.
+func smtpMessage(from string, email Email) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(email.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if email.Text == "" || email.HTML == "" {
+		contentType, body := "text/plain", email.Text
+		if email.HTML != "" {
+			contentType, body = "text/html", email.HTML
+		}
+
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n\r\n%s", contentType, body)
+
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, body string }{{"text/plain", email.Text}, {"text/html", email.HTML}} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType + "; charset=utf-8"}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create message part: %w", err)
+		}
+
+		_, err = pw.Write([]byte(part.body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to write message part: %w", err)
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to close message: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// HTTPEmailSender sends emails through the HTTP API of an email provider. It posts each email as JSON, with from,
+// to, subject, text, and html fields, authenticated with a bearer API key.
+type HTTPEmailSender struct {
+	endpoint string
+	apiKey   string
+	from     string
+	client   *http.Client
+}
+
+var _ EmailSender = (*HTTPEmailSender)(nil)
+
+func NewHTTPEmailSender(endpoint, apiKey, from string) *HTTPEmailSender {
+	return &HTTPEmailSender{endpoint: endpoint, apiKey: apiKey, from: from, client: http.DefaultClient}
+}
+
+func (s *HTTPEmailSender) SendEmail(ctx context.Context, email Email) error {
+	body, err := json.Marshal(struct {
+		Email
+
+		From string `json:"from"`
+	}{Email: email, From: s.from})
+	if err != nil {
+		return fmt.Errorf("failed to marshal email: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("failed to send email: provider responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package bass
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	ErasureAction = apiv1.ErasureAction
+	ErasureReport = apiv1.ErasureReport
+)
+
+const (
+	ErasureActionDelete    = apiv1.ErasureActionDelete
+	ErasureActionAnonymize = apiv1.ErasureActionAnonymize
+)
+
+func erasureReportResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "ErasureReport.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "ErasureReport",
+		Plural:       "ErasureReports",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"subjectHash": map[string]any{"type": "string"},
+						"deleted":     map[string]any{"type": "array", "items": resourceReferenceSchema()},
+						"anonymized":  map[string]any{"type": "array", "items": resourceReferenceSchema()},
+						"held":        map[string]any{"type": "array", "items": resourceReferenceSchema()},
+						"error":       map[string]any{"type": "string"},
+					},
+					"required": []any{"subjectHash", "deleted", "anonymized", "held"},
+				},
+			},
+		},
+	}
+}
+
+// dataSubjectProperties returns the properties of schema identifying the data subject of items, marked by
+// "x-data-subject": true, in order.
+func dataSubjectProperties(schema map[string]any) []string {
+	declared, ok := schema["properties"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var properties []string
+
+	for _, key := range slices.Sorted(maps.Keys(declared)) {
+		propertySchema, ok := declared[key].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if dataSubject, _ := propertySchema["x-data-subject"].(bool); dataSubject {
+			properties = append(properties, key)
+		}
+	}
+
+	return properties
+}
+
+// linkedToSubject reports whether any of the data subject properties of item is subject. Numbers are compared in
+// their shortest decimal form, like numeric user IDs.
+func linkedToSubject(item *Resource, properties []string, subject string) bool {
+	return slices.ContainsFunc(properties, func(property string) bool {
+		switch value := item.Properties[property].(type) {
+		case string:
+			return value == subject
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64) == subject
+		default:
+			return false
+		}
+	})
+}
+
+// handleErase erases the items of all packages linked to the data subject of the "subject" query parameter, by
+// deleting or anonymizing them as their resource types declare, and responds with the erasure report. Items under
+// legal hold are kept as they are, and reported as held. Erased items are redacted from the revision history and the
+// event log too.
+func (h *Handler) handleErase() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subject := r.URL.Query().Get("subject")
+		if subject == "" {
+			respond.Done(w, r, problem.BadRequest("subject query parameter is required", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		item, err := h.eraseSubject(r.Context(), subject)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to erase data subject", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		w.Header().Set("Location", h.resourcePath(corePackageName, "v1", "erasurereports", item.Metadata.Name))
+		w.WriteHeader(http.StatusCreated)
+		respond.Done(w, r, item)
+	}
+}
+
+// eraseSubject erases the items linked to a data subject, and writes the erasure report. The report is written if the
+// erasure fails partway too, recording the items erased before it failed and why it failed.
+func (h *Handler) eraseSubject(ctx context.Context, subject string) (*Resource, error) {
+	ctx = contextWithWriteOrigin(ctx, originErasure)
+
+	erased, eraseErr := h.eraseSubjectItems(ctx, subject)
+
+	hash := sha256.Sum256([]byte(subject))
+	now := h.now()
+	uid := uuid.NewString()
+
+	properties := map[string]any{
+		"subjectHash": hex.EncodeToString(hash[:]),
+		"deleted":     erased.deleted,
+		"anonymized":  erased.anonymized,
+		"held":        erased.held,
+	}
+
+	if eraseErr != nil {
+		properties["error"] = eraseErr.Error()
+	}
+
+	report := &Resource{
+		Metadata: Metadata{
+			UID:          uid,
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ErasureReport",
+			Name:         uid,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		Properties: properties,
+	}
+
+	err := h.repo.Create(ctx, report)
+	if err != nil {
+		return nil, errors.Join(eraseErr, fmt.Errorf("failed to create erasure report: %w", err))
+	}
+
+	h.resourceWritten(ctx, OperationCreate, report)
+
+	// The report is the audit record of the erasure, and is logged too.
+	if eraseErr != nil {
+		h.logger.ErrorContext(ctx, "failed to erase data subject", "report", report.Metadata.Name, "deleted", len(erased.deleted), "anonymized", len(erased.anonymized), "held", len(erased.held), "error", eraseErr)
+
+		return nil, eraseErr
+	}
+
+	h.logger.InfoContext(ctx, "erased data subject", "report", report.Metadata.Name, "deleted", len(erased.deleted), "anonymized", len(erased.anonymized), "held", len(erased.held))
+
+	return report, nil
+}
+
+// erasedItems are the references of the items of an erasure, by how they were erased.
+type erasedItems struct {
+	deleted    []any
+	anonymized []any
+	held       []any
+}
+
+// eraseSubjectItems erases the items linked to a data subject. If it fails, it returns the items erased before it
+// failed.
+func (h *Handler) eraseSubjectItems(ctx context.Context, subject string) (erasedItems, error) {
+	erased := erasedItems{deleted: make([]any, 0), anonymized: make([]any, 0), held: make([]any, 0)}
+
+	list, err := h.repoList(ctx, corePackageName, "v1", "ResourceTypeDefinition")
+	if err != nil {
+		return erased, fmt.Errorf("failed to list resource type definitions: %w", err)
+	}
+
+	for _, rtdItem := range list.Items {
+		if rtdItem.Properties["package"] == corePackageName {
+			continue
+		}
+
+		resourceTypeDefinition, err := h.parseResourceTypeDefinition(ctx, rtdItem)
+		if err != nil {
+			return erased, err
+		}
+
+		properties := dataSubjectProperties(resourceTypeDefinition.Versions[0].Schema)
+		if len(properties) == 0 {
+			continue
+		}
+
+		items, err := h.repoList(ctx, resourceTypeDefinition.Package, resourceTypeDefinition.Versions[0].Name, resourceTypeDefinition.ResourceType)
+		if err != nil {
+			return erased, err
+		}
+
+		for _, item := range items.Items {
+			if !linkedToSubject(item, properties, subject) {
+				continue
+			}
+
+			err = h.eraseSubjectItem(ctx, item, resourceTypeDefinition.Erasure, properties, &erased)
+			if err != nil {
+				return erased, err
+			}
+		}
+	}
+
+	return erased, nil
+}
+
+// eraseSubjectItem erases an item linked to a data subject, unless it is under legal hold, and adds it to erased once
+// it is.
+func (h *Handler) eraseSubjectItem(ctx context.Context, item *Resource, action ErasureAction, properties []string, erased *erasedItems) error {
+	ref := map[string]any{"packageName": item.Metadata.PackageName, "resourceType": item.Metadata.ResourceType, "name": item.Metadata.Name}
+
+	hold, err := h.getLegalHold(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case hold != nil:
+		erased.held = append(erased.held, ref)
+	case action == ErasureActionAnonymize:
+		err = h.anonymizeItem(ctx, item, properties)
+		if err != nil {
+			return err
+		}
+
+		erased.anonymized = append(erased.anonymized, ref)
+	default:
+		err = h.eraseItem(ctx, item)
+		if err != nil {
+			return err
+		}
+
+		erased.deleted = append(erased.deleted, ref)
+	}
+
+	return nil
+}
+
+// eraseItem deletes an item linked to an erased data subject, with its activity and localizations.
+func (h *Handler) eraseItem(ctx context.Context, item *Resource) error {
+	err := h.repoDelete(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	h.resourceWritten(ctx, OperationDelete, item)
+	h.deleteActivity(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	h.deleteLocalizations(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+
+	return h.redactHistory(ctx, item, nil)
+}
+
+// anonymizeItem removes the data subject properties of an item linked to an erased data subject. The item is written
+// as it is otherwise, even if its schema requires the removed properties.
+func (h *Handler) anonymizeItem(ctx context.Context, item *Resource, properties []string) error {
+	anonymized := item.DeepCopy()
+	anonymized.Metadata.UpdatedAt = h.now()
+
+	for _, property := range properties {
+		delete(anonymized.Properties, property)
+	}
+
+	err := h.repo.Update(ctx, anonymized)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize resource: %w", err)
+	}
+
+	h.resourceWritten(ctx, OperationUpdate, anonymized)
+
+	return h.redactHistory(ctx, item, properties)
+}
+
+// redactHistory removes properties from the earlier writes of an item erased for a data subject, kept by the revision
+// history and the event log, or all of their properties if properties is nil. The revisions of deleted items are
+// erased, so restores don't bring them back.
+func (h *Handler) redactHistory(ctx context.Context, item *Resource, properties []string) error {
+	if h.revisions != nil {
+		if properties == nil {
+			h.revisions.erase(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+		} else {
+			h.revisions.anonymize(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name, properties)
+		}
+	}
+
+	if h.eventLogging != nil {
+		err := h.eventLogging.Log.Redact(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name, properties)
+		if err != nil {
+			return fmt.Errorf("failed to redact event log: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,472 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdRepoMaxRetries bounds the retries of a write whose keys other writes modified in between.
+const etcdRepoMaxRetries = 16
+
+// ErrEtcdWriteConflict is returned for writes that kept conflicting with other writes of the same resources.
+var ErrEtcdWriteConflict = errors.New("write conflicted with concurrent writes too many times")
+
+// EtcdRepo is a ResourcesRepository storing resources in etcd.
+//
+// Every resource is the JSON encoding of it under "/bass/{package}/{type}/{name}". Resource versions are the
+// revisions etcd modified the keys at, so watches of the repository resume from them, and writes are transactions
+// comparing the revisions of the keys they read.
+type EtcdRepo struct {
+	client *clientv3.Client
+	prefix string
+}
+
+type EtcdRepoOption func(repo *EtcdRepo)
+
+// WithEtcdRepoKeyPrefix prefixes the keys of the repository, "/bass" by default, to share an etcd cluster.
+func WithEtcdRepoKeyPrefix(prefix string) EtcdRepoOption {
+	return func(repo *EtcdRepo) {
+		repo.prefix = prefix
+	}
+}
+
+var (
+	_ ResourcesRepository     = (*EtcdRepo)(nil)
+	_ TransactionalRepository = (*EtcdRepo)(nil)
+	_ AtomicUpdater           = (*EtcdRepo)(nil)
+	_ RepositoryWatcher       = (*EtcdRepo)(nil)
+)
+
+func NewEtcdRepo(client *clientv3.Client, opts ...EtcdRepoOption) *EtcdRepo {
+	repo := &EtcdRepo{
+		client: client,
+		prefix: "/bass",
+	}
+
+	for _, opt := range opts {
+		opt(repo)
+	}
+
+	return repo
+}
+
+func (repo *EtcdRepo) List(ctx context.Context, packageName, apiVersion, resourceType string) (ResourceList, error) {
+	// Keys are sorted, so the items are in order of name.
+	resp, err := repo.client.Get(ctx, repo.resourceTypeKey(packageName, resourceType), clientv3.WithPrefix())
+	if err != nil {
+		return ResourceList{}, fmt.Errorf("failed to list resources: %w", err)
+	}
+
+	items := make([]*Resource, 0, len(resp.Kvs))
+
+	for _, kv := range resp.Kvs {
+		item, err := decodeEtcdResource(kv.Value, kv.ModRevision)
+		if err != nil {
+			return ResourceList{}, err
+		}
+
+		items = append(items, item)
+	}
+
+	return newMemResourceList(packageName, apiVersion, resourceType, items), nil
+}
+
+func (repo *EtcdRepo) Create(ctx context.Context, item *Resource) error {
+	return repo.write(ctx, func(txn *etcdTxn) error {
+		return txn.create(item)
+	})
+}
+
+func (repo *EtcdRepo) Get(ctx context.Context, packageName, resourceType, name string) (*Resource, error) {
+	resp, err := repo.client.Get(ctx, repo.resourceKey(packageName, resourceType, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource: %w", err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ResourceNotFoundError{
+			PackageName:  packageName,
+			ResourceType: resourceType,
+			Name:         name,
+		}
+	}
+
+	return decodeEtcdResource(resp.Kvs[0].Value, resp.Kvs[0].ModRevision)
+}
+
+func (repo *EtcdRepo) Update(ctx context.Context, item *Resource) error {
+	return repo.write(ctx, func(txn *etcdTxn) error {
+		return txn.update(item, Precondition{})
+	})
+}
+
+func (repo *EtcdRepo) Delete(ctx context.Context, packageName, resourceType, name string) error {
+	return repo.write(ctx, func(txn *etcdTxn) error {
+		return txn.delete(packageName, resourceType, name, Precondition{})
+	})
+}
+
+func (repo *EtcdRepo) UpdateFunc(ctx context.Context, packageName, resourceType, name string, fn func(item *Resource) error) (*Resource, error) {
+	var item *Resource
+
+	err := repo.write(ctx, func(txn *etcdTxn) error {
+		var err error
+
+		item, err = txn.get(packageName, resourceType, name)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion := item.Metadata.ResourceVersion
+
+		err = fn(item)
+		if err != nil {
+			return err
+		}
+
+		return txn.update(item, Precondition{UID: "", ResourceVersion: resourceVersion})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (repo *EtcdRepo) Transact(ctx context.Context, ops []Operation) error {
+	return repo.write(ctx, func(txn *etcdTxn) error {
+		for i, op := range ops {
+			err := txn.apply(op)
+			if err != nil {
+				return TransactionError{Index: i, Err: err}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Watch streams the writes of resources with etcd's watch, so writes of all handlers sharing the cluster are
+// observed.
+func (repo *EtcdRepo) Watch(ctx context.Context, packageName, resourceType, resourceVersion string) (<-chan RepositoryEvent, error) {
+	key := repo.packageKey(packageName)
+	if resourceType != "" {
+		key = repo.resourceTypeKey(packageName, resourceType)
+	}
+
+	opts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithPrevKV()}
+
+	if resourceVersion != "" {
+		revision, err := strconv.ParseInt(resourceVersion, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse resource version %q: %w", resourceVersion, err)
+		}
+
+		opts = append(opts, clientv3.WithRev(revision+1))
+	}
+
+	watchChan := repo.client.Watch(clientv3.WithRequireLeader(ctx), key, opts...)
+	events := make(chan RepositoryEvent, watchBufferSize)
+
+	go func() {
+		defer close(events)
+
+		for resp := range watchChan {
+			if resp.Err() != nil {
+				return
+			}
+
+			for _, event := range resp.Events {
+				repositoryEvent, err := etcdRepositoryEvent(event)
+				if err != nil {
+					return
+				}
+
+				select {
+				case events <- repositoryEvent:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func (repo *EtcdRepo) packageKey(packageName string) string {
+	return repo.prefix + "/" + packageName + "/"
+}
+
+func (repo *EtcdRepo) resourceTypeKey(packageName, resourceType string) string {
+	return repo.packageKey(packageName) + resourceType + "/"
+}
+
+func (repo *EtcdRepo) resourceKey(packageName, resourceType, name string) string {
+	return repo.resourceTypeKey(packageName, resourceType) + name
+}
+
+// write runs fn on a transaction, and applies the writes of fn at once if fn succeeds, and the keys it read haven't
+// been modified in between. Otherwise, it starts over.
+func (repo *EtcdRepo) write(ctx context.Context, fn func(txn *etcdTxn) error) error {
+	for range etcdRepoMaxRetries {
+		txn := &etcdTxn{
+			repo:      repo,
+			ctx:       ctx,
+			revisions: make(map[string]int64),
+			items:     make(map[string]*Resource),
+			ops:       make(map[string]clientv3.Op),
+			written:   nil,
+		}
+
+		err := fn(txn)
+		if err != nil {
+			return err
+		}
+
+		committed, err := txn.commit()
+		if err != nil {
+			return err
+		}
+
+		if committed {
+			return nil
+		}
+	}
+
+	return ErrEtcdWriteConflict
+}
+
+type etcdTxn struct {
+	repo *EtcdRepo
+	ctx  context.Context //nolint:containedctx // scoped to a single write
+	// revisions holds the revisions the keys read were modified at, and zero for missing ones.
+	revisions map[string]int64
+	// items holds the resources written in the transaction so far by key, and nil for deleted ones.
+	items map[string]*Resource
+	// ops holds the last write of every key, as etcd doesn't allow writing a key twice in a transaction.
+	ops map[string]clientv3.Op
+	// written holds the items put, to set their resource versions once the transaction is committed.
+	written []*Resource
+}
+
+func (txn *etcdTxn) get(packageName, resourceType, name string) (*Resource, error) {
+	notFound := ResourceNotFoundError{
+		PackageName:  packageName,
+		ResourceType: resourceType,
+		Name:         name,
+	}
+
+	key := txn.repo.resourceKey(packageName, resourceType, name)
+
+	item, ok := txn.items[key]
+	if ok {
+		if item == nil {
+			return nil, notFound
+		}
+
+		return item.DeepCopy(), nil
+	}
+
+	resp, err := txn.repo.client.Get(txn.ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource: %w", err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		txn.observe(key, 0)
+
+		return nil, notFound
+	}
+
+	txn.observe(key, resp.Kvs[0].ModRevision)
+
+	return decodeEtcdResource(resp.Kvs[0].Value, resp.Kvs[0].ModRevision)
+}
+
+// observe records the revision a key was modified at when it was first read.
+func (txn *etcdTxn) observe(key string, revision int64) {
+	if _, ok := txn.revisions[key]; !ok {
+		txn.revisions[key] = revision
+	}
+}
+
+func (txn *etcdTxn) apply(op Operation) error {
+	switch op.Type {
+	case OperationCreate:
+		return txn.create(op.Item)
+	case OperationUpdate:
+		return txn.update(op.Item, op.Precondition)
+	case OperationDelete:
+		return txn.delete(op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name, op.Precondition)
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+}
+
+func (txn *etcdTxn) create(item *Resource) error {
+	_, err := txn.get(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err == nil {
+		return ResourceExistsError{
+			PackageName:  item.Metadata.PackageName,
+			ResourceType: item.Metadata.ResourceType,
+			Name:         item.Metadata.Name,
+		}
+	}
+
+	var resourceNotFoundError ResourceNotFoundError
+	if !errors.As(err, &resourceNotFoundError) {
+		return err
+	}
+
+	return txn.put(item)
+}
+
+// update replaces a stored item. The UID and creation time of the stored item are kept. An item carrying a resource
+// version must match the stored one.
+func (txn *etcdTxn) update(item *Resource, precondition Precondition) error {
+	current, err := txn.get(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	err = CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	if item.Metadata.ResourceVersion != "" && item.Metadata.ResourceVersion != current.Metadata.ResourceVersion {
+		return ResourceVersionConflictError{
+			PackageName:     item.Metadata.PackageName,
+			ResourceType:    item.Metadata.ResourceType,
+			Name:            item.Metadata.Name,
+			ResourceVersion: item.Metadata.ResourceVersion,
+		}
+	}
+
+	item.Metadata.UID = current.Metadata.UID
+	item.Metadata.CreatedAt = current.Metadata.CreatedAt
+
+	return txn.put(item)
+}
+
+func (txn *etcdTxn) delete(packageName, resourceType, name string, precondition Precondition) error {
+	current, err := txn.get(packageName, resourceType, name)
+	if err != nil {
+		return err
+	}
+
+	err = CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	key := txn.repo.resourceKey(packageName, resourceType, name)
+
+	txn.items[key] = nil
+	txn.ops[key] = clientv3.OpDelete(key)
+
+	return nil
+}
+
+// put stores the item. Its resource version is the revision of the transaction, so it is left out of the stored
+// encoding, and set once the transaction is committed.
+func (txn *etcdTxn) put(item *Resource) error {
+	stored := item.DeepCopy()
+	stored.Metadata.ResourceVersion = ""
+
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource: %w", err)
+	}
+
+	key := txn.repo.resourceKey(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+
+	txn.items[key] = stored
+	txn.ops[key] = clientv3.OpPut(key, string(encoded))
+	txn.written = append(txn.written, item)
+
+	return nil
+}
+
+// commit applies the writes of the transaction, unless any key read has been modified since, and reports whether it
+// did.
+func (txn *etcdTxn) commit() (bool, error) {
+	if len(txn.ops) == 0 {
+		return true, nil
+	}
+
+	cmps := make([]clientv3.Cmp, 0, len(txn.revisions))
+	for _, key := range slices.Sorted(maps.Keys(txn.revisions)) {
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", txn.revisions[key]))
+	}
+
+	ops := make([]clientv3.Op, 0, len(txn.ops))
+	for _, key := range slices.Sorted(maps.Keys(txn.ops)) {
+		ops = append(ops, txn.ops[key])
+	}
+
+	resp, err := txn.repo.client.Txn(txn.ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return false, fmt.Errorf("failed to write resources: %w", err)
+	}
+
+	if !resp.Succeeded {
+		return false, nil
+	}
+
+	resourceVersion := strconv.FormatInt(resp.Header.Revision, 10)
+	for _, item := range txn.written {
+		item.Metadata.ResourceVersion = resourceVersion
+	}
+
+	return true, nil
+}
+
+// etcdRepositoryEvent returns the write of a resource an etcd event is.
+func etcdRepositoryEvent(event *clientv3.Event) (RepositoryEvent, error) {
+	if event.Type == mvccpb.DELETE {
+		if event.PrevKv == nil {
+			return RepositoryEvent{}, fmt.Errorf("deleted resource %q is compacted", event.Kv.Key)
+		}
+
+		item, err := decodeEtcdResource(event.PrevKv.Value, event.Kv.ModRevision)
+		if err != nil {
+			return RepositoryEvent{}, err
+		}
+
+		return RepositoryEvent{Type: OperationDelete, Item: item}, nil
+	}
+
+	item, err := decodeEtcdResource(event.Kv.Value, event.Kv.ModRevision)
+	if err != nil {
+		return RepositoryEvent{}, err
+	}
+
+	if event.IsCreate() {
+		return RepositoryEvent{Type: OperationCreate, Item: item}, nil
+	}
+
+	return RepositoryEvent{Type: OperationUpdate, Item: item}, nil
+}
+
+func decodeEtcdResource(data []byte, revision int64) (*Resource, error) {
+	var item Resource
+
+	err := json.Unmarshal(data, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource: %w", err)
+	}
+
+	item.Metadata.ResourceVersion = strconv.FormatInt(revision, 10)
+
+	return &item, nil
+}
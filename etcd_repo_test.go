@@ -0,0 +1,168 @@
+package bass_test
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nasermirzaei89/bass"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// newEtcdClient starts an embedded etcd server, and returns a client of it.
+func newEtcdClient(t *testing.T) *clientv3.Client {
+	t.Helper()
+
+	listenURL := url.URL{Scheme: "http", Host: "127.0.0.1:0"}
+
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	cfg.ListenClientUrls = []url.URL{listenURL}
+	cfg.ListenPeerUrls = []url.URL{listenURL}
+
+	server, err := embed.StartEtcd(cfg)
+	require.NoError(t, err)
+
+	t.Cleanup(server.Close)
+
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "etcd server didn't start")
+	}
+
+	client, err := clientv3.New(clientv3.Config{Endpoints: []string{server.Clients[0].Addr().String()}, DialTimeout: 5 * time.Second})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func TestEtcdRepo(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	client := newEtcdClient(t)
+	repo := bass.NewEtcdRepo(client)
+
+	newFoo := func(name string) *bass.Resource {
+		return &bass.Resource{
+			Metadata:   bass.Metadata{UID: "uid-" + name, PackageName: "test", APIVersion: "v1", ResourceType: "Foo", Name: name},
+			Properties: map[string]any{"n": 1.0},
+		}
+	}
+
+	events, err := repo.Watch(ctx, "test", "", "")
+	require.NoError(t, err)
+
+	// resources are stored by package, resource type, and name, and versioned by etcd revisions
+	{
+		item := newFoo("foo1")
+		require.NoError(t, repo.Create(ctx, item))
+
+		resp, err := client.Get(ctx, "/bass/test/Foo/foo1")
+		require.NoError(t, err)
+
+		require.Len(t, resp.Kvs, 1)
+		assert.Equal(t, strconv.FormatInt(resp.Kvs[0].ModRevision, 10), item.Metadata.ResourceVersion)
+	}
+
+	// failed transactions write nothing
+	{
+		err := repo.Transact(ctx, []bass.Operation{
+			{Type: bass.OperationCreate, Item: newFoo("foo2")},
+			{Type: bass.OperationCreate, Item: newFoo("foo1")},
+		})
+
+		var transactionError bass.TransactionError
+
+		require.ErrorAs(t, err, &transactionError)
+		assert.Equal(t, 1, transactionError.Index)
+
+		resp, err := client.Get(ctx, "/bass/test/Foo/foo2")
+		require.NoError(t, err)
+
+		assert.Empty(t, resp.Kvs)
+	}
+
+	// writes within a transaction see each other
+	{
+		require.NoError(t, repo.Transact(ctx, []bass.Operation{
+			{Type: bass.OperationCreate, Item: newFoo("foo2")},
+			{Type: bass.OperationUpdate, Item: &bass.Resource{Metadata: bass.Metadata{PackageName: "test", ResourceType: "Foo", Name: "foo2"}, Properties: map[string]any{"n": 2.0}}},
+		}))
+
+		item, err := repo.Get(ctx, "test", "Foo", "foo2")
+		require.NoError(t, err)
+
+		assert.Equal(t, "uid-foo2", item.Metadata.UID)
+		assert.Equal(t, map[string]any{"n": 2.0}, item.Properties)
+	}
+
+	// watches stream writes, including writes not made through the repository
+	var resourceVersion string
+
+	{
+		require.NoError(t, repo.Delete(ctx, "test", "Foo", "foo1"))
+
+		for _, want := range []struct {
+			op   bass.OperationType
+			name string
+		}{{bass.OperationCreate, "foo1"}, {bass.OperationCreate, "foo2"}, {bass.OperationDelete, "foo1"}} {
+			select {
+			case event := <-events:
+				assert.Equal(t, want.op, event.Type)
+				assert.Equal(t, want.name, event.Item.Metadata.Name)
+
+				resourceVersion = event.Item.Metadata.ResourceVersion
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "missing watch event", "%s of %s", want.op, want.name)
+			}
+		}
+
+		_, err := client.Put(ctx, "/bass/test/Foo/foo2", `{"metadata": {"name": "foo2", "uid": "uid-foo2"}, "n": 3}`)
+		require.NoError(t, err)
+
+		select {
+		case event := <-events:
+			assert.Equal(t, bass.OperationUpdate, event.Type)
+			assert.Equal(t, map[string]any{"n": 3.0}, event.Item.Properties)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "missing watch event")
+		}
+	}
+
+	// watches resume after resource versions
+	{
+		resumed, err := repo.Watch(ctx, "test", "Foo", resourceVersion)
+		require.NoError(t, err)
+
+		select {
+		case event := <-resumed:
+			assert.Equal(t, bass.OperationUpdate, event.Type)
+			assert.Equal(t, "foo2", event.Item.Metadata.Name)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "missing watch event")
+		}
+	}
+}
+
+func TestEtcdRepoModel(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+
+	n := 0
+
+	checkRepositoryModel(t, func() bass.ResourcesRepository {
+		n++
+
+		return bass.NewEtcdRepo(client, bass.WithEtcdRepoKeyPrefix("/"+strconv.Itoa(n)))
+	})
+}
@@ -0,0 +1,228 @@
+package bass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type LoggedEvent = apiv1.LoggedEvent
+
+// EventLog is an append-only log of the writes made through the handler, for watches to resume from and to replay.
+// Positions start at 1 and increase by one per event.
+type EventLog interface {
+	// Append adds an event to the log, and returns its position.
+	Append(ctx context.Context, event LoggedEvent) (int64, error)
+	// Read returns the events after a position, oldest first. It returns EventsExpiredError if events after the
+	// position were trimmed.
+	Read(ctx context.Context, after int64) ([]LoggedEvent, error)
+	// Trim removes the events logged before a time, and returns how many it removed.
+	Trim(ctx context.Context, before time.Time) (int, error)
+	// Redact removes properties from the items of the logged events of an item, or all their properties if
+	// properties is nil, for erasures of data subjects. The events stay in the log.
+	Redact(ctx context.Context, packageName, resourceType, name string, properties []string) error
+}
+
+// EventLogging configures the event log of the writes made through the handler. Watches with a since query parameter
+// replay the events after that position before streaming live events.
+type EventLogging struct {
+	Log EventLog
+	// Retention is how long events are kept by Handler.TrimEventLog. Zero keeps them forever.
+	Retention time.Duration
+}
+
+type EventsExpiredError struct {
+	Position int64
+	Trimmed  int64
+}
+
+func (err EventsExpiredError) Error() string {
+	return fmt.Sprintf("events after position %d are not retained; the event log starts after position %d", err.Position, err.Trimmed)
+}
+
+// MemEventLog is an in-memory EventLog. Its events are lost when the process exits.
+type MemEventLog struct {
+	mu      sync.Mutex
+	events  []LoggedEvent
+	trimmed int64
+}
+
+var _ EventLog = (*MemEventLog)(nil)
+
+func NewMemEventLog() *MemEventLog {
+	return &MemEventLog{
+		mu:      sync.Mutex{},
+		events:  nil,
+		trimmed: 0,
+	}
+}
+
+func (log *MemEventLog) Append(_ context.Context, event LoggedEvent) (int64, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	event.Position = log.trimmed + int64(len(log.events)) + 1
+	event.Item = event.Item.DeepCopy()
+
+	log.events = append(log.events, event)
+
+	return event.Position, nil
+}
+
+func (log *MemEventLog) Read(_ context.Context, after int64) ([]LoggedEvent, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if after < log.trimmed {
+		return nil, EventsExpiredError{Position: after, Trimmed: log.trimmed}
+	}
+
+	start := min(after-log.trimmed, int64(len(log.events)))
+	res := make([]LoggedEvent, 0, int64(len(log.events))-start)
+
+	for _, event := range log.events[start:] {
+		event.Item = event.Item.DeepCopy()
+		res = append(res, event)
+	}
+
+	return res, nil
+}
+
+func (log *MemEventLog) Trim(_ context.Context, before time.Time) (int, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	n := 0
+	for n < len(log.events) && log.events[n].At.Before(before) {
+		n++
+	}
+
+	log.events = log.events[n:]
+	log.trimmed += int64(n)
+
+	return n, nil
+}
+
+func (log *MemEventLog) Redact(_ context.Context, packageName, resourceType, name string, properties []string) error {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	for i, event := range log.events {
+		metadata := event.Item.Metadata
+		if metadata.PackageName != packageName || metadata.ResourceType != resourceType || metadata.Name != name {
+			continue
+		}
+
+		if properties == nil {
+			event.Item.Properties = make(map[string]any)
+		}
+
+		for _, property := range properties {
+			delete(event.Item.Properties, property)
+		}
+
+		log.events[i] = event
+	}
+
+	return nil
+}
+
+// TrimEventLog removes the events beyond the retention from the event log, and returns how many it removed. It is
+// meant to be called periodically.
+func (h *Handler) TrimEventLog(ctx context.Context) (int, error) {
+	if h.eventLogging == nil || h.eventLogging.Retention <= 0 {
+		return 0, nil
+	}
+
+	n, err := h.eventLogging.Log.Trim(ctx, h.now().Add(-h.eventLogging.Retention))
+	if err != nil {
+		return n, fmt.Errorf("failed to trim event log: %w", err)
+	}
+
+	return n, nil
+}
+
+// logEvent appends a write to the event log, and returns its position, or zero if appending fails.
+func (h *Handler) logEvent(ctx context.Context, event resourceEvent) int64 {
+	position, err := h.eventLogging.Log.Append(ctx, LoggedEvent{Position: 0, Type: event.Type, At: h.now(), Item: event.Item})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to append to event log", "name", event.Item.Metadata.Name, "error", err)
+
+		return 0
+	}
+
+	return position
+}
+
+// watchBacklog returns what a watch sends before live events: the current items, listed with list, or the logged
+// events after the position of the since query parameter, for a watch resuming from it. It responds with the failure
+// and returns false if it fails.
+func (h *Handler) watchBacklog(w http.ResponseWriter, r *http.Request, list func(ctx context.Context) ([]*Resource, error)) ([]*Resource, []resourceEvent, bool) {
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		items, err := list(r.Context())
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to list resources", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return nil, nil, false
+		}
+
+		return items, nil, true
+	}
+
+	if h.eventLogging == nil {
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusNotImplemented),
+			problem.WithTitle("Not Implemented"),
+			problem.WithDetail("event log is not configured"),
+			withReason(apierrors.ReasonNotImplemented),
+		))
+
+		return nil, nil, false
+	}
+
+	position, err := strconv.ParseInt(since, 10, 64)
+	if err != nil || position < 0 {
+		respond.Done(w, r, problem.BadRequest(fmt.Sprintf("invalid since query parameter %q", since), withReason(apierrors.ReasonBadRequest)))
+
+		return nil, nil, false
+	}
+
+	logged, err := h.eventLogging.Log.Read(r.Context(), position)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to read event log", "error", err)
+
+		var eventsExpiredError EventsExpiredError
+
+		switch {
+		case errors.As(err, &eventsExpiredError):
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusGone),
+				problem.WithTitle("Gone"),
+				problem.WithDetail(eventsExpiredError.Error()),
+				withReason(apierrors.ReasonEventsExpired),
+			))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return nil, nil, false
+	}
+
+	replay := make([]resourceEvent, 0, len(logged))
+	for _, event := range logged {
+		replay = append(replay, resourceEvent{Type: event.Type, Item: event.Item, Position: event.Position})
+	}
+
+	return nil, replay, true
+}
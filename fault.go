@@ -0,0 +1,88 @@
+package bass
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+// FaultRule injects faults into the requests it matches, to test the resilience of clients against a misbehaving
+// server. It matches requests by method, if given, and by the prefix of their path below the base path.
+type FaultRule struct {
+	Method     string
+	PathPrefix string
+	// Latency delays matching requests.
+	Latency time.Duration
+	// ErrorRate is the fraction of matching requests failing with ErrorStatus, 503 Service Unavailable by default.
+	ErrorRate   float64
+	ErrorStatus int
+	// DropWatchAfter ends matching watches after the duration, as if the connection dropped.
+	DropWatchAfter time.Duration
+}
+
+func (rule FaultRule) matches(r *http.Request, basePath string) bool {
+	return (rule.Method == "" || rule.Method == r.Method) && strings.HasPrefix(strings.TrimPrefix(r.URL.Path, basePath), rule.PathPrefix)
+}
+
+// withFaultInjection applies the first fault rule matching the request, if any.
+func (h *Handler) withFaultInjection(next http.Handler) http.Handler {
+	if len(h.faultRules) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range h.faultRules {
+			if rule.matches(r, h.basePath) {
+				h.injectFault(w, r, rule, next)
+
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) injectFault(w http.ResponseWriter, r *http.Request, rule FaultRule, next http.Handler) {
+	if rule.Latency > 0 {
+		timer := time.NewTimer(rule.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+		}
+	}
+
+	if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate { //nolint:gosec // faults don't need a secure source
+		status := rule.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(status),
+			problem.WithTitle(http.StatusText(status)),
+			problem.WithDetail("fault injected"),
+			withReason(apierrors.ReasonUnavailable),
+		))
+
+		return
+	}
+
+	if rule.DropWatchAfter > 0 && strings.HasSuffix(r.URL.Path, "/watch") {
+		ctx, cancel := context.WithTimeout(r.Context(), rule.DropWatchAfter)
+		defer cancel()
+
+		r = r.WithContext(ctx)
+	}
+
+	next.ServeHTTP(w, r)
+}
@@ -0,0 +1,177 @@
+package bass
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	FeatureFlag                  = apiv1.FeatureFlag
+	FeatureFlagRule              = apiv1.FeatureFlagRule
+	FeatureFlagEvaluationRequest = apiv1.FeatureFlagEvaluationRequest
+	FeatureFlagReason            = apiv1.FeatureFlagReason
+	FeatureFlagEvaluation        = apiv1.FeatureFlagEvaluation
+)
+
+const (
+	FeatureFlagReasonDisabled = apiv1.FeatureFlagReasonDisabled
+	FeatureFlagReasonRule     = apiv1.FeatureFlagReasonRule
+	FeatureFlagReasonRollout  = apiv1.FeatureFlagReasonRollout
+	FeatureFlagReasonDefault  = apiv1.FeatureFlagReasonDefault
+)
+
+const featureFlagPercentageBuckets = 100
+
+func featureFlagResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "FeatureFlag.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "FeatureFlag",
+		Plural:       "FeatureFlags",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"enabled":    map[string]any{"type": "boolean"},
+						"percentage": map[string]any{"type": "integer", "minimum": 0, "maximum": featureFlagPercentageBuckets},
+						"rules": map[string]any{
+							"type": "array",
+							"items": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"attribute": map[string]any{"type": "string"},
+									"values":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+								},
+								"required": []any{"attribute", "values"},
+							},
+						},
+					},
+					"required": []any{"enabled"},
+				},
+			},
+		},
+	}
+}
+
+func featureFlagFromResource(item *Resource) (*FeatureFlag, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal feature flag properties: %w", err)
+	}
+
+	var featureFlag FeatureFlag
+
+	err = json.Unmarshal(b, &featureFlag)
+	if err != nil {
+		return nil, fmt.Errorf("feature flag %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	featureFlag.Metadata = item.Metadata
+
+	return &featureFlag, nil
+}
+
+// EvaluateFeatureFlag reports whether the flag is on for the subject of req. A disabled flag is off for everyone.
+// Otherwise, it is on for subjects matching any rule, and for a stable share of the other subjects by their key, as
+// given by the rollout percentage.
+func EvaluateFeatureFlag(featureFlag *FeatureFlag, req FeatureFlagEvaluationRequest) FeatureFlagEvaluation {
+	res := FeatureFlagEvaluation{Name: featureFlag.Metadata.Name, Enabled: false, Reason: FeatureFlagReasonDefault}
+
+	if !featureFlag.Enabled {
+		res.Reason = FeatureFlagReasonDisabled
+
+		return res
+	}
+
+	for _, rule := range featureFlag.Rules {
+		value, ok := req.Attributes[rule.Attribute]
+		if ok && slices.Contains(rule.Values, value) {
+			res.Enabled = true
+			res.Reason = FeatureFlagReasonRule
+
+			return res
+		}
+	}
+
+	if featureFlag.Percentage > 0 && featureFlagBucket(featureFlag.Metadata.Name, req.Key) < featureFlag.Percentage {
+		res.Enabled = true
+		res.Reason = FeatureFlagReasonRollout
+	}
+
+	return res
+}
+
+// featureFlagBucket maps a subject key to one of the percentage buckets. Keys are hashed with the flag name, so
+// different flags roll out to different subjects.
+func featureFlagBucket(name, key string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name + "/" + key))
+
+	return int(hash.Sum32() % featureFlagPercentageBuckets)
+}
+
+func (h *Handler) handleEvaluateFeatureFlag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		var req FeatureFlagEvaluationRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		item, err := h.repoGet(r.Context(), corePackageName, "FeatureFlag", name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get feature flag", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		featureFlag, err := featureFlagFromResource(item)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to parse feature flag", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		respond.Done(w, r, EvaluateFeatureFlag(featureFlag, req))
+	}
+}
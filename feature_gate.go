@@ -0,0 +1,61 @@
+package bass
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type FeatureGate = apiv1.FeatureGate
+
+// featureNames returns the names of all known features, sorted.
+func featureNames() []string {
+	stages := featureStages()
+	names := make([]string, 0, len(stages))
+
+	for _, feature := range slices.Sorted(maps.Keys(stages)) {
+		names = append(names, string(feature))
+	}
+
+	return names
+}
+
+// withFeature serves next only while the feature is enabled, and responds with 404 Not Found otherwise, as if the
+// endpoint wasn't registered.
+func (h *Handler) withFeature(feature Feature, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.featureEnabled(feature) {
+			respond.Done(w, r, problem.NotFound(fmt.Sprintf("feature %q is disabled", feature), withReason(apierrors.ReasonNotFound)))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleListFeatureGates lists the known features with their stage and whether they are enabled.
+func (h *Handler) handleListFeatureGates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stages := featureStages()
+		res := make([]FeatureGate, 0, len(stages))
+
+		for _, name := range featureNames() {
+			feature := Feature(name)
+
+			res = append(res, FeatureGate{
+				Name:    name,
+				Stage:   string(stages[feature]),
+				Enabled: h.featureEnabled(feature),
+			})
+		}
+
+		respond.Done(w, r, res)
+	}
+}
@@ -1,17 +1,27 @@
 package bass
 
 import (
+	"context"
 	"encoding/json/jsontext"
 	"encoding/json/v2"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/gertd/go-pluralize"
 	"github.com/google/uuid"
+	"github.com/nasermirzaei89/bass/apierrors"
 	"github.com/nasermirzaei89/problem"
 	"github.com/nasermirzaei89/respond"
 	"github.com/xeipuuv/gojsonschema"
@@ -21,17 +31,129 @@ type Handler struct {
 	mux             *http.ServeMux
 	repo            ResourcesRepository
 	pluralizeClient *pluralize.Client
+	logger          *slog.Logger
+	now             func() time.Time
+	basePath        string
+	maxBodyBytes    int64
+	listLimits      ListLimits
+	features        map[Feature]bool
+	pluralOverrides map[string]string
+
+	unknownFieldPolicy UnknownFieldPolicy
+	pruning            bool
+	requestTimeout     time.Duration
+	retryPolicy        RetryPolicy
+	metricsEnabled     bool
+	debugEnabled       bool
+	faultRules         []FaultRule
+	metrics            *metrics
+	watchHub           *watchHub
+	schemaDefinitions  *schemaDefinitionCache
+	views              *viewCache
+	revisionHistory    bool
+	revisionLimit      int
+	revisions          *revisionLog
+	renameAliasPeriod  time.Duration
+	apiKeys            []APIKey
+	apiKeyLimiter      *apiKeyLimiter
+	meterSink          MeterSink
+	meterSubject       func(r *http.Request) string
+
+	changeRequestNotifier ChangeRequestNotifier
+	emailSender           EmailSender
+	pushSenders           map[string]PushSender
+	notifiers             map[string]Notifier
+	moderationClassifiers map[string]ModerationClassifier
+
+	networkPolicy          *NetworkPolicy
+	packageNetworkPolicies map[string]NetworkPolicy
+	trustedProxies         []netip.Prefix
+	countryResolver        CountryResolver
+	requestSigning         *RequestSigning
+	maintenance            atomic.Pointer[Maintenance]
+	archiving              *Archiving
+	eventLogging           *EventLogging
+	outbox                 *Outbox
+	backupPolicy           *BackupPolicy
+	backups                *backupCatalog
+	replication            *Replication
+	replicationQueue       chan ReplicationEvent
+	logLevel               *slog.LevelVar
+	initialLogLevel        slog.Level
+	featureOverrides       atomic.Pointer[map[Feature]bool]
 }
 
 var _ http.Handler = (*Handler)(nil)
 
-func NewHandler(resourcesRepo ResourcesRepository) *Handler {
+func NewHandler(resourcesRepo ResourcesRepository, opts ...Option) *Handler {
 	mux := http.NewServeMux()
 
 	handler := &Handler{
 		mux:             mux,
 		repo:            resourcesRepo,
 		pluralizeClient: pluralize.NewClient(),
+		logger:          slog.Default(),
+		now:             time.Now,
+		basePath:        "",
+		maxBodyBytes:    DefaultMaxBodyBytes,
+		listLimits:      ListLimits{DefaultLimit: 0, MaxLimit: 0, MaxUnpaginatedBytes: 0},
+		features:        defaultFeatures(),
+		pluralOverrides: make(map[string]string),
+
+		unknownFieldPolicy: UnknownFieldPolicyPreserve,
+		pruning:            false,
+		requestTimeout:     0,
+		retryPolicy:        defaultRetryPolicy(),
+		metricsEnabled:     false,
+		debugEnabled:       false,
+		faultRules:         nil,
+		metrics:            newMetrics(),
+		watchHub:           newWatchHub(),
+		schemaDefinitions:  newSchemaDefinitionCache(),
+		views:              newViewCache(),
+		revisionHistory:    false,
+		revisionLimit:      DefaultRevisionHistoryLimit,
+		revisions:          nil,
+		renameAliasPeriod:  0,
+		apiKeys:            nil,
+		apiKeyLimiter:      newAPIKeyLimiter(),
+		meterSink:          nil,
+		meterSubject:       nil,
+
+		changeRequestNotifier: nil,
+		emailSender:           nil,
+		pushSenders:           make(map[string]PushSender),
+		notifiers:             make(map[string]Notifier),
+		moderationClassifiers: make(map[string]ModerationClassifier),
+
+		networkPolicy:          nil,
+		packageNetworkPolicies: make(map[string]NetworkPolicy),
+		trustedProxies:         nil,
+		countryResolver:        nil,
+		requestSigning:         nil,
+		maintenance:            atomic.Pointer[Maintenance]{},
+		archiving:              nil,
+		eventLogging:           nil,
+		outbox:                 nil,
+		backupPolicy:           nil,
+		backups:                newBackupCatalog(),
+		replication:            nil,
+		replicationQueue:       make(chan ReplicationEvent, replicationQueueSize),
+		logLevel:               nil,
+		initialLogLevel:        slog.LevelInfo,
+		featureOverrides:       atomic.Pointer[map[Feature]bool]{},
+	}
+
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	for singular, plural := range handler.pluralOverrides {
+		handler.pluralizeClient.AddIrregularRule(singular, plural)
+	}
+
+	if handler.revisionHistory {
+		handler.revisions = newRevisionLog(handler.now(), handler.revisionLimit)
 	}
 
 	handler.registerRoutes()
@@ -40,16 +162,242 @@ func NewHandler(resourcesRepo ResourcesRepository) *Handler {
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.mux.ServeHTTP(w, r)
+	if h.maxBodyBytes > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	}
+
+	r = r.WithContext(contextWithWriteOrigin(r.Context(), r.URL.Path))
+
+	h.withRequestSigning(h.withCanonicalPath(h.withMaintenance(h.withFaultInjection(h.mux)))).ServeHTTP(w, r)
 }
 
 func (h *Handler) registerRoutes() {
-	h.mux.Handle("GET /api/{packageName}/{apiVersion}/{resourceTypePlural}", h.handleListResources())
-	h.mux.Handle("POST /api/{packageName}/{apiVersion}/{resourceTypePlural}", h.handleCreateResource())
-	h.mux.Handle("GET /api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.handleGetResource())
-	h.mux.Handle("PUT /api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.handleReplaceResource())
-	h.mux.Handle("PATCH /api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.handlePatchResource())
-	h.mux.Handle("DELETE /api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.handleDeleteResource())
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.compressResponse(h.handleListResources()))))))))
+	h.mux.Handle("GET "+h.basePath+"/api/watch", h.withAPIKeys(h.withNetworkPolicy(h.withMetering(h.handleWatchPackage()))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/watch", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withMetering(h.handleWatchPackage())))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/watch", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.handleWatchResources()))))))
+	h.mux.Handle("POST "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleCreateResource())))))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleGetResourceOrView())))))))
+	h.mux.Handle("POST "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleResourceAction())))))))
+	h.mux.Handle("PUT "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleReplaceResource())))))))
+	h.mux.Handle("PATCH "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handlePatchResource())))))))
+	h.mux.Handle("DELETE "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleDeleteResource())))))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/related", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleListRelatedResources())))))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/activity", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleListActivity())))))))
+	h.mux.Handle("POST "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/activity", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleCreateActivity())))))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/draft", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleGetDraft())))))))
+	h.mux.Handle("PUT "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/draft", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handlePutDraft())))))))
+	h.mux.Handle("DELETE "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/draft", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleDeleteDraft())))))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/versions", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleListPublishedVersions())))))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/revisions/{revision}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withMetering(h.withTimeout(h.handleRevisionDiff())))))))
+	h.mux.Handle("GET "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/locales/{locale}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withFeature(FeatureLocalization, h.withMetering(h.withTimeout(h.handleGetLocalization()))))))))
+	h.mux.Handle("PUT "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/locales/{locale}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withFeature(FeatureLocalization, h.withMetering(h.withTimeout(h.handlePutLocalization()))))))))
+	h.mux.Handle("DELETE "+h.basePath+"/api/{packageName}/{apiVersion}/{resourceTypePlural}/{name}/locales/{locale}", h.withPathValidation(h.withAPIKeys(h.withNetworkPolicy(h.withDeprecationWarnings(h.withFeature(FeatureLocalization, h.withMetering(h.withTimeout(h.handleDeleteLocalization()))))))))
+	h.mux.Handle("POST "+h.basePath+"/api/transactions", h.withAPIKeys(h.withNetworkPolicy(h.withMetering(h.withTimeout(h.handleTransaction())))))
+	h.mux.Handle("POST "+h.basePath+"/sync", h.withAPIKeys(h.withNetworkPolicy(h.withMetering(h.withTimeout(h.withWriteOrigin(originSync, h.handleSync()))))))
+
+	h.mux.Handle("POST "+h.basePath+"/api/core/v1/changerequests/{name}/approve", h.withAPIKeys(h.withNetworkPolicy(h.withMetering(h.withTimeout(h.handleApproveChangeRequest())))))
+	h.mux.Handle("POST "+h.basePath+"/api/core/v1/emailtemplates/{name}/send", h.withAPIKeys(h.withNetworkPolicy(h.withFeature(FeatureNotifications, h.withMetering(h.withTimeout(h.handleSendEmail()))))))
+	h.mux.Handle("POST "+h.basePath+"/api/core/v1/pushtemplates/{name}/send", h.withAPIKeys(h.withNetworkPolicy(h.withFeature(FeatureNotifications, h.withMetering(h.withTimeout(h.handleSendPush()))))))
+	h.mux.Handle("POST "+h.basePath+"/api/core/v1/notificationchannels/{name}/notify", h.withAPIKeys(h.withNetworkPolicy(h.withFeature(FeatureNotifications, h.withMetering(h.withTimeout(h.handleNotify()))))))
+	h.mux.Handle("POST "+h.basePath+"/api/core/v1/featureflags/{name}/evaluate", h.withAPIKeys(h.withNetworkPolicy(h.withMetering(h.withTimeout(h.handleEvaluateFeatureFlag())))))
+	h.mux.Handle("GET "+h.basePath+"/api/core/v1/settings/{name}/value", h.withAPIKeys(h.withNetworkPolicy(h.withMetering(h.handleGetSettingValue()))))
+	h.mux.Handle("GET "+h.basePath+"/admin/usage", h.withAdminAPIKeys(h.withNetworkPolicy(h.withTimeout(h.handleUsage()))))
+	h.mux.Handle("GET "+h.basePath+"/admin/features", h.withAdminAPIKeys(h.withNetworkPolicy(h.handleListFeatureGates())))
+	h.mux.Handle("GET "+h.basePath+"/admin/maintenance", h.withAdminAPIKeys(h.withNetworkPolicy(h.handleGetMaintenance())))
+	h.mux.Handle("PUT "+h.basePath+"/admin/maintenance", h.withAdminAPIKeys(h.withNetworkPolicy(h.handlePutMaintenance())))
+	h.mux.Handle("POST "+h.basePath+"/admin/restore", h.withAdminAPIKeys(h.withNetworkPolicy(h.withWriteOrigin(originRestore, h.handleRestore()))))
+	h.mux.Handle("GET "+h.basePath+"/admin/backups", h.withAdminAPIKeys(h.withNetworkPolicy(h.handleListBackups())))
+	h.mux.Handle("POST "+h.basePath+"/admin/replication", h.withAdminAPIKeys(h.withNetworkPolicy(h.handleReplication())))
+	h.mux.Handle("POST "+h.basePath+"/admin/erase", h.withAdminAPIKeys(h.withNetworkPolicy(h.withTimeout(h.handleErase()))))
+	h.mux.Handle("GET "+h.basePath+"/admin/legalholds", h.withAdminAPIKeys(h.withNetworkPolicy(h.withTimeout(h.handleListLegalHolds()))))
+	h.mux.Handle("PUT "+h.basePath+"/admin/legalholds/{packageName}/{resourceTypePlural}/{name}", h.withLegalHoldAPIKeys(h.withNetworkPolicy(h.withTimeout(h.handlePutLegalHold()))))
+	h.mux.Handle("DELETE "+h.basePath+"/admin/legalholds/{packageName}/{resourceTypePlural}/{name}", h.withLegalHoldAPIKeys(h.withNetworkPolicy(h.withTimeout(h.handleDeleteLegalHold()))))
+
+	if h.metricsEnabled {
+		h.mux.Handle("GET "+h.basePath+"/metrics", h.withAdminAPIKeys(h.withNetworkPolicy(h.handleMetrics())))
+	}
+
+	if h.debugEnabled {
+		h.registerDebugRoutes()
+	}
+}
+
+type ResourceInvalidError struct {
+	Errors []ValidationError
+}
+
+func (err ResourceInvalidError) Error() string {
+	return "resource item is invalid"
+}
+
+// checkResourceItem applies the unknown field policy, pruning, and the resource type schema to the item.
+func (h *Handler) checkResourceItem(ctx context.Context, resourceTypeDefinition *ResourceTypeDefinition, item *Resource) error {
+	schema := resourceTypeDefinition.Versions[0].Schema
+
+	err := h.applyUnknownFieldPolicy(schema, item)
+	if err != nil {
+		return err
+	}
+
+	if h.pruning && item.Properties != nil {
+		pruneValue(item.Properties, schema)
+	}
+
+	itemLoader := gojsonschema.NewGoLoader(item.Properties)
+	schemaLoader := gojsonschema.NewGoLoader(schema)
+
+	result, err := gojsonschema.Validate(schemaLoader, itemLoader)
+	if err != nil {
+		return fmt.Errorf("failed to validate resource item: %w", err)
+	}
+
+	if !result.Valid() {
+		return ResourceInvalidError{Errors: validationErrors("/properties", result.Errors())}
+	}
+
+	switch {
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "Setting":
+		return checkSetting(item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "EmailTemplate":
+		return checkEmailTemplate(item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "PushTemplate":
+		return checkPushTemplate(item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "NotificationRule":
+		return checkNotificationRule(item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "Automation":
+		return checkAutomation(item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "View":
+		return checkView(item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "ServerConfig":
+		return checkServerConfig(item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "ResourceTypeDefinition":
+		err = checkTimeSeriesDefinition(item)
+		if err != nil {
+			return err
+		}
+
+		return h.checkResourceTypeDefinitionSchemas(ctx, item)
+	case resourceTypeDefinition.Package == corePackageName && resourceTypeDefinition.ResourceType == "SchemaDefinition":
+		return h.checkSchemaDefinition(ctx, item)
+	case resourceTypeDefinition.TimeSeries != nil:
+		return checkTimeSeriesItem(resourceTypeDefinition.TimeSeries, item)
+	default:
+		return nil
+	}
+}
+
+// validateResourceItem checks the item with checkResourceItem. If the item is not acceptable, it responds with the
+// failure and returns false. Otherwise, it warns about deprecated properties and violated soft constraints of the item.
+func (h *Handler) validateResourceItem(w http.ResponseWriter, r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, item *Resource) bool {
+	err := h.checkResourceItem(r.Context(), resourceTypeDefinition, item)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "resource item is not acceptable", "error", err)
+
+		var (
+			unknownFieldsError      UnknownFieldsError
+			resourceInvalidError    ResourceInvalidError
+			settingSchemaError      SettingSchemaError
+			propertyPathError       PropertyPathError
+			templateError           TemplateError
+			serverConfigNameError   ServerConfigNameError
+			schemaReferenceError    SchemaReferenceError
+			resourceTypeSchemaError ResourceTypeSchemaError
+		)
+
+		switch {
+		case errors.As(err, &settingSchemaError):
+			respond.Done(w, r, problem.BadRequest(settingSchemaError.Error(), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &propertyPathError):
+			respond.Done(w, r, problem.BadRequest(propertyPathError.Error(), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &templateError):
+			respond.Done(w, r, problem.BadRequest(templateError.Error(), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &serverConfigNameError):
+			respond.Done(w, r, problem.BadRequest(serverConfigNameError.Error(), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &schemaReferenceError):
+			respond.Done(w, r, problem.BadRequest(schemaReferenceError.Error(), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &resourceTypeSchemaError):
+			respond.Done(w, r, problem.BadRequest(resourceTypeSchemaError.Error(), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &unknownFieldsError):
+			respond.Done(w, r, problem.BadRequest(unknownFieldsError.Error(), problem.WithExtension("fields", unknownFieldsError.Fields), withReason(apierrors.ReasonUnknownFields)))
+		case errors.As(err, &resourceInvalidError):
+			respond.Done(w, r, problem.BadRequest(resourceInvalidError.Error(), problem.WithExtension("errors", resourceInvalidError.Errors), withReason(apierrors.ReasonInvalid)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return false
+	}
+
+	h.warnDeprecatedProperties(w, resourceTypeDefinition, item)
+	h.warnSoftConstraints(r.Context(), w, resourceTypeDefinition, item)
+
+	return true
+}
+
+// resourcePath returns the absolute path of a resource item, including the base path of the handler.
+func (h *Handler) resourcePath(packageName, apiVersion, resourceTypePlural, name string) string {
+	return h.basePath + "/" + path.Join("api", url.PathEscape(packageName), url.PathEscape(apiVersion), url.PathEscape(resourceTypePlural), url.PathEscape(name))
+}
+
+// withTimeout bounds the request context of next by the request timeout of the handler, so repositories receive the
+// deadline.
+func (h *Handler) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.requestTimeout <= 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// featureEnabled reports whether an optional feature is enabled, by the server config if it overrides the feature.
+func (h *Handler) featureEnabled(feature Feature) bool {
+	if featureOverrides := h.featureOverrides.Load(); featureOverrides != nil {
+		if enabled, ok := (*featureOverrides)[feature]; ok {
+			return enabled
+		}
+	}
+
+	return h.features[feature]
+}
+
+// withReason sets the problem type and code of the failure mode of a problem from the error catalog.
+func withReason(reason apierrors.Reason) problem.Option {
+	return func(p *problem.Problem) {
+		problem.WithType(reason.Type())(p)
+		problem.WithExtension("code", string(reason))(p)
+	}
+}
+
+// serverError converts an unexpected error to a problem. Errors caused by the request deadline mean the repository
+// didn't finish within the time budget and are reported as gateway timeout.
+func serverError(err error) problem.Problem {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return problem.CustomError(
+			problem.WithStatus(http.StatusGatewayTimeout),
+			problem.WithTitle("Gateway Timeout"),
+			problem.WithDetail("request did not complete within the time limit"),
+			withReason(apierrors.ReasonTimeout),
+		)
+	}
+
+	return problem.InternalServerError(err, withReason(apierrors.ReasonInternal))
+}
+
+func requestEntityTooLarge(err *http.MaxBytesError) problem.Problem {
+	return problem.CustomError(
+		problem.WithStatus(http.StatusRequestEntityTooLarge),
+		problem.WithTitle("Request Entity Too Large"),
+		problem.WithDetail(fmt.Sprintf("request body exceeds %d bytes", err.Limit)),
+		withReason(apierrors.ReasonRequestTooLarge),
+	)
 }
 
 func (h *Handler) handleListResources() http.HandlerFunc {
@@ -60,32 +408,117 @@ func (h *Handler) handleListResources() http.HandlerFunc {
 
 		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
 
 			var resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
 
 			switch {
 			case errors.As(err, &resourceTypeDefinitionNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceTypeDefinitionNotFoundError.Error()))
+				h.handleListCategoryResources(w, r, resourceTypeDefinitionNotFoundError)
 			default:
-				respond.Done(w, r, problem.InternalServerError(err))
+				respond.Done(w, r, serverError(err))
 			}
 
 			return
 		}
 
+		selector, err := parseResourceSelector(r)
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
 		resourceType := resourceTypeDefinition.ResourceType
 
-		res, err := h.repo.List(r.Context(), packageName, apiVersion, resourceType)
+		res, err := h.repoList(r.Context(), packageName, apiVersion, resourceType)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to list resources", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to list resources", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		res.Items = slices.DeleteFunc(res.Items, func(item *Resource) bool { return !selector.matches(item) })
+
+		if !privilegedRead(r) {
+			anonymizeItems(resourceTypeDefinition, res.Items)
+		}
+
+		if resourceTypeDefinition.TimeSeries != nil {
+			h.respondTimeSeries(w, r, resourceTypeDefinition.TimeSeries, res)
 
 			return
 		}
 
-		respond.Done(w, r, res)
+		res, paginated, err := h.paginate(r, res)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to paginate resources", "error", err)
+
+			var listQueryError ListQueryError
+
+			switch {
+			case errors.As(err, &listQueryError):
+				respond.Done(w, r, problem.BadRequest(listQueryError.Error(), withReason(apierrors.ReasonBadRequest)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		h.respondList(w, r, res, paginated)
+	}
+}
+
+// handleListCategoryResources lists resources of all types in the category named by the URL segment, e.g.
+// "/api/{packageName}/{apiVersion}/all". It responds not found if no resource type is in the category.
+func (h *Handler) handleListCategoryResources(w http.ResponseWriter, r *http.Request, notFoundErr ResourceTypeDefinitionNotFoundError) {
+	packageName := r.PathValue("packageName")
+	apiVersion := r.PathValue("apiVersion")
+	category := r.PathValue("resourceTypePlural")
+
+	resourceTypeDefinitions, err := h.listResourceTypeDefinitionsInCategory(r.Context(), packageName, category)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to list resource type definitions in category", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return
+	}
+
+	if len(resourceTypeDefinitions) == 0 {
+		respond.Done(w, r, problem.NotFound(notFoundErr.Error(), withReason(apierrors.ReasonNotFound)))
+
+		return
+	}
+
+	res := ResourceList{
+		Metadata: ListMetadata{
+			PackageName:  packageName,
+			APIVersion:   apiVersion,
+			ResourceType: "List",
+			Continue:     "",
+		},
+		Items: make([]*Resource, 0),
+	}
+
+	for _, resourceTypeDefinition := range resourceTypeDefinitions {
+		list, err := h.repoList(r.Context(), packageName, apiVersion, resourceTypeDefinition.ResourceType)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to list resources", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		if !privilegedRead(r) {
+			anonymizeItems(resourceTypeDefinition, list.Items)
+		}
+
+		res.Items = append(res.Items, list.Items...)
 	}
+
+	h.respondList(w, r, res, false)
 }
 
 func (h *Handler) handleCreateResource() http.HandlerFunc {
@@ -96,8 +529,8 @@ func (h *Handler) handleCreateResource() http.HandlerFunc {
 
 		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			respond.Done(w, r, serverError(err))
 
 			return
 		}
@@ -108,42 +541,37 @@ func (h *Handler) handleCreateResource() http.HandlerFunc {
 
 		err = json.UnmarshalDecode(dec, &item)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
 
-			var semanticError *json.SemanticError
+			var (
+				semanticError *json.SemanticError
+				maxBytesError *http.MaxBytesError
+			)
 
 			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
 			case errors.As(err, &semanticError):
-				respond.Done(w, r, problem.BadRequest(semanticError.Error()))
+				respond.Done(w, r, problem.BadRequest(semanticError.Error(), withReason(apierrors.ReasonBadRequest)))
 			default:
-				respond.Done(w, r, problem.InternalServerError(err))
+				respond.Done(w, r, serverError(err))
 			}
 
 			return
 		}
 
 		if item.Metadata.Name == "" {
-			slog.ErrorContext(r.Context(), "resource item without name")
-			respond.Done(w, r, problem.BadRequest("resource item without name"))
+			h.logger.ErrorContext(r.Context(), "resource item without name")
+			respond.Done(w, r, problem.BadRequest("resource item without name", withReason(apierrors.ReasonBadRequest)))
 
 			return
 		}
 
-		itemLoader := gojsonschema.NewGoLoader(item.Properties)
-		schemaLoader := gojsonschema.NewGoLoader(resourceTypeDefinition.Versions[0].Schema)
-
-		result, err := gojsonschema.Validate(schemaLoader, itemLoader)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to validate resource item", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
-
+		if !h.checkLegalHoldWrite(w, r, packageName, resourceTypeDefinition.ResourceType, "") {
 			return
 		}
 
-		if !result.Valid() {
-			slog.ErrorContext(r.Context(), "resource item is invalid", "errors", result.Errors())
-			respond.Done(w, r, problem.BadRequest("resource item is invalid", problem.WithExtension("errors", result.Errors())))
-
+		if !h.validateResourceItem(w, r, resourceTypeDefinition, &item) {
 			return
 		}
 
@@ -151,27 +579,40 @@ func (h *Handler) handleCreateResource() http.HandlerFunc {
 		item.Metadata.PackageName = packageName
 		item.Metadata.APIVersion = apiVersion
 		item.Metadata.ResourceType = resourceTypeDefinition.ResourceType
-		item.Metadata.CreatedAt = time.Now()
+		item.Metadata.CreatedAt = h.now()
 		item.Metadata.UpdatedAt = item.Metadata.CreatedAt
 
-		slog.DebugContext(r.Context(), "creating resource", "item", item)
+		if !h.moderateResourceItem(w, r, resourceTypeDefinition, OperationCreate, &item, Precondition{UID: "", ResourceVersion: ""}) {
+			return
+		}
+
+		if resourceTypeDefinition.Approval != nil {
+			h.requestChange(w, r, resourceTypeDefinition, OperationCreate, &item, Precondition{UID: "", ResourceVersion: ""})
+
+			return
+		}
+
+		h.logger.DebugContext(r.Context(), "creating resource", "item", anonymizedItem(resourceTypeDefinition, &item))
 
 		err = h.repo.Create(r.Context(), &item)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to create resource", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to create resource", "error", err)
 
 			var resourceExistsError ResourceExistsError
 
 			switch {
 			case errors.As(err, &resourceExistsError):
-				respond.Done(w, r, problem.Conflict(resourceExistsError.Error()))
+				respond.Done(w, r, problem.Conflict(resourceExistsError.Error(), withReason(apierrors.ReasonAlreadyExists)))
 			default:
-				respond.Done(w, r, problem.InternalServerError(err))
+				respond.Done(w, r, serverError(err))
 			}
 
 			return
 		}
 
+		w.Header().Set("Location", h.resourcePath(packageName, apiVersion, resourceTypePlural, item.Metadata.Name))
+		h.resourceWritten(r.Context(), OperationCreate, &item)
+
 		w.WriteHeader(http.StatusCreated)
 		respond.Done(w, r, item)
 	}
@@ -185,30 +626,77 @@ func (h *Handler) handleGetResource() http.HandlerFunc {
 
 		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			respond.Done(w, r, serverError(err))
 
 			return
 		}
 
 		resourceType := resourceTypeDefinition.ResourceType
 
-		item, err := h.repo.Get(r.Context(), packageName, resourceType, name)
+		locale := r.URL.Query().Get("locale")
+		localized := locale != "" && resourceTypeDefinition.Localization != nil && h.featureEnabled(FeatureLocalization)
+		anonymized := !privilegedRead(r) && hasPII(resourceTypeDefinition.Versions[0].Schema)
+
+		// Items that don't need to be localized or anonymized are written as the repository encoded them, if it keeps
+		// encodings.
+		var (
+			item *Resource
+			raw  jsontext.Value
+		)
+
+		rawGetter, ok := h.repo.(RawGetter)
+		if ok && !localized && !anonymized {
+			raw, err = h.repoGetRaw(r.Context(), rawGetter, packageName, resourceType, name)
+		} else {
+			item, err = h.repoGet(r.Context(), packageName, resourceType, name)
+		}
+
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to get resource", "error", err)
 
 			var resourceNotFoundError ResourceNotFoundError
 
 			switch {
 			case errors.As(err, &resourceNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error()))
+				h.resourceNotFound(w, r, resourceNotFoundError)
 			default:
-				respond.Done(w, r, problem.InternalServerError(err))
+				respond.Done(w, r, serverError(err))
 			}
 
 			return
 		}
 
+		if raw != nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write(raw)
+
+			return
+		}
+
+		if localized {
+			err = checkLocale(locale)
+			if err != nil {
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+				return
+			}
+
+			err = h.localize(r.Context(), resourceTypeDefinition.Localization, item, locale)
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "failed to localize resource", "error", err)
+				respond.Done(w, r, serverError(err))
+
+				return
+			}
+
+			w.Header().Set("Content-Language", locale)
+		}
+
+		if anonymized {
+			item = anonymizedItem(resourceTypeDefinition, item)
+		}
+
 		respond.Done(w, r, item)
 	}
 }
@@ -222,88 +710,129 @@ func (h *Handler) handleReplaceResource() http.HandlerFunc {
 
 		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
 
-			respond.Done(w, r, problem.InternalServerError(err))
+			respond.Done(w, r, serverError(err))
 
 			return
 		}
 
 		resourceType := resourceTypeDefinition.ResourceType
 
+		if !h.checkItemUpdatable(w, r, resourceTypeDefinition, packageName, name) {
+			return
+		}
+
 		dec := jsontext.NewDecoder(r.Body)
 
 		var item Resource
 
 		err = json.UnmarshalDecode(dec, &item)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
 
-			var semanticError *json.SemanticError
-			if errors.As(err, &semanticError) {
-				respond.Done(w, r, problem.BadRequest(semanticError.Error()))
-			} else {
-				respond.Done(w, r, problem.InternalServerError(err))
+			var (
+				semanticError *json.SemanticError
+				maxBytesError *http.MaxBytesError
+			)
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			case errors.As(err, &semanticError):
+				respond.Done(w, r, problem.BadRequest(semanticError.Error(), withReason(apierrors.ReasonBadRequest)))
+			default:
+				respond.Done(w, r, serverError(err))
 			}
 
 			return
 		}
 
-		itemLoader := gojsonschema.NewGoLoader(item.Properties)
-		schemaLoader := gojsonschema.NewGoLoader(resourceTypeDefinition.Versions[0].Schema)
-
-		result, err := gojsonschema.Validate(schemaLoader, itemLoader)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to validate resource item", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
-
+		if !h.validateResourceItem(w, r, resourceTypeDefinition, &item) {
 			return
 		}
 
-		if !result.Valid() {
-			slog.ErrorContext(r.Context(), "resource item is invalid", "errors", result.Errors())
-			respond.Done(w, r, problem.BadRequest("resource item is invalid", problem.WithExtension("errors", result.Errors())))
+		if resourceTypeDefinition.StateMachine != nil {
+			currentItem, err := h.repoGet(r.Context(), packageName, resourceType, name)
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "failed to get current resource item", "error", err)
 
-			return
+				var resourceNotFoundError ResourceNotFoundError
+
+				switch {
+				case errors.As(err, &resourceNotFoundError):
+					h.resourceNotFound(w, r, resourceNotFoundError)
+				default:
+					respond.Done(w, r, serverError(err))
+				}
+
+				return
+			}
+
+			if !h.validateTransition(w, r, resourceTypeDefinition, currentItem, &item) {
+				return
+			}
 		}
 
 		item.Metadata.PackageName = packageName
 		item.Metadata.APIVersion = apiVersion
 		item.Metadata.ResourceType = resourceType
 		item.Metadata.Name = name
-		item.Metadata.UpdatedAt = time.Now()
+		item.Metadata.UpdatedAt = h.now()
 
-		err = h.repo.Update(r.Context(), &item)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to update resource", "error", err)
+		if !h.moderateResourceItem(w, r, resourceTypeDefinition, OperationUpdate, &item, Precondition{UID: "", ResourceVersion: item.Metadata.ResourceVersion}) {
+			return
+		}
 
-			var resourceNotFoundError ResourceNotFoundError
+		if resourceTypeDefinition.Approval != nil {
+			h.requestChange(w, r, resourceTypeDefinition, OperationUpdate, &item, Precondition{UID: "", ResourceVersion: item.Metadata.ResourceVersion})
 
-			switch {
-			case errors.As(err, &resourceNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error()))
-			default:
-				respond.Done(w, r, problem.InternalServerError(err))
-			}
+			return
+		}
+
+		err = h.repo.Update(r.Context(), &item)
+		if err != nil {
+			h.respondUpdateFailure(w, r, err)
 
 			return
 		}
 
+		h.resourceWritten(r.Context(), OperationUpdate, &item)
+
 		respond.Done(w, r, item)
 	}
 }
 
+func (h *Handler) respondUpdateFailure(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.ErrorContext(r.Context(), "failed to update resource", "error", err)
+
+	var (
+		resourceNotFoundError        ResourceNotFoundError
+		resourceVersionConflictError ResourceVersionConflictError
+	)
+
+	switch {
+	case errors.As(err, &resourceNotFoundError):
+		h.resourceNotFound(w, r, resourceNotFoundError)
+	case errors.As(err, &resourceVersionConflictError):
+		respond.Done(w, r, problem.Conflict(resourceVersionConflictError.Error(), withReason(apierrors.ReasonConflict)))
+	default:
+		respond.Done(w, r, serverError(err))
+	}
+}
+
 func (h *Handler) handlePatchResource() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		switch r.Header.Get("Content-Type") {
-		case "application/json-patch+json":
+		switch contentType := r.Header.Get("Content-Type"); {
+		case contentType == "application/json-patch+json" && h.featureEnabled(FeatureJSONPatch):
 			h.handleJSONPatchResource()(w, r)
-		case "application/merge-patch+json":
+		case contentType == "application/merge-patch+json" && h.featureEnabled(FeatureMergePatch):
 			h.handleMergePatchResource()(w, r)
 		default:
 			respond.Done(w, r, problem.CustomError(
 				problem.WithStatus(http.StatusUnsupportedMediaType),
 				problem.WithTitle("Unsupported Media Type"),
+				withReason(apierrors.ReasonUnsupportedMediaType),
 			))
 		}
 	}
@@ -318,119 +847,133 @@ func (h *Handler) handleJSONPatchResource() http.HandlerFunc {
 
 		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
 
-			respond.Done(w, r, problem.InternalServerError(err))
+			respond.Done(w, r, serverError(err))
 
 			return
 		}
 
 		resourceType := resourceTypeDefinition.ResourceType
 
-		currentItem, err := h.repo.Get(r.Context(), packageName, resourceType, name)
+		if !h.checkItemUpdatable(w, r, resourceTypeDefinition, packageName, name) {
+			return
+		}
+
+		currentItem, err := h.repoGet(r.Context(), packageName, resourceType, name)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get current resource item", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to get current resource item", "error", err)
 
 			var resourceNotFoundError ResourceNotFoundError
 
 			switch {
 			case errors.As(err, &resourceNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error()))
+				h.resourceNotFound(w, r, resourceNotFoundError)
 			default:
-				respond.Done(w, r, problem.InternalServerError(err))
+				respond.Done(w, r, serverError(err))
 			}
 
 			return
 		}
 
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to read request body", "error", err)
-
-			respond.Done(w, r, problem.InternalServerError(err))
+		newItem, ok := h.jsonPatchedItem(w, r, currentItem)
+		if !ok {
+			return
+		}
 
+		if !h.validateResourceItem(w, r, resourceTypeDefinition, newItem) {
 			return
 		}
 
-		patch, err := jsonpatch.DecodePatch(body)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to decode JSON patch", "error", err)
+		if !h.validateTransition(w, r, resourceTypeDefinition, currentItem, newItem) {
+			return
+		}
 
-			respond.Done(w, r, problem.InternalServerError(err))
+		newItem.Metadata.PackageName = packageName
+		newItem.Metadata.APIVersion = apiVersion
+		newItem.Metadata.ResourceType = resourceType
+		newItem.Metadata.Name = name
+		newItem.Metadata.UpdatedAt = h.now()
 
+		if !h.moderateResourceItem(w, r, resourceTypeDefinition, OperationUpdate, newItem, Precondition{UID: "", ResourceVersion: currentItem.Metadata.ResourceVersion}) {
 			return
 		}
 
-		original, err := json.Marshal(currentItem)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to marshal original item", "error", err)
-
-			respond.Done(w, r, problem.InternalServerError(err))
+		if resourceTypeDefinition.Approval != nil {
+			h.requestChange(w, r, resourceTypeDefinition, OperationUpdate, newItem, Precondition{UID: "", ResourceVersion: currentItem.Metadata.ResourceVersion})
 
 			return
 		}
 
-		modified, err := patch.Apply(original)
+		err = h.repo.Update(r.Context(), newItem)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to apply JSON patch", "error", err)
-
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.respondUpdateFailure(w, r, err)
 
 			return
 		}
 
-		var newItem Resource
+		h.resourceWritten(r.Context(), OperationUpdate, newItem)
 
-		err = json.Unmarshal(modified, &newItem)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to unmarshal modified item", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+		respond.Done(w, r, newItem)
+	}
+}
 
-			return
+// jsonPatchedItem applies the JSON patch in the body of r to currentItem. If it can't, it responds, and returns false.
+func (h *Handler) jsonPatchedItem(w http.ResponseWriter, r *http.Request, currentItem *Resource) (*Resource, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to read request body", "error", err)
+
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+		default:
+			respond.Done(w, r, serverError(err))
 		}
 
-		itemLoader := gojsonschema.NewGoLoader(newItem.Properties)
-		schemaLoader := gojsonschema.NewGoLoader(resourceTypeDefinition.Versions[0].Schema)
+		return nil, false
+	}
 
-		result, err := gojsonschema.Validate(schemaLoader, itemLoader)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to validate resource item", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+	patch, err := jsonpatch.DecodePatch(body)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to decode JSON patch", "error", err)
 
-			return
-		}
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return nil, false
+	}
 
-		if !result.Valid() {
-			slog.ErrorContext(r.Context(), "resource item is invalid", "errors", result.Errors())
-			respond.Done(w, r, problem.BadRequest("resource item is invalid", problem.WithExtension("errors", result.Errors())))
+	original, err := json.Marshal(currentItem)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to marshal original item", "error", err)
 
-			return
-		}
+		respond.Done(w, r, serverError(err))
 
-		newItem.Metadata.PackageName = packageName
-		newItem.Metadata.APIVersion = apiVersion
-		newItem.Metadata.ResourceType = resourceType
-		newItem.Metadata.Name = name
-		newItem.Metadata.UpdatedAt = time.Now()
+		return nil, false
+	}
 
-		err = h.repo.Update(r.Context(), &newItem)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to update resource", "error", err)
+	modified, err := patch.Apply(original)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to apply JSON patch", "error", err)
 
-			var resourceNotFoundError ResourceNotFoundError
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
 
-			switch {
-			case errors.As(err, &resourceNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error()))
-			default:
-				respond.Done(w, r, problem.InternalServerError(err))
-			}
+		return nil, false
+	}
 
-			return
-		}
+	var newItem Resource
 
-		respond.Done(w, r, newItem)
+	err = json.Unmarshal(modified, &newItem)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to unmarshal modified item", "error", err)
+		respond.Done(w, r, problem.BadRequest("patched item is not a resource: "+err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return nil, false
 	}
+
+	return &newItem, true
 }
 
 func (h *Handler) handleMergePatchResource() http.HandlerFunc {
@@ -442,25 +985,29 @@ func (h *Handler) handleMergePatchResource() http.HandlerFunc {
 
 		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			respond.Done(w, r, serverError(err))
 
 			return
 		}
 
 		resourceType := resourceTypeDefinition.ResourceType
 
-		currentItem, err := h.repo.Get(r.Context(), packageName, resourceType, name)
+		if !h.checkItemUpdatable(w, r, resourceTypeDefinition, packageName, name) {
+			return
+		}
+
+		currentItem, err := h.repoGet(r.Context(), packageName, resourceType, name)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get current resource item", "error", err)
+			h.logger.ErrorContext(r.Context(), "failed to get current resource item", "error", err)
 
 			var resourceNotFoundError ResourceNotFoundError
 
 			switch {
 			case errors.As(err, &resourceNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error()))
+				h.resourceNotFound(w, r, resourceNotFoundError)
 			default:
-				respond.Done(w, r, problem.InternalServerError(err))
+				respond.Done(w, r, serverError(err))
 			}
 
 			return
@@ -468,24 +1015,32 @@ func (h *Handler) handleMergePatchResource() http.HandlerFunc {
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to read request body", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to read request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
 
 			return
 		}
 
 		original, err := json.Marshal(currentItem)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to marshal original item", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to marshal original item", "error", err)
+			respond.Done(w, r, serverError(err))
 
 			return
 		}
 
 		modified, err := jsonpatch.MergePatch(original, body)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to apply JSON merge patch", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to apply JSON merge patch", "error", err)
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
 
 			return
 		}
@@ -494,27 +1049,17 @@ func (h *Handler) handleMergePatchResource() http.HandlerFunc {
 
 		err = json.Unmarshal(modified, &newItem)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to unmarshal modified item", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to unmarshal modified item", "error", err)
+			respond.Done(w, r, problem.BadRequest("patched item is not a resource: "+err.Error(), withReason(apierrors.ReasonBadRequest)))
 
 			return
 		}
 
-		itemLoader := gojsonschema.NewGoLoader(newItem.Properties)
-		schemaLoader := gojsonschema.NewGoLoader(resourceTypeDefinition.Versions[0].Schema)
-
-		result, err := gojsonschema.Validate(schemaLoader, itemLoader)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to validate resource item", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
-
+		if !h.validateResourceItem(w, r, resourceTypeDefinition, &newItem) {
 			return
 		}
 
-		if !result.Valid() {
-			slog.ErrorContext(r.Context(), "resource item is invalid", "errors", result.Errors())
-			respond.Done(w, r, problem.BadRequest("resource item is invalid", problem.WithExtension("errors", result.Errors())))
-
+		if !h.validateTransition(w, r, resourceTypeDefinition, currentItem, &newItem) {
 			return
 		}
 
@@ -522,24 +1067,27 @@ func (h *Handler) handleMergePatchResource() http.HandlerFunc {
 		newItem.Metadata.APIVersion = apiVersion
 		newItem.Metadata.ResourceType = resourceType
 		newItem.Metadata.Name = name
-		newItem.Metadata.UpdatedAt = time.Now()
+		newItem.Metadata.UpdatedAt = h.now()
 
-		err = h.repo.Update(r.Context(), &newItem)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to update resource", "error", err)
+		if !h.moderateResourceItem(w, r, resourceTypeDefinition, OperationUpdate, &newItem, Precondition{UID: "", ResourceVersion: currentItem.Metadata.ResourceVersion}) {
+			return
+		}
 
-			var resourceNotFoundError ResourceNotFoundError
+		if resourceTypeDefinition.Approval != nil {
+			h.requestChange(w, r, resourceTypeDefinition, OperationUpdate, &newItem, Precondition{UID: "", ResourceVersion: currentItem.Metadata.ResourceVersion})
 
-			switch {
-			case errors.As(err, &resourceNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error()))
-			default:
-				respond.Done(w, r, problem.InternalServerError(err))
-			}
+			return
+		}
+
+		err = h.repo.Update(r.Context(), &newItem)
+		if err != nil {
+			h.respondUpdateFailure(w, r, err)
 
 			return
 		}
 
+		h.resourceWritten(r.Context(), OperationUpdate, &newItem)
+
 		respond.Done(w, r, newItem)
 	}
 }
@@ -552,30 +1100,207 @@ func (h *Handler) handleDeleteResource() http.HandlerFunc {
 
 		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
-			respond.Done(w, r, problem.InternalServerError(err))
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			respond.Done(w, r, serverError(err))
 
 			return
 		}
 
 		resourceType := resourceTypeDefinition.ResourceType
 
-		err = h.repo.Delete(r.Context(), packageName, resourceType, name)
+		if !h.checkLegalHoldWrite(w, r, packageName, resourceType, name) {
+			return
+		}
+
+		precondition := deletePrecondition(r)
+
+		returnDeleted, err := parseBoolQuery(r, "returnDeleted")
 		if err != nil {
-			slog.ErrorContext(r.Context(), "failed to delete resource", "error", err)
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
 
-			var resourceNotFoundError ResourceNotFoundError
+			return
+		}
 
-			switch {
-			case errors.As(err, &resourceNotFoundError):
-				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error()))
-			default:
-				respond.Done(w, r, problem.InternalServerError(err))
-			}
+		if resourceTypeDefinition.Approval != nil {
+			h.requestChange(w, r, resourceTypeDefinition, OperationDelete, &Resource{Metadata: Metadata{Name: name}, Properties: nil}, precondition)
+
+			return
+		}
+
+		// Deletes returning the deleted item delete it with a precondition too, so it is the item deleted.
+		transactionalRepo, ok := h.repo.(TransactionalRepository)
+		if !ok && (returnDeleted || precondition != (Precondition{})) {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("repository does not support delete preconditions"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		var deleted *Resource
+
+		switch {
+		case returnDeleted:
+			deleted, err = h.deleteReturning(r.Context(), transactionalRepo, packageName, resourceType, name, precondition)
+		case precondition != (Precondition{}):
+			err = deleteWithPrecondition(r.Context(), transactionalRepo, packageName, resourceType, name, precondition)
+		default:
+			err = h.repoDelete(r.Context(), packageName, resourceType, name)
+		}
+
+		if err != nil {
+			h.respondDeleteFailure(w, r, err)
 
 			return
 		}
 
+		h.deleteActivity(r.Context(), packageName, resourceType, name)
+		h.deleteLocalizations(r.Context(), packageName, resourceType, name)
+
+		if deleted != nil {
+			h.resourceWritten(r.Context(), OperationDelete, deleted)
+		} else {
+			h.resourceWritten(r.Context(), OperationDelete, &Resource{
+				Metadata:   Metadata{PackageName: packageName, ResourceType: resourceType, Name: name},
+				Properties: nil,
+			})
+		}
+
+		respondDeleted(w, r, resourceTypeDefinition, deleted)
+	}
+}
+
+// deleteWithPrecondition deletes an item in a single operation transaction, which checks the precondition and deletes
+// atomically.
+func deleteWithPrecondition(ctx context.Context, transactionalRepo TransactionalRepository, packageName, resourceType, name string, precondition Precondition) error {
+	//nolint:wrapcheck // repositories wrap their errors
+	return transactionalRepo.Transact(ctx, []Operation{{
+		Type: OperationDelete,
+		Item: &Resource{
+			Metadata:   Metadata{PackageName: packageName, ResourceType: resourceType, Name: name},
+			Properties: nil,
+		},
+		Precondition: precondition,
+	}})
+}
+
+// deleteReturning deletes an item if it satisfies the precondition, and returns it as it was deleted. The item is
+// fetched, and deleted only if it wasn't written since; if it was, it is fetched again, up to conflictAttempts times.
+func (h *Handler) deleteReturning(ctx context.Context, transactionalRepo TransactionalRepository, packageName, resourceType, name string, precondition Precondition) (*Resource, error) {
+	var deleted *Resource
+
+	err := retryConflicts(ctx, func() error {
+		item, err := h.repoGet(ctx, packageName, resourceType, name)
+		if err != nil {
+			return err
+		}
+
+		err = CheckPrecondition(item, precondition)
+		if err != nil {
+			return err
+		}
+
+		err = deleteWithPrecondition(ctx, transactionalRepo, packageName, resourceType, name, Precondition{UID: item.Metadata.UID, ResourceVersion: item.Metadata.ResourceVersion})
+
+		var preconditionFailedError PreconditionFailedError
+		if errors.As(err, &preconditionFailedError) {
+			// The item changed since it was read, so it is read again.
+			return ResourceVersionConflictError{
+				PackageName:     packageName,
+				ResourceType:    resourceType,
+				Name:            name,
+				ResourceVersion: item.Metadata.ResourceVersion,
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		deleted = item
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// respondDeleted responds with the deleted item, if it was asked for, with its PII anonymized for readers that may
+// not read it, or with no content.
+func respondDeleted(w http.ResponseWriter, r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, deleted *Resource) {
+	if deleted == nil {
 		respond.Done(w, r, nil)
+
+		return
+	}
+
+	if !privilegedRead(r) {
+		deleted = anonymizedItem(resourceTypeDefinition, deleted)
+	}
+
+	respond.Done(w, r, deleted)
+}
+
+func (h *Handler) respondDeleteFailure(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.ErrorContext(r.Context(), "failed to delete resource", "error", err)
+
+	var (
+		resourceNotFoundError        ResourceNotFoundError
+		preconditionFailedError      PreconditionFailedError
+		resourceVersionConflictError ResourceVersionConflictError
+	)
+
+	switch {
+	case errors.As(err, &resourceNotFoundError):
+		h.resourceNotFound(w, r, resourceNotFoundError)
+	case errors.As(err, &resourceVersionConflictError):
+		respond.Done(w, r, problem.Conflict(resourceVersionConflictError.Error(), withReason(apierrors.ReasonConflict)))
+	case errors.As(err, &preconditionFailedError):
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusPreconditionFailed),
+			problem.WithTitle("Precondition Failed"),
+			problem.WithDetail(preconditionFailedError.Error()),
+			withReason(apierrors.ReasonPreconditionFailed),
+		))
+	default:
+		respond.Done(w, r, serverError(err))
+	}
+}
+
+// parseBoolQuery parses an optional boolean query parameter, which is false when absent.
+func parseBoolQuery(r *http.Request, key string) (bool, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s query parameter %q: %w", key, value, err)
+	}
+
+	return b, nil
+}
+
+// deletePrecondition reads the delete precondition of a request from the preconditionUID query parameter and the
+// resource version in the If-Match header.
+func deletePrecondition(r *http.Request) Precondition {
+	resourceVersion := strings.TrimSpace(r.Header.Get("If-Match"))
+	resourceVersion = strings.TrimPrefix(resourceVersion, "W/")
+	resourceVersion = strings.Trim(resourceVersion, `"`)
+
+	if resourceVersion == "*" {
+		resourceVersion = ""
+	}
+
+	return Precondition{
+		UID:             r.URL.Query().Get("preconditionUID"),
+		ResourceVersion: resourceVersion,
 	}
 }
@@ -0,0 +1,371 @@
+package bass
+
+import (
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/gertd/go-pluralize"
+)
+
+// DefaultMaxBodyBytes is the request body size limit used when WithMaxBodyBytes is not given.
+const DefaultMaxBodyBytes int64 = 10 << 20
+
+// DefaultRevisionHistoryLimit is the number of revisions kept in the revision history when WithRevisionHistoryLimit is
+// not given.
+const DefaultRevisionHistoryLimit = 100_000
+
+// Feature names an optional feature gated by the handler.
+type Feature string
+
+const (
+	FeatureJSONPatch     Feature = "JSONPatch"
+	FeatureMergePatch    Feature = "MergePatch"
+	FeatureCompression   Feature = "Compression"
+	FeatureLocalization  Feature = "Localization"
+	FeatureNotifications Feature = "Notifications"
+	FeatureAutomations   Feature = "Automations"
+)
+
+// FeatureStage is the maturity of a feature. Alpha features are disabled by default, beta and GA features are
+// enabled by default.
+type FeatureStage string
+
+const (
+	FeatureStageAlpha FeatureStage = "alpha"
+	FeatureStageBeta  FeatureStage = "beta"
+	FeatureStageGA    FeatureStage = "GA"
+)
+
+// featureStages returns the stage of every known feature.
+func featureStages() map[Feature]FeatureStage {
+	return map[Feature]FeatureStage{
+		FeatureJSONPatch:     FeatureStageGA,
+		FeatureMergePatch:    FeatureStageGA,
+		FeatureCompression:   FeatureStageGA,
+		FeatureLocalization:  FeatureStageBeta,
+		FeatureNotifications: FeatureStageBeta,
+		FeatureAutomations:   FeatureStageAlpha,
+	}
+}
+
+func defaultFeatures() map[Feature]bool {
+	stages := featureStages()
+	features := make(map[Feature]bool, len(stages))
+
+	for feature, stage := range stages {
+		features[feature] = stage != FeatureStageAlpha
+	}
+
+	return features
+}
+
+type Option func(h *Handler)
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		h.now = now
+	}
+}
+
+func WithPluralizeClient(client *pluralize.Client) Option {
+	return func(h *Handler) {
+		h.pluralizeClient = client
+	}
+}
+
+// WithPluralOverrides registers irregular singular to plural forms the pluralize client gets wrong, e.g.
+// {"person": "persons"}. Resource type URL segments given in singular are resolved with these forms.
+func WithPluralOverrides(overrides map[string]string) Option {
+	return func(h *Handler) {
+		maps.Copy(h.pluralOverrides, overrides)
+	}
+}
+
+// WithBasePath serves the API under the given path prefix, e.g. "/v1" serves "/v1/api/...".
+func WithBasePath(basePath string) Option {
+	return func(h *Handler) {
+		h.basePath = strings.TrimSuffix(basePath, "/")
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies. Zero or a negative value disables the limit.
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodyBytes = n
+	}
+}
+
+// WithListLimits sets the page sizes and the maximum size of unpaginated lists of list endpoints. By default, lists
+// are neither paginated nor limited unless clients ask for pages.
+func WithListLimits(limits ListLimits) Option {
+	return func(h *Handler) {
+		h.listLimits = limits
+	}
+}
+
+// WithUnknownFieldPolicy sets how fields not declared in resource type schemas are handled. The default is
+// UnknownFieldPolicyPreserve.
+func WithUnknownFieldPolicy(policy UnknownFieldPolicy) Option {
+	return func(h *Handler) {
+		h.unknownFieldPolicy = policy
+	}
+}
+
+// WithPruning removes properties not declared in resource type schemas before items are validated and stored,
+// recursively. Schemas can opt out for a subtree with "x-preserve-unknown-fields: true".
+func WithPruning(enabled bool) Option {
+	return func(h *Handler) {
+		h.pruning = enabled
+	}
+}
+
+// WithRequestTimeout limits the time CRUD requests may take. Repository calls get the deadline through their context,
+// and requests exceeding it are responded with gateway timeout. Zero or a negative value disables the limit.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.requestTimeout = timeout
+	}
+}
+
+// WithRetryPolicy sets how list, get and delete repository calls failing with a TransientError are retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(h *Handler) {
+		h.retryPolicy = policy
+	}
+}
+
+// WithMetrics serves per resource type gauges of stored resources and counters of writes in the OpenMetrics text
+// format on "GET /metrics". Stored resource counts are only reported for repositories implementing ResourceCounter.
+// Once API keys are configured, scraping them takes a secret key of the core package, as the admin endpoints do.
+func WithMetrics(enabled bool) Option {
+	return func(h *Handler) {
+		h.metricsEnabled = enabled
+	}
+}
+
+// WithDebug serves the runtime profiles of net/http/pprof on "/debug/pprof/" and a snapshot of the in-memory state of
+// the server, like cached schema definitions, materialized views, watches, and queues, on "GET /debug/state". Like
+// the admin endpoints, they are only guarded by the network policy, so they should only be enabled for trusted
+// networks.
+func WithDebug(enabled bool) Option {
+	return func(h *Handler) {
+		h.debugEnabled = enabled
+	}
+}
+
+// WithFaultInjection injects latency, errors, and dropped watches into the requests matching the rules, the first
+// matching rule applying. It is meant for testing clients against a misbehaving server, never for production.
+func WithFaultInjection(rules ...FaultRule) Option {
+	return func(h *Handler) {
+		h.faultRules = rules
+	}
+}
+
+// WithMeterSink records a MeterEvent for every API request to sink, for billing tenants.
+func WithMeterSink(sink MeterSink) Option {
+	return func(h *Handler) {
+		h.meterSink = sink
+	}
+}
+
+// WithMeterSubject sets how the subject a request is billed to, e.g. an API token ID, is resolved for meter events.
+func WithMeterSubject(subject func(r *http.Request) string) Option {
+	return func(h *Handler) {
+		h.meterSubject = subject
+	}
+}
+
+// WithChangeRequestNotifier notifies n about the change requests of protected resource types, e.g. to ask for
+// approvals.
+func WithChangeRequestNotifier(n ChangeRequestNotifier) Option {
+	return func(h *Handler) {
+		h.changeRequestNotifier = n
+	}
+}
+
+// WithEmailSender enables sending emails from email templates through sender.
+func WithEmailSender(sender EmailSender) Option {
+	return func(h *Handler) {
+		h.emailSender = sender
+	}
+}
+
+// WithPushSender enables sending push notifications to device tokens of the platform, "apns" or "fcm", through
+// sender.
+func WithPushSender(platform string, sender PushSender) Option {
+	return func(h *Handler) {
+		h.pushSenders[platform] = sender
+	}
+}
+
+// WithNotifier enables delivering notifications to channels of the type, e.g. "webhook", through notifier.
+func WithNotifier(channelType string, notifier Notifier) Option {
+	return func(h *Handler) {
+		h.notifiers[channelType] = notifier
+	}
+}
+
+// WithModerationClassifier enables moderation policies to check content with classifier, by name.
+func WithModerationClassifier(name string, classifier ModerationClassifier) Option {
+	return func(h *Handler) {
+		h.moderationClassifiers[name] = classifier
+	}
+}
+
+// WithNetworkPolicy restricts the client addresses allowed to access the API. Denied requests get 403 Forbidden.
+func WithNetworkPolicy(policy NetworkPolicy) Option {
+	return func(h *Handler) {
+		h.networkPolicy = &policy
+	}
+}
+
+// WithPackageNetworkPolicy restricts the client addresses allowed to access the package, in addition to the global
+// network policy.
+func WithPackageNetworkPolicy(packageName string, policy NetworkPolicy) Option {
+	return func(h *Handler) {
+		h.packageNetworkPolicies[packageName] = policy
+	}
+}
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For headers are trusted to carry the client address for
+// network policies.
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(h *Handler) {
+		h.trustedProxies = prefixes
+	}
+}
+
+// WithCountryResolver enables the country rules of network policies, resolving client countries with resolver.
+func WithCountryResolver(resolver CountryResolver) Option {
+	return func(h *Handler) {
+		h.countryResolver = resolver
+	}
+}
+
+// WithRequestSigning requires requests to be signed with HMAC, e.g. by machine integrations sending webhooks.
+// Requests without a valid signature get 401 Unauthorized.
+func WithRequestSigning(signing RequestSigning) Option {
+	return func(h *Handler) {
+		h.requestSigning = &signing
+	}
+}
+
+// WithMaintenance sets the initial maintenance mode of the server. It can be changed on "PUT /admin/maintenance".
+func WithMaintenance(maintenance Maintenance) Option {
+	return func(h *Handler) {
+		h.maintenance.Store(&maintenance)
+	}
+}
+
+// WithRevisionHistory enables recording the writes of resources, from the creation of the handler on, for point in
+// time restores on "POST /admin/restore" and syncs of offline clients on "POST /sync". The history is kept in memory,
+// up to the limit of WithRevisionHistoryLimit.
+func WithRevisionHistory(enabled bool) Option {
+	return func(h *Handler) {
+		h.revisionHistory = enabled
+	}
+}
+
+// WithRevisionHistoryLimit keeps at most limit revisions in the revision history, DefaultRevisionHistoryLimit by
+// default, dropping the oldest beyond it. Restores can't go back before the dropped revisions, and sync tokens from
+// before them expire. Zero keeps all revisions.
+func WithRevisionHistoryLimit(limit int) Option {
+	return func(h *Handler) {
+		h.revisionLimit = limit
+	}
+}
+
+// WithAPIKeys requires requests to "/api/" to carry one of keys, in the X-Bass-Api-Key header or the "apiKey" query
+// parameter, and restricts them to the package and permissions of the key. Other routes, like the admin endpoints,
+// are only guarded by the network policy.
+func WithAPIKeys(keys ...APIKey) Option {
+	return func(h *Handler) {
+		h.apiKeys = append(h.apiKeys, keys...)
+	}
+}
+
+// WithRenameAliases keeps the old names of renamed resources as aliases for period, redirecting requests for them to
+// the new names, so existing links don't break. Zero, the default, disables aliases.
+func WithRenameAliases(period time.Duration) Option {
+	return func(h *Handler) {
+		h.renameAliasPeriod = period
+	}
+}
+
+// WithArchiving enables tiering of stale resources to cold storage by Handler.Archive.
+func WithArchiving(archiving Archiving) Option {
+	return func(h *Handler) {
+		h.archiving = &archiving
+	}
+}
+
+// WithEventLogging enables the event log of writes, for watches to resume from and replay, trimmed to the retention by
+// Handler.TrimEventLog.
+func WithEventLogging(eventLogging EventLogging) Option {
+	return func(h *Handler) {
+		h.eventLogging = &eventLogging
+	}
+}
+
+// WithOutbox configures the dispatch of the outbox of the repository by Handler.RunOutbox.
+func WithOutbox(outbox Outbox) Option {
+	return func(h *Handler) {
+		h.outbox = &outbox
+	}
+}
+
+// WithBackupPolicy configures the backups taken by Handler.RunBackups, listed on "GET /admin/backups".
+func WithBackupPolicy(policy BackupPolicy) Option {
+	return func(h *Handler) {
+		h.backupPolicy = &policy
+	}
+}
+
+// WithReplication forwards the writes made through the handler to replicas, as Handler.RunReplication sends them.
+func WithReplication(replication Replication) Option {
+	return func(h *Handler) {
+		if replication.ConflictPolicy == "" {
+			replication.ConflictPolicy = ConflictLastWriterWins
+		}
+
+		h.replication = &replication
+	}
+}
+
+// WithLogLevelVar lets the server config change the log level through level, which the logger of the handler should
+// be created with. Without a server config log level, the level it had when given is used.
+func WithLogLevelVar(level *slog.LevelVar) Option {
+	return func(h *Handler) {
+		h.logLevel = level
+		h.initialLogLevel = level.Level()
+	}
+}
+
+// WithFeatures replaces the set of enabled optional features. By default, beta and GA features are enabled.
+func WithFeatures(features ...Feature) Option {
+	return func(h *Handler) {
+		h.features = make(map[Feature]bool, len(features))
+
+		for _, feature := range features {
+			h.features[feature] = true
+		}
+	}
+}
+
+// WithFeatureGates enables or disables the given features, keeping the others as they are, e.g. to try an alpha
+// feature.
+func WithFeatureGates(gates map[Feature]bool) Option {
+	return func(h *Handler) {
+		maps.Copy(h.features, gates)
+	}
+}
@@ -2,15 +2,39 @@ package bass_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json/jsontext"
 	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/nasermirzaei89/bass"
+	"github.com/nasermirzaei89/bass/apierrors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -443,3 +467,7840 @@ func TestAPI(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, rec.Code)
 	}
 }
+
+func TestHandlerOptions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithBasePath("/bass/"),
+		bass.WithClock(func() time.Time { return now }),
+		bass.WithMaxBodyBytes(512),
+		bass.WithFeatures(bass.FeatureJSONPatch),
+	)
+
+	// register resource type under base path
+	{
+		body := bytes.NewBufferString(`{"package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}], "metadata": {"name": "foos.test"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/bass/api/core/v1/resourcetypedefinitions", body)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// route without base path
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// create uses clock and links under base path
+	{
+		body := bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": 1}`)
+		req := httptest.NewRequest(http.MethodPost, "/bass/api/test/v1/foos", body)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/bass/api/test/v1/foos/foo1", rec.Header().Get("Location"))
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.True(t, now.Equal(res.Metadata.CreatedAt))
+	}
+
+	// mounted behind an existing mux
+	{
+		mux := http.NewServeMux()
+		mux.Handle("/bass/", h)
+
+		req := httptest.NewRequest(http.MethodGet, "/bass/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// body too large
+	{
+		body := bytes.NewBufferString(`{"metadata": {"name": "foo2"}, "bar": "` + strings.Repeat("x", 1024) + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/bass/api/test/v1/foos", body)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	}
+
+	// disabled feature
+	{
+		body := bytes.NewBufferString(`{"bar": 1}`)
+		req := httptest.NewRequest(http.MethodPatch, "/bass/api/test/v1/foos/foo1", body)
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	}
+}
+
+func TestResourceTypeNames(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithPluralOverrides(map[string]string{"octopus": "octopuses"}))
+
+	// register resource types with short name alias
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "shortNames": ["fo"], "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+			`{"metadata": {"name": "octopuses.test"}, "package": "test", "resourceType": "Octopus", "plural": "octopuses", "singular": "octo", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/rtd", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// list by plural, singular, short name, and override
+	{
+		for _, segment := range []string{"foos", "foo", "fo", "octopuses", "octopus", "octo"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/test/v1/"+segment, nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code, segment)
+		}
+	}
+
+	// unknown name
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/bars", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestResourceTypeCategories(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource types and items
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "categories": ["all"], "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+			`{"metadata": {"name": "bars.test"}, "package": "test", "resourceType": "Bar", "plural": "bars", "categories": ["all", "bars"], "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+			`{"metadata": {"name": "bazs.test"}, "package": "test", "resourceType": "Baz", "plural": "bazs", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+
+		for _, plural := range []string{"foos", "bars", "bazs"} {
+			req := httptest.NewRequest(http.MethodPost, "/api/test/v1/"+plural, bytes.NewBufferString(`{"metadata": {"name": "item1"}, "a": 1}`))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// list category
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/all", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "List", res.Metadata.ResourceType)
+		require.Len(t, res.Items, 2)
+		assert.ElementsMatch(t, []string{"Foo", "Bar"}, []string{res.Items[0].Metadata.ResourceType, res.Items[1].Metadata.ResourceType})
+	}
+
+	// resource type plural takes precedence over category
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/bars", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "BarList", res.Metadata.ResourceType)
+	}
+
+	// unknown category
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/none", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestUnknownFieldPolicy(t *testing.T) {
+	t.Parallel()
+
+	rtd := `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "integer"}}}}]}`
+
+	newHandler := func(t *testing.T, policy bass.UnknownFieldPolicy) *bass.Handler {
+		t.Helper()
+
+		h := bass.NewHandler(bass.NewMemRepo(), bass.WithUnknownFieldPolicy(policy))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(rtd))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		return h
+	}
+
+	// strip
+	{
+		h := newHandler(t, bass.UnknownFieldPolicyStrip)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": 1, "barr": 2}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Contains(t, res.Properties, "bar")
+		assert.NotContains(t, res.Properties, "barr")
+
+		req = httptest.NewRequest(http.MethodPatch, "/api/test/v1/foos/foo1", bytes.NewBufferString(`{"bazz": true}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "bazz")
+	}
+
+	// reject
+	{
+		h := newHandler(t, bass.UnknownFieldPolicyReject)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": 1, "barr": 2}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "barr")
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": 1}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+}
+
+func TestPruning(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithPruning(true))
+
+	// register resource type
+	{
+		body := `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {
+"type": "object",
+"properties": {
+	"bar": {"type": "object", "properties": {"baz": {"type": "string"}}},
+	"tags": {"type": "array", "items": {"type": "object", "properties": {"key": {"type": "string"}}}},
+	"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+	"raw": {"type": "object", "properties": {}, "x-preserve-unknown-fields": true}
+}}}]}`
+
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create prunes undeclared properties
+	{
+		body := `{"metadata": {"name": "foo1"}, "junk": 1, "bar": {"baz": "a", "junk": 2}, "tags": [{"key": "k", "junk": 3}], "labels": {"any": "x"}, "raw": {"kept": 4}}`
+
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.NotContains(t, res.Properties, "junk")
+		assert.Equal(t, map[string]any{"baz": "a"}, res.Properties["bar"])
+		assert.Equal(t, []any{map[string]any{"key": "k"}}, res.Properties["tags"])
+		assert.Equal(t, map[string]any{"any": "x"}, res.Properties["labels"])
+		assert.Equal(t, map[string]any{"kept": float64(4)}, res.Properties["raw"])
+	}
+}
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	for _, tc := range []struct {
+		acceptEncoding  string
+		contentEncoding string
+		decode          func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			acceptEncoding:  "",
+			contentEncoding: "",
+			decode:          func(r io.Reader) (io.Reader, error) { return r, nil },
+		},
+		{
+			acceptEncoding:  "gzip, deflate",
+			contentEncoding: "gzip",
+			decode:          func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+		{
+			acceptEncoding:  "gzip;q=0.5, zstd",
+			contentEncoding: "zstd",
+			decode:          func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		},
+		{
+			acceptEncoding:  "zstd;q=0, gzip;q=0",
+			contentEncoding: "",
+			decode:          func(r io.Reader) (io.Reader, error) { return r, nil },
+		},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/resourcetypedefinitions", nil)
+		req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, tc.contentEncoding, rec.Header().Get("Content-Encoding"))
+
+		body, err := tc.decode(rec.Body)
+		require.NoError(t, err)
+
+		var res bass.ResourceList
+
+		err = json.UnmarshalRead(body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "ResourceTypeDefinitionList", res.Metadata.ResourceType)
+	}
+}
+
+type slowRepo struct {
+	*bass.MemRepo
+}
+
+func (repo slowRepo) List(ctx context.Context, _, _, _ string) (bass.ResourceList, error) {
+	<-ctx.Done()
+
+	return bass.ResourceList{}, fmt.Errorf("slow list: %w", ctx.Err())
+}
+
+func TestRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(slowRepo{MemRepo: bass.NewMemRepo()}, bass.WithRequestTimeout(10*time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/core/v1/resourcetypedefinitions", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+}
+
+type transientError struct{}
+
+func (transientError) Error() string   { return "temporarily unavailable" }
+func (transientError) Transient() bool { return true }
+
+type flakyRepo struct {
+	*bass.MemRepo
+
+	failures atomic.Int32
+	// lostDeletes is how many deletes fail after deleting, as if their response was lost.
+	lostDeletes atomic.Int32
+}
+
+func (repo *flakyRepo) Get(ctx context.Context, packageName, resourceType, name string) (*bass.Resource, error) {
+	if repo.failures.Add(-1) >= 0 {
+		return nil, transientError{}
+	}
+
+	return repo.MemRepo.Get(ctx, packageName, resourceType, name) //nolint:wrapcheck
+}
+
+func (repo *flakyRepo) Delete(ctx context.Context, packageName, resourceType, name string) error {
+	err := repo.MemRepo.Delete(ctx, packageName, resourceType, name)
+	if err == nil && repo.lostDeletes.Add(-1) >= 0 {
+		return transientError{}
+	}
+
+	return err //nolint:wrapcheck
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	repo := &flakyRepo{MemRepo: bass.NewMemRepo()}
+	h := bass.NewHandler(repo, bass.WithRetryPolicy(bass.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+
+	// register resource type and item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": 1}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// recovers within attempts
+	{
+		repo.failures.Store(2)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// gives up after attempts
+	{
+		repo.failures.Store(10)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	}
+
+	// retried deletes succeed if a failed attempt deleted the item
+	{
+		repo.failures.Store(0)
+		repo.lostDeletes.Store(1)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		req = httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "accounts.test"}, "package": "test", "resourceType": "Account", "plural": "accounts", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"balance": {"type": "integer"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create accounts atomically
+	{
+		body := `{"operations": [
+{"op": "create", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "accounts", "name": "alice", "properties": {"balance": 100}},
+{"op": "create", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "accounts", "name": "bob", "properties": {"balance": 0}}
+]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/transactions", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.TransactionResponse
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		require.Len(t, res.Results, 2)
+		assert.Equal(t, http.StatusCreated, res.Results[0].Status)
+		assert.NotEmpty(t, res.Results[0].Item.Metadata.ResourceVersion)
+	}
+
+	getAccount := func(t *testing.T, name string) *bass.Resource {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/accounts/"+name, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		return &res
+	}
+
+	// failed precondition applies nothing
+	{
+		alice := getAccount(t, "alice")
+
+		body := `{"operations": [
+{"op": "update", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "accounts", "name": "alice", "properties": {"balance": 50}, "precondition": {"resourceVersion": "` + alice.Metadata.ResourceVersion + `"}},
+{"op": "update", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "accounts", "name": "bob", "properties": {"balance": 50}, "precondition": {"resourceVersion": "stale"}}
+]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/transactions", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assert.InDelta(t, float64(100), getAccount(t, "alice").Properties["balance"], 0)
+	}
+
+	// transfer with preconditions
+	{
+		alice := getAccount(t, "alice")
+		bob := getAccount(t, "bob")
+
+		body := `{"operations": [
+{"op": "update", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "accounts", "name": "alice", "properties": {"balance": 50}, "precondition": {"resourceVersion": "` + alice.Metadata.ResourceVersion + `"}},
+{"op": "update", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "accounts", "name": "bob", "properties": {"balance": 50}, "precondition": {"resourceVersion": "` + bob.Metadata.ResourceVersion + `"}}
+]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/transactions", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.InDelta(t, float64(50), getAccount(t, "alice").Properties["balance"], 0)
+		assert.InDelta(t, float64(50), getAccount(t, "bob").Properties["balance"], 0)
+		assert.Equal(t, alice.Metadata.UID, getAccount(t, "alice").Metadata.UID)
+	}
+
+	// replace with stale resource version
+	{
+		req := httptest.NewRequest(http.MethodPut, "/api/test/v1/accounts/alice", bytes.NewBufferString(`{"metadata": {"resourceVersion": "1"}, "balance": 0}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	}
+}
+
+func TestConditionalDelete(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	var item bass.Resource
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		err := json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+	}
+
+	// delete with another uid
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?preconditionUID=other", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	}
+
+	// delete with stale resource version
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1", nil)
+		req.Header.Set("If-Match", `"stale"`)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	}
+
+	// delete with matching preconditions
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?preconditionUID="+item.Metadata.UID, nil)
+		req.Header.Set("If-Match", `"`+item.Metadata.ResourceVersion+`"`)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+
+	// delete missing item with preconditions
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?preconditionUID="+item.Metadata.UID, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestReturnDeleted(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// invalid flag
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?returnDeleted=maybe", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// delete and return deleted item
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?returnDeleted=true", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo1", res.Metadata.Name)
+		assert.Equal(t, "baz", res.Properties["bar"])
+	}
+
+	// item is deleted
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// delete missing item
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?returnDeleted=true", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+// racingUpdateRepo updates foo1 right after it is fetched once the race is armed, or every time while racing, as a
+// concurrent write would.
+type racingUpdateRepo struct {
+	*bass.MemRepo
+
+	armed  atomic.Bool
+	racing atomic.Bool
+}
+
+func (repo *racingUpdateRepo) Get(ctx context.Context, packageName, resourceType, name string) (*bass.Resource, error) {
+	item, err := repo.MemRepo.Get(ctx, packageName, resourceType, name)
+	if err != nil || name != "foo1" || !repo.racing.Load() && !repo.armed.CompareAndSwap(true, false) {
+		return item, err //nolint:wrapcheck
+	}
+
+	updated := item.DeepCopy()
+	updated.Properties["bar"] = "qux"
+
+	err = repo.Update(ctx, updated)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return item, nil
+}
+
+func TestReturnDeletedConcurrentUpdate(t *testing.T) {
+	t.Parallel()
+
+	repo := &racingUpdateRepo{MemRepo: bass.NewMemRepo()} //nolint:exhaustruct
+	h := bass.NewHandler(repo)
+
+	// register resource type and create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// item updated on every attempt to delete it is not deleted
+	{
+		repo.racing.Store(true)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?returnDeleted=true", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		repo.racing.Store(false)
+
+		require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+		req = httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// item updated while deleting is returned as it was deleted
+	{
+		repo.armed.Store(true)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1?returnDeleted=true", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.False(t, repo.armed.Load())
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "qux", res.Properties["bar"])
+	}
+
+	// item is deleted
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	// disabled by default
+	{
+		h := bass.NewHandler(bass.NewMemRepo())
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithMetrics(true))
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// write items
+	{
+		for _, name := range []string{"foo1", "foo2"} {
+			req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "`+name+`"}, "bar": "baz"}`))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo2", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+	}
+
+	// get metrics
+	{
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "application/openmetrics-text")
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `bass_resources{package="test",resource_type="Foo"} 1`+"\n")
+		assert.Contains(t, body, `bass_resources{package="core",resource_type="ResourceTypeDefinition"} 1`+"\n")
+		assert.Contains(t, body, `bass_resource_writes_total{package="test",resource_type="Foo",operation="create"} 2`+"\n")
+		assert.Contains(t, body, `bass_resource_writes_total{package="test",resource_type="Foo",operation="delete"} 1`+"\n")
+		assert.True(t, strings.HasSuffix(body, "# EOF\n"))
+	}
+
+	// metrics take an admin key once API keys are configured
+	{
+		h := bass.NewHandler(
+			bass.NewMemRepo(),
+			bass.WithMetrics(true),
+			bass.WithAPIKeys(
+				bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+				bass.APIKey{Name: "server", Key: "sk_test", Type: bass.APIKeySecret, PackageName: "test"},
+			),
+		)
+
+		for _, tc := range []struct {
+			key  string
+			code int
+		}{
+			{"", http.StatusUnauthorized},
+			{"sk_test", http.StatusForbidden},
+			{"sk_core", http.StatusOK},
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.key != "" {
+				req.Header.Set(bass.APIKeyHeader, tc.key)
+			}
+
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.code, rec.Code, tc.key)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create items
+	{
+		for _, name := range []string{"foo1", "foo2"} {
+			req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "`+name+`"}, "bar": "baz"}`))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// get usage
+	{
+		req := httptest.NewRequest(http.MethodGet, "/admin/usage", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Usage
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, res.Count)
+		require.Len(t, res.Packages, 2)
+		assert.Equal(t, "core", res.Packages[0].PackageName)
+		assert.Equal(t, "test", res.Packages[1].PackageName)
+		require.Len(t, res.Packages[1].ResourceTypes, 1)
+		assert.Equal(t, "Foo", res.Packages[1].ResourceTypes[0].ResourceType)
+		assert.Equal(t, 2, res.Packages[1].ResourceTypes[0].Count)
+		assert.Positive(t, res.Packages[1].ResourceTypes[0].Bytes)
+		assert.Equal(t, res.Packages[0].Bytes+res.Packages[1].Bytes, res.Bytes)
+	}
+}
+
+type recordingMeterSink struct {
+	mu     sync.Mutex
+	events []bass.MeterEvent
+}
+
+func (sink *recordingMeterSink) Record(_ context.Context, event bass.MeterEvent) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.events = append(sink.events, event)
+}
+
+func TestMetering(t *testing.T) {
+	t.Parallel()
+
+	sink := new(recordingMeterSink)
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithMeterSink(sink),
+		bass.WithMeterSubject(func(r *http.Request) string { return r.Header.Get("X-Tenant") }),
+	)
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create and get item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`))
+		req.Header.Set("X-Tenant", "acme")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		req.Header.Set("X-Tenant", "acme")
+
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// check events
+	{
+		require.Len(t, sink.events, 3)
+
+		assert.Equal(t, "core", sink.events[0].PackageName)
+		assert.Equal(t, bass.MeterOperationWrite, sink.events[0].Operation)
+
+		assert.Equal(t, "acme", sink.events[1].Subject)
+		assert.Equal(t, "test", sink.events[1].PackageName)
+		assert.Equal(t, "foos", sink.events[1].ResourceTypePlural)
+		assert.Equal(t, bass.MeterOperationWrite, sink.events[1].Operation)
+		assert.Equal(t, http.StatusCreated, sink.events[1].Status)
+
+		assert.Equal(t, bass.MeterOperationRead, sink.events[2].Operation)
+		assert.Equal(t, http.StatusOK, sink.events[2].Status)
+		assert.Positive(t, sink.events[2].EgressBytes)
+	}
+}
+
+func TestFeatureFlags(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	evaluate := func(t *testing.T, name, body string) bass.FeatureFlagEvaluation {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/featureflags/"+name+"/evaluate", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.FeatureFlagEvaluation
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	// create invalid flag
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/featureflags", bytes.NewBufferString(`{"metadata": {"name": "invalid"}, "enabled": true, "percentage": 101}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// create flags
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "beta"}, "enabled": true, "rules": [{"attribute": "country", "values": ["DE", "NL"]}]}`,
+			`{"metadata": {"name": "half"}, "enabled": true, "percentage": 50}`,
+			`{"metadata": {"name": "off"}, "enabled": false, "percentage": 100}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/featureflags", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// evaluate rules
+	{
+		res := evaluate(t, "beta", `{"key": "user1", "attributes": {"country": "DE"}}`)
+		assert.True(t, res.Enabled)
+		assert.Equal(t, bass.FeatureFlagReasonRule, res.Reason)
+
+		res = evaluate(t, "beta", `{"key": "user1", "attributes": {"country": "US"}}`)
+		assert.False(t, res.Enabled)
+		assert.Equal(t, bass.FeatureFlagReasonDefault, res.Reason)
+	}
+
+	// evaluate disabled flag
+	{
+		res := evaluate(t, "off", `{"key": "user1"}`)
+		assert.False(t, res.Enabled)
+		assert.Equal(t, bass.FeatureFlagReasonDisabled, res.Reason)
+	}
+
+	// evaluate percentage rollout
+	{
+		enabled := 0
+
+		for i := range 1000 {
+			res := evaluate(t, "half", fmt.Sprintf(`{"key": "user%d"}`, i))
+			if res.Enabled {
+				enabled++
+			}
+
+			assert.Equal(t, res, evaluate(t, "half", fmt.Sprintf(`{"key": "user%d"}`, i)))
+		}
+
+		assert.InDelta(t, 500, enabled, 100)
+	}
+
+	// evaluate missing flag
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/featureflags/missing/evaluate", bytes.NewBufferString(`{"key": "user1"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// create setting with value not matching schema
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/settings", bytes.NewBufferString(`{"metadata": {"name": "pageSize"}, "schema": {"type": "integer", "minimum": 1}, "value": 20, "environments": {"prod": "many"}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// create setting with invalid schema
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/settings", bytes.NewBufferString(`{"metadata": {"name": "pageSize"}, "schema": {"type": 1}, "value": 20}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// create setting
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/settings", bytes.NewBufferString(`{"metadata": {"name": "pageSize"}, "schema": {"type": "integer", "minimum": 1}, "value": 20, "environments": {"prod": 50}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	getValue := func(t *testing.T, query string) bass.SettingValue {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/settings/pageSize/value"+query, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.SettingValue
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	// get values
+	{
+		assert.InDelta(t, float64(20), getValue(t, "").Value, 0)
+		assert.InDelta(t, float64(50), getValue(t, "?environment=prod").Value, 0)
+		assert.InDelta(t, float64(20), getValue(t, "?environment=dev").Value, 0)
+	}
+
+	// watch value
+	{
+		server := httptest.NewServer(h)
+		defer server.Close()
+
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/api/core/v1/settings/pageSize/value?environment=prod&watch=true", nil)
+		require.NoError(t, err)
+
+		res, err := server.Client().Do(req)
+		require.NoError(t, err)
+
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		dec := jsontext.NewDecoder(res.Body)
+
+		var value bass.SettingValue
+
+		err = json.UnmarshalDecode(dec, &value)
+		require.NoError(t, err)
+		assert.InDelta(t, float64(50), value.Value, 0)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/core/v1/settings/pageSize", bytes.NewBufferString(`{"schema": {"type": "integer", "minimum": 1}, "value": 20, "environments": {"prod": 100}}`)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		err = json.UnmarshalDecode(dec, &value)
+		require.NoError(t, err)
+		assert.InDelta(t, float64(100), value.Value, 0)
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/core/v1/settings/pageSize", nil))
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		err = json.UnmarshalDecode(dec, &value)
+		require.ErrorIs(t, err, io.EOF)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "products.test"}, "package": "test", "resourceType": "Product", "plural": "products", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"stock": {"type": "object", "properties": {"level": {"type": "integer", "minimum": 0}}}, "name": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/products", bytes.NewBufferString(`{"metadata": {"name": "p1"}, "name": "pen", "stock": {"level": 10}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	increment := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/products/p1:increment", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// increment concurrently
+	{
+		var wg sync.WaitGroup
+
+		for range 50 {
+			wg.Go(func() {
+				rec := increment(`{"path": "/properties/stock/level", "delta": 2}`)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			})
+		}
+
+		wg.Wait()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/products/p1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"level": float64(110)}, res.Properties["stock"])
+	}
+
+	// decrement below schema minimum
+	{
+		rec := increment(`{"path": "/properties/stock/level", "delta": -200}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// increment non numeric property
+	{
+		rec := increment(`{"path": "/properties/name", "delta": 1}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// increment with invalid path
+	{
+		rec := increment(`{"path": "/metadata/name", "delta": 1}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// increment missing item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/products/p2:increment", bytes.NewBufferString(`{"path": "/properties/stock/level", "delta": 1}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// unknown action
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/products/p1:explode", bytes.NewBufferString(`{}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestArrayValues(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "posts.test"}, "package": "test", "resourceType": "Post", "plural": "posts", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}, "tags": {"type": "array", "items": {"type": "string"}}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/posts", bytes.NewBufferString(`{"metadata": {"name": "p1"}, "title": "hello"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	action := func(action, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/posts/p1:"+action, bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	getTags := func(t *testing.T) any {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/posts/p1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		return res.Properties["tags"]
+	}
+
+	// append unique values concurrently
+	{
+		var wg sync.WaitGroup
+
+		for i := range 20 {
+			wg.Go(func() {
+				rec := action("appendUnique", fmt.Sprintf(`{"path": "/properties/tags", "value": "tag%d"}`, i%5))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			})
+		}
+
+		wg.Wait()
+
+		assert.ElementsMatch(t, []any{"tag0", "tag1", "tag2", "tag3", "tag4"}, getTags(t))
+	}
+
+	// remove value
+	{
+		rec := action("removeValue", `{"path": "/properties/tags", "value": "tag2"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.ElementsMatch(t, []any{"tag0", "tag1", "tag3", "tag4"}, getTags(t))
+	}
+
+	// append value not matching schema
+	{
+		rec := action("appendUnique", `{"path": "/properties/tags", "value": 1}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// append to non array property
+	{
+		rec := action("appendUnique", `{"path": "/properties/title", "value": "x"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithClock(func() time.Time { return now }))
+
+	// register resource type with invalid retention
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "readings.test"}, "package": "test", "resourceType": "Reading", "plural": "readings", "timeSeries": {"timeProperty": "at", "retention": "forever"}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"at": {"type": "string"}, "value": {"type": "number"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "readings.test"}, "package": "test", "resourceType": "Reading", "plural": "readings", "timeSeries": {"timeProperty": "at", "retention": "24h"}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"at": {"type": "string"}, "value": {"type": "number"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create item without time
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/readings", bytes.NewBufferString(`{"metadata": {"name": "r0"}, "value": 1}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// create items
+	{
+		for i, body := range []string{
+			`{"metadata": {"name": "r1"}, "at": "2025-06-01T11:40:00Z", "value": 4}`,
+			`{"metadata": {"name": "r2"}, "at": "2025-06-01T10:10:00Z", "value": 1}`,
+			`{"metadata": {"name": "r3"}, "at": "2025-06-01T10:50:00Z", "value": 3}`,
+			`{"metadata": {"name": "r4"}, "at": "2025-05-30T10:00:00Z", "value": 9}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/test/v1/readings", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, i)
+		}
+	}
+
+	// items are immutable
+	{
+		req := httptest.NewRequest(http.MethodPut, "/api/test/v1/readings/r1", bytes.NewBufferString(`{"at": "2025-06-01T11:40:00Z", "value": 5}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	}
+
+	// list in time order within retention
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/readings", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		names := make([]string, 0, len(res.Items))
+		for _, item := range res.Items {
+			names = append(names, item.Metadata.Name)
+		}
+
+		assert.Equal(t, []string{"r2", "r3", "r1"}, names)
+	}
+
+	// list time range
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/readings?from=2025-06-01T10:30:00Z&to=2025-06-01T11:40:00Z", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, "r3", res.Items[0].Metadata.Name)
+	}
+
+	// downsample
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/readings?downsample=1h&property=value", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.TimeSeriesBucketList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		require.Len(t, res.Buckets, 2)
+		assert.Equal(t, time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), res.Buckets[0].Start)
+		assert.Equal(t, 2, res.Buckets[0].Count)
+		assert.InDelta(t, 2.0, res.Buckets[0].Avg, 0)
+		assert.InDelta(t, 1.0, res.Buckets[0].Min, 0)
+		assert.InDelta(t, 3.0, res.Buckets[0].Max, 0)
+		assert.Equal(t, 1, res.Buckets[1].Count)
+	}
+}
+
+func TestRelationships(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource types
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "users.test"}, "package": "test", "resourceType": "User", "plural": "users", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string"}}}}]}`,
+			`{"metadata": {"name": "teams.test"}, "package": "test", "resourceType": "Team", "plural": "teams", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}}}}]}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// create items and relationships
+	{
+		for _, item := range []struct{ path, body string }{
+			{"/api/test/v1/users", `{"metadata": {"name": "alice"}, "email": "alice@example.com"}`},
+			{"/api/test/v1/teams", `{"metadata": {"name": "backend"}, "title": "Backend"}`},
+			{"/api/test/v1/teams", `{"metadata": {"name": "engineering"}, "title": "Engineering"}`},
+			{"/api/core/v1/relationships", `{"metadata": {"name": "r1"}, "edge": "member-of", "from": {"packageName": "test", "resourceType": "User", "name": "alice"}, "to": {"packageName": "test", "resourceType": "Team", "name": "backend"}}`},
+			{"/api/core/v1/relationships", `{"metadata": {"name": "r2"}, "edge": "member-of", "from": {"packageName": "test", "resourceType": "Team", "name": "backend"}, "to": {"packageName": "test", "resourceType": "Team", "name": "engineering"}}`},
+			{"/api/core/v1/relationships", `{"metadata": {"name": "r3"}, "edge": "follows", "from": {"packageName": "test", "resourceType": "User", "name": "alice"}, "to": {"packageName": "test", "resourceType": "User", "name": "bob"}}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, item.path, bytes.NewBufferString(item.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	related := func(t *testing.T, path string) []string {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		names := make([]string, 0, len(res.Items))
+		for _, item := range res.Items {
+			names = append(names, item.Metadata.Name)
+		}
+
+		return names
+	}
+
+	// traverse
+	{
+		assert.Equal(t, []string{"backend"}, related(t, "/api/test/v1/users/alice/related?edge=member-of"))
+		assert.Equal(t, []string{"backend", "engineering"}, related(t, "/api/test/v1/users/alice/related?edge=member-of&depth=2"))
+		assert.Equal(t, []string{"backend"}, related(t, "/api/test/v1/users/alice/related"))
+		assert.Equal(t, []string{"backend", "alice"}, related(t, "/api/test/v1/teams/engineering/related?direction=in&depth=3"))
+	}
+
+	// invalid depth
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/users/alice/related?depth=100", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// missing item
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/users/bob/related", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestRelatedAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithAPIKeys(
+			bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+			bass.APIKey{Name: "a", Key: "sk_a", Type: bass.APIKeySecret, PackageName: "a"},
+			bass.APIKey{Name: "b", Key: "sk_b", Type: bass.APIKeySecret, PackageName: "b"},
+			bass.APIKey{Name: "web", Key: "pk_a", Type: bass.APIKeyPublishable, PackageName: "a"},
+			bass.APIKey{Name: "notes", Key: "pk_notes", Type: bass.APIKeyPublishable, PackageName: "a", Permissions: []bass.APIKeyPermission{
+				{ResourceTypes: []string{"notes"}, Verbs: []string{bass.APIKeyVerbGet}},
+			}},
+		),
+	)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(bass.APIKeyHeader, key)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource types, and create items and relationships
+	{
+		for _, tc := range []struct{ path, key, body string }{
+			{"/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "notes.a"}, "package": "a", "resourceType": "Note", "plural": "notes", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`},
+			{"/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "tags.a"}, "package": "a", "resourceType": "Tag", "plural": "tags", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`},
+			{"/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "users.b"}, "package": "b", "resourceType": "User", "plural": "users", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`},
+			{"/api/a/v1/notes", "sk_a", `{"metadata": {"name": "n1"}, "text": "hi"}`},
+			{"/api/a/v1/tags", "sk_a", `{"metadata": {"name": "t1"}, "title": "go"}`},
+			{"/api/b/v1/users", "sk_b", `{"metadata": {"name": "u1"}, "email": "secret@example.com"}`},
+			{"/api/core/v1/relationships", "sk_core", `{"metadata": {"name": "r1"}, "edge": "author", "from": {"packageName": "a", "resourceType": "Note", "name": "n1"}, "to": {"packageName": "b", "resourceType": "User", "name": "u1"}}`},
+			{"/api/core/v1/relationships", "sk_core", `{"metadata": {"name": "r2"}, "edge": "tagged", "from": {"packageName": "a", "resourceType": "Note", "name": "n1"}, "to": {"packageName": "a", "resourceType": "Tag", "name": "t1"}}`},
+		} {
+			rec := do(http.MethodPost, tc.path, tc.key, tc.body)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	related := func(t *testing.T, key string) []string {
+		t.Helper()
+
+		rec := do(http.MethodGet, "/api/a/v1/notes/n1/related", key, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotContains(t, rec.Body.String(), "secret@example.com")
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		names := make([]string, 0, len(res.Items))
+		for _, item := range res.Items {
+			names = append(names, item.Metadata.Name)
+		}
+
+		return names
+	}
+
+	// related items of other packages, or of resource types the key may not get, are left out
+	{
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/b/v1/users/u1", "pk_a", "").Code)
+
+		assert.Equal(t, []string{"t1"}, related(t, "pk_a"))
+		assert.Empty(t, related(t, "pk_notes"))
+	}
+}
+
+func TestActivity(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "tickets.test"}, "package": "test", "resourceType": "Ticket", "plural": "tickets", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/tickets", bytes.NewBufferString(`{"metadata": {"name": "t1"}, "title": "broken"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	listActivity := func(t *testing.T) []*bass.Resource {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/activities", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		return res.Items
+	}
+
+	// add activity
+	{
+		for _, body := range []string{
+			`{"kind": "comment", "text": "looking into it"}`,
+			`{"kind": "stateChange", "data": {"from": "open", "to": "closed"}}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/test/v1/tickets/t1/activity", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// add invalid activity
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/tickets/t1/activity", bytes.NewBufferString(`{"text": "no kind"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// add activity to missing item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/tickets/t2/activity", bytes.NewBufferString(`{"kind": "comment"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// list activity
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/tickets/t1/activity", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 2)
+		assert.Equal(t, "comment", res.Items[0].Properties["kind"])
+		assert.Equal(t, "looking into it", res.Items[0].Properties["text"])
+		assert.Equal(t, "stateChange", res.Items[1].Properties["kind"])
+	}
+
+	// delete item cascades
+	{
+		require.Len(t, listActivity(t), 2)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/tickets/t1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		assert.Empty(t, listActivity(t))
+	}
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "pages.test"}, "package": "test", "resourceType": "Page", "plural": "pages", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}, "body": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	var template bass.Resource
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/pages", bytes.NewBufferString(`{"metadata": {"name": "template", "labels": {"kind": "landing"}}, "title": "Template", "body": "Hello"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		err := json.UnmarshalRead(rec.Body, &template)
+		require.NoError(t, err)
+	}
+
+	// clone with patch
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/pages/template:clone", bytes.NewBufferString(`{"newName": "home", "patch": {"title": "Home"}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/api/test/v1/pages/home", rec.Header().Get("Location"))
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "home", res.Metadata.Name)
+		assert.NotEqual(t, template.Metadata.UID, res.Metadata.UID)
+		assert.Equal(t, map[string]string{"kind": "landing"}, res.Metadata.Labels)
+		assert.Equal(t, "Home", res.Properties["title"])
+		assert.Equal(t, "Hello", res.Properties["body"])
+	}
+
+	// clone with invalid patch
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/pages/template:clone", bytes.NewBufferString(`{"newName": "about", "patch": {"title": 1}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// clone to existing name
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/pages/template:clone", bytes.NewBufferString(`{"newName": "home"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	}
+}
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource types
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "users.test"}, "package": "test", "resourceType": "User", "plural": "users", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string"}}}}]}`,
+			`{"metadata": {"name": "users.archive"}, "package": "archive", "resourceType": "User", "plural": "users", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string"}}}}]}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	var alice bass.Resource
+
+	// create items, relationship and activity
+	{
+		for _, item := range []struct{ path, body string }{
+			{"/api/test/v1/users", `{"metadata": {"name": "alice"}, "email": "alice@example.com"}`},
+			{"/api/test/v1/users", `{"metadata": {"name": "bob"}, "email": "bob@example.com"}`},
+			{"/api/core/v1/relationships", `{"metadata": {"name": "r1"}, "edge": "follows", "from": {"packageName": "test", "resourceType": "User", "name": "bob"}, "to": {"packageName": "test", "resourceType": "User", "name": "alice"}}`},
+			{"/api/test/v1/users/alice/activity", `{"kind": "comment", "text": "joined"}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, item.path, bytes.NewBufferString(item.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			if item.path == "/api/test/v1/users" && alice.Metadata.UID == "" {
+				err := json.UnmarshalRead(rec.Body, &alice)
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	// rename referenced item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users/alice:rename", bytes.NewBufferString(`{"newName": "alicia"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	}
+
+	// rename with reference updates
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users/alice:rename", bytes.NewBufferString(`{"newName": "alicia", "updateReferences": true}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/api/test/v1/users/alicia", rec.Header().Get("Location"))
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "alicia", res.Metadata.Name)
+		assert.Equal(t, alice.Metadata.UID, res.Metadata.UID)
+		assert.True(t, alice.Metadata.CreatedAt.Equal(res.Metadata.CreatedAt))
+	}
+
+	// old name is gone
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/users/alice", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// relationship and activity follow
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/users/bob/related", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var related bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &related)
+		require.NoError(t, err)
+
+		require.Len(t, related.Items, 1)
+		assert.Equal(t, "alicia", related.Items[0].Metadata.Name)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/test/v1/users/alicia/activity", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var activity bass.ResourceList
+
+		err = json.UnmarshalRead(rec.Body, &activity)
+		require.NoError(t, err)
+
+		assert.Len(t, activity.Items, 1)
+	}
+
+	// move to another package
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users/bob:rename", bytes.NewBufferString(`{"newPackageName": "archive", "updateReferences": true}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/api/archive/v1/users/bob", rec.Header().Get("Location"))
+	}
+
+	// move to package without the resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users/alicia:rename", bytes.NewBufferString(`{"newPackageName": "other"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// rename to existing name
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users", bytes.NewBufferString(`{"metadata": {"name": "carol"}, "email": "carol@example.com"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/users/carol:rename", bytes.NewBufferString(`{"newName": "alicia"}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	}
+}
+
+func TestStateMachine(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type with state machine
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "articles.test"}, "package": "test", "resourceType": "Article", "plural": "articles", "stateMachine": {"property": "status", "transitions": {"draft": ["review"], "review": ["draft", "published"]}}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}, "status": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/articles", bytes.NewBufferString(`{"metadata": {"name": "a1"}, "title": "Hello", "status": "draft"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// skip a state
+	{
+		req := httptest.NewRequest(http.MethodPut, "/api/test/v1/articles/a1", bytes.NewBufferString(`{"metadata": {"name": "a1"}, "title": "Hello", "status": "published"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		var res map[string]any
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, "draft", res["from"])
+		assert.Equal(t, "published", res["to"])
+	}
+
+	// keep the state
+	{
+		req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/articles/a1", bytes.NewBufferString(`{"title": "Hello, World"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// follow transitions
+	{
+		for _, status := range []string{"review", "published"} {
+			req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/articles/a1", bytes.NewBufferString(`[{"op": "replace", "path": "/status", "value": "`+status+`"}]`))
+			req.Header.Set("Content-Type", "application/json-patch+json")
+
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+		}
+	}
+
+	// leave a final state
+	{
+		req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/articles/a1", bytes.NewBufferString(`{"status": "draft"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	}
+
+	// leave a final state in transaction
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/transactions", bytes.NewBufferString(`{"operations": [{"op": "update", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "articles", "name": "a1", "properties": {"title": "Hello", "status": "review"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+type recordingChangeRequestNotifier struct {
+	mu     sync.Mutex
+	events []bass.ChangeRequestStatus
+}
+
+func (n *recordingChangeRequestNotifier) NotifyChangeRequest(_ context.Context, changeRequest *bass.ChangeRequest) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.events = append(n.events, changeRequest.Status)
+}
+
+func TestApproval(t *testing.T) {
+	t.Parallel()
+
+	notifier := &recordingChangeRequestNotifier{mu: sync.Mutex{}, events: nil}
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithChangeRequestNotifier(notifier))
+
+	// register protected resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "prices.test"}, "package": "test", "resourceType": "Price", "plural": "prices", "approval": {"approvals": 2}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"amount": {"type": "number"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	var changeRequest bass.Resource
+
+	// create item requests change
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/prices", bytes.NewBufferString(`{"metadata": {"name": "basic"}, "amount": 10}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		err := json.UnmarshalRead(rec.Body, &changeRequest)
+		require.NoError(t, err)
+
+		assert.Equal(t, "ChangeRequest", changeRequest.Metadata.ResourceType)
+		assert.Equal(t, "pending", changeRequest.Properties["status"])
+		assert.Equal(t, "/api/core/v1/changerequests/"+changeRequest.Metadata.Name, rec.Header().Get("Location"))
+	}
+
+	getItem := func(t *testing.T) int {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/prices/basic", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	approve := func(t *testing.T, name, approver string) (int, bass.Resource) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/changerequests/"+name+"/approve", bytes.NewBufferString(`{"approver": "`+approver+`"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		var res bass.Resource
+
+		if rec.Code == http.StatusOK {
+			err := json.UnmarshalRead(rec.Body, &res)
+			require.NoError(t, err)
+		}
+
+		return rec.Code, res
+	}
+
+	// approvals apply change
+	{
+		assert.Equal(t, http.StatusNotFound, getItem(t))
+
+		code, res := approve(t, changeRequest.Metadata.Name, "alice")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "pending", res.Properties["status"])
+
+		code, res = approve(t, changeRequest.Metadata.Name, "alice")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "pending", res.Properties["status"])
+		assert.Equal(t, http.StatusNotFound, getItem(t))
+
+		code, res = approve(t, changeRequest.Metadata.Name, "bob")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "applied", res.Properties["status"])
+		assert.Equal(t, http.StatusOK, getItem(t))
+
+		code, _ = approve(t, changeRequest.Metadata.Name, "carol")
+		assert.Equal(t, http.StatusConflict, code)
+	}
+
+	// stale change fails
+	{
+		var names []string
+
+		for range 2 {
+			req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/prices/basic", bytes.NewBufferString(`{"amount": 12}`))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusAccepted, rec.Code)
+
+			var res bass.Resource
+
+			err := json.UnmarshalRead(rec.Body, &res)
+			require.NoError(t, err)
+
+			names = append(names, res.Metadata.Name)
+		}
+
+		for _, approver := range []string{"alice", "bob"} {
+			code, _ := approve(t, names[0], approver)
+			require.Equal(t, http.StatusOK, code)
+		}
+
+		_, _ = approve(t, names[1], "alice")
+		code, res := approve(t, names[1], "bob")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "failed", res.Properties["status"])
+		assert.NotEmpty(t, res.Properties["error"])
+	}
+
+	// direct writes are rejected
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/transactions", bytes.NewBufferString(`{"operations": [{"op": "delete", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "prices", "name": "basic"}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/prices/basic:increment", bytes.NewBufferString(`{"path": "/properties/amount", "delta": 1}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	// notifications
+	{
+		notifier.mu.Lock()
+		defer notifier.mu.Unlock()
+
+		assert.Equal(t, []bass.ChangeRequestStatus{"pending", "pending", "applied", "pending", "pending", "pending", "applied", "pending", "failed"}, notifier.events)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type with drafts
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "pages.test"}, "package": "test", "resourceType": "Page", "plural": "pages", "drafts": true, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}}, "required": ["title"]}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	putDraft := func(t *testing.T, body string) int {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPut, "/api/test/v1/pages/home/draft", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	publish := func(t *testing.T) int {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/pages/home:publish", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	getTitle := func(t *testing.T) any {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/pages/home", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			return nil
+		}
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		return res.Properties["title"]
+	}
+
+	// incomplete draft is not published
+	{
+		assert.Equal(t, http.StatusCreated, putDraft(t, `{"metadata": {"labels": {"section": "main"}}}`))
+		assert.Equal(t, http.StatusBadRequest, publish(t))
+		assert.Nil(t, getTitle(t))
+	}
+
+	// publish first version
+	{
+		assert.Equal(t, http.StatusOK, putDraft(t, `{"metadata": {"labels": {"section": "main"}}, "title": "Welcome"}`))
+		assert.Equal(t, http.StatusOK, publish(t))
+		assert.Equal(t, "Welcome", getTitle(t))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/pages/home/draft", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		assert.Equal(t, http.StatusNotFound, publish(t))
+	}
+
+	// published item is immutable
+	{
+		req := httptest.NewRequest(http.MethodPut, "/api/test/v1/pages/home", bytes.NewBufferString(`{"metadata": {"name": "home"}, "title": "Changed"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	}
+
+	// draft doesn't change published item
+	{
+		assert.Equal(t, http.StatusCreated, putDraft(t, `{"title": "Welcome home"}`))
+		assert.Equal(t, "Welcome", getTitle(t))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/pages/home/draft", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"title": "Welcome home"}, res.Properties["properties"])
+	}
+
+	// publish second version
+	{
+		assert.Equal(t, http.StatusOK, publish(t))
+		assert.Equal(t, "Welcome home", getTitle(t))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/pages/home/versions", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 2)
+		assert.InDelta(t, 1, res.Items[0].Properties["version"], 0)
+		assert.Equal(t, map[string]any{"title": "Welcome"}, res.Items[0].Properties["properties"])
+		assert.InDelta(t, 2, res.Items[1].Properties["version"], 0)
+		assert.Equal(t, map[string]any{"title": "Welcome home"}, res.Items[1].Properties["properties"])
+	}
+
+	// discard draft
+	{
+		assert.Equal(t, http.StatusCreated, putDraft(t, `{"title": "Oops"}`))
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/pages/home/draft", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, http.StatusNotFound, publish(t))
+	}
+}
+
+func TestLocalization(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register localized resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "products.test"}, "package": "test", "resourceType": "Product", "plural": "products", "localization": {"properties": ["title", "description"], "fallbacks": {"lb": ["de"]}}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}, "description": {"type": "string"}, "price": {"type": "number"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/products", bytes.NewBufferString(`{"metadata": {"name": "p1"}, "title": "Chair", "description": "A chair", "price": 10}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	putLocale := func(t *testing.T, locale, body string) int {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPut, "/api/test/v1/products/p1/locales/"+locale, bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	getLocalized := func(t *testing.T, locale string) map[string]any {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/products/p1?locale="+locale, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, locale, rec.Header().Get("Content-Language"))
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		return res.Properties
+	}
+
+	// write locales
+	{
+		assert.Equal(t, http.StatusCreated, putLocale(t, "de", `{"title": "Stuhl", "description": "Ein Stuhl"}`))
+		assert.Equal(t, http.StatusCreated, putLocale(t, "de-AT", `{"title": "Sessel"}`))
+		assert.Equal(t, http.StatusOK, putLocale(t, "de-AT", `{"title": "Sessel"}`))
+		assert.Equal(t, http.StatusBadRequest, putLocale(t, "fr", `{"price": 12}`))
+		assert.Equal(t, http.StatusBadRequest, putLocale(t, "fr", `{"title": 12}`))
+		assert.Equal(t, http.StatusBadRequest, putLocale(t, "f_r", `{"title": "Chaise"}`))
+	}
+
+	// read with fallbacks
+	{
+		assert.Equal(t, map[string]any{"title": "Stuhl", "description": "Ein Stuhl", "price": float64(10)}, getLocalized(t, "de"))
+		assert.Equal(t, map[string]any{"title": "Sessel", "description": "Ein Stuhl", "price": float64(10)}, getLocalized(t, "de-AT-x-vienna"))
+		assert.Equal(t, map[string]any{"title": "Stuhl", "description": "Ein Stuhl", "price": float64(10)}, getLocalized(t, "lb"))
+		assert.Equal(t, map[string]any{"title": "Chair", "description": "A chair", "price": float64(10)}, getLocalized(t, "fr"))
+	}
+
+	// delete locale
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/products/p1/locales/de-AT", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "Stuhl", getLocalized(t, "de-AT")["title"])
+	}
+
+	// delete item cascades
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/test/v1/products/p1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/core/v1/localizations", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Empty(t, res.Items)
+	}
+}
+
+type recordingEmailSender struct {
+	emails chan bass.Email
+}
+
+func (s *recordingEmailSender) SendEmail(_ context.Context, email bass.Email) error {
+	s.emails <- email
+
+	return nil
+}
+
+func TestEmail(t *testing.T) {
+	t.Parallel()
+
+	sender := &recordingEmailSender{emails: make(chan bass.Email, 10)}
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithEmailSender(sender))
+
+	// create templates
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "welcome"}, "subject": "Welcome, {{.name}}", "text": "Hello {{.name}}", "html": "<p>Hello {{.name}}</p>"}`,
+			`{"metadata": {"name": "order-created"}, "subject": "Order {{.item.metadata.name}}", "text": "Total: {{.item.total}}", "to": ["{{.item.email}}"], "trigger": {"packageName": "shop", "resourceType": "Order", "operations": ["create"]}}`,
+			`{"metadata": {"name": "orders.shop"}, "package": "shop", "resourceType": "Order", "plural": "orders", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string"}, "total": {"type": "number"}}}}]}`,
+		} {
+			path := "/api/core/v1/emailtemplates"
+			if strings.Contains(body, `"package"`) {
+				path = "/api/core/v1/resourcetypedefinitions"
+			}
+
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// create invalid template
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/emailtemplates", bytes.NewBufferString(`{"metadata": {"name": "broken"}, "subject": "{{.name"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// send email
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/emailtemplates/welcome/send", bytes.NewBufferString(`{"to": ["alice@example.com"], "data": {"name": "<Alice>"}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		email := <-sender.emails
+		assert.Equal(t, bass.Email{
+			To:      []string{"alice@example.com"},
+			Subject: "Welcome, <Alice>",
+			Text:    "Hello <Alice>",
+			HTML:    "<p>Hello &lt;Alice&gt;</p>",
+		}, email)
+	}
+
+	// send email without recipients
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/emailtemplates/welcome/send", bytes.NewBufferString(`{}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// trigger email
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/shop/v1/orders", bytes.NewBufferString(`{"metadata": {"name": "o1"}, "email": "bob@example.com", "total": 42}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		select {
+		case email := <-sender.emails:
+			assert.Equal(t, []string{"bob@example.com"}, email.To)
+			assert.Equal(t, "Order o1", email.Subject)
+			assert.Equal(t, "Total: 42", email.Text)
+		case <-time.After(time.Second):
+			t.Fatal("email not triggered")
+		}
+	}
+
+	// send through provider API
+	{
+		var received map[string]any
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+
+			err := json.UnmarshalRead(r.Body, &received)
+			assert.NoError(t, err)
+
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+
+		err := bass.NewHTTPEmailSender(server.URL, "key", "noreply@example.com").SendEmail(t.Context(), bass.Email{
+			To:      []string{"alice@example.com"},
+			Subject: "Hi",
+			Text:    "Hello",
+			HTML:    "",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"from": "noreply@example.com", "to": []any{"alice@example.com"}, "subject": "Hi", "text": "Hello"}, received)
+	}
+}
+
+type recordedPush struct {
+	token        string
+	notification bass.PushNotification
+}
+
+type recordingPushSender struct {
+	pushes chan recordedPush
+}
+
+func (s *recordingPushSender) SendPush(_ context.Context, token string, notification bass.PushNotification) error {
+	s.pushes <- recordedPush{token: token, notification: notification}
+
+	if token == "stale" {
+		return bass.DeviceTokenInvalidError{Reason: "Unregistered"}
+	}
+
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	t.Parallel()
+
+	sender := &recordingPushSender{pushes: make(chan recordedPush, 10)}
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithPushSender(bass.PushPlatformAPNs, sender), bass.WithPushSender(bass.PushPlatformFCM, sender))
+
+	// register device tokens and create templates
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/devicetokens", `{"metadata": {"name": "d1"}, "platform": "apns", "token": "t1", "subject": "alice"}`},
+			{"/api/core/v1/devicetokens", `{"metadata": {"name": "d2"}, "platform": "fcm", "token": "stale", "subject": "alice"}`},
+			{"/api/core/v1/devicetokens", `{"metadata": {"name": "d3"}, "platform": "fcm", "token": "t3", "subject": "bob"}`},
+			{"/api/core/v1/pushtemplates", `{"metadata": {"name": "greeting"}, "title": "Hi {{.name}}", "body": "Welcome back", "data": {"screen": "home/{{.name}}"}}`},
+			{"/api/core/v1/pushtemplates", `{"metadata": {"name": "order-shipped"}, "title": "Order {{.item.metadata.name}} shipped", "subject": "{{.item.customer}}", "trigger": {"packageName": "shop", "resourceType": "Order", "operations": ["update"]}}`},
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "orders.shop"}, "package": "shop", "resourceType": "Order", "plural": "orders", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"customer": {"type": "string"}, "status": {"type": "string"}}}}]}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// register device token of unknown platform
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/devicetokens", bytes.NewBufferString(`{"metadata": {"name": "d4"}, "platform": "sms", "token": "t4"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// create invalid template
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/pushtemplates", bytes.NewBufferString(`{"metadata": {"name": "broken"}, "title": "{{.name"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// send push notification
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/pushtemplates/greeting/send", bytes.NewBufferString(`{"subject": "alice", "data": {"name": "Alice"}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.PushSendResponse
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, bass.PushSendResponse{Sent: 1, Failed: 1}, res)
+
+		notification := bass.PushNotification{Title: "Hi Alice", Body: "Welcome back", Data: map[string]string{"screen": "home/Alice"}}
+		assert.ElementsMatch(t, []recordedPush{{token: "t1", notification: notification}, {token: "stale", notification: notification}}, []recordedPush{<-sender.pushes, <-sender.pushes})
+	}
+
+	// invalid device token is deleted
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/devicetokens/d2", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// send push notification without subject
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/pushtemplates/greeting/send", bytes.NewBufferString(`{}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// trigger push notification
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/shop/v1/orders", bytes.NewBufferString(`{"metadata": {"name": "o1"}, "customer": "bob", "status": "paid"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPatch, "/api/shop/v1/orders/o1", bytes.NewBufferString(`{"status": "shipped"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		select {
+		case push := <-sender.pushes:
+			assert.Equal(t, "t3", push.token)
+			assert.Equal(t, "Order o1 shipped", push.notification.Title)
+		case <-time.After(time.Second):
+			t.Fatal("push notification not triggered")
+		}
+	}
+
+	// send through APNs
+	{
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		var received map[string]any
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "com.example.app", r.Header.Get("Apns-Topic"))
+
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "bearer ")
+			assert.True(t, ok)
+
+			parts := strings.Split(token, ".")
+			assert.Len(t, parts, 3)
+
+			signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+			assert.NoError(t, err)
+
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])))
+
+			if r.URL.Path == "/3/device/gone" {
+				w.WriteHeader(http.StatusGone)
+				_, _ = w.Write([]byte(`{"reason": "Unregistered"}`))
+
+				return
+			}
+
+			assert.Equal(t, "/3/device/t1", r.URL.Path)
+
+			err = json.UnmarshalRead(r.Body, &received)
+			assert.NoError(t, err)
+		}))
+		defer server.Close()
+
+		apns := bass.NewAPNsPushSender(server.URL, "TEAM", "KEY", key, "com.example.app")
+
+		err = apns.SendPush(t.Context(), "t1", bass.PushNotification{Title: "Hi", Body: "Hello", Data: map[string]string{"screen": "home"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"aps": map[string]any{"alert": map[string]any{"title": "Hi", "body": "Hello"}}, "screen": "home"}, received)
+
+		err = apns.SendPush(t.Context(), "gone", bass.PushNotification{Title: "Hi", Body: "", Data: nil})
+		require.ErrorAs(t, err, &bass.DeviceTokenInvalidError{})
+	}
+
+	// send through FCM
+	{
+		var received map[string]any
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/projects/demo/messages:send", r.URL.Path)
+			assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+
+			err := json.UnmarshalRead(r.Body, &received)
+			assert.NoError(t, err)
+
+			if received["message"].(map[string]any)["token"] == "gone" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		fcm := bass.NewFCMPushSender(server.URL, "demo", func(context.Context) (string, error) { return "access", nil })
+
+		err := fcm.SendPush(t.Context(), "t3", bass.PushNotification{Title: "Hi", Body: "Hello", Data: map[string]string{"screen": "home"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"message": map[string]any{
+			"token":        "t3",
+			"notification": map[string]any{"title": "Hi", "body": "Hello"},
+			"data":         map[string]any{"screen": "home"},
+		}}, received)
+
+		err = fcm.SendPush(t.Context(), "gone", bass.PushNotification{Title: "Hi", Body: "", Data: nil})
+		require.ErrorAs(t, err, &bass.DeviceTokenInvalidError{})
+	}
+}
+
+type recordingSMSSender struct {
+	messages chan string
+}
+
+func (s *recordingSMSSender) SendSMS(_ context.Context, to, text string) error {
+	s.messages <- to + ": " + text
+
+	return nil
+}
+
+func TestNotifications(t *testing.T) {
+	t.Parallel()
+
+	posts := make(chan map[string]any, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+
+		err := json.UnmarshalRead(r.Body, &body)
+		assert.NoError(t, err)
+
+		body["path"] = r.URL.Path
+		posts <- body
+	}))
+	defer server.Close()
+
+	smsSender := &recordingSMSSender{messages: make(chan string, 10)}
+	h := bass.NewHandler(bass.NewMemRepo(),
+		bass.WithNotifier(bass.NotificationChannelWebhook, bass.NewWebhookNotifier()),
+		bass.WithNotifier(bass.NotificationChannelSlack, bass.NewSlackNotifier()),
+		bass.WithNotifier(bass.NotificationChannelSMS, bass.NewSMSNotifier(smsSender)),
+	)
+
+	// create channels and rules
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/notificationchannels", `{"metadata": {"name": "ops-webhook"}, "type": "webhook", "url": "` + server.URL + `/hook"}`},
+			{"/api/core/v1/notificationchannels", `{"metadata": {"name": "ops-slack"}, "type": "slack", "url": "` + server.URL + `/slack"}`},
+			{"/api/core/v1/notificationchannels", `{"metadata": {"name": "on-call"}, "type": "sms", "to": ["+15550100"]}`},
+			{"/api/core/v1/notificationchannels", `{"metadata": {"name": "support"}, "type": "email", "to": ["support@example.com"]}`},
+			{"/api/core/v1/notificationrules", `{"metadata": {"name": "new-orders"}, "trigger": {"packageName": "shop", "resourceType": "Order", "operations": ["create"]}, "channels": ["ops-webhook", "ops-slack", "on-call"], "title": "New order {{.item.metadata.name}}", "text": "Total: {{.item.total}}"}`},
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "orders.shop"}, "package": "shop", "resourceType": "Order", "plural": "orders", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"total": {"type": "number"}}}}]}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// create invalid channel and rule
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/notificationchannels", `{"metadata": {"name": "pager"}, "type": "pager"}`},
+			{"/api/core/v1/notificationrules", `{"metadata": {"name": "broken"}, "trigger": {"packageName": "shop", "resourceType": "Order"}, "channels": ["ops-webhook"], "title": "{{.item"}`},
+			{"/api/core/v1/notificationrules", `{"metadata": {"name": "nowhere"}, "trigger": {"packageName": "shop", "resourceType": "Order"}, "channels": [], "title": "Order"}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tc.body)
+		}
+	}
+
+	// notify channel
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/ops-slack/notify", bytes.NewBufferString(`{"title": "Test", "text": "Hello"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		assert.Equal(t, map[string]any{"path": "/slack", "text": "*Test*\nHello"}, <-posts)
+	}
+
+	// notify channel without notifier
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/support/notify", bytes.NewBufferString(`{"title": "Test"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	}
+
+	// notify missing channel
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/missing/notify", bytes.NewBufferString(`{"title": "Test"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// route notifications of writes
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/shop/v1/orders", bytes.NewBufferString(`{"metadata": {"name": "o1"}, "total": 42}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		received := make(map[string]map[string]any)
+
+		for range 2 {
+			select {
+			case post := <-posts:
+				received[post["path"].(string)] = post
+			case <-time.After(time.Second):
+				t.Fatal("notification not delivered")
+			}
+		}
+
+		assert.Equal(t, "*New order o1*\nTotal: 42", received["/slack"]["text"])
+		assert.Equal(t, "New order o1", received["/hook"]["title"])
+		assert.Equal(t, "Total: 42", received["/hook"]["text"])
+		assert.Equal(t, "create", received["/hook"]["data"].(map[string]any)["operation"])
+
+		select {
+		case message := <-smsSender.messages:
+			assert.Equal(t, "+15550100: New order o1\nTotal: 42", message)
+		case <-time.After(time.Second):
+			t.Fatal("sms not sent")
+		}
+	}
+
+	// send sms through provider API
+	{
+		err := bass.NewHTTPSMSSender(server.URL+"/sms", "key", "+15550199").SendSMS(t.Context(), "+15550100", "Hello")
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"path": "/sms", "from": "+15550199", "to": "+15550100", "text": "Hello"}, <-posts)
+	}
+}
+
+type countryResolverFunc func(addr netip.Addr) string
+
+func (f countryResolverFunc) Country(addr netip.Addr) string {
+	return f(addr)
+}
+
+func TestNetworkPolicy(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(),
+		bass.WithNetworkPolicy(bass.NetworkPolicy{
+			Allow:          nil,
+			Deny:           []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+			AllowCountries: nil,
+			DenyCountries:  []string{"XX"},
+		}),
+		bass.WithPackageNetworkPolicy("billing", bass.NetworkPolicy{
+			Allow:          []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+			Deny:           nil,
+			AllowCountries: nil,
+			DenyCountries:  nil,
+		}),
+		bass.WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")),
+		bass.WithCountryResolver(countryResolverFunc(func(addr netip.Addr) string {
+			if addr == netip.MustParseAddr("233.252.0.1") {
+				return "XX"
+			}
+
+			return ""
+		})),
+	)
+
+	request := func(method, target, remoteAddr, forwardedFor, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.RemoteAddr = remoteAddr
+
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource type
+	{
+		rec := request(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "192.0.2.1:1234", "", `{"metadata": {"name": "invoices.billing"}, "package": "billing", "resourceType": "Invoice", "plural": "invoices", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// denied address
+	{
+		rec := request(http.MethodGet, "/api/core/v1/resourcetypedefinitions", "203.0.113.7:1234", "", "")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	// denied address behind trusted proxies
+	{
+		rec := request(http.MethodGet, "/api/core/v1/resourcetypedefinitions", "10.0.0.1:1234", "203.0.113.7, 10.0.0.2", "")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	// forwarded address from untrusted peer is ignored
+	{
+		rec := request(http.MethodGet, "/api/billing/v1/invoices", "198.51.100.1:1234", "192.0.2.1", "")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = request(http.MethodGet, "/api/core/v1/resourcetypedefinitions", "198.51.100.1:1234", "203.0.113.7", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// invalid forwarded address
+	{
+		rec := request(http.MethodGet, "/api/core/v1/resourcetypedefinitions", "10.0.0.1:1234", "unknown", "")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// denied country
+	{
+		rec := request(http.MethodGet, "/api/core/v1/resourcetypedefinitions", "233.252.0.1:1234", "", "")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	// allowed package address
+	{
+		rec := request(http.MethodGet, "/api/billing/v1/invoices", "10.0.0.1:1234", "192.0.2.9", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// transaction on denied package
+	{
+		rec := request(http.MethodPost, "/api/transactions", "198.51.100.1:1234", "", `{"operations": [{"op": "create", "packageName": "billing", "apiVersion": "v1", "resourceTypePlural": "invoices", "name": "i1", "properties": {}}]}`)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestRequestSigning(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	key := []byte("secret")
+	sink := new(recordingMeterSink)
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithClock(func() time.Time { return now }),
+		bass.WithRequestSigning(bass.RequestSigning{Keys: map[string][]byte{"ci": key}, MaxSkew: time.Minute}),
+		bass.WithMeterSink(sink),
+		bass.WithMeterSubject(func(r *http.Request) string { return bass.SigningKeyIDFromContext(r.Context()) }),
+	)
+
+	body := `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`
+
+	// signed request
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(body))
+
+		err := bass.SignRequest(req, "ci", key, now)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		sink.mu.Lock()
+		assert.Equal(t, "ci", sink.events[0].Subject)
+		sink.mu.Unlock()
+	}
+
+	// unsigned request
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/resourcetypedefinitions", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	// tampered body
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}}`))
+
+		err := bass.SignRequest(req, "ci", key, now)
+		require.NoError(t, err)
+
+		req.Body = io.NopCloser(bytes.NewBufferString(`{"metadata": {"name": "foo2"}}`))
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	// expired timestamp
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/resourcetypedefinitions", nil)
+
+		err := bass.SignRequest(req, "ci", key, now.Add(-2*time.Minute))
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	// unknown key
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/resourcetypedefinitions", nil)
+
+		err := bass.SignRequest(req, "other", key, now)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// enable read-only mode
+	{
+		req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", bytes.NewBufferString(`{"readOnly": true, "retryAfter": 30, "reason": "storage migration"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/admin/maintenance", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Maintenance
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, bass.Maintenance{ReadOnly: true, RetryAfter: 30, Reason: "storage migration"}, res)
+	}
+
+	// writes are rejected
+	{
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`)),
+			httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo1", nil),
+			httptest.NewRequest(http.MethodPost, "/api/transactions", bytes.NewBufferString(`{"operations": []}`)),
+		} {
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+			assert.Contains(t, rec.Body.String(), "storage migration")
+		}
+	}
+
+	// reads continue
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// disable read-only mode
+	{
+		req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", bytes.NewBufferString(`{"readOnly": false}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// start in read-only mode
+	{
+		h := bass.NewHandler(bass.NewMemRepo(), bass.WithMaintenance(bass.Maintenance{ReadOnly: true, RetryAfter: 0, Reason: ""}))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	t.Parallel()
+
+	repo := bass.NewMemRepo()
+	logLevel := new(slog.LevelVar)
+	h := bass.NewHandler(repo, bass.WithLogLevelVar(logLevel))
+
+	mergePatch := func(h *bass.Handler) int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/foos/foo1", bytes.NewBufferString(`{"bar": "qux"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// create item
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`},
+			{"/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "bar": "baz"}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// create server config with other name
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/serverconfigs", bytes.NewBufferString(`{"metadata": {"name": "other"}, "logLevel": "debug"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// create server config with unknown feature
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/serverconfigs", bytes.NewBufferString(`{"metadata": {"name": "default"}, "features": {"Teleport": true}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// apply server config
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/serverconfigs", bytes.NewBufferString(`{"metadata": {"name": "default"}, "logLevel": "debug", "features": {"MergePatch": false}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		assert.Equal(t, slog.LevelDebug, logLevel.Level())
+		assert.Equal(t, http.StatusUnsupportedMediaType, mergePatch(h))
+	}
+
+	// reload server config written through other replica
+	{
+		replica := bass.NewHandler(repo)
+		assert.Equal(t, http.StatusOK, mergePatch(replica))
+
+		err := replica.ReloadServerConfig(t.Context())
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, mergePatch(replica))
+	}
+
+	// delete server config
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/api/core/v1/serverconfigs/default", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		assert.Equal(t, slog.LevelInfo, logLevel.Level())
+		assert.Equal(t, http.StatusOK, mergePatch(h))
+	}
+}
+
+func TestFeatureGates(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithFeatureGates(map[bass.Feature]bool{bass.FeatureNotifications: false}))
+
+	// list feature gates
+	{
+		req := httptest.NewRequest(http.MethodGet, "/admin/features", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res []bass.FeatureGate
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Contains(t, res, bass.FeatureGate{Name: "JSONPatch", Stage: "GA", Enabled: true})
+		assert.Contains(t, res, bass.FeatureGate{Name: "Localization", Stage: "beta", Enabled: true})
+		assert.Contains(t, res, bass.FeatureGate{Name: "Notifications", Stage: "beta", Enabled: false})
+	}
+
+	// disabled endpoint
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/ops/notify", bytes.NewBufferString(`{"title": "Test"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), `feature \"Notifications\" is disabled`)
+	}
+
+	// enable feature by server config
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/serverconfigs", bytes.NewBufferString(`{"metadata": {"name": "default"}, "features": {"Notifications": true}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/ops/notify", bytes.NewBufferString(`{"title": "Test"}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "is disabled")
+	}
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithMetrics(true))
+
+	// register resource type with deprecated version and property
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [
+{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string", "deprecated": true}, "nested": {"type": "object", "properties": {"old": {"type": "string", "deprecated": true}}}, "baz": {"type": "string"}}}, "deprecated": true, "deprecationWarning": "use v2", "sunset": "2030-01-01T00:00:00Z"},
+{"name": "v2", "schema": {"type": "object"}}
+]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// use deprecated version and properties
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "x", "baz": "y", "nested": {"old": "z"}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+		assert.Equal(t, "Tue, 01 Jan 2030 00:00:00 GMT", rec.Header().Get("Sunset"))
+		assert.Equal(t, []string{
+			`299 - "v1 of test/foos is deprecated: use v2"`,
+			`299 - "property /properties/bar is deprecated"`,
+			`299 - "property /properties/nested/old is deprecated"`,
+		}, rec.Header().Values("Warning"))
+	}
+
+	// use current version
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v2/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+		assert.Empty(t, rec.Header().Values("Warning"))
+	}
+
+	// count deprecated usage
+	{
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Contains(t, rec.Body.String(), `bass_deprecated_usage_total{package="test",resource_type="Foo",kind="apiVersion",name="v1"} 1`)
+		assert.Contains(t, rec.Body.String(), `bass_deprecated_usage_total{package="test",resource_type="Foo",kind="property",name="/properties/bar"} 1`)
+	}
+}
+
+func TestErrorCatalog(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithUnknownFieldPolicy(bass.UnknownFieldPolicyReject))
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// create resource item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// failures carry the type and code of their reason
+	{
+		for _, tc := range []struct {
+			req    *http.Request
+			reason apierrors.Reason
+		}{
+			{httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo2", nil), apierrors.ReasonNotFound},
+			{httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": "baz"}`)), apierrors.ReasonAlreadyExists},
+			{httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo2"}, "baz": "qux"}`)), apierrors.ReasonUnknownFields},
+			{httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo2"}, "bar": 1}`)), apierrors.ReasonInvalid},
+			{httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": "foo2"}`)), apierrors.ReasonBadRequest},
+			{httptest.NewRequest(http.MethodPost, "/api/transactions", bytes.NewBufferString(`{"operations": [{"op": "create", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "foos", "name": "foo1", "properties": {"bar": "baz"}}]}`)), apierrors.ReasonAlreadyExists},
+		} {
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, tc.req)
+
+			require.Equal(t, tc.reason.Status(), rec.Code, rec.Body.String())
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+			var res map[string]any
+
+			err := json.UnmarshalRead(rec.Body, &res)
+			require.NoError(t, err)
+
+			assert.Equal(t, "urn:bass:problem:"+string(tc.reason), res["type"])
+			assert.Equal(t, string(tc.reason), res["code"])
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "string"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		require.NoError(t, apierrors.FromResponse(rec.Result()))
+	}
+
+	// not found
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		err := apierrors.FromResponse(rec.Result())
+		require.Error(t, err)
+
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.False(t, apierrors.IsConflict(err))
+		assert.Equal(t, apierrors.ReasonNotFound, apierrors.ReasonFrom(fmt.Errorf("failed to get foo1: %w", err)))
+	}
+
+	// invalid
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": 1}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		err := apierrors.FromResponse(rec.Result())
+
+		var statusError *apierrors.StatusError
+
+		require.ErrorAs(t, err, &statusError)
+
+		assert.True(t, apierrors.IsInvalid(err))
+		assert.Equal(t, http.StatusBadRequest, statusError.Status)
+		assert.Contains(t, statusError.Extensions, "errors")
+	}
+
+	// responses without problem details fall back to the status
+	{
+		err := apierrors.FromResponse(&http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: http.NoBody})
+
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.Equal(t, apierrors.Reason(""), apierrors.ReasonFrom(err))
+		assert.False(t, apierrors.IsNotFound(io.EOF))
+	}
+}
+
+func TestSchemaDefinitions(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// create schema definition
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/schemadefinitions", bytes.NewBufferString(`{"metadata": {"name": "address"}, "schema": {"type": "object", "properties": {"street": {"type": "string"}, "city": {"type": "string"}}, "required": ["city"]}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// references to missing schema definitions are rejected
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"price": {"$ref": "schemadefinitions/money"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "schemadefinitions/money")
+	}
+
+	// register resource type referring to schema definition
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"address": {"$ref": "schemadefinitions/address"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// items are validated against the referred schema
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "address": {"street": "Main"}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "address": {"street": "Main", "city": "Tehran"}}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// updated schema definitions apply to referring resource types
+	{
+		req := httptest.NewRequest(http.MethodPut, "/api/core/v1/schemadefinitions/address", bytes.NewBufferString(`{"metadata": {"name": "address"}, "schema": {"type": "object", "properties": {"city": {"type": "string"}, "zip": {"type": "string"}}, "required": ["city", "zip"]}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo2"}, "address": {"city": "Tehran"}}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// circular references are rejected
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/schemadefinitions", bytes.NewBufferString(`{"metadata": {"name": "a"}, "schema": {"type": "object", "properties": {"city": {"type": "string"}}}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/core/v1/schemadefinitions", bytes.NewBufferString(`{"metadata": {"name": "b"}, "schema": {"type": "array", "items": {"$ref": "schemadefinitions/a"}}}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPut, "/api/core/v1/schemadefinitions/a", bytes.NewBufferString(`{"metadata": {"name": "a"}, "schema": {"type": "object", "properties": {"children": {"$ref": "schemadefinitions/b"}}}}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "is circular")
+	}
+
+	// atomic updates of referring schema definitions resolve references
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/schemadefinitions", bytes.NewBufferString(`{"metadata": {"name": "money"}, "schema": {"type": "object", "properties": {"amount": {"type": "number"}}}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/core/v1/schemadefinitions", bytes.NewBufferString(`{"metadata": {"name": "price"}, "schema": {"$ref": "schemadefinitions/money"}}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		done := make(chan *httptest.ResponseRecorder)
+
+		go func() {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/schemadefinitions/price:appendUnique", bytes.NewBufferString(`{"path": "/properties/schema/required", "value": "amount"}`))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			done <- rec
+		}()
+
+		select {
+		case rec := <-done:
+			assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Contains(t, rec.Body.String(), `"required":["amount"]`)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "atomic update of schema definition didn't return")
+		}
+	}
+}
+
+func TestValidationErrorPaths(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"name": {"type": "string", "maxLength": 3}, "address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}, "tags": {"type": "array", "items": {"type": "string"}}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// invalid fields are reported with pointers, keywords, and constraints
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "name": "long", "address": {}, "tags": ["a", 1]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var res struct {
+			Errors []bass.ValidationError `json:"errors"`
+		}
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		require.Len(t, res.Errors, 3)
+
+		errorsByPointer := make(map[string]bass.ValidationError)
+		for _, validationError := range res.Errors {
+			errorsByPointer[validationError.Pointer] = validationError
+		}
+
+		assert.Equal(t, "maxLength", errorsByPointer["/properties/name"].Keyword)
+		assert.Equal(t, map[string]any{"max": float64(3)}, errorsByPointer["/properties/name"].Params)
+		assert.Equal(t, "required", errorsByPointer["/properties/address/city"].Keyword)
+		assert.Equal(t, "type", errorsByPointer["/properties/tags/1"].Keyword)
+		assert.Equal(t, "string", errorsByPointer["/properties/tags/1"].Params["expected"])
+		assert.NotEmpty(t, errorsByPointer["/properties/tags/1"].Message)
+	}
+}
+
+func TestSoftConstraints(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string", "x-warn": {"maxLength": 10}}, "tags": {"type": "array", "items": {"type": "string", "x-warn": {"pattern": "^[a-z]+$"}}}}, "x-warn": {"required": ["tags"]}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// violated soft constraints are warned about
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "title": "a rather long title"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		warnings := rec.Header().Values("Warning")
+		require.Len(t, warnings, 2)
+		assert.Contains(t, strings.Join(warnings, "\n"), "/properties/title")
+		assert.Contains(t, strings.Join(warnings, "\n"), "/properties/tags")
+
+		req = httptest.NewRequest(http.MethodPut, "/api/test/v1/foos/foo1", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "title": "short", "tags": ["ok", "Not OK"]}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		warnings = rec.Header().Values("Warning")
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "/properties/tags/1")
+	}
+
+	// satisfied soft constraints aren't
+	{
+		req := httptest.NewRequest(http.MethodPut, "/api/test/v1/foos/foo1", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "title": "short", "tags": ["ok"]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Values("Warning"))
+	}
+}
+
+func TestRawProperties(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// key order and number spelling survive round trips
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"z": 1, "metadata": {"name": "foo1"}, "a": {"y": true, "x": 12345678901234567890}, "price": 1.10}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"z":1,"a":{"y":true,"x":12345678901234567890},"price":1.10}`)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"z":1,"a":{"y":true,"x":12345678901234567890},"price":1.10}`)
+	}
+
+	// properties changed in place are encoded from their values, and the others as they were
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos/foo1:increment", bytes.NewBufferString(`{"path": "/properties/z", "delta": 1}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), `"z":2,"a":{"y":true,"x":12345678901234567890},"price":1.10}`)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"z":2,"a":{"y":true,"x":12345678901234567890},"price":1.10}`)
+	}
+
+	// changed properties are encoded from their values
+	{
+		req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/foos/foo1", bytes.NewBufferString(`{"price": 2}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.InDelta(t, 2, res.Properties["price"], 0)
+		assert.InDelta(t, 2, res.Properties["z"], 0)
+	}
+
+	// pruned properties are encoded from their values
+	{
+		h := bass.NewHandler(bass.NewMemRepo(), bass.WithPruning(true))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "bars.test"}, "package": "test", "resourceType": "Bar", "plural": "bars", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"kept": {"type": "number"}}}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/bars", bytes.NewBufferString(`{"metadata": {"name": "bar1"}, "kept": 1, "dropped": 2}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.NotContains(t, rec.Body.String(), "dropped")
+	}
+}
+
+// rawlessRepo hides the optional interfaces of a repository, e.g. to get resources without their raw encoding.
+type rawlessRepo struct {
+	bass.ResourcesRepository
+}
+
+func TestRawGet(t *testing.T) {
+	t.Parallel()
+
+	for _, repo := range []bass.ResourcesRepository{bass.NewMemRepo(), rawlessRepo{ResourcesRepository: bass.NewMemRepo()}} {
+		h := bass.NewHandler(repo)
+
+		// register resource type
+		{
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+
+		// get responds with the item as created
+		{
+			req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "b": 1, "a": [true, null]}`))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			created := strings.TrimSpace(rec.Body.String())
+
+			req = httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+			rec = httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+			assert.Equal(t, created, strings.TrimSpace(rec.Body.String()))
+		}
+
+		// get of missing items is not found
+		{
+			req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo2", nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+	}
+}
+
+func TestListLimits(t *testing.T) {
+	t.Parallel()
+
+	newHandler := func(t *testing.T, limits bass.ListLimits) *bass.Handler {
+		t.Helper()
+
+		h := bass.NewHandler(bass.NewMemRepo(), bass.WithListLimits(limits))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		for _, name := range []string{"foo3", "foo1", "foo5", "foo2", "foo4"} {
+			req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "`+name+`"}, "title": "Foo"}`))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+
+		return h
+	}
+
+	list := func(t *testing.T, h *bass.Handler, query string) (int, bass.ResourceList) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos"+query, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		var res bass.ResourceList
+		if rec.Code == http.StatusOK {
+			err := json.Unmarshal(rec.Body.Bytes(), &res)
+			require.NoError(t, err)
+		}
+
+		return rec.Code, res
+	}
+
+	names := func(list bass.ResourceList) []string {
+		result := make([]string, 0, len(list.Items))
+		for _, item := range list.Items {
+			result = append(result, item.Metadata.Name)
+		}
+
+		return result
+	}
+
+	// lists are paged by the default limit, following continue tokens
+	{
+		h := newHandler(t, bass.ListLimits{DefaultLimit: 2, MaxLimit: 3, MaxUnpaginatedBytes: 0})
+
+		var pages [][]string
+
+		query := ""
+
+		for {
+			code, res := list(t, h, query)
+			require.Equal(t, http.StatusOK, code)
+
+			pages = append(pages, names(res))
+
+			if res.Metadata.Continue == "" {
+				break
+			}
+
+			query = "?continue=" + res.Metadata.Continue
+		}
+
+		assert.Equal(t, [][]string{{"foo1", "foo2"}, {"foo3", "foo4"}, {"foo5"}}, pages)
+	}
+
+	// requested limits are capped
+	{
+		h := newHandler(t, bass.ListLimits{DefaultLimit: 2, MaxLimit: 3, MaxUnpaginatedBytes: 0})
+
+		code, res := list(t, h, "?limit=10")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"foo1", "foo2", "foo3"}, names(res))
+		assert.NotEmpty(t, res.Metadata.Continue)
+	}
+
+	// invalid limits and continue tokens are bad requests
+	{
+		h := newHandler(t, bass.ListLimits{DefaultLimit: 2, MaxLimit: 3, MaxUnpaginatedBytes: 0})
+
+		for _, query := range []string{"?limit=abc", "?limit=0", "?continue=%25"} {
+			code, _ := list(t, h, query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	}
+
+	// large unpaginated lists require pagination
+	{
+		h := newHandler(t, bass.ListLimits{DefaultLimit: 0, MaxLimit: 0, MaxUnpaginatedBytes: 512})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), `"code":"PaginationRequired"`)
+
+		code, res := list(t, h, "?limit=1")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"foo1"}, names(res))
+	}
+
+	// small unpaginated lists are responded at once
+	{
+		h := newHandler(t, bass.ListLimits{DefaultLimit: 0, MaxLimit: 0, MaxUnpaginatedBytes: 1 << 20})
+
+		code, res := list(t, h, "")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"foo1", "foo2", "foo3", "foo4", "foo5"}, names(res))
+		assert.Empty(t, res.Metadata.Continue)
+	}
+}
+
+type memColdStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memColdStorage) Put(_ context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[key] = data
+
+	return nil
+}
+
+func (s *memColdStorage) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+
+	return data, nil
+}
+
+func (s *memColdStorage) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, key)
+
+	return nil
+}
+
+func (s *memColdStorage) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.objects)
+}
+
+func TestArchiving(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	storage := &memColdStorage{mu: sync.Mutex{}, objects: make(map[string][]byte)}
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithClock(func() time.Time { return now }),
+		bass.WithArchiving(bass.Archiving{Storage: storage, After: 30 * 24 * time.Hour}),
+	)
+
+	// register resource type and create items
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "title": "Old"}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		now = now.Add(20 * 24 * time.Hour)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo2"}, "title": "New"}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// nothing is stale yet
+	{
+		archived, err := h.Archive(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 0, archived)
+	}
+
+	// stale items are replaced by stubs
+	{
+		now = now.Add(15 * 24 * time.Hour)
+
+		archived, err := h.Archive(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 1, archived)
+		assert.Equal(t, 1, storage.len())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.ResourceList
+
+		err = json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+		require.Len(t, res.Items, 2)
+		assert.Equal(t, "foo1", res.Items[0].Metadata.Name)
+		assert.Contains(t, res.Items[0].Metadata.Labels, "bass.archive/key")
+		assert.Empty(t, res.Items[0].Properties)
+		assert.Equal(t, "New", res.Items[1].Properties["title"])
+	}
+
+	// archived items are rehydrated on access
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.Resource
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+		assert.Equal(t, "Old", res.Properties["title"])
+		assert.NotContains(t, res.Metadata.Labels, "bass.archive/key")
+		assert.Equal(t, 0, storage.len())
+
+		req = httptest.NewRequest(http.MethodGet, "/api/test/v1/foos", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"Old"`)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithClock(func() time.Time { return now }), bass.WithRevisionHistory(true))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if body == "" {
+			req = httptest.NewRequest(method, target, nil)
+		} else {
+			req = httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		}
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// write some history
+	{
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`).Code)
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`).Code)
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo2"}, "v": 1}`).Code)
+
+		now = now.Add(time.Hour)
+
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 2}`).Code)
+		require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/test/v1/foos/foo2", "").Code)
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo3"}, "v": 1}`).Code)
+	}
+
+	at := "2025-06-01T12:30:00Z"
+
+	// invalid restores are rejected
+	{
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/restore?at="+at, "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/restore?package=test&at=yesterday", "").Code)
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/restore?package=test&at=2025-05-01T00:00:00Z", "").Code)
+
+		rec := httptest.NewRecorder()
+
+		bass.NewHandler(bass.NewMemRepo()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/restore?package=test&at="+at, nil))
+
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	}
+
+	// restore into a new package
+	{
+		rec := do(http.MethodPost, "/admin/restore?package=test&target=restored&at="+at, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.RestoreResult
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.Created)
+		assert.Empty(t, res.Unrecoverable)
+
+		rec = do(http.MethodGet, "/api/restored/v1/foos/foo1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"v":1`)
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/restored/v1/foos/foo2", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/restored/v1/foos/foo3", "").Code)
+	}
+
+	// restore in place
+	{
+		rec := do(http.MethodPost, "/admin/restore?package=test&at="+at, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.RestoreResult
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+		assert.Equal(t, 1, res.Created)
+		assert.Equal(t, 1, res.Updated)
+		assert.Equal(t, 1, res.Deleted)
+
+		rec = do(http.MethodGet, "/api/test/v1/foos/foo1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"v":1`)
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/test/v1/foos/foo2", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/test/v1/foos/foo3", "").Code)
+	}
+
+	// restores in place leave held items as they are
+	{
+		now = now.Add(time.Hour)
+
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 3}`).Code)
+		require.Equal(t, http.StatusCreated, do(http.MethodPut, "/admin/legalholds/test/foos/foo1", `{"reason": "litigation"}`).Code)
+
+		rec := do(http.MethodPost, "/admin/restore?package=test&at="+at, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.RestoreResult
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Foo/foo1"}, res.Held)
+
+		assert.Contains(t, do(http.MethodGet, "/api/test/v1/foos/foo1", "").Body.String(), `"v":3`)
+	}
+}
+
+func TestRevisionHistoryLimit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithClock(func() time.Time { return now }), bass.WithRevisionHistory(true), bass.WithRevisionHistoryLimit(3))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+
+		return rec
+	}
+
+	sync := func(token string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, "/sync", `{"packageName": "test", "syncToken": "`+token+`"}`)
+	}
+
+	var token string
+
+	// write history up to the limit
+	{
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`).Code)
+
+		now = now.Add(time.Hour)
+
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`).Code)
+
+		rec := sync("")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res bass.SyncResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+
+		token = res.SyncToken
+
+		now = now.Add(time.Hour)
+
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 2}`).Code)
+		require.Equal(t, http.StatusOK, sync(token).Code)
+	}
+
+	// revisions beyond the limit are dropped, and the history starts later
+	{
+		now = now.Add(time.Hour)
+
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 3}`).Code)
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 4}`).Code)
+
+		rec := do(http.MethodPost, "/admin/restore?package=test&at=2025-06-01T12:30:00Z", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "revision history starts at 2025-06-01T13:00:00Z")
+
+		rec = do(http.MethodPost, "/admin/restore?package=test&at=2025-06-01T14:30:00Z", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, do(http.MethodGet, "/api/test/v1/foos/foo1", "").Body.String(), `"v":2`)
+	}
+
+	// sync tokens from before dropped revisions expire
+	{
+		assert.Equal(t, http.StatusGone, sync(token).Code)
+	}
+}
+
+type memBackupTarget struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (target *memBackupTarget) Put(_ context.Context, name string, data []byte) error {
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	target.objects[name] = data
+
+	return nil
+}
+
+func (target *memBackupTarget) Delete(_ context.Context, name string) error {
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	delete(target.objects, name)
+
+	return nil
+}
+
+func (target *memBackupTarget) get(name string) ([]byte, bool) {
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	data, ok := target.objects[name]
+
+	return data, ok
+}
+
+func TestBackups(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	target := &memBackupTarget{mu: sync.Mutex{}, objects: make(map[string][]byte)}
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithClock(func() time.Time { return now }),
+		bass.WithBackupPolicy(bass.BackupPolicy{Target: target, FullInterval: 24 * time.Hour, IncrementalInterval: time.Hour, Retention: 1}),
+	)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`).Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`).Code)
+
+	var full bass.Backup
+
+	// the first backup is full
+	{
+		backup, err := h.Backup(t.Context(), bass.BackupIncremental)
+		require.NoError(t, err)
+		assert.Equal(t, bass.BackupFull, backup.Type)
+		assert.Equal(t, 2, backup.Count)
+
+		data, ok := target.get(backup.Name)
+		require.True(t, ok)
+
+		sum := sha256.Sum256(data)
+		assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), backup.Checksum)
+		assert.Equal(t, int64(len(data)), backup.Bytes)
+
+		full = backup
+	}
+
+	// incremental backups hold the changes since the previous backup
+	{
+		now = now.Add(time.Hour)
+
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo2"}, "v": 1}`).Code)
+		require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/test/v1/foos/foo1", "").Code)
+
+		backup, err := h.Backup(t.Context(), bass.BackupIncremental)
+		require.NoError(t, err)
+		assert.Equal(t, bass.BackupIncremental, backup.Type)
+		assert.Equal(t, full.Name, backup.Base)
+
+		b, ok := target.get(backup.Name)
+		require.True(t, ok)
+
+		var data bass.BackupData
+
+		err = json.Unmarshal(b, &data)
+		require.NoError(t, err)
+		require.Len(t, data.Items, 1)
+		assert.Equal(t, "foo2", data.Items[0].Metadata.Name)
+		assert.Equal(t, []string{"test/Foo/foo1"}, data.Deleted)
+	}
+
+	// backups beyond the retention are removed
+	{
+		now = now.Add(24 * time.Hour)
+
+		backup, err := h.Backup(t.Context(), bass.BackupFull)
+		require.NoError(t, err)
+
+		_, ok := target.get(full.Name)
+		assert.False(t, ok)
+
+		rec := do(http.MethodGet, "/admin/backups", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res bass.BackupList
+
+		err = json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, backup.Name, res.Items[0].Name)
+	}
+
+	// the scheduler takes a backup when one is due
+	{
+		target := &memBackupTarget{mu: sync.Mutex{}, objects: make(map[string][]byte)}
+		h := bass.NewHandler(bass.NewMemRepo(), bass.WithBackupPolicy(bass.BackupPolicy{Target: target, FullInterval: time.Hour, IncrementalInterval: 0, Retention: 0}))
+
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan struct{})
+
+		go func() {
+			h.RunBackups(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool {
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/backups", nil))
+
+			return strings.Contains(rec.Body.String(), `"type":"full"`)
+		}, time.Second, 10*time.Millisecond)
+
+		cancel()
+		<-done
+	}
+}
+
+func TestReplication(t *testing.T) {
+	t.Parallel()
+
+	rtd := `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`
+
+	replica := bass.NewHandler(bass.NewMemRepo())
+	server := httptest.NewServer(replica)
+	t.Cleanup(server.Close)
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithReplication(bass.Replication{
+		Targets:        []string{server.URL},
+		ConflictPolicy: "",
+		Client:         server.Client(),
+		SigningKeyID:   "",
+		SigningKey:     nil,
+	}))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+
+	go func() {
+		h.RunReplication(ctx)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	get := func(h *bass.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec
+	}
+
+	// writes are forwarded to replicas
+	{
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(rtd)),
+			httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "v": 1}`)),
+			httptest.NewRequest(http.MethodPut, "/api/test/v1/foos/foo1", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "v": 2}`)),
+			httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo2"}, "v": 1}`)),
+			httptest.NewRequest(http.MethodDelete, "/api/test/v1/foos/foo2", nil),
+		} {
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Less(t, rec.Code, http.StatusMultipleChoices)
+		}
+
+		assert.Eventually(t, func() bool {
+			rec := get(replica, "/api/test/v1/foos/foo1")
+
+			return rec.Code == http.StatusOK && strings.Contains(rec.Body.String(), `"v":2`) &&
+				get(replica, "/api/test/v1/foos/foo2").Code == http.StatusNotFound
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	event := func(policy bass.ConflictPolicy, updatedAt time.Time) *httptest.ResponseRecorder {
+		body, err := json.Marshal(bass.ReplicationEvent{
+			Type: bass.OperationUpdate,
+			At:   updatedAt,
+			Item: &bass.Resource{
+				Metadata: bass.Metadata{
+					PackageName:  "test",
+					APIVersion:   "v1",
+					ResourceType: "Foo",
+					Name:         "foo1",
+					UpdatedAt:    updatedAt,
+				},
+				Properties: map[string]any{"v": 0},
+			},
+			ConflictPolicy: policy,
+		})
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+
+		replica.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/replication", bytes.NewReader(body)))
+
+		return rec
+	}
+
+	stale := time.Now().Add(-time.Hour)
+
+	// stale writes are ignored with last writer wins
+	{
+		rec := event(bass.ConflictLastWriterWins, stale)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Contains(t, get(replica, "/api/test/v1/foos/foo1").Body.String(), `"v":2`)
+	}
+
+	// stale writes are conflicts with reject
+	{
+		rec := event(bass.ConflictReject, stale)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, get(replica, "/api/test/v1/foos/foo1").Body.String(), `"v":2`)
+	}
+
+	// later writes are applied
+	{
+		rec := event(bass.ConflictReject, time.Now().Add(time.Hour))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Contains(t, get(replica, "/api/test/v1/foos/foo1").Body.String(), `"v":0`)
+	}
+
+	// resent creates that were applied already are not conflicts
+	{
+		body, err := json.Marshal(bass.ReplicationEvent{
+			Type: bass.OperationCreate,
+			At:   stale,
+			Item: &bass.Resource{
+				Metadata:   bass.Metadata{UID: "uid3", PackageName: "test", APIVersion: "v1", ResourceType: "Foo", Name: "foo3", UpdatedAt: stale},
+				Properties: map[string]any{"v": 1},
+			},
+			ConflictPolicy: bass.ConflictReject,
+		})
+		require.NoError(t, err)
+
+		for range 2 {
+			rec := httptest.NewRecorder()
+
+			replica.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/replication", bytes.NewReader(body)))
+			assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		}
+
+		assert.Contains(t, get(replica, "/api/test/v1/foos/foo3").Body.String(), `"v":1`)
+	}
+
+	// replicated writes don't modify held items
+	{
+		rec := httptest.NewRecorder()
+
+		replica.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/legalholds/test/foos/foo1", bytes.NewBufferString(`{"reason": "litigation"}`)))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = event(bass.ConflictLastWriterWins, time.Now().Add(2*time.Hour))
+		assert.Equal(t, http.StatusLocked, rec.Code)
+		assert.Contains(t, get(replica, "/api/test/v1/foos/foo1").Body.String(), `"v":0`)
+	}
+}
+
+func TestSync(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithRevisionHistory(true))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+
+		return rec
+	}
+
+	sync := func(req bass.SyncRequest) (int, bass.SyncResponse) {
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+
+		rec := do(http.MethodPost, "/sync", string(body))
+
+		var res bass.SyncResponse
+
+		if rec.Code == http.StatusOK {
+			err = json.Unmarshal(rec.Body.Bytes(), &res)
+			require.NoError(t, err)
+		}
+
+		return rec.Code, res
+	}
+
+	names := func(changes []bass.SyncServerChange) []string {
+		res := make([]string, 0, len(changes))
+		for _, change := range changes {
+			res = append(res, string(change.Type)+" "+change.Item.Metadata.Name)
+		}
+
+		return res
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`).Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`).Code)
+
+	var (
+		token       string
+		baseVersion string
+	)
+
+	// the first sync gets all items
+	{
+		code, res := sync(bass.SyncRequest{
+			PackageName: "test",
+			SyncToken:   "",
+			Changes: []bass.SyncChange{
+				{Op: bass.OperationCreate, APIVersion: "v1", ResourceTypePlural: "foos", Name: "bar1", Labels: nil, Properties: map[string]any{"v": 1}, BaseResourceVersion: ""},
+			},
+		})
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, res.Results, 1)
+		assert.Equal(t, http.StatusCreated, res.Results[0].Status)
+		assert.ElementsMatch(t, []string{"create foo1", "create bar1"}, names(res.Changes))
+		assert.NotEmpty(t, res.SyncToken)
+
+		for _, change := range res.Changes {
+			if change.Item.Metadata.Name == "foo1" {
+				baseVersion = change.Item.Metadata.ResourceVersion
+			}
+		}
+
+		token = res.SyncToken
+	}
+
+	// conflicting changes are reported with the current item, and server changes since the token are returned
+	{
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 2}`).Code)
+
+		code, res := sync(bass.SyncRequest{
+			PackageName: "test",
+			SyncToken:   token,
+			Changes: []bass.SyncChange{
+				{Op: bass.OperationUpdate, APIVersion: "v1", ResourceTypePlural: "foos", Name: "foo1", Labels: nil, Properties: map[string]any{"v": 3}, BaseResourceVersion: baseVersion},
+				{Op: bass.OperationCreate, APIVersion: "v1", ResourceTypePlural: "foos", Name: "bar2", Labels: nil, Properties: map[string]any{"v": 1}, BaseResourceVersion: ""},
+			},
+		})
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, res.Results, 2)
+		assert.Equal(t, http.StatusConflict, res.Results[0].Status)
+		assert.True(t, res.Results[0].Conflict)
+		require.NotNil(t, res.Results[0].Current)
+		assert.InDelta(t, 2, res.Results[0].Current.Properties["v"], 0)
+		assert.Equal(t, http.StatusCreated, res.Results[1].Status)
+		assert.Equal(t, []string{"update foo1"}, names(res.Changes))
+
+		token = res.SyncToken
+	}
+
+	// syncs without changes on either side are empty
+	{
+		code, res := sync(bass.SyncRequest{PackageName: "test", SyncToken: token, Changes: nil})
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, res.Changes)
+	}
+
+	// unknown tokens are expired
+	{
+		code, _ := sync(bass.SyncRequest{PackageName: "test", SyncToken: "1.2", Changes: nil})
+		assert.Equal(t, http.StatusGone, code)
+	}
+}
+
+func TestWatchResources(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	do := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "title": "first", "body": "a long body", "tags": ["a/b"]}`))
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	watch := func(query string) *jsontext.Decoder {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/api/test/v1/foos/watch"+query, nil)
+		require.NoError(t, err)
+
+		res, err := server.Client().Do(req)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = res.Body.Close() })
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		return jsontext.NewDecoder(res.Body)
+	}
+
+	next := func(dec *jsontext.Decoder) bass.WatchEvent {
+		var event bass.WatchEvent
+
+		err := json.UnmarshalDecode(dec, &event)
+		require.NoError(t, err)
+
+		return event
+	}
+
+	full := watch("")
+	delta := watch("?delta=true")
+
+	// current items come first, whole
+	{
+		for _, dec := range []*jsontext.Decoder{full, delta} {
+			event := next(dec)
+			assert.Equal(t, bass.OperationCreate, event.Type)
+			require.NotNil(t, event.Item)
+			assert.Equal(t, "foo1", event.Item.Metadata.Name)
+		}
+	}
+
+	// updates are whole items, or patches for delta watches
+	{
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "title": "second", "body": "a long body", "tags": ["a/b"], "draft~": true}`))
+
+		event := next(full)
+		assert.Equal(t, bass.OperationUpdate, event.Type)
+		require.NotNil(t, event.Item)
+		assert.Equal(t, "second", event.Item.Properties["title"])
+
+		event = next(delta)
+		assert.Equal(t, bass.OperationUpdate, event.Type)
+		assert.Nil(t, event.Item)
+		assert.Equal(t, "foo1", event.Name)
+		assert.NotEmpty(t, event.ResourceVersion)
+
+		ops := make(map[string]bass.JSONPatchOperation)
+		for _, op := range event.Patch {
+			ops[op.Path] = op
+		}
+
+		assert.Equal(t, bass.JSONPatchOperation{Op: "replace", Path: "/title", Value: jsontext.Value(`"second"`)}, ops["/title"])
+		assert.Equal(t, bass.JSONPatchOperation{Op: "add", Path: "/draft~0", Value: jsontext.Value(`true`)}, ops["/draft~0"])
+		assert.Contains(t, ops, "/metadata/resourceVersion")
+		assert.NotContains(t, ops, "/body")
+		assert.NotContains(t, ops, "/tags")
+	}
+
+	// deletes of delta watches carry the name only
+	{
+		require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/test/v1/foos/foo1", ""))
+
+		event := next(full)
+		assert.Equal(t, bass.OperationDelete, event.Type)
+		require.NotNil(t, event.Item)
+
+		event = next(delta)
+		assert.Equal(t, bass.OperationDelete, event.Type)
+		assert.Nil(t, event.Item)
+		assert.Equal(t, "foo1", event.Name)
+	}
+
+	// unknown resource types are not found
+	{
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/test/v1/bars/watch", ""))
+	}
+}
+
+func TestWatchResourcesSelector(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	do := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "tasks.test"}, "package": "test", "resourceType": "Task", "plural": "tasks", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/tasks", `{"metadata": {"name": "t1", "labels": {"team": "a"}}, "status": "open"}`))
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/tasks", `{"metadata": {"name": "t2", "labels": {"team": "b"}}, "status": "open"}`))
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/tasks", `{"metadata": {"name": "t3", "labels": {"team": "a"}}, "status": "done"}`))
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/api/test/v1/tasks/watch?labelSelector=team%3Da&fieldSelector=/properties/status%3Dopen", nil)
+	require.NoError(t, err)
+
+	res, err := server.Client().Do(req)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = res.Body.Close() })
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	dec := jsontext.NewDecoder(res.Body)
+
+	next := func() (bass.OperationType, string) {
+		var event bass.WatchEvent
+
+		err := json.UnmarshalDecode(dec, &event)
+		require.NoError(t, err)
+		require.NotNil(t, event.Item)
+
+		return event.Type, event.Item.Metadata.Name
+	}
+
+	// only the items selected are listed
+	{
+		op, name := next()
+		assert.Equal(t, bass.OperationCreate, op)
+		assert.Equal(t, "t1", name)
+	}
+
+	// writes of items not selected are left out, items updated into the selection are created, and items updated out
+	// of it are deleted
+	{
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/tasks/t2", `{"metadata": {"name": "t2", "labels": {"team": "b"}}, "status": "done"}`))
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/tasks/t3", `{"metadata": {"name": "t3", "labels": {"team": "a"}}, "status": "open"}`))
+
+		op, name := next()
+		assert.Equal(t, bass.OperationCreate, op)
+		assert.Equal(t, "t3", name)
+
+		require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/tasks/t1", `{"metadata": {"name": "t1", "labels": {"team": "a"}}, "status": "done"}`))
+
+		op, name = next()
+		assert.Equal(t, bass.OperationDelete, op)
+		assert.Equal(t, "t1", name)
+
+		require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/test/v1/tasks/t1", ""))
+		require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/test/v1/tasks/t3", ""))
+
+		op, name = next()
+		assert.Equal(t, bass.OperationDelete, op)
+		assert.Equal(t, "t3", name)
+	}
+
+	// invalid selectors are bad requests
+	{
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/test/v1/tasks/watch?fieldSelector=status%3Dopen", ""))
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/test/v1/tasks/watch?labelSelector=%3Da", ""))
+	}
+}
+
+func TestWatchPackage(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	do := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+
+		return rec.Code
+	}
+
+	for _, body := range []string{
+		`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+		`{"metadata": {"name": "bars.test"}, "package": "test", "resourceType": "Bar", "plural": "bars", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+		`{"metadata": {"name": "items.other"}, "package": "other", "resourceType": "Item", "plural": "items", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", body))
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`))
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/other/v1/items", `{"metadata": {"name": "item1"}, "v": 1}`))
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	watch := func(path string) *jsontext.Decoder {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+
+		res, err := server.Client().Do(req)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = res.Body.Close() })
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		return jsontext.NewDecoder(res.Body)
+	}
+
+	next := func(dec *jsontext.Decoder) string {
+		var event bass.WatchEvent
+
+		err := json.UnmarshalDecode(dec, &event)
+		require.NoError(t, err)
+		require.NotNil(t, event.Item)
+
+		return string(event.Type) + " " + event.Item.Metadata.PackageName + "/" + event.Item.Metadata.ResourceType + "/" + event.Item.Metadata.Name
+	}
+
+	pkg := watch("/api/test/v1/watch")
+	all := watch("/api/watch")
+
+	// current items of all resource types come first
+	{
+		assert.Equal(t, "create test/Foo/foo1", next(pkg))
+		assert.ElementsMatch(t, []string{"create test/Foo/foo1", "create other/Item/item1"}, []string{next(all), next(all)})
+	}
+
+	// writes of all resource types of the package are streamed, and of all packages without one
+	{
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/other/v1/items", `{"metadata": {"name": "item2"}, "v": 1}`))
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/bars", `{"metadata": {"name": "bar1"}, "v": 1}`))
+		require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/test/v1/foos/foo1", ""))
+
+		assert.Equal(t, "create test/Bar/bar1", next(pkg))
+		assert.Equal(t, "delete test/Foo/foo1", next(pkg))
+
+		assert.Equal(t, "create other/Item/item2", next(all))
+		assert.Equal(t, "create test/Bar/bar1", next(all))
+		assert.Equal(t, "delete test/Foo/foo1", next(all))
+	}
+
+	// core resources aren't streamed
+	{
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/core/v1/watch", ""))
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithEventLogging(bass.EventLogging{Log: bass.NewMemEventLog(), Retention: time.Hour}))
+
+	do := func(h *bass.Handler, method, path, body string) int {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusCreated, do(h, http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+	require.Equal(t, http.StatusCreated, do(h, http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`))
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	watch := func(query string) *jsontext.Decoder {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/api/test/v1/foos/watch"+query, nil)
+		require.NoError(t, err)
+
+		res, err := server.Client().Do(req)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = res.Body.Close() })
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		return jsontext.NewDecoder(res.Body)
+	}
+
+	next := func(dec *jsontext.Decoder) bass.WatchEvent {
+		var event bass.WatchEvent
+
+		err := json.UnmarshalDecode(dec, &event)
+		require.NoError(t, err)
+		require.NotNil(t, event.Item)
+
+		return event
+	}
+
+	var position int64
+
+	// live events carry their position in the event log
+	{
+		dec := watch("")
+
+		event := next(dec)
+		assert.Equal(t, bass.OperationCreate, event.Type)
+		assert.Zero(t, event.Position)
+
+		require.Equal(t, http.StatusCreated, do(h, http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo2"}, "v": 1}`))
+
+		event = next(dec)
+		assert.Equal(t, "foo2", event.Item.Metadata.Name)
+		assert.Positive(t, event.Position)
+
+		position = event.Position
+	}
+
+	// watches resume after a position, replaying the events logged since, then streaming live events
+	{
+		require.Equal(t, http.StatusOK, do(h, http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 2}`))
+
+		dec := watch("?since=" + strconv.FormatInt(position-1, 10))
+
+		event := next(dec)
+		assert.Equal(t, bass.OperationCreate, event.Type)
+		assert.Equal(t, "foo2", event.Item.Metadata.Name)
+		assert.Equal(t, position, event.Position)
+
+		event = next(dec)
+		assert.Equal(t, bass.OperationUpdate, event.Type)
+		assert.Equal(t, "foo1", event.Item.Metadata.Name)
+		assert.Equal(t, position+1, event.Position)
+
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodDelete, "/api/test/v1/foos/foo2", ""))
+
+		event = next(dec)
+		assert.Equal(t, bass.OperationDelete, event.Type)
+		assert.Equal(t, position+2, event.Position)
+	}
+
+	// events beyond the retention are trimmed, and watches can't resume from them
+	{
+		now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		h := bass.NewHandler(
+			bass.NewMemRepo(),
+			bass.WithClock(func() time.Time { return now }),
+			bass.WithEventLogging(bass.EventLogging{Log: bass.NewMemEventLog(), Retention: time.Hour}),
+		)
+
+		require.Equal(t, http.StatusCreated, do(h, http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+
+		now = now.Add(2 * time.Hour)
+
+		require.Equal(t, http.StatusCreated, do(h, http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`))
+
+		n, err := h.TrimEventLog(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		assert.Equal(t, http.StatusGone, do(h, http.MethodGet, "/api/test/v1/foos/watch?since=0", ""))
+	}
+
+	// resuming needs the event log
+	{
+		h := bass.NewHandler(bass.NewMemRepo())
+
+		require.Equal(t, http.StatusCreated, do(h, http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+
+		assert.Equal(t, http.StatusNotImplemented, do(h, http.MethodGet, "/api/test/v1/foos/watch?since=0", ""))
+	}
+}
+
+type recordingOutboxPublisher struct {
+	mu      sync.Mutex
+	failing bool
+	events  []string
+}
+
+func (publisher *recordingOutboxPublisher) Publish(_ context.Context, event bass.OutboxEvent) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	if publisher.failing {
+		return errors.New("bus unavailable")
+	}
+
+	publisher.events = append(publisher.events, string(event.Type)+" "+event.Item.Metadata.ResourceType+"/"+event.Item.Metadata.Name)
+
+	return nil
+}
+
+func (publisher *recordingOutboxPublisher) setFailing(failing bool) {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	publisher.failing = failing
+}
+
+func (publisher *recordingOutboxPublisher) published() []string {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	return slices.Clone(publisher.events)
+}
+
+func TestOutbox(t *testing.T) {
+	t.Parallel()
+
+	publisher := &recordingOutboxPublisher{mu: sync.Mutex{}, failing: true, events: nil}
+	h := bass.NewHandler(bass.NewMemRepo(bass.WithMemRepoOutbox()), bass.WithOutbox(bass.Outbox{Publisher: publisher, Interval: time.Minute, BatchSize: 0}))
+
+	do := func(method, path, body string) int {
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/test/v1/foos", `{"metadata": {"name": "foo1"}, "v": 1}`))
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/test/v1/foos/foo1", `{"metadata": {"name": "foo1"}, "v": 2}`))
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/test/v1/foos/foo1", ""))
+
+	// failed transactions record nothing
+	{
+		body := `{"operations": [
+{"op": "create", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "foos", "name": "foo2", "properties": {"v": 1}},
+{"op": "update", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "foos", "name": "foo3", "properties": {"v": 1}}
+]}`
+		require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/transactions", body))
+	}
+
+	// events failing to publish stay in the outbox
+	{
+		n, err := h.DispatchOutbox(t.Context())
+		require.Error(t, err)
+		assert.Zero(t, n)
+		assert.Empty(t, publisher.published())
+	}
+
+	// events are published in order, once
+	{
+		publisher.setFailing(false)
+
+		n, err := h.DispatchOutbox(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, []string{"create ResourceTypeDefinition/foos.test", "create Foo/foo1", "update Foo/foo1", "delete Foo/foo1"}, publisher.published())
+
+		n, err = h.DispatchOutbox(t.Context())
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	}
+
+	// repositories without outbox aren't supported
+	{
+		h := bass.NewHandler(rawlessRepo{ResourcesRepository: bass.NewMemRepo()}, bass.WithOutbox(bass.Outbox{Publisher: publisher, Interval: time.Minute, BatchSize: 0}))
+
+		_, err := h.DispatchOutbox(t.Context())
+		require.ErrorIs(t, err, bass.ErrOutboxUnsupported)
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithNotifier(bass.NotificationChannelWebhook, bass.NewWebhookNotifier()))
+
+	// create channel
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels", bytes.NewBufferString(`{"metadata": {"name": "ops"}, "type": "webhook", "url": "`+server.URL+`"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// failed delivery is recorded
+	var failed bass.Resource
+
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/ops/notify", bytes.NewBufferString(`{"title": "Deploy", "text": "v2"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadGateway, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/core/v1/webhookdeliveries", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var list bass.ResourceList
+
+		err := json.Unmarshal(rec.Body.Bytes(), &list)
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+
+		failed = *list.Items[0]
+
+		assert.Equal(t, "ops", failed.Properties["channel"])
+		assert.Equal(t, server.URL, failed.Properties["url"])
+		assert.Equal(t, "failed", failed.Properties["status"])
+		assert.InDelta(t, http.StatusServiceUnavailable, failed.Properties["statusCode"], 0)
+		assert.Contains(t, failed.Properties["error"], "503")
+		assert.Equal(t, "Deploy", failed.Properties["payload"].(map[string]any)["title"])
+		assert.Equal(t, "failed", failed.Metadata.Labels["bass.webhook/status"])
+	}
+
+	// redeliver
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/webhookdeliveries/"+failed.Metadata.Name+":redeliver", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var delivery bass.Resource
+
+		err := json.Unmarshal(rec.Body.Bytes(), &delivery)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, failed.Metadata.Name, delivery.Metadata.Name)
+		assert.Equal(t, "succeeded", delivery.Properties["status"])
+		assert.InDelta(t, http.StatusAccepted, delivery.Properties["statusCode"], 0)
+		assert.Equal(t, failed.Metadata.Name, delivery.Properties["redeliveryOf"])
+		assert.Equal(t, failed.Properties["payload"], delivery.Properties["payload"])
+		assert.EqualValues(t, 2, calls.Load())
+	}
+
+	// query deliveries by status
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/webhookdeliveries?labelSelector=bass.webhook/status=succeeded", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var list bass.ResourceList
+
+		err := json.Unmarshal(rec.Body.Bytes(), &list)
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+
+		assert.Equal(t, failed.Metadata.Name, list.Items[0].Properties["redeliveryOf"])
+	}
+
+	// redeliver missing delivery
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/webhookdeliveries/missing:redeliver", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// redeliver is only available for webhook deliveries
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/ops:redeliver", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestChatNotifications(t *testing.T) {
+	t.Parallel()
+
+	posts := make(chan map[string]any, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+
+		err := json.UnmarshalRead(r.Body, &body)
+		assert.NoError(t, err)
+
+		body["path"] = r.URL.Path
+		posts <- body
+	}))
+	t.Cleanup(server.Close)
+
+	h := bass.NewHandler(bass.NewMemRepo(),
+		bass.WithNotifier(bass.NotificationChannelSlack, bass.NewSlackNotifier()),
+		bass.WithNotifier(bass.NotificationChannelDiscord, bass.NewDiscordNotifier()),
+	)
+
+	// create channels and rule with channel templates
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/notificationchannels", `{"metadata": {"name": "team-slack"}, "type": "slack", "url": "` + server.URL + `/slack"}`},
+			{"/api/core/v1/notificationchannels", `{"metadata": {"name": "team-discord"}, "type": "discord", "url": "` + server.URL + `/discord"}`},
+			{"/api/core/v1/notificationrules", `{"metadata": {"name": "tickets"}, "trigger": {"packageName": "support", "resourceType": "Ticket"}, "channels": ["team-slack", "team-discord"], "title": "Ticket {{.item.metadata.name}} {{.operation}}d", "text": "Priority: {{.item.priority}}", "channelTemplates": {"team-discord": {"title": "{{.item.subject}}", "text": "**{{.item.priority}}** priority"}}}`},
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "tickets.support"}, "package": "support", "resourceType": "Ticket", "plural": "tickets", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"subject": {"type": "string"}, "priority": {"type": "string"}}}}]}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// create rule with templates of a channel it doesn't notify
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationrules", bytes.NewBufferString(`{"metadata": {"name": "stray"}, "trigger": {"packageName": "support", "resourceType": "Ticket"}, "channels": ["team-slack"], "title": "Ticket", "channelTemplates": {"team-discord": {"title": "Ticket"}}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// notify discord channel
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/notificationchannels/team-discord/notify", bytes.NewBufferString(`{"title": "Test"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		assert.Equal(t, map[string]any{"path": "/discord", "embeds": []any{map[string]any{"title": "Test"}}}, <-posts)
+	}
+
+	// route notifications of writes with channel templates
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/support/v1/tickets", bytes.NewBufferString(`{"metadata": {"name": "t1"}, "subject": "Login fails", "priority": "high"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		received := make(map[string]map[string]any)
+
+		for range 2 {
+			select {
+			case post := <-posts:
+				received[post["path"].(string)] = post
+			case <-time.After(time.Second):
+				t.Fatal("notification not delivered")
+			}
+		}
+
+		assert.Equal(t, "*Ticket t1 created*\nPriority: high", received["/slack"]["text"])
+		assert.Equal(t, []any{map[string]any{"title": "Login fails", "description": "**high** priority"}}, received["/discord"]["embeds"])
+	}
+}
+
+func TestAutomations(t *testing.T) {
+	t.Parallel()
+
+	posts := make(chan map[string]any, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+
+		err := json.UnmarshalRead(r.Body, &body)
+		assert.NoError(t, err)
+
+		posts <- body
+	}))
+	t.Cleanup(server.Close)
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithFeatureGates(map[bass.Feature]bool{bass.FeatureAutomations: true}))
+
+	// create resource types and automation
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "orders.shop"}, "package": "shop", "resourceType": "Order", "plural": "orders", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"total": {"type": "number"}, "status": {"type": "string"}}}}]}`},
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "invoices.shop"}, "package": "shop", "resourceType": "Invoice", "plural": "invoices", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"order": {"type": "string"}, "amount": {"type": "number"}}, "required": ["order", "amount"]}}]}`},
+			{"/api/core/v1/automations", `{"metadata": {"name": "invoice-large-orders"}, "trigger": {"packageName": "shop", "resourceType": "Order", "operations": ["create"]}, "filter": "{{gt .item.total 100.0}}", "actions": [
+{"type": "createResource", "packageName": "shop", "apiVersion": "v1", "resourceTypePlural": "invoices", "name": "inv-{{.item.metadata.name}}", "properties": {"order": "{{.item.metadata.name}}", "amount": "{{.item.total}}"}},
+{"type": "patchResource", "packageName": "shop", "apiVersion": "v1", "resourceTypePlural": "orders", "name": "{{.item.metadata.name}}", "properties": {"status": "invoiced"}},
+{"type": "webhook", "url": "` + server.URL + `"}
+]}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// create invalid automations
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "incomplete"}, "trigger": {"packageName": "shop", "resourceType": "Order"}, "actions": [{"type": "patchResource", "packageName": "shop", "apiVersion": "v1", "resourceTypePlural": "orders"}]}`,
+			`{"metadata": {"name": "broken"}, "trigger": {"packageName": "shop", "resourceType": "Order"}, "filter": "{{.item", "actions": [{"type": "webhook", "url": "http://example.com"}]}`,
+			`{"metadata": {"name": "unknown"}, "trigger": {"packageName": "shop", "resourceType": "Order"}, "actions": [{"type": "email"}]}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/automations", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+	}
+
+	// run automation of matching write
+	{
+		for _, body := range []string{`{"metadata": {"name": "o1"}, "total": 50}`, `{"metadata": {"name": "o2"}, "total": 150}`} {
+			req := httptest.NewRequest(http.MethodPost, "/api/shop/v1/orders", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		select {
+		case post := <-posts:
+			assert.Equal(t, "create", post["operation"])
+			assert.Equal(t, "o2", post["item"].(map[string]any)["metadata"].(map[string]any)["name"])
+		case <-time.After(time.Second):
+			t.Fatal("webhook not called")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/shop/v1/invoices/inv-o2", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var invoice bass.Resource
+
+		err := json.Unmarshal(rec.Body.Bytes(), &invoice)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"order": "o2", "amount": 150.0}, invoice.Properties)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/shop/v1/orders/o2", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"invoiced"`)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/shop/v1/invoices/inv-o1", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestViews(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	getView := func(t *testing.T, path string) bass.ViewResult {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res bass.ViewResult
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	// create resource type, items, and views
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "orders.shop"}, "package": "shop", "resourceType": "Order", "plural": "orders", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"region": {"type": "string"}, "total": {"type": "number"}}}}]}`},
+			{"/api/shop/v1/orders", `{"metadata": {"name": "o1"}, "region": "eu", "total": 10}`},
+			{"/api/shop/v1/orders", `{"metadata": {"name": "o2"}, "region": "eu", "total": 30}`},
+			{"/api/core/v1/views", `{"metadata": {"name": "order-list"}, "package": "shop", "resourceTypes": ["Order"], "fields": {"total": "/properties/total"}}`},
+			{"/api/core/v1/views", `{"metadata": {"name": "order-stats"}, "package": "shop", "resourceTypes": ["Order"], "groupBy": "/properties/region", "aggregates": {
+"orders": {"function": "count"}, "revenue": {"function": "sum", "field": "/properties/total"}, "average": {"function": "avg", "field": "/properties/total"},
+"smallest": {"function": "min", "field": "/properties/total"}, "largest": {"function": "max", "field": "/properties/total"}}}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// create invalid views
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "mixed"}, "package": "shop", "resourceTypes": ["Order"], "fields": {"total": "/properties/total"}, "aggregates": {"orders": {"function": "count"}}}`,
+			`{"metadata": {"name": "ungrouped"}, "package": "shop", "resourceTypes": ["Order"], "groupBy": "/properties/region"}`,
+			`{"metadata": {"name": "fieldless"}, "package": "shop", "resourceTypes": ["Order"], "aggregates": {"revenue": {"function": "sum"}}}`,
+			`{"metadata": {"name": "median"}, "package": "shop", "resourceTypes": ["Order"], "aggregates": {"median": {"function": "median", "field": "/properties/total"}}}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/views", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+	}
+
+	// get views
+	{
+		assert.Equal(t, []map[string]any{
+			{"resourceType": "Order", "name": "o1", "total": 10.0},
+			{"resourceType": "Order", "name": "o2", "total": 30.0},
+		}, getView(t, "/api/shop/v1/views/order-list").Rows)
+
+		assert.Equal(t, []map[string]any{
+			{"group": "eu", "orders": 2.0, "revenue": 40.0, "average": 20.0, "smallest": 10.0, "largest": 30.0},
+		}, getView(t, "/api/shop/v1/views/order-stats").Rows)
+	}
+
+	// views follow writes
+	{
+		for _, tc := range []struct{ method, path, body string }{
+			{http.MethodPost, "/api/shop/v1/orders", `{"metadata": {"name": "o3"}, "region": "us", "total": 5}`},
+			{http.MethodPut, "/api/shop/v1/orders/o2", `{"metadata": {"name": "o2"}, "region": "us", "total": 20}`},
+			{http.MethodDelete, "/api/shop/v1/orders/o1", ""},
+		} {
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Less(t, rec.Code, http.StatusMultipleChoices, rec.Body.String())
+		}
+
+		assert.Equal(t, []map[string]any{
+			{"resourceType": "Order", "name": "o2", "total": 20.0},
+			{"resourceType": "Order", "name": "o3", "total": 5.0},
+		}, getView(t, "/api/shop/v1/views/order-list").Rows)
+
+		assert.Equal(t, []map[string]any{
+			{"group": "us", "orders": 2.0, "revenue": 25.0, "average": 12.5, "smallest": 5.0, "largest": 20.0},
+		}, getView(t, "/api/shop/v1/views/order-stats").Rows)
+	}
+
+	// get missing view and view of another package
+	{
+		for _, path := range []string{"/api/shop/v1/views/missing", "/api/other/v1/views/order-list"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, path)
+		}
+	}
+}
+
+func TestDebug(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithDebug(true), bass.WithBasePath("/bass"))
+
+	// get state
+	{
+		req := httptest.NewRequest(http.MethodGet, "/bass/debug/state", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res bass.DebugState
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+
+		assert.Positive(t, res.Goroutines)
+		assert.Empty(t, res.Views)
+		assert.Zero(t, res.WatchSubscribers)
+		assert.Equal(t, bass.DebugQueue{Length: 0, Capacity: 1024}, res.Queues["replication"])
+	}
+
+	// get profiles
+	{
+		for _, path := range []string{"/bass/debug/pprof/", "/bass/debug/pprof/goroutine?debug=1"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, path)
+			assert.Contains(t, rec.Body.String(), "goroutine", path)
+		}
+	}
+
+	// debug endpoints are disabled by default
+	{
+		req := httptest.NewRequest(http.MethodGet, "/debug/state", nil)
+		rec := httptest.NewRecorder()
+
+		bass.NewHandler(bass.NewMemRepo()).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithFaultInjection(
+		bass.FaultRule{Method: http.MethodGet, PathPrefix: "/api/core/v1/settings", ErrorRate: 1, ErrorStatus: http.StatusInternalServerError},
+		bass.FaultRule{Method: http.MethodGet, PathPrefix: "/api/shop/v1/orders/watch", DropWatchAfter: 50 * time.Millisecond},
+		bass.FaultRule{Method: http.MethodGet, PathPrefix: "/api/shop", Latency: 50 * time.Millisecond},
+	))
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "orders.shop"}, "package": "shop", "resourceType": "Order", "plural": "orders", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// inject error
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/settings", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), "fault injected")
+	}
+
+	// inject latency
+	{
+		start := time.Now()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/shop/v1/orders", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	}
+
+	// drop watch
+	{
+		server := httptest.NewServer(h)
+		t.Cleanup(server.Close)
+
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/api/shop/v1/orders/watch", nil)
+		require.NoError(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = res.Body.Close() })
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		done := make(chan struct{})
+
+		go func() {
+			_, _ = io.Copy(io.Discard, res.Body)
+
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("watch not dropped")
+		}
+	}
+
+	// unmatched requests are served as usual
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/resourcetypedefinitions", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestPathSegmentValidation(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	// invalid segments are rejected naming the segment
+	for path, segment := range map[string]string{
+		"/api/test_pkg/v1/foos":      "packageName",
+		"/api/-test/v1/foos":         "packageName",
+		"/api/test/version1/foos":    "apiVersion",
+		"/api/test/v1beta/foos/foo1": "apiVersion",
+		"/api/test/v1/foo%24s":       "resourceTypePlural",
+		"/api/test/v1/1foos/watch":   "resourceTypePlural",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code, path)
+
+		var res struct {
+			Code    apierrors.Reason `json:"code"`
+			Segment string           `json:"segment"`
+		}
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, apierrors.ReasonBadRequest, res.Code, path)
+		assert.Equal(t, segment, res.Segment, path)
+	}
+
+	// valid segments of unknown resources are not found
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/my-org.test/v2beta1/foos", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// resource type definitions must follow the naming rules
+	for _, body := range []string{
+		`{"metadata": {"name": "foos.Test_Pkg"}, "package": "Test_Pkg", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+		`{"metadata": {"name": "foo_s.test"}, "package": "test", "resourceType": "Foo", "plural": "foo_s", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`,
+		`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "1.0", "schema": {"type": "object"}}]}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestCanonicalRedirects(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithBasePath("/bass"))
+
+	// register resource type
+	{
+		req := httptest.NewRequest(http.MethodPost, "/bass/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// case variations and trailing slashes redirect to the canonical path, keeping the name and query
+	for path, location := range map[string]string{
+		"/bass/api/test/v1/Foos":                    "/bass/api/test/v1/foos",
+		"/bass/api/test/v1/foos/":                   "/bass/api/test/v1/foos",
+		"/bass/api/Test/V1/FOOS/Foo1/":              "/bass/api/test/v1/foos/Foo1",
+		"/bass/api/test/v1/Foos?limit=1":            "/bass/api/test/v1/foos?limit=1",
+		"/bass/api/core/v1/ResourceTypeDefinitions": "/bass/api/core/v1/resourcetypedefinitions",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code, path)
+		assert.Equal(t, location, rec.Header().Get("Location"), path)
+	}
+
+	// writes are redirected with their method kept
+	{
+		req := httptest.NewRequest(http.MethodPost, "/bass/api/test/v1/Foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, "/bass/api/test/v1/foos", rec.Header().Get("Location"))
+	}
+
+	// canonical paths are served
+	{
+		req := httptest.NewRequest(http.MethodGet, "/bass/api/test/v1/foos", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// resource type names must be lowercase
+	{
+		req := httptest.NewRequest(http.MethodPost, "/bass/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "Bars.test"}, "package": "test", "resourceType": "Bar", "plural": "Bars", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestRenameTargetPackage(t *testing.T) {
+	t.Parallel()
+
+	noteType := func(packageName, policy string) string {
+		return `{"metadata": {"name": "notes.` + packageName + `"}, "package": "` + packageName + `", "resourceType": "Note", "plural": "notes", ` + policy + `"versions": [{"name": "v1", "schema": {"type": "object", "properties": {"text": {"type": "string"}}}}]}`
+	}
+
+	newHandler := func(t *testing.T, opts ...bass.Option) func(method, path, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		h := bass.NewHandler(bass.NewMemRepo(), opts...)
+
+		do := func(method, path, key, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+			if key != "" {
+				req.Header.Set(bass.APIKeyHeader, key)
+			}
+
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			return rec
+		}
+
+		return do
+	}
+
+	// API keys must allow creating the renamed item in its package
+	{
+		do := newHandler(t, bass.WithAPIKeys(
+			bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+			bass.APIKey{Name: "a", Key: "sk_a", Type: bass.APIKeySecret, PackageName: "a"},
+			bass.APIKey{Name: "b", Key: "sk_b", Type: bass.APIKeySecret, PackageName: "b"},
+		))
+
+		for _, packageName := range []string{"a", "b"} {
+			rec := do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "sk_core", noteType(packageName, ""))
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		rec := do(http.MethodPost, "/api/a/v1/notes", "sk_a", `{"metadata": {"name": "n1"}, "text": "hi"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/b/v1/notes", "sk_a", `{"metadata": {"name": "n2"}, "text": "hi"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/a/v1/notes/n1:rename", "sk_a", `{"newPackageName": "b"}`).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/a/v1/notes/n1", "sk_a", "").Code)
+		assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/b/v1/notes/n1", "sk_b", "").Code)
+
+		assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/a/v1/notes/n1:rename", "sk_a", `{"newName": "n2"}`).Code)
+	}
+
+	// network, approval and moderation policies of the package apply
+	{
+		do := newHandler(t, bass.WithPackageNetworkPolicy("internal", bass.NetworkPolicy{
+			Allow:          []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")},
+			Deny:           nil,
+			AllowCountries: nil,
+			DenyCountries:  nil,
+		}))
+
+		for _, body := range []string{
+			noteType("a", ""),
+			noteType("internal", ""),
+			noteType("reviewed", `"approval": {"approvals": 1}, `),
+			noteType("moderated", `"moderation": {"properties": ["text"], "bannedWords": ["spam"], "action": "reject"}, `),
+		} {
+			rec := do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "", body)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		rec := do(http.MethodPost, "/api/a/v1/notes", "", `{"metadata": {"name": "n1"}, "text": "buy spam"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		for _, tc := range []struct {
+			packageName string
+			code        int
+			reason      apierrors.Reason
+		}{
+			{"internal", http.StatusForbidden, apierrors.ReasonAccessDenied},
+			{"reviewed", http.StatusForbidden, apierrors.ReasonApprovalRequired},
+			{"moderated", http.StatusUnprocessableEntity, apierrors.ReasonContentRejected},
+		} {
+			rec := do(http.MethodPost, "/api/a/v1/notes/n1:rename", "", `{"newPackageName": "`+tc.packageName+`"}`)
+			assert.Equal(t, tc.code, rec.Code, tc.packageName)
+
+			var res struct {
+				Code apierrors.Reason `json:"code"`
+			}
+
+			err := json.Unmarshal(rec.Body.Bytes(), &res)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.reason, res.Code, tc.packageName)
+		}
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/a/v1/notes/n1", "", "").Code)
+	}
+}
+
+func TestRenameAliases(t *testing.T) {
+	t.Parallel()
+
+	var now atomic.Pointer[time.Time]
+
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	now.Store(&start)
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithClock(func() time.Time { return *now.Load() }),
+		bass.WithRenameAliases(time.Hour),
+	)
+
+	// register resource type and create item
+	{
+		for _, step := range []struct{ path, body string }{
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "users.test"}, "package": "test", "resourceType": "User", "plural": "users", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"visits": {"type": "integer"}}}}]}`},
+			{"/api/test/v1/users", `{"metadata": {"name": "alice"}, "visits": 1}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, step.path, bytes.NewBufferString(step.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+
+	// rename item
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users/alice:rename", bytes.NewBufferString(`{"newName": "alicia"}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// reads of the old name are moved permanently, keeping the query
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/users/alice?locale=en", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, "/api/test/v1/users/alicia?locale=en", rec.Header().Get("Location"))
+	}
+
+	// writes and actions of the old name are redirected permanently, keeping the method
+	{
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodPut, "/api/test/v1/users/alice", bytes.NewBufferString(`{"metadata": {"name": "alice"}, "visits": 2}`)),
+			httptest.NewRequest(http.MethodDelete, "/api/test/v1/users/alice", nil),
+		} {
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusPermanentRedirect, rec.Code, req.Method)
+			assert.Equal(t, "/api/test/v1/users/alicia", rec.Header().Get("Location"), req.Method)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users/alice:increment", bytes.NewBufferString(`{"path": "/properties/visits", "delta": 1}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, "/api/test/v1/users/alicia:increment", rec.Header().Get("Location"))
+	}
+
+	// aliases are listed as core resources
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/core/v1/resourcealiases", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res struct {
+			Items []bass.ResourceAlias `json:"items"`
+		}
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, "alice", res.Items[0].From.Name)
+		assert.Equal(t, "alicia", res.Items[0].To.Name)
+		assert.True(t, start.Add(time.Hour).Equal(res.Items[0].ExpiresAt))
+	}
+
+	// an item taking the old name is served instead
+	{
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/users", bytes.NewBufferString(`{"metadata": {"name": "alice"}, "visits": 5}`))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/test/v1/users/alice", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodDelete, "/api/test/v1/users/alice", nil)
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusNoContent, rec.Code)
+	}
+
+	// expired aliases are not found
+	{
+		later := start.Add(time.Hour)
+		now.Store(&later)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/test/v1/users/alice", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithAPIKeys(
+			bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+			bass.APIKey{Name: "server", Key: "sk_shop", Type: bass.APIKeySecret, PackageName: "shop"},
+			bass.APIKey{Name: "web", Key: "pk_shop", Type: bass.APIKeyPublishable, PackageName: "shop", RateLimit: 5},
+			bass.APIKey{Name: "orders", Key: "pk_orders", Type: bass.APIKeyPublishable, PackageName: "shop", Permissions: []bass.APIKeyPermission{
+				{ResourceTypes: []string{"orders"}, Verbs: []string{bass.APIKeyVerbCreate}},
+			}},
+			bass.APIKey{Name: "compliance", Key: "sk_compliance", Type: bass.APIKeySecret, PackageName: "shop", Permissions: []bass.APIKeyPermission{
+				{ResourceTypes: []string{"products"}, Verbs: []string{bass.APIKeyVerbLegalHold}},
+			}},
+			bass.APIKey{Name: "auditor", Key: "sk_auditor", Type: bass.APIKeySecret, PackageName: "core", Permissions: []bass.APIKeyPermission{
+				{ResourceTypes: nil, Verbs: []string{bass.APIKeyVerbLegalHold}},
+			}},
+		),
+	)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(bass.APIKeyHeader, key)
+		}
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource types with a key of the core package
+	{
+		for _, plural := range []string{"products", "orders"} {
+			rec := do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "`+plural+`.shop"}, "package": "shop", "resourceType": "T`+plural+`", "plural": "`+plural+`", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// requests without a known key are unauthorized
+	{
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/shop/v1/products", "", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/shop/v1/products", "pk_unknown", "").Code)
+	}
+
+	// secret keys do anything in their package only
+	{
+		rec := do(http.MethodPost, "/api/shop/v1/products", "sk_shop", `{"metadata": {"name": "mug"}, "note": "x"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "sk_shop", `{}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/transactions", "sk_shop", `{"operations": []}`).Code)
+	}
+
+	// publishable keys read their package by default
+	{
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/shop/v1/products", "pk_shop", "").Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/shop/v1/products/mug", "pk_shop", "").Code)
+
+		rec := do(http.MethodDelete, "/api/shop/v1/products/mug", "pk_shop", "")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		var res struct {
+			Code apierrors.Reason `json:"code"`
+		}
+
+		err := json.UnmarshalRead(rec.Body, &res)
+		require.NoError(t, err)
+
+		assert.Equal(t, apierrors.ReasonAccessDenied, res.Code)
+	}
+
+	// permissions restrict keys to verbs of resource types, named by any of their names
+	{
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/shop/v1/orders", "pk_orders", `{"metadata": {"name": "order1"}, "note": "x"}`).Code)
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/shop/v1/order", "pk_orders", `{"metadata": {"name": "order2"}, "note": "x"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/shop/v1/orders/order1", "pk_orders", "").Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/shop/v1/products", "pk_orders", `{"metadata": {"name": "hat"}, "note": "x"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/shop/v1/unknowns", "pk_orders", `{"metadata": {"name": "hat"}, "note": "x"}`).Code)
+	}
+
+	// publishable keys are rate limited
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/shop/v1/products?apiKey=pk_shop", nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		for range 2 {
+			do(http.MethodGet, "/api/shop/v1/products", "pk_shop", "")
+		}
+
+		rec = do(http.MethodGet, "/api/shop/v1/products", "pk_shop", "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/shop/v1/products", "sk_shop", "").Code)
+	}
+
+	// admin endpoints need a secret key of the core package
+	{
+		for _, tc := range []struct{ method, path, body string }{
+			{http.MethodGet, "/admin/features", ""},
+			{http.MethodPost, "/admin/erase", `{"subject": "a@example.com"}`},
+			{http.MethodPost, "/admin/restore?package=shop&at=2020-01-01T00:00:00Z", ""},
+			{http.MethodPut, "/admin/maintenance", `{"enabled": true}`},
+			{http.MethodPut, "/admin/legalholds/shop/products/mug", `{"reason": "audit"}`},
+			{http.MethodDelete, "/admin/legalholds/shop/products/mug", ""},
+		} {
+			assert.Equal(t, http.StatusUnauthorized, do(tc.method, tc.path, "", tc.body).Code, tc.path)
+			assert.Equal(t, http.StatusForbidden, do(tc.method, tc.path, "pk_orders", tc.body).Code, tc.path)
+			assert.Equal(t, http.StatusForbidden, do(tc.method, tc.path, "sk_shop", tc.body).Code, tc.path)
+		}
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/features", "sk_core", "").Code)
+	}
+
+	// legal holds need keys granted the legal hold permission
+	{
+		for _, key := range []string{"sk_core", "sk_shop", "pk_orders"} {
+			assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/admin/legalholds/shop/products/mug", key, `{"reason": "audit"}`).Code, key)
+		}
+
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/admin/legalholds/shop/orders/order1", "sk_compliance", `{"reason": "audit"}`).Code)
+
+		rec := do(http.MethodPut, "/admin/legalholds/shop/products/mug", "sk_compliance", `{"reason": "audit"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/admin/legalholds/shop/products/mug", "sk_core", "").Code)
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/legalholds/shop/products/mug", "sk_auditor", "").Code)
+	}
+}
+
+func TestSyncAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithRevisionHistory(true),
+		bass.WithAPIKeys(
+			bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+			bass.APIKey{Name: "server", Key: "sk_shop", Type: bass.APIKeySecret, PackageName: "shop"},
+			bass.APIKey{Name: "web", Key: "pk_shop", Type: bass.APIKeyPublishable, PackageName: "shop"},
+			bass.APIKey{Name: "orders", Key: "pk_orders", Type: bass.APIKeyPublishable, PackageName: "shop", Permissions: []bass.APIKeyPermission{
+				{ResourceTypes: []string{"orders"}, Verbs: []string{bass.APIKeyVerbCreate, bass.APIKeyVerbList}},
+			}},
+		),
+	)
+
+	do := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(bass.APIKeyHeader, key)
+		}
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	sync := func(t *testing.T, key, body string) bass.SyncResponse {
+		t.Helper()
+
+		rec := do("/sync", key, body)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res bass.SyncResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	// register resource types and items
+	{
+		for _, plural := range []string{"products", "orders"} {
+			rec := do("/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "`+plural+`.shop"}, "package": "shop", "resourceType": "T`+plural+`", "plural": "`+plural+`", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		rec := do("/api/shop/v1/products", "sk_shop", `{"metadata": {"name": "mug"}, "note": "x"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// syncs need a key of their package
+	{
+		assert.Equal(t, http.StatusUnauthorized, do("/sync", "", `{"packageName": "shop"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do("/sync", "pk_shop", `{"packageName": "other"}`).Code)
+	}
+
+	// changes need the permissions of their writes
+	{
+		res := sync(t, "pk_shop", `{"packageName": "shop", "changes": [{"op": "create", "apiVersion": "v1", "resourceTypePlural": "products", "name": "hat", "properties": {}}]}`)
+
+		require.Len(t, res.Results, 1)
+		assert.Equal(t, http.StatusForbidden, res.Results[0].Status)
+
+		res = sync(t, "pk_orders", `{"packageName": "shop", "changes": [
+{"op": "create", "apiVersion": "v1", "resourceTypePlural": "orders", "name": "order1", "properties": {}},
+{"op": "create", "apiVersion": "v1", "resourceTypePlural": "products", "name": "hat", "properties": {}}]}`)
+
+		require.Len(t, res.Results, 2)
+		assert.Equal(t, http.StatusCreated, res.Results[0].Status)
+		assert.Equal(t, http.StatusForbidden, res.Results[1].Status)
+	}
+
+	// changes of the server are limited to the resource types the key lists
+	{
+		res := sync(t, "pk_orders", `{"packageName": "shop"}`)
+
+		names := make([]string, 0, len(res.Changes))
+		for _, change := range res.Changes {
+			names = append(names, change.Item.Metadata.Name)
+		}
+
+		assert.Equal(t, []string{"order1"}, names)
+
+		res = sync(t, "pk_shop", `{"packageName": "shop"}`)
+		assert.Len(t, res.Changes, 2)
+	}
+}
+
+type moderationClassifierFunc func(ctx context.Context, text string) ([]string, error)
+
+func (f moderationClassifierFunc) Classify(ctx context.Context, text string) ([]string, error) {
+	return f(ctx, text)
+}
+
+func TestModeration(t *testing.T) {
+	t.Parallel()
+
+	classifier := moderationClassifierFunc(func(_ context.Context, text string) ([]string, error) {
+		if strings.Contains(text, "idiot") {
+			return []string{"insult"}, nil
+		}
+
+		return nil, nil
+	})
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithModerationClassifier("toxicity", classifier))
+
+	do := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register moderated resource types
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "comments.test"}, "package": "test", "resourceType": "Comment", "plural": "comments", "moderation": {"properties": ["text", "tags"], "bannedWords": ["spam"], "patterns": ["https?://"], "action": "reject"}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"text": {"type": "string"}, "tags": {"type": "array", "items": {"type": "string"}}}}}]}`,
+			`{"metadata": {"name": "posts.test"}, "package": "test", "resourceType": "Post", "plural": "posts", "moderation": {"properties": ["text"], "classifiers": ["toxicity"], "action": "quarantine"}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"text": {"type": "string"}}}}]}`,
+			`{"metadata": {"name": "reviews.test"}, "package": "test", "resourceType": "Review", "plural": "reviews", "moderation": {"properties": ["text"], "bannedWords": ["awful"], "action": "flag"}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"text": {"type": "string"}}}}]}`,
+			`{"metadata": {"name": "notes.test"}, "package": "test", "resourceType": "Note", "plural": "notes", "moderation": {"properties": ["text"], "classifiers": ["missing"], "action": "reject"}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"text": {"type": "string"}}}}]}`,
+		} {
+			rec := do(t, http.MethodPost, "/api/core/v1/resourcetypedefinitions", body)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// invalid moderation policy is rejected
+	{
+		rec := do(t, http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "bads.test"}, "package": "test", "resourceType": "Bad", "plural": "bads", "moderation": {"properties": ["text"], "patterns": ["("], "action": "delete"}, "versions": [{"name": "v1", "schema": {"type": "object"}}]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// objectionable content is rejected
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "first"}, "text": "Buy SPAM now"}`,
+			`{"metadata": {"name": "first"}, "text": "see http://example.com"}`,
+			`{"metadata": {"name": "first"}, "text": "hello", "tags": ["spam"]}`,
+		} {
+			rec := do(t, http.MethodPost, "/api/test/v1/comments", body)
+			require.Equal(t, http.StatusUnprocessableEntity, rec.Code, body)
+
+			var res map[string]any
+
+			err := json.UnmarshalRead(rec.Body, &res)
+			require.NoError(t, err)
+
+			assert.Equal(t, string(apierrors.ReasonContentRejected), res["code"])
+			assert.NotEmpty(t, res["findings"])
+		}
+
+		rec := do(t, http.MethodPost, "/api/test/v1/comments", `{"metadata": {"name": "first"}, "text": "spammer is not a banned word"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = do(t, http.MethodPatch, "/api/test/v1/comments/first", `{"text": "spam"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		rec = do(t, http.MethodPost, "/api/transactions", `{"operations": [{"op": "create", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "comments", "name": "second", "properties": {"text": "spam"}}]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	}
+
+	// objectionable content is quarantined until approved
+	{
+		rec := do(t, http.MethodPost, "/api/test/v1/posts", `{"metadata": {"name": "rant"}, "text": "you idiot"}`)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		var changeRequest bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &changeRequest)
+		require.NoError(t, err)
+
+		assert.Equal(t, "true", changeRequest.Metadata.Labels["bass.moderation/quarantined"])
+		assert.InDelta(t, 1, changeRequest.Properties["requiredApprovals"], 0)
+
+		rec = do(t, http.MethodGet, "/api/test/v1/posts/rant", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(t, http.MethodPost, "/api/core/v1/changerequests/"+changeRequest.Metadata.Name+"/approve", `{"approver": "moderator"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = do(t, http.MethodGet, "/api/test/v1/posts/rant", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = do(t, http.MethodPost, "/api/test/v1/posts", `{"metadata": {"name": "kind"}, "text": "nice work"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// objectionable content is flagged
+	{
+		rec := do(t, http.MethodPost, "/api/test/v1/reviews", `{"metadata": {"name": "first"}, "text": "Awful service"}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var item bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		assert.Equal(t, "true", item.Metadata.Labels["bass.moderation/flagged"])
+
+		rec = do(t, http.MethodPatch, "/api/test/v1/reviews/first", `{"text": "Great service"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		item = bass.Resource{}
+
+		err = json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		assert.NotContains(t, item.Metadata.Labels, "bass.moderation/flagged")
+	}
+
+	// missing classifier is not implemented
+	{
+		rec := do(t, http.MethodPost, "/api/test/v1/notes", `{"metadata": {"name": "first"}, "text": "hello"}`)
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	}
+}
+
+func TestErasure(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	do := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource types linked to data subjects
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "orders.test"}, "package": "test", "resourceType": "Order", "plural": "orders", "erasure": "anonymize", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string", "x-data-subject": true}, "total": {"type": "number"}}}}]}`,
+			`{"metadata": {"name": "profiles.test"}, "package": "test", "resourceType": "Profile", "plural": "profiles", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"userId": {"type": "integer", "x-data-subject": true}, "bio": {"type": "string"}}}}]}`,
+			`{"metadata": {"name": "profiles.other"}, "package": "other", "resourceType": "Profile", "plural": "profiles", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"owner": {"type": "string", "x-data-subject": true}}}}]}`,
+		} {
+			rec := do(t, http.MethodPost, "/api/core/v1/resourcetypedefinitions", body)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		for target, body := range map[string]string{
+			"/api/test/v1/orders":    `{"metadata": {"name": "first"}, "email": "alice@example.com", "total": 10}`,
+			"/api/test/v1/profiles":  `{"metadata": {"name": "alice"}, "userId": 42, "bio": "hi"}`,
+			"/api/other/v1/profiles": `{"metadata": {"name": "alice"}, "owner": "alice@example.com"}`,
+		} {
+			rec := do(t, http.MethodPost, target, body)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		rec := do(t, http.MethodPost, "/api/test/v1/orders", `{"metadata": {"name": "second"}, "email": "bob@example.com", "total": 20}`)
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// subject is required
+	{
+		rec := do(t, http.MethodPost, "/admin/erase", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// erase deletes and anonymizes items of the subject
+	{
+		rec := do(t, http.MethodPost, "/admin/erase?subject=alice@example.com", "")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var report bass.ErasureReport
+
+		err := json.UnmarshalRead(rec.Body, &report)
+		require.NoError(t, err)
+
+		assert.Equal(t, "/api/core/v1/erasurereports/"+report.Metadata.Name, rec.Header().Get("Location"))
+		assert.Len(t, report.SubjectHash, 64)
+		assert.Equal(t, []bass.ResourceReference{{PackageName: "other", ResourceType: "Profile", Name: "alice"}}, report.Deleted)
+		assert.Equal(t, []bass.ResourceReference{{PackageName: "test", ResourceType: "Order", Name: "first"}}, report.Anonymized)
+
+		rec = do(t, http.MethodGet, "/api/other/v1/profiles/alice", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(t, http.MethodGet, "/api/test/v1/orders/first", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var item bass.Resource
+
+		err = json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		assert.NotContains(t, item.Properties, "email")
+		assert.InDelta(t, 10, item.Properties["total"], 0)
+
+		rec = do(t, http.MethodGet, "/api/test/v1/orders/second", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = do(t, http.MethodGet, "/api/core/v1/erasurereports/"+report.Metadata.Name, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// numeric subjects match
+	{
+		rec := do(t, http.MethodPost, "/admin/erase?subject=42", "")
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = do(t, http.MethodGet, "/api/test/v1/profiles/alice", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestErasureRedactsHistory(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	eventLog := bass.NewMemEventLog()
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithClock(func() time.Time { return now }),
+		bass.WithRevisionHistory(true),
+		bass.WithEventLogging(bass.EventLogging{Log: eventLog, Retention: 0}),
+	)
+
+	do := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource types and write items of the subject
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "orders.test"}, "package": "test", "resourceType": "Order", "plural": "orders", "erasure": "anonymize", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string", "x-data-subject": true}, "total": {"type": "number"}}}}]}`,
+			`{"metadata": {"name": "profiles.test"}, "package": "test", "resourceType": "Profile", "plural": "profiles", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"owner": {"type": "string", "x-data-subject": true}}}}]}`,
+		} {
+			rec := do(t, http.MethodPost, "/api/core/v1/resourcetypedefinitions", body)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		rec := do(t, http.MethodPost, "/api/test/v1/orders", `{"metadata": {"name": "first"}, "email": "alice@example.com", "total": 10}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = do(t, http.MethodPut, "/api/test/v1/orders/first", `{"metadata": {"name": "first"}, "email": "alice@example.com", "total": 20}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = do(t, http.MethodPost, "/api/test/v1/profiles", `{"metadata": {"name": "alice"}, "owner": "alice@example.com"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		now = now.Add(time.Hour)
+
+		rec = do(t, http.MethodPost, "/admin/erase?subject=alice@example.com", "")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// revision diffs don't show erased data
+	{
+		rec := do(t, http.MethodGet, "/api/test/v1/orders/first", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var item bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		rec = do(t, http.MethodGet, "/api/test/v1/orders/first/revisions/"+item.Metadata.ResourceVersion+":diff", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotContains(t, rec.Body.String(), "alice@example.com")
+	}
+
+	// restores don't bring erased data back
+	{
+		rec := do(t, http.MethodPost, "/admin/restore?package=test&at=2025-06-01T12:30:00Z", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		assert.Equal(t, http.StatusNotFound, do(t, http.MethodGet, "/api/test/v1/profiles/alice", "").Code)
+
+		rec = do(t, http.MethodGet, "/api/test/v1/orders/first", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "alice@example.com")
+		assert.Contains(t, rec.Body.String(), `"total":20`)
+	}
+
+	// logged events don't keep erased data
+	{
+		events, err := eventLog.Read(t.Context(), 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, events)
+
+		for _, event := range events {
+			assert.NotContains(t, event.Item.Properties, "email", event.Item.Metadata.Name)
+			assert.NotContains(t, event.Item.Properties, "owner", event.Item.Metadata.Name)
+		}
+	}
+}
+
+type failingDeleteRepo struct {
+	*bass.MemRepo
+}
+
+func (failingDeleteRepo) Delete(context.Context, string, string, string) error {
+	return errors.New("delete failed")
+}
+
+func TestErasureFailure(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(failingDeleteRepo{MemRepo: bass.NewMemRepo()})
+
+	do := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource type and create item
+	{
+		rec := do(t, http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "profiles.test"}, "package": "test", "resourceType": "Profile", "plural": "profiles", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"owner": {"type": "string", "x-data-subject": true}}}}]}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = do(t, http.MethodPost, "/api/test/v1/profiles", `{"metadata": {"name": "alice"}, "owner": "alice"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// failed erasures are reported too
+	{
+		rec := do(t, http.MethodPost, "/admin/erase?subject=alice", "")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		rec = do(t, http.MethodGet, "/api/core/v1/erasurereports", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list struct {
+			Items []bass.ErasureReport `json:"items"`
+		}
+
+		err := json.UnmarshalRead(rec.Body, &list)
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+
+		assert.Contains(t, list.Items[0].Error, "delete failed")
+		assert.Empty(t, list.Items[0].Deleted)
+	}
+}
+
+func TestPIIAnonymization(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithRevisionHistory(true),
+		bass.WithAPIKeys(
+			bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+			bass.APIKey{Name: "server", Key: "sk_shop", Type: bass.APIKeySecret, PackageName: "shop"},
+			bass.APIKey{Name: "web", Key: "pk_shop", Type: bass.APIKeyPublishable, PackageName: "shop"},
+			bass.APIKey{Name: "app", Key: "pk_app", Type: bass.APIKeyPublishable, PackageName: "shop", Permissions: []bass.APIKeyPermission{
+				{ResourceTypes: []string{"customers"}, Verbs: []string{bass.APIKeyVerbGet, bass.APIKeyVerbDelete}},
+			}},
+		),
+	)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(bass.APIKeyHeader, key)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource type with PII and create item
+	{
+		rec := do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "customers.shop"}, "package": "shop", "resourceType": "Customer", "plural": "customers", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string", "x-pii": "hash"}, "phone": {"type": "string", "x-pii": "mask"}, "address": {"type": "object", "properties": {"street": {"type": "string", "x-pii": "drop"}, "city": {"type": "string"}}}, "tier": {"type": "string"}}}}]}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = do(http.MethodPost, "/api/shop/v1/customers", "sk_shop", `{"metadata": {"name": "alice"}, "email": "alice@example.com", "phone": "+15551234567", "address": {"street": "1 Main St", "city": "Springfield"}, "tier": "gold"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	checkAnonymized := func(t *testing.T, item bass.Resource) {
+		t.Helper()
+
+		hash := sha256.Sum256([]byte(`"alice@example.com"`))
+
+		assert.Equal(t, hex.EncodeToString(hash[:]), item.Properties["email"])
+		assert.Equal(t, "********4567", item.Properties["phone"])
+		assert.Equal(t, map[string]any{"city": "Springfield"}, item.Properties["address"])
+		assert.Equal(t, "gold", item.Properties["tier"])
+	}
+
+	// secret keys read PII as it is
+	{
+		rec := do(http.MethodGet, "/api/shop/v1/customers/alice", "sk_shop", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var item bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		assert.Equal(t, "alice@example.com", item.Properties["email"])
+	}
+
+	// publishable keys read PII anonymized
+	{
+		rec := do(http.MethodGet, "/api/shop/v1/customers/alice", "pk_shop", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var item bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		checkAnonymized(t, item)
+
+		rec = do(http.MethodGet, "/api/shop/v1/customers", "pk_shop", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list struct {
+			Items []bass.Resource `json:"items"`
+		}
+
+		err = json.UnmarshalRead(rec.Body, &list)
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+
+		checkAnonymized(t, list.Items[0])
+	}
+
+	// publishable keys watch PII anonymized
+	{
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+
+		req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/api/shop/v1/customers/watch", nil)
+		req.Header.Set(bass.APIKeyHeader, "pk_shop")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		var event struct {
+			Item bass.Resource `json:"item"`
+		}
+
+		err := json.Unmarshal([]byte(strings.SplitN(rec.Body.String(), "\n", 2)[0]), &event)
+		require.NoError(t, err)
+
+		checkAnonymized(t, event.Item)
+	}
+
+	// publishable keys sync PII anonymized
+	{
+		rec := do(http.MethodPost, "/sync", "pk_shop", `{"packageName": "shop"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res bass.SyncResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+		require.Len(t, res.Changes, 1)
+
+		checkAnonymized(t, *res.Changes[0].Item)
+	}
+
+	// publishable keys read view projections of PII anonymized
+	{
+		rec := do(http.MethodPost, "/api/core/v1/views", "sk_core", `{"metadata": {"name": "contacts"}, "package": "shop", "resourceTypes": ["Customer"], "fields": {"email": "/properties/email", "tier": "/properties/tier"}}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		view := func(key string) map[string]any {
+			rec := do(http.MethodGet, "/api/shop/v1/views/contacts", key, "")
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			var res bass.ViewResult
+
+			err := json.Unmarshal(rec.Body.Bytes(), &res)
+			require.NoError(t, err)
+			require.Len(t, res.Rows, 1)
+
+			return res.Rows[0]
+		}
+
+		assert.Equal(t, "alice@example.com", view("sk_shop")["email"])
+
+		hash := sha256.Sum256([]byte(`"alice@example.com"`))
+
+		row := view("pk_shop")
+		assert.Equal(t, hex.EncodeToString(hash[:]), row["email"])
+		assert.Equal(t, "gold", row["tier"])
+	}
+
+	// publishable keys get deleted items PII anonymized
+	{
+		rec := do(http.MethodDelete, "/api/shop/v1/customers/alice?returnDeleted=true", "pk_app", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var item bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		checkAnonymized(t, item)
+	}
+
+	// publishable keys read PII in array items, additional properties and schema definitions anonymized
+	{
+		rec := do(http.MethodPost, "/api/core/v1/schemadefinitions", "sk_core", `{"metadata": {"name": "contact"}, "schema": {"type": "object", "properties": {"email": {"type": "string", "x-pii": "drop"}, "role": {"type": "string"}}}}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "companies.shop"}, "package": "shop", "resourceType": "Company", "plural": "companies", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"contacts": {"type": "array", "items": {"type": "object", "properties": {"email": {"type": "string", "x-pii": "drop"}, "role": {"type": "string"}}}}, "phones": {"type": "object", "additionalProperties": {"type": "string", "x-pii": "mask"}}, "owner": {"$ref": "schemadefinitions/contact"}}}}]}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = do(http.MethodPost, "/api/shop/v1/companies", "sk_shop", `{"metadata": {"name": "acme"}, "contacts": [{"email": "bob@example.com", "role": "sales"}], "phones": {"office": "+15551234567"}, "owner": {"email": "carol@example.com", "role": "ceo"}}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = do(http.MethodGet, "/api/shop/v1/companies/acme", "pk_shop", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var item bass.Resource
+
+		err := json.UnmarshalRead(rec.Body, &item)
+		require.NoError(t, err)
+
+		assert.Equal(t, []any{map[string]any{"role": "sales"}}, item.Properties["contacts"])
+		assert.Equal(t, map[string]any{"office": "********4567"}, item.Properties["phones"])
+		assert.Equal(t, map[string]any{"role": "ceo"}, item.Properties["owner"])
+	}
+}
+
+func TestPIIAnonymizationSnapshots(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithAPIKeys(
+			bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+			bass.APIKey{Name: "server", Key: "sk_shop", Type: bass.APIKeySecret, PackageName: "shop"},
+			bass.APIKey{Name: "web", Key: "pk_shop", Type: bass.APIKeyPublishable, PackageName: "shop"},
+		),
+	)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(bass.APIKeyHeader, key)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource type with PII, drafts and localization, and create items, versions, drafts and localizations
+	{
+		for _, tc := range []struct{ method, path, key, body string }{
+			{http.MethodPost, "/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "members.shop"}, "package": "shop", "resourceType": "Member", "plural": "members", "drafts": true, "localization": {"properties": ["bio", "email"]}, "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string", "x-pii": "drop"}, "bio": {"type": "string"}}}}]}`},
+			{http.MethodPut, "/api/shop/v1/members/m1/draft", "sk_shop", `{"email": "secret@example.com", "bio": "Hi"}`},
+			{http.MethodPost, "/api/shop/v1/members/m1:publish", "sk_shop", ""},
+			{http.MethodPut, "/api/shop/v1/members/m1/draft", "sk_shop", `{"email": "secret@example.com", "bio": "Hello"}`},
+			{http.MethodPut, "/api/shop/v1/members/m1/locales/de", "sk_shop", `{"email": "secret@example.com", "bio": "Hallo"}`},
+			{http.MethodPost, "/api/shop/v1/members", "sk_shop", `{"metadata": {"name": "m2"}, "email": "secret@example.com", "bio": "Hey"}`},
+			{http.MethodPost, "/api/core/v1/relationships", "sk_core", `{"metadata": {"name": "r1"}, "edge": "follows", "from": {"packageName": "shop", "resourceType": "Member", "name": "m1"}, "to": {"packageName": "shop", "resourceType": "Member", "name": "m2"}}`},
+		} {
+			rec := do(tc.method, tc.path, tc.key, tc.body)
+			require.Less(t, rec.Code, http.StatusMultipleChoices, rec.Body.String())
+		}
+	}
+
+	// secret keys read PII of related items, drafts, published versions and localizations as it is
+	{
+		for _, path := range []string{"/api/shop/v1/members/m1/related", "/api/shop/v1/members/m1/draft", "/api/shop/v1/members/m1/versions", "/api/shop/v1/members/m1/locales/de"} {
+			rec := do(http.MethodGet, path, "sk_shop", "")
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Contains(t, rec.Body.String(), "secret@example.com", path)
+		}
+	}
+
+	// publishable keys read PII of related items, drafts, published versions and localizations anonymized
+	{
+		for _, tc := range []struct{ path, want string }{
+			{"/api/shop/v1/members/m1/related", "Hey"},
+			{"/api/shop/v1/members/m1/draft", "Hello"},
+			{"/api/shop/v1/members/m1/versions", "Hi"},
+			{"/api/shop/v1/members/m1/locales/de", "Hallo"},
+		} {
+			rec := do(http.MethodGet, tc.path, "pk_shop", "")
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Contains(t, rec.Body.String(), tc.want, tc.path)
+			assert.NotContains(t, rec.Body.String(), "secret@example.com", tc.path)
+		}
+	}
+}
+
+func TestLegalHold(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	do := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	// register resource type and create items
+	{
+		rec := do(t, http.MethodPost, "/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "contracts.legal"}, "package": "legal", "resourceType": "Contract", "plural": "contracts", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"owner": {"type": "string", "x-data-subject": true}, "value": {"type": "number"}}}}]}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		for _, name := range []string{"held", "free"} {
+			rec = do(t, http.MethodPost, "/api/legal/v1/contracts", `{"metadata": {"name": "`+name+`"}, "owner": "alice", "value": 1}`)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// holds need a reason and an existing item
+	{
+		rec := do(t, http.MethodPut, "/admin/legalholds/legal/contracts/held", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = do(t, http.MethodPut, "/admin/legalholds/legal/contracts/missing", `{"reason": "litigation"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// place hold
+	{
+		rec := do(t, http.MethodPut, "/admin/legalholds/legal/contracts/held", `{"reason": "litigation", "placedBy": "compliance"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.NotEmpty(t, rec.Header().Get("Location"))
+
+		rec = do(t, http.MethodPut, "/admin/legalholds/legal/contracts/held", `{"reason": "again"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// concurrent holds of an item place one hold
+	{
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			codes = make(map[int]int)
+		)
+
+		for range 10 {
+			wg.Go(func() {
+				rec := do(t, http.MethodPut, "/admin/legalholds/legal/contracts/free", `{"reason": "audit"}`)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				codes[rec.Code]++
+			})
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, map[int]int{http.StatusCreated: 1, http.StatusOK: 9}, codes)
+
+		rec := do(t, http.MethodDelete, "/admin/legalholds/legal/contracts/free", "")
+		require.Equal(t, http.StatusNoContent, rec.Code)
+	}
+
+	// held items can't be deleted or modified
+	{
+		for _, req := range []struct{ method, target, body string }{
+			{http.MethodDelete, "/api/legal/v1/contracts/held", ""},
+			{http.MethodPut, "/api/legal/v1/contracts/held", `{"metadata": {"name": "held"}, "owner": "alice", "value": 2}`},
+			{http.MethodPatch, "/api/legal/v1/contracts/held", `{"value": 2}`},
+			{http.MethodPost, "/api/legal/v1/contracts/held:increment", `{"property": "value", "by": 1}`},
+		} {
+			rec := do(t, req.method, req.target, req.body)
+			assert.Equal(t, http.StatusLocked, rec.Code, req.method)
+
+			var res map[string]any
+
+			err := json.UnmarshalRead(rec.Body, &res)
+			require.NoError(t, err)
+
+			assert.Equal(t, string(apierrors.ReasonLegalHold), res["code"])
+		}
+
+		rec := do(t, http.MethodPost, "/api/transactions", `{"operations": [{"op": "delete", "packageName": "legal", "apiVersion": "v1", "resourceTypePlural": "contracts", "name": "held"}]}`)
+		assert.Equal(t, http.StatusLocked, rec.Code)
+
+		rec = do(t, http.MethodPatch, "/api/legal/v1/contracts/free", `{"value": 2}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// holds can't be written through the resource API
+	{
+		rec := do(t, http.MethodGet, "/api/core/v1/legalholds", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list bass.ResourceList
+
+		err := json.UnmarshalRead(rec.Body, &list)
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+
+		rec = do(t, http.MethodDelete, "/api/core/v1/legalholds/"+list.Items[0].Metadata.Name, "")
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	}
+
+	// erasure keeps held items
+	{
+		rec := do(t, http.MethodPost, "/admin/erase?subject=alice", "")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var report bass.ErasureReport
+
+		err := json.UnmarshalRead(rec.Body, &report)
+		require.NoError(t, err)
+
+		assert.Equal(t, []bass.ResourceReference{{PackageName: "legal", ResourceType: "Contract", Name: "held"}}, report.Held)
+		assert.Equal(t, []bass.ResourceReference{{PackageName: "legal", ResourceType: "Contract", Name: "free"}}, report.Deleted)
+	}
+
+	// inventory
+	{
+		rec := do(t, http.MethodGet, "/admin/legalholds?packageName=legal", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var inventory bass.LegalHoldInventory
+
+		err := json.UnmarshalRead(rec.Body, &inventory)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, inventory.Count)
+		assert.Equal(t, bass.ResourceReference{PackageName: "legal", ResourceType: "Contract", Name: "held"}, inventory.Holds[0].Resource)
+		assert.Equal(t, "litigation", inventory.Holds[0].Reason)
+		assert.Equal(t, "compliance", inventory.Holds[0].PlacedBy)
+
+		rec = do(t, http.MethodGet, "/admin/legalholds?packageName=other", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		err = json.UnmarshalRead(rec.Body, &inventory)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, inventory.Count)
+	}
+
+	// release hold
+	{
+		rec := do(t, http.MethodDelete, "/admin/legalholds/legal/contracts/held", "")
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = do(t, http.MethodDelete, "/admin/legalholds/legal/contracts/held", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(t, http.MethodDelete, "/api/legal/v1/contracts/held", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestViewPrivacy(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(bass.NewMemRepo())
+
+	getView := func(t *testing.T, path string) bass.ViewResult {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res bass.ViewResult
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	// create resource type, items, and private views
+	{
+		for _, tc := range []struct{ path, body string }{
+			{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "orders.shop"}, "package": "shop", "resourceType": "Order", "plural": "orders", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"region": {"type": "string"}, "total": {"type": "number"}}}}]}`},
+			{"/api/shop/v1/orders", `{"metadata": {"name": "o1"}, "region": "eu", "total": 10}`},
+			{"/api/shop/v1/orders", `{"metadata": {"name": "o2"}, "region": "eu", "total": 30}`},
+			{"/api/shop/v1/orders", `{"metadata": {"name": "o3"}, "region": "eu", "total": 500}`},
+			{"/api/shop/v1/orders", `{"metadata": {"name": "o4"}, "region": "us", "total": 5}`},
+			{"/api/core/v1/views", `{"metadata": {"name": "suppressed"}, "package": "shop", "resourceTypes": ["Order"], "groupBy": "/properties/region", "aggregates": {"orders": {"function": "count"}, "revenue": {"function": "sum", "field": "/properties/total"}}, "privacy": {"minGroupSize": 2}}`},
+			{"/api/core/v1/views", `{"metadata": {"name": "noisy"}, "package": "shop", "resourceTypes": ["Order"], "groupBy": "/properties/region", "aggregates": {"orders": {"function": "count"}, "revenue": {"function": "sum", "field": "/properties/total", "bound": 100}, "average": {"function": "avg", "field": "/properties/total", "bound": 100}}, "privacy": {"minGroupSize": 2, "epsilon": 1}}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	// create invalid private views
+	{
+		for _, body := range []string{
+			`{"metadata": {"name": "projection"}, "package": "shop", "resourceTypes": ["Order"], "fields": {"total": "/properties/total"}, "privacy": {"minGroupSize": 2}}`,
+			`{"metadata": {"name": "largest"}, "package": "shop", "resourceTypes": ["Order"], "aggregates": {"largest": {"function": "max", "field": "/properties/total"}}, "privacy": {"minGroupSize": 2}}`,
+			`{"metadata": {"name": "unbounded"}, "package": "shop", "resourceTypes": ["Order"], "aggregates": {"revenue": {"function": "sum", "field": "/properties/total"}}, "privacy": {"epsilon": 1}}`,
+			`{"metadata": {"name": "zero"}, "package": "shop", "resourceTypes": ["Order"], "aggregates": {"orders": {"function": "count"}}, "privacy": {"epsilon": 0}}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/core/v1/views", bytes.NewBufferString(body))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+	}
+
+	// small groups are suppressed
+	{
+		assert.Equal(t, []map[string]any{
+			{"group": "eu", "orders": 3.0, "revenue": 540.0},
+		}, getView(t, "/api/shop/v1/views/suppressed").Rows)
+	}
+
+	// noisy aggregates are stable between reads
+	{
+		rows := getView(t, "/api/shop/v1/views/noisy").Rows
+		require.Len(t, rows, 1)
+		assert.Equal(t, "eu", rows[0]["group"])
+
+		orders, ok := rows[0]["orders"].(float64)
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, orders, 0.0)
+		assert.InDelta(t, math.Round(orders), orders, 0)
+
+		assert.Equal(t, rows, getView(t, "/api/shop/v1/views/noisy").Rows)
+	}
+}
+
+func TestRevisionDiff(t *testing.T) {
+	t.Parallel()
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithRevisionHistory(true),
+		bass.WithAPIKeys(
+			bass.APIKey{Name: "admin", Key: "sk_core", Type: bass.APIKeySecret, PackageName: "core"},
+			bass.APIKey{Name: "server", Key: "sk_shop", Type: bass.APIKeySecret, PackageName: "shop"},
+			bass.APIKey{Name: "web", Key: "pk_shop", Type: bass.APIKeyPublishable, PackageName: "shop"},
+		),
+	)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(bass.APIKeyHeader, key)
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	getDiff := func(t *testing.T, path, key string) bass.RevisionDiff {
+		t.Helper()
+
+		rec := do(http.MethodGet, path, key, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res bass.RevisionDiff
+
+		err := json.Unmarshal(rec.Body.Bytes(), &res)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	resourceVersions := make([]string, 0, 3)
+
+	// write revisions of an item
+	{
+		rec := do(http.MethodPost, "/api/core/v1/resourcetypedefinitions", "sk_core", `{"metadata": {"name": "customers.shop"}, "package": "shop", "resourceType": "Customer", "plural": "customers", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"email": {"type": "string", "x-pii": "hash"}, "tier": {"type": "string"}}}}]}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		for _, tc := range []struct{ method, path, body string }{
+			{http.MethodPost, "/api/shop/v1/customers", `{"metadata": {"name": "c1"}, "email": "a@example.com", "tier": "free"}`},
+			{http.MethodPut, "/api/shop/v1/customers/c1", `{"metadata": {"name": "c1"}, "email": "a@example.com", "tier": "pro"}`},
+			{http.MethodPut, "/api/shop/v1/customers/c1", `{"metadata": {"name": "c1"}, "email": "b@example.com", "tier": "pro"}`},
+		} {
+			rec := do(tc.method, tc.path, "sk_shop", tc.body)
+			require.Less(t, rec.Code, http.StatusMultipleChoices, rec.Body.String())
+
+			var item bass.Resource
+
+			err := json.Unmarshal(rec.Body.Bytes(), &item)
+			require.NoError(t, err)
+
+			resourceVersions = append(resourceVersions, item.Metadata.ResourceVersion)
+		}
+	}
+
+	// diff against the revision before, with who wrote them
+	{
+		res := getDiff(t, "/api/shop/v1/customers/c1/revisions/"+resourceVersions[1]+":diff", "sk_shop")
+
+		assert.Equal(t, resourceVersions[0], res.From.ResourceVersion)
+		assert.Equal(t, bass.OperationCreate, res.From.Type)
+		assert.Equal(t, "server", res.From.By)
+		assert.Equal(t, "/api/shop/v1/customers", res.From.Origin)
+		assert.Equal(t, resourceVersions[1], res.To.ResourceVersion)
+		assert.Equal(t, "/api/shop/v1/customers/c1", res.To.Origin)
+		assert.Equal(t, bass.OperationUpdate, res.To.Type)
+		assert.False(t, res.To.At.IsZero())
+
+		assert.Contains(t, res.Patch, bass.JSONPatchOperation{Op: "replace", Path: "/tier", Value: jsontext.Value(`"pro"`)})
+		assert.NotContains(t, res.Patch, bass.JSONPatchOperation{Op: "replace", Path: "/email", Value: jsontext.Value(`"b@example.com"`)})
+	}
+
+	// diff against another revision
+	{
+		res := getDiff(t, "/api/shop/v1/customers/c1/revisions/"+resourceVersions[2]+":diff?against="+resourceVersions[0], "sk_shop")
+
+		assert.Equal(t, resourceVersions[0], res.From.ResourceVersion)
+		assert.Equal(t, resourceVersions[2], res.To.ResourceVersion)
+		assert.Contains(t, res.Patch, bass.JSONPatchOperation{Op: "replace", Path: "/tier", Value: jsontext.Value(`"pro"`)})
+		assert.Contains(t, res.Patch, bass.JSONPatchOperation{Op: "replace", Path: "/email", Value: jsontext.Value(`"b@example.com"`)})
+	}
+
+	// diffs of publishable keys anonymize PII
+	{
+		res := getDiff(t, "/api/shop/v1/customers/c1/revisions/"+resourceVersions[2]+":diff", "pk_shop")
+
+		hash := sha256.Sum256([]byte(`"b@example.com"`))
+
+		assert.Contains(t, res.Patch, bass.JSONPatchOperation{Op: "replace", Path: "/email", Value: jsontext.Value(`"` + hex.EncodeToString(hash[:]) + `"`)})
+	}
+
+	// diffs of deleted items are kept
+	{
+		rec := do(http.MethodDelete, "/api/shop/v1/customers/c1", "sk_shop", "")
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+		res := getDiff(t, "/api/shop/v1/customers/c1/revisions/"+resourceVersions[2]+":diff", "sk_shop")
+
+		assert.Equal(t, resourceVersions[1], res.From.ResourceVersion)
+	}
+
+	// diff missing revisions, the first revision, and other sub-resources of revisions
+	{
+		for _, tc := range []struct {
+			path string
+			code int
+		}{
+			{"/api/shop/v1/customers/c1/revisions/missing:diff", http.StatusNotFound},
+			{"/api/shop/v1/customers/c1/revisions/" + resourceVersions[2] + ":diff?against=missing", http.StatusNotFound},
+			{"/api/shop/v1/customers/c2/revisions/" + resourceVersions[2] + ":diff", http.StatusNotFound},
+			{"/api/shop/v1/missing/c1/revisions/" + resourceVersions[2] + ":diff", http.StatusNotFound},
+			{"/api/shop/v1/customers/c1/revisions/" + resourceVersions[2], http.StatusNotFound},
+			{"/api/shop/v1/customers/c1/revisions/" + resourceVersions[0] + ":diff", http.StatusBadRequest},
+		} {
+			rec := do(http.MethodGet, tc.path, "sk_shop", "")
+
+			assert.Equal(t, tc.code, rec.Code, tc.path)
+		}
+	}
+
+	// writes the handler makes itself have their actor as origin
+	{
+		rec := do(http.MethodPost, "/api/shop/v1/customers", "sk_shop", `{"metadata": {"name": "c2"}, "tier": "free"}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		at := time.Now()
+
+		rec = do(http.MethodPut, "/api/shop/v1/customers/c2", "sk_shop", `{"metadata": {"name": "c2"}, "tier": "pro"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = do(http.MethodPost, "/admin/restore?package=shop&at="+at.Format(time.RFC3339Nano), "sk_core", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = do(http.MethodGet, "/api/shop/v1/customers/c2", "sk_shop", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var item bass.Resource
+
+		err := json.Unmarshal(rec.Body.Bytes(), &item)
+		require.NoError(t, err)
+
+		res := getDiff(t, "/api/shop/v1/customers/c2/revisions/"+item.Metadata.ResourceVersion+":diff", "sk_shop")
+
+		assert.Equal(t, "/api/shop/v1/customers/c2", res.From.Origin)
+		assert.Equal(t, "admin", res.To.By)
+		assert.Equal(t, "restore", res.To.Origin)
+		assert.Contains(t, res.Patch, bass.JSONPatchOperation{Op: "replace", Path: "/tier", Value: jsontext.Value(`"free"`)})
+	}
+
+	// diff without revision history
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/shop/v1/customers/c1/revisions/1:diff", nil)
+		rec := httptest.NewRecorder()
+
+		bass.NewHandler(bass.NewMemRepo()).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	}
+}
+
+// TestAPICompatibility replays canonical requests and compares their responses with the golden files in
+// testdata/golden, to catch accidental changes of status codes and serialization breaking clients. After an
+// intended change, regenerate the golden files with UPDATE_GOLDEN=1 and review their diff.
+func TestAPICompatibility(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	h := bass.NewHandler(bass.NewMemRepo(), bass.WithClock(func() time.Time { return now }))
+
+	update := os.Getenv("UPDATE_GOLDEN") == "1"
+	uids := regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+	type goldenRequest struct {
+		Method string         `json:"method"`
+		Path   string         `json:"path"`
+		Body   jsontext.Value `json:"body,omitzero"`
+	}
+
+	type goldenResponse struct {
+		Status      int            `json:"status"`
+		ContentType string         `json:"contentType,omitzero"`
+		Location    string         `json:"location,omitzero"`
+		Body        jsontext.Value `json:"body,omitzero"`
+	}
+
+	type golden struct {
+		Request  goldenRequest  `json:"request"`
+		Response goldenResponse `json:"response"`
+	}
+
+	// the cases run in order, on the same handler
+	cases := []struct {
+		name string
+		req  goldenRequest
+	}{
+		{"create-resource-type-definition", goldenRequest{http.MethodPost, "/api/core/v1/resourcetypedefinitions", jsontext.Value(`{"metadata": {"name": "books.test"}, "package": "test", "resourceType": "Book", "plural": "books", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"title": {"type": "string"}, "pages": {"type": "integer"}}, "required": ["title"]}}]}`)}},
+		{"get-resource-type-definition", goldenRequest{http.MethodGet, "/api/core/v1/resourcetypedefinitions/books.test", nil}},
+		{"create-resource", goldenRequest{http.MethodPost, "/api/test/v1/books", jsontext.Value(`{"metadata": {"name": "dune", "labels": {"genre": "scifi"}}, "title": "Dune", "pages": 412}`)}},
+		{"create-resource-exists", goldenRequest{http.MethodPost, "/api/test/v1/books", jsontext.Value(`{"metadata": {"name": "dune"}, "title": "Dune"}`)}},
+		{"create-resource-invalid", goldenRequest{http.MethodPost, "/api/test/v1/books", jsontext.Value(`{"metadata": {"name": "untitled"}, "pages": "many"}`)}},
+		{"get-resource", goldenRequest{http.MethodGet, "/api/test/v1/books/dune", nil}},
+		{"get-resource-not-found", goldenRequest{http.MethodGet, "/api/test/v1/books/emma", nil}},
+		{"list-resources", goldenRequest{http.MethodGet, "/api/test/v1/books", nil}},
+		{"list-resources-unknown-type", goldenRequest{http.MethodGet, "/api/test/v1/films", nil}},
+		{"replace-resource", goldenRequest{http.MethodPut, "/api/test/v1/books/dune", jsontext.Value(`{"metadata": {"name": "dune", "labels": {"genre": "scifi"}}, "title": "Dune", "pages": 896}`)}},
+		{"patch-resource", goldenRequest{http.MethodPatch, "/api/test/v1/books/dune", jsontext.Value(`{"title": "Dune Messiah"}`)}},
+		{"increment-resource", goldenRequest{http.MethodPost, "/api/test/v1/books/dune:increment", jsontext.Value(`{"path": "/properties/pages", "delta": 1}`)}},
+		{"transaction", goldenRequest{http.MethodPost, "/api/transactions", jsontext.Value(`{"operations": [{"op": "create", "packageName": "test", "apiVersion": "v1", "resourceTypePlural": "books", "name": "emma", "properties": {"title": "Emma"}}]}`)}},
+		{"delete-resource", goldenRequest{http.MethodDelete, "/api/test/v1/books/emma", nil}},
+		{"list-feature-gates", goldenRequest{http.MethodGet, "/admin/features", nil}},
+		{"get-maintenance", goldenRequest{http.MethodGet, "/admin/maintenance", nil}},
+	}
+
+	for _, tc := range cases {
+		var body io.Reader
+		if tc.req.Body != nil {
+			body = bytes.NewReader(tc.req.Body)
+		}
+
+		req := httptest.NewRequest(tc.req.Method, tc.req.Path, body)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		got := golden{
+			Request: tc.req,
+			Response: goldenResponse{
+				Status:      rec.Code,
+				ContentType: rec.Header().Get("Content-Type"),
+				Location:    rec.Header().Get("Location"),
+				Body:        nil,
+			},
+		}
+
+		if rec.Body.Len() > 0 {
+			got.Response.Body = jsontext.Value(uids.ReplaceAll(rec.Body.Bytes(), []byte("<uuid>")))
+		}
+
+		b, err := json.Marshal(got, json.Deterministic(true), jsontext.Multiline(true), jsontext.WithIndent("  "))
+		require.NoError(t, err, tc.name)
+
+		b = append(b, '\n')
+		path := filepath.Join("testdata", "golden", tc.name+".json")
+
+		if update {
+			err = os.WriteFile(path, b, 0o600)
+			require.NoError(t, err, tc.name)
+
+			continue
+		}
+
+		want, err := os.ReadFile(path)
+		require.NoError(t, err, tc.name)
+
+		assert.Equal(t, string(want), string(b), "response of %s changed; if intended, regenerate with UPDATE_GOLDEN=1", tc.name)
+	}
+}
+
+// newFuzzHandler returns a handler with the "foos" resource type of the "test" package and its "foo1" item.
+func newFuzzHandler(t *testing.T) *bass.Handler {
+	t.Helper()
+
+	h := bass.NewHandler(
+		bass.NewMemRepo(),
+		bass.WithLogger(slog.New(slog.DiscardHandler)),
+		bass.WithFeatures(bass.FeatureJSONPatch, bass.FeatureMergePatch),
+	)
+
+	for _, step := range []struct{ path, body string }{
+		{"/api/core/v1/resourcetypedefinitions", `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object", "properties": {"bar": {"type": "integer", "minimum": 0}, "baz": {"type": "boolean"}, "bad": {"type": "object", "properties": {"bag": {"type": "string", "maxLength": 8}}}, "tags": {"type": "array", "items": {"type": "string"}}}, "required": ["bar"]}}]}`},
+		{"/api/test/v1/foos", `{"metadata": {"name": "foo1", "labels": {"a": "b"}}, "bar": 1, "baz": true, "tags": ["x"]}`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, step.path, bytes.NewBufferString(step.body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	return h
+}
+
+// checkFuzzResponse fails for server errors, and for successful responses that aren't valid JSON.
+func checkFuzzResponse(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	require.Less(t, rec.Code, http.StatusInternalServerError, rec.Body.String())
+
+	if rec.Code < http.StatusMultipleChoices && rec.Body.Len() > 0 {
+		require.True(t, jsontext.Value(rec.Body.Bytes()).IsValid(), rec.Body.String())
+	}
+}
+
+func FuzzCreateResource(f *testing.F) {
+	f.Add(`{"metadata": {"name": "foo2"}, "bar": 2}`)
+	f.Add(`{"metadata": {"name": "foo2", "labels": {"a": "b"}}, "bar": 2, "bad": {"bag": "wiz"}}`)
+	f.Add(`{"metadata": {"name": "foo1"}, "bar": 1}`)
+	f.Add(`{"metadata": {"name": "Foo 2"}, "bar": -1, "tags": [1]}`)
+	f.Add(`{"metadata": {}, "bar": 1e400}`)
+	f.Add(`{"bar": 1, "bar": 2}`)
+	f.Add(`[]`)
+	f.Add(`{`)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		h := newFuzzHandler(t)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		checkFuzzResponse(t, rec)
+	})
+}
+
+func FuzzJSONPatch(f *testing.F) {
+	f.Add(`[{"op": "add", "path": "/bad", "value": {}}, {"op": "add", "path": "/bad/bag", "value": "wiz"}, {"op": "replace", "path": "/bar", "value": 4}]`)
+	f.Add(`[{"op": "remove", "path": "/bar"}]`)
+	f.Add(`[{"op": "test", "path": "/baz", "value": true}, {"op": "copy", "from": "/bar", "path": "/tags/-"}]`)
+	f.Add(`[{"op": "move", "from": "/tags", "path": "/tags/0"}]`)
+	f.Add(`[{"op": "replace", "path": "/metadata/name", "value": "foo2"}]`)
+	f.Add(`[{"op": "add", "path": "/tags/999", "value": "y"}]`)
+	f.Add(`{}`)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		h := newFuzzHandler(t)
+
+		req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/foos/foo1", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json-patch+json")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		checkFuzzResponse(t, rec)
+	})
+}
+
+func FuzzMergePatch(f *testing.F) {
+	f.Add(`{"bar": 3, "baz": true}`)
+	f.Add(`{"bar": null}`)
+	f.Add(`{"bad": {"bag": "wiz"}, "tags": null}`)
+	f.Add(`{"metadata": {"name": "foo2", "labels": {"a": null}}}`)
+	f.Add(`{"metadata": null}`)
+	f.Add(`[1]`)
+	f.Add(`null`)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		h := newFuzzHandler(t)
+
+		req := httptest.NewRequest(http.MethodPatch, "/api/test/v1/foos/foo1", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		checkFuzzResponse(t, rec)
+	})
+}
+
+func FuzzSchemaValidation(f *testing.F) {
+	f.Add(`{"type": "object", "properties": {"bar": {"type": "integer"}}}`, `{"bar": 1}`)
+	f.Add(`{"type": "object", "properties": {"bar": {"type": "string", "pattern": "^a+$"}}, "required": ["bar"]}`, `{"bar": "b"}`)
+	f.Add(`{"type": "object", "properties": {"tags": {"type": "array", "uniqueItems": true, "maxItems": 2}}}`, `{"tags": [1, 1, 1]}`)
+	f.Add(`{"type": "object", "additionalProperties": false}`, `{"extra": {}}`)
+	f.Add(`{"type": "object", "properties": {"bar": {"$ref": "#/definitions/missing"}}}`, `{"bar": 1}`)
+	f.Add(`{"type": "object", "properties": {"bar": {"oneOf": [{"type": "integer"}, {"minimum": 0}]}}}`, `{"bar": 1}`)
+	f.Add(`{"type": "string"}`, `"foo"`)
+
+	f.Fuzz(func(t *testing.T, schema, body string) {
+		h := bass.NewHandler(bass.NewMemRepo(), bass.WithLogger(slog.New(slog.DiscardHandler)))
+
+		// the schema may be invalid JSON too, so it is spliced into the definition as is
+		definition := `{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": ` + schema + `}]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(definition))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		checkFuzzResponse(t, rec)
+
+		if rec.Code != http.StatusCreated {
+			return
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "bar": `+body+`}`))
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		checkFuzzResponse(t, rec)
+	})
+}
+
+func BenchmarkGetResource(b *testing.B) {
+	for name, repo := range map[string]bass.ResourcesRepository{
+		"raw":     bass.NewMemRepo(),
+		"decoded": rawlessRepo{ResourcesRepository: bass.NewMemRepo()},
+	} {
+		b.Run(name, func(b *testing.B) {
+			h := bass.NewHandler(repo)
+
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodPost, "/api/core/v1/resourcetypedefinitions", bytes.NewBufferString(`{"metadata": {"name": "foos.test"}, "package": "test", "resourceType": "Foo", "plural": "foos", "versions": [{"name": "v1", "schema": {"type": "object"}}]}`)),
+				httptest.NewRequest(http.MethodPost, "/api/test/v1/foos", bytes.NewBufferString(`{"metadata": {"name": "foo1"}, "title": "Foo", "tags": ["a", "b", "c"], "address": {"street": "Main", "city": "Tehran", "zip": "12345"}, "price": 12.5, "stock": 42}`)),
+			} {
+				rec := httptest.NewRecorder()
+
+				h.ServeHTTP(rec, req)
+
+				require.Equal(b, http.StatusCreated, rec.Code)
+			}
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				req := httptest.NewRequest(http.MethodGet, "/api/test/v1/foos/foo1", nil)
+				rec := httptest.NewRecorder()
+
+				h.ServeHTTP(rec, req)
+
+				if rec.Code != http.StatusOK {
+					b.Fatalf("unexpected status %d", rec.Code)
+				}
+			}
+		})
+	}
+}
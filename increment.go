@@ -0,0 +1,194 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"net/http"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type IncrementRequest = apiv1.IncrementRequest
+
+// handleIncrementResource adds a delta to a numeric property of an item atomically in the repository.
+func (h *Handler) handleIncrementResource(w http.ResponseWriter, r *http.Request, name string) {
+	packageName := r.PathValue("packageName")
+	resourceTypePlural := r.PathValue("resourceTypePlural")
+
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return
+	}
+
+	var req IncrementRequest
+
+	err = json.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+		default:
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+		}
+
+		return
+	}
+
+	segments, err := parsePropertyPath(req.Path)
+	if err != nil {
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return
+	}
+
+	h.updateResourceFunc(w, r, resourceTypeDefinition, name, func(item *Resource) error {
+		parent, key, err := propertyParent(item.Properties, req.Path, segments)
+		if err != nil {
+			return err
+		}
+
+		var current float64
+
+		if value, ok := parent[key]; ok {
+			current, ok = value.(float64)
+			if !ok {
+				return PropertyPathError{Path: req.Path, Reason: "property is not a number"}
+			}
+		}
+
+		parent[key] = current + req.Delta
+
+		return nil
+	})
+}
+
+// updateResourceFunc modifies an item with fn, validates the result, and responds with the updated item. The item is
+// written only if it wasn't written since it was read, and is read again otherwise. Validation may read the repository,
+// so it runs before the atomic update rather than in it.
+func (h *Handler) updateResourceFunc(w http.ResponseWriter, r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, name string, fn func(item *Resource) error) {
+	updater, ok := h.repo.(AtomicUpdater)
+	if !ok {
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusNotImplemented),
+			problem.WithTitle("Not Implemented"),
+			problem.WithDetail("repository does not support atomic updates"),
+			withReason(apierrors.ReasonNotImplemented),
+		))
+
+		return
+	}
+
+	var resourceImmutableError ResourceImmutableError
+
+	if errors.As(checkUpdatable(resourceTypeDefinition), &resourceImmutableError) {
+		respond.Done(w, r, resourceImmutable(resourceImmutableError))
+
+		return
+	}
+
+	ctx := r.Context()
+
+	var item *Resource
+
+	err := retryConflicts(ctx, func() error {
+		current, err := h.repoGet(ctx, resourceTypeDefinition.Package, resourceTypeDefinition.ResourceType, name)
+		if err != nil {
+			return err
+		}
+
+		updated, err := h.modifyResource(ctx, resourceTypeDefinition, current, fn)
+		if err != nil {
+			return err
+		}
+
+		item, err = updater.UpdateFunc(ctx, resourceTypeDefinition.Package, resourceTypeDefinition.ResourceType, name, func(item *Resource) error {
+			if item.Metadata.ResourceVersion != current.Metadata.ResourceVersion {
+				return ResourceVersionConflictError{
+					PackageName:     resourceTypeDefinition.Package,
+					ResourceType:    resourceTypeDefinition.ResourceType,
+					Name:            name,
+					ResourceVersion: current.Metadata.ResourceVersion,
+				}
+			}
+
+			*item = *updated.DeepCopy()
+
+			return nil
+		})
+
+		return err //nolint:wrapcheck // repositories wrap their errors
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to update resource", "error", err)
+
+		var (
+			resourceNotFoundError        ResourceNotFoundError
+			resourceVersionConflictError ResourceVersionConflictError
+			propertyPathError            PropertyPathError
+			unknownFieldsError           UnknownFieldsError
+			resourceInvalidError         ResourceInvalidError
+			stateTransitionError         StateTransitionError
+		)
+
+		switch {
+		case errors.As(err, &resourceNotFoundError):
+			h.resourceNotFound(w, r, resourceNotFoundError)
+		case errors.As(err, &resourceVersionConflictError):
+			respond.Done(w, r, problem.Conflict(resourceVersionConflictError.Error(), withReason(apierrors.ReasonConflict)))
+		case errors.As(err, &propertyPathError):
+			respond.Done(w, r, problem.BadRequest(propertyPathError.Error(), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &unknownFieldsError):
+			respond.Done(w, r, problem.BadRequest(unknownFieldsError.Error(), problem.WithExtension("fields", unknownFieldsError.Fields), withReason(apierrors.ReasonUnknownFields)))
+		case errors.As(err, &resourceInvalidError):
+			respond.Done(w, r, problem.BadRequest(resourceInvalidError.Error(), problem.WithExtension("errors", resourceInvalidError.Errors), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &stateTransitionError):
+			respond.Done(w, r, stateTransitionInvalid(stateTransitionError))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	h.resourceWritten(r.Context(), OperationUpdate, item)
+
+	respond.Done(w, r, item)
+}
+
+// modifyResource returns a copy of an item modified with fn, after checking it.
+func (h *Handler) modifyResource(ctx context.Context, resourceTypeDefinition *ResourceTypeDefinition, current *Resource, fn func(item *Resource) error) (*Resource, error) {
+	item := current.DeepCopy()
+	if item.Properties == nil {
+		item.Properties = make(map[string]any)
+	}
+
+	err := fn(item)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Metadata.UpdatedAt = h.now()
+
+	err = h.checkResourceItem(ctx, resourceTypeDefinition, item)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkTransition(resourceTypeDefinition, current, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
@@ -0,0 +1,423 @@
+package bass
+
+import (
+	"cmp"
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	LegalHold          = apiv1.LegalHold
+	LegalHoldRequest   = apiv1.LegalHoldRequest
+	LegalHoldInventory = apiv1.LegalHoldInventory
+)
+
+// legalHoldResourceLabel labels legal holds with the resource they hold.
+const legalHoldResourceLabel = "bass.legalhold/resource"
+
+// LegalHoldError is returned for deletes and modifications of resources under legal hold, regardless of the other
+// permissions of the request.
+type LegalHoldError struct {
+	PackageName  string
+	ResourceType string
+	Name         string
+	Reason       string
+}
+
+func (err LegalHoldError) Error() string {
+	return fmt.Sprintf("resource with name %q and resource type %q and package %q is under legal hold: %s", err.Name, err.ResourceType, err.PackageName, err.Reason)
+}
+
+func legalHoldResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "LegalHold.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "LegalHold",
+		Plural:       "LegalHolds",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"resource": resourceReferenceSchema(),
+						"reason":   map[string]any{"type": "string", "minLength": 1},
+						"placedBy": map[string]any{"type": "string"},
+					},
+					"required": []any{"resource", "reason"},
+				},
+			},
+		},
+	}
+}
+
+func legalHoldFromResource(item *Resource) (*LegalHold, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal legal hold properties: %w", err)
+	}
+
+	var hold LegalHold
+
+	err = json.Unmarshal(b, &hold)
+	if err != nil {
+		return nil, fmt.Errorf("legal hold %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	hold.Metadata = item.Metadata
+
+	return &hold, nil
+}
+
+// getLegalHold returns the legal hold of an item, or nil if it isn't held.
+func (h *Handler) getLegalHold(ctx context.Context, packageName, resourceType, name string) (*Resource, error) {
+	list, err := h.repoListByLabels(ctx, corePackageName, "v1", "LegalHold", map[string]string{
+		legalHoldResourceLabel: resourceLabelValue(packageName, resourceType, name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list.Items) == 0 {
+		return nil, nil //nolint:nilnil // not held
+	}
+
+	return list.Items[0], nil
+}
+
+// legalHoldName names the legal hold of an item. Each item has at most one, so concurrent holds of it conflict.
+func legalHoldName(packageName, resourceType, name string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(resourceLabelValue(packageName, resourceType, name))).String()
+}
+
+// checkLegalHold returns LegalHoldError if the item is under legal hold. Legal holds themselves are only placed and
+// released through the admin API, so writes of them through the resource API return ResourceImmutableError. An empty
+// name only checks the resource type, for creates.
+func (h *Handler) checkLegalHold(ctx context.Context, packageName, resourceType, name string) error {
+	if isLegalHold(packageName, resourceType) {
+		return ResourceImmutableError{PackageName: packageName, ResourceType: resourceType}
+	}
+
+	if name == "" {
+		return nil
+	}
+
+	item, err := h.getLegalHold(ctx, packageName, resourceType, name)
+	if err != nil {
+		return err
+	}
+
+	if item == nil {
+		return nil
+	}
+
+	reason, _ := item.Properties["reason"].(string)
+
+	return LegalHoldError{PackageName: packageName, ResourceType: resourceType, Name: name, Reason: reason}
+}
+
+// isLegalHold reports whether the resource type is the one of legal holds.
+func isLegalHold(packageName, resourceType string) bool {
+	return packageName == corePackageName && resourceType == "LegalHold"
+}
+
+func legalHold(err LegalHoldError) problem.Problem {
+	return problem.CustomError(
+		problem.WithStatus(http.StatusLocked),
+		problem.WithTitle("Locked"),
+		problem.WithDetail(err.Error()),
+		withReason(apierrors.ReasonLegalHold),
+	)
+}
+
+// checkLegalHoldWrite checks a write of an item with checkLegalHold, and reports whether it may proceed. Otherwise,
+// it responds with the failure.
+func (h *Handler) checkLegalHoldWrite(w http.ResponseWriter, r *http.Request, packageName, resourceType, name string) bool {
+	err := h.checkLegalHold(r.Context(), packageName, resourceType, name)
+	if err == nil {
+		return true
+	}
+
+	h.logger.ErrorContext(r.Context(), "write of resource under legal hold", "error", err)
+
+	var (
+		legalHoldError         LegalHoldError
+		resourceImmutableError ResourceImmutableError
+	)
+
+	switch {
+	case errors.As(err, &legalHoldError):
+		respond.Done(w, r, legalHold(legalHoldError))
+	case errors.As(err, &resourceImmutableError):
+		respond.Done(w, r, resourceImmutable(resourceImmutableError))
+	default:
+		respond.Done(w, r, serverError(err))
+	}
+
+	return false
+}
+
+// checkItemUpdatable checks items of the resource type can be updated, with checkUpdatable, and the item isn't held,
+// and reports whether the update may proceed. Otherwise, it responds with the failure.
+func (h *Handler) checkItemUpdatable(w http.ResponseWriter, r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, packageName, name string) bool {
+	var resourceImmutableError ResourceImmutableError
+
+	if errors.As(checkUpdatable(resourceTypeDefinition), &resourceImmutableError) {
+		respond.Done(w, r, resourceImmutable(resourceImmutableError))
+
+		return false
+	}
+
+	return h.checkLegalHoldWrite(w, r, packageName, resourceTypeDefinition.ResourceType, name)
+}
+
+// legalHoldTarget resolves the resource type of the item of a legal hold request, and checks the item exists. It
+// responds with the failure and returns nil if it can't.
+func (h *Handler) legalHoldTarget(w http.ResponseWriter, r *http.Request) *Resource {
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), r.PathValue("packageName"), r.PathValue("resourceTypePlural"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+
+		var resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
+
+		switch {
+		case errors.As(err, &resourceTypeDefinitionNotFoundError):
+			respond.Done(w, r, problem.NotFound(resourceTypeDefinitionNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return nil
+	}
+
+	item, err := h.repoGet(r.Context(), r.PathValue("packageName"), resourceTypeDefinition.ResourceType, r.PathValue("name"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource", "error", err)
+
+		var resourceNotFoundError ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &resourceNotFoundError):
+			respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return nil
+	}
+
+	return item
+}
+
+// handlePutLegalHold places a legal hold on an item, and responds with it. Holding a held item responds with its
+// hold as it is.
+func (h *Handler) handlePutLegalHold() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LegalHoldRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		if req.Reason == "" {
+			respond.Done(w, r, problem.BadRequest("legal hold without reason", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		target := h.legalHoldTarget(w, r)
+		if target == nil {
+			return
+		}
+
+		item, err := h.getLegalHold(r.Context(), target.Metadata.PackageName, target.Metadata.ResourceType, target.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get legal hold", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		if item != nil {
+			respond.Done(w, r, item)
+
+			return
+		}
+
+		// The item is kept from expiring before it is held, so it can't expire under hold.
+		err = h.setExpiring(r.Context(), target, false)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to keep held resource from expiring", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		now := h.now()
+		uid := legalHoldName(target.Metadata.PackageName, target.Metadata.ResourceType, target.Metadata.Name)
+
+		properties := map[string]any{
+			"resource": map[string]any{"packageName": target.Metadata.PackageName, "resourceType": target.Metadata.ResourceType, "name": target.Metadata.Name},
+			"reason":   req.Reason,
+		}
+
+		if req.PlacedBy != "" {
+			properties["placedBy"] = req.PlacedBy
+		}
+
+		item = &Resource{
+			Metadata: Metadata{
+				UID:          uid,
+				PackageName:  corePackageName,
+				APIVersion:   "v1",
+				ResourceType: "LegalHold",
+				Name:         uid,
+				Labels:       map[string]string{legalHoldResourceLabel: resourceLabelValue(target.Metadata.PackageName, target.Metadata.ResourceType, target.Metadata.Name)},
+				CreatedAt:    now,
+				UpdatedAt:    now,
+			},
+			Properties: properties,
+		}
+
+		err = h.repo.Create(r.Context(), item)
+
+		// A concurrent request placed the hold first.
+		var resourceExistsError ResourceExistsError
+		if errors.As(err, &resourceExistsError) {
+			item, err = h.repoGet(r.Context(), corePackageName, "LegalHold", uid)
+			if err == nil {
+				respond.Done(w, r, item)
+
+				return
+			}
+		}
+
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to create legal hold", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		h.resourceWritten(r.Context(), OperationCreate, item)
+		h.logger.InfoContext(r.Context(), "placed legal hold", "resource", resourceLabelValue(target.Metadata.PackageName, target.Metadata.ResourceType, target.Metadata.Name), "placedBy", req.PlacedBy)
+
+		w.Header().Set("Location", h.resourcePath(corePackageName, "v1", "legalholds", item.Metadata.Name))
+		w.WriteHeader(http.StatusCreated)
+		respond.Done(w, r, item)
+	}
+}
+
+// handleDeleteLegalHold releases the legal hold of an item.
+func (h *Handler) handleDeleteLegalHold() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := h.legalHoldTarget(w, r)
+		if target == nil {
+			return
+		}
+
+		item, err := h.getLegalHold(r.Context(), target.Metadata.PackageName, target.Metadata.ResourceType, target.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get legal hold", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		if item == nil {
+			respond.Done(w, r, problem.NotFound(fmt.Sprintf("resource with name %q and resource type %q and package %q is not under legal hold", target.Metadata.Name, target.Metadata.ResourceType, target.Metadata.PackageName), withReason(apierrors.ReasonNotFound)))
+
+			return
+		}
+
+		err = h.repoDelete(r.Context(), corePackageName, "LegalHold", item.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to delete legal hold", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		h.resourceWritten(r.Context(), OperationDelete, item)
+		h.logger.InfoContext(r.Context(), "released legal hold", "resource", item.Metadata.Labels[legalHoldResourceLabel])
+
+		err = h.setExpiring(r.Context(), target, true)
+		if err != nil {
+			// The hold is released anyway; the item expires once written again.
+			h.logger.ErrorContext(r.Context(), "failed to let released resource expire", "error", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// setExpiring sets whether an item expires, in repositories expiring resources.
+func (h *Handler) setExpiring(ctx context.Context, item *Resource, expiring bool) error {
+	repo, ok := h.repo.(ExpiringRepository)
+	if !ok {
+		return nil
+	}
+
+	return repo.SetExpiring(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name, expiring) //nolint:wrapcheck // repositories wrap their errors
+}
+
+// handleListLegalHolds responds with the inventory of legal holds, oldest first, optionally of the package of the
+// "packageName" query parameter only.
+func (h *Handler) handleListLegalHolds() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := r.URL.Query().Get("packageName")
+
+		list, err := h.repoList(r.Context(), corePackageName, "v1", "LegalHold")
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to list legal holds", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		inventory := LegalHoldInventory{Count: 0, Holds: make([]LegalHold, 0, len(list.Items))}
+
+		for _, item := range list.Items {
+			hold, err := legalHoldFromResource(item)
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "failed to parse legal hold", "error", err)
+				respond.Done(w, r, serverError(err))
+
+				return
+			}
+
+			if packageName != "" && hold.Resource.PackageName != packageName {
+				continue
+			}
+
+			inventory.Holds = append(inventory.Holds, *hold)
+		}
+
+		slices.SortFunc(inventory.Holds, func(a, b LegalHold) int {
+			return cmp.Or(a.Metadata.CreatedAt.Compare(b.Metadata.CreatedAt), cmp.Compare(a.Metadata.Name, b.Metadata.Name))
+		})
+
+		inventory.Count = len(inventory.Holds)
+
+		respond.Done(w, r, inventory)
+	}
+}
@@ -0,0 +1,156 @@
+package bass
+
+import (
+	"encoding/base64"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+// ListLimits guards list endpoints against unbounded responses. Zero values disable the respective limit.
+type ListLimits struct {
+	// DefaultLimit is the page size of lists requested without the limit query parameter.
+	DefaultLimit int
+	// MaxLimit caps the page size clients may request. Larger limits are lowered to it, and lists requested without
+	// limit are paginated with it if there is no default limit.
+	MaxLimit int
+	// MaxUnpaginatedBytes is the maximum size of the encoding of lists responded at once. Larger lists are rejected,
+	// asking the client to request pages instead, before they are encoded completely.
+	MaxUnpaginatedBytes int64
+}
+
+type ListQueryError struct {
+	Param string
+	Value string
+}
+
+func (err ListQueryError) Error() string {
+	return fmt.Sprintf("invalid %s query parameter %q", err.Param, err.Value)
+}
+
+type ListTooLargeError struct {
+	MaxBytes int64
+}
+
+func (err ListTooLargeError) Error() string {
+	return fmt.Sprintf("list exceeds %d bytes; request it in pages with the limit and continue query parameters", err.MaxBytes)
+}
+
+// paginate returns the page of list requested with the limit and continue query parameters of r, ordered by name,
+// and whether list is paginated.
+func (h *Handler) paginate(r *http.Request, list ResourceList) (ResourceList, bool, error) {
+	limit := h.listLimits.DefaultLimit
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return ResourceList{}, false, ListQueryError{Param: "limit", Value: value}
+		}
+
+		limit = n
+	}
+
+	if h.listLimits.MaxLimit > 0 && (limit <= 0 || limit > h.listLimits.MaxLimit) {
+		limit = h.listLimits.MaxLimit
+	}
+
+	items := slices.Clone(list.Items)
+	slices.SortFunc(items, func(a, b *Resource) int {
+		return strings.Compare(a.Metadata.Name, b.Metadata.Name)
+	})
+
+	if value := r.URL.Query().Get("continue"); value != "" {
+		after, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return ResourceList{}, false, ListQueryError{Param: "continue", Value: value}
+		}
+
+		i, _ := slices.BinarySearchFunc(items, string(after), func(item *Resource, name string) int {
+			// Items after the last one of the previous page, even if it has been deleted since.
+			if item.Metadata.Name <= name {
+				return -1
+			}
+
+			return 1
+		})
+		items = items[i:]
+	}
+
+	list.Items = items
+
+	if limit <= 0 {
+		return list, false, nil
+	}
+
+	if len(items) > limit {
+		list.Items = items[:limit]
+		list.Metadata.Continue = base64.RawURLEncoding.EncodeToString([]byte(items[limit-1].Metadata.Name))
+	}
+
+	return list, true, nil
+}
+
+// respondList responds with list. Lists that aren't paginated are encoded up to the maximum size of unpaginated lists.
+func (h *Handler) respondList(w http.ResponseWriter, r *http.Request, list ResourceList, paginated bool) {
+	if paginated || h.listLimits.MaxUnpaginatedBytes <= 0 {
+		respond.Done(w, r, list)
+
+		return
+	}
+
+	b, err := encodeListWithin(list, h.listLimits.MaxUnpaginatedBytes)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode list", "error", err)
+
+		var listTooLargeError ListTooLargeError
+
+		switch {
+		case errors.As(err, &listTooLargeError):
+			respond.Done(w, r, problem.BadRequest(listTooLargeError.Error(), withReason(apierrors.ReasonPaginationRequired)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(b)
+}
+
+// encodeListWithin encodes list item by item, failing as soon as the encoding exceeds maxBytes.
+func encodeListWithin(list ResourceList, maxBytes int64) ([]byte, error) {
+	metadata, err := json.Marshal(list.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal list metadata: %w", err)
+	}
+
+	b := slices.Concat([]byte(`{"metadata":`), metadata, []byte(`,"items":[`))
+
+	for i, item := range list.Items {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal resource: %w", err)
+		}
+
+		b = append(b, encoded...)
+
+		if int64(len(b)) > maxBytes {
+			return nil, ListTooLargeError{MaxBytes: maxBytes}
+		}
+	}
+
+	return append(b, "]}\n"...), nil
+}
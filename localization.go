@@ -0,0 +1,410 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	LocalizationPolicy = apiv1.LocalizationPolicy
+	Localization       = apiv1.Localization
+)
+
+// localizationParentLabel labels localizations with their item.
+const localizationParentLabel = "bass.localization/parent"
+
+type LocalizationDisabledError struct {
+	PackageName  string
+	ResourceType string
+}
+
+func (err LocalizationDisabledError) Error() string {
+	return fmt.Sprintf("resource type %q of package %q is not localized", err.ResourceType, err.PackageName)
+}
+
+type InvalidLocaleError struct {
+	Locale string
+}
+
+func (err InvalidLocaleError) Error() string {
+	return fmt.Sprintf("invalid locale %q", err.Locale)
+}
+
+func localizationResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "Localization.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "Localization",
+		Plural:       "Localizations",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"parent":     resourceReferenceSchema(),
+						"locale":     map[string]any{"type": "string", "minLength": 1},
+						"properties": map[string]any{"type": "object"},
+					},
+					"required": []any{"parent", "locale", "properties"},
+				},
+			},
+		},
+	}
+}
+
+func localizationPolicyFromProperties(name string, properties map[string]any) (*LocalizationPolicy, error) {
+	value, ok := properties["localization"]
+	if !ok {
+		return nil, nil //nolint:nilnil // not localized
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal localization property: %w", err)
+	}
+
+	var localization LocalizationPolicy
+
+	err = json.Unmarshal(b, &localization)
+	if err != nil {
+		return nil, fmt.Errorf("resource type %q has invalid localization property: %w", name, err)
+	}
+
+	return &localization, nil
+}
+
+// checkLocale returns InvalidLocaleError unless locale is a language tag, like "de" or "de-AT".
+func checkLocale(locale string) error {
+	for subtag := range strings.SplitSeq(locale, "-") {
+		if subtag == "" || strings.ContainsFunc(subtag, func(c rune) bool {
+			return (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9')
+		}) {
+			return InvalidLocaleError{Locale: locale}
+		}
+	}
+
+	return nil
+}
+
+// localizationName names the localization of an item in a locale. Each item has at most one per locale.
+func localizationName(packageName, resourceType, name, locale string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(resourceLabelValue(packageName, resourceType, name)+"/"+locale)).String()
+}
+
+// localeChain returns the locales to look up for locale, most specific first: the locale itself, its fallbacks, and
+// then its parent locales.
+func localeChain(localization *LocalizationPolicy, locale string) []string {
+	chain := append([]string{locale}, localization.Fallbacks[locale]...)
+
+	for i := strings.LastIndex(locale, "-"); i > 0; i = strings.LastIndex(locale[:i], "-") {
+		chain = append(chain, locale[:i])
+	}
+
+	res := make([]string, 0, len(chain))
+
+	for _, locale := range chain {
+		if !slices.Contains(res, locale) {
+			res = append(res, locale)
+		}
+	}
+
+	return res
+}
+
+// localize overlays the localizable properties of item with their translations in locale, falling back along the
+// locale chain to the properties of the item itself.
+func (h *Handler) localize(ctx context.Context, localization *LocalizationPolicy, item *Resource, locale string) error {
+	list, err := h.repoListByLabels(ctx, corePackageName, "v1", "Localization", map[string]string{
+		localizationParentLabel: resourceLabelValue(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name),
+	})
+	if err != nil {
+		return err
+	}
+
+	overlays := make(map[string]map[string]any, len(list.Items))
+
+	for _, localizationItem := range list.Items {
+		locale, _ := localizationItem.Properties["locale"].(string)
+		overlays[locale], _ = localizationItem.Properties["properties"].(map[string]any)
+	}
+
+	if item.Properties == nil {
+		item.Properties = make(map[string]any)
+	}
+
+	for _, property := range localization.Properties {
+		for _, locale := range localeChain(localization, locale) {
+			if value, ok := overlays[locale][property]; ok {
+				item.Properties[property] = value
+
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// getLocalizationParent resolves the resource type and the item of a localization request. It responds with the
+// failure and returns nil if the item is not found or not localized.
+func (h *Handler) getLocalizationParent(w http.ResponseWriter, r *http.Request) (*ResourceTypeDefinition, *Resource) {
+	err := checkLocale(r.PathValue("locale"))
+	if err != nil {
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return nil, nil
+	}
+
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), r.PathValue("packageName"), r.PathValue("resourceTypePlural"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return nil, nil
+	}
+
+	if resourceTypeDefinition.Localization == nil {
+		respond.Done(w, r, problem.BadRequest(LocalizationDisabledError{
+			PackageName:  resourceTypeDefinition.Package,
+			ResourceType: resourceTypeDefinition.ResourceType,
+		}.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return nil, nil
+	}
+
+	parent, err := h.repoGet(r.Context(), r.PathValue("packageName"), resourceTypeDefinition.ResourceType, r.PathValue("name"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource", "error", err)
+
+		var resourceNotFoundError ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &resourceNotFoundError):
+			respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return nil, nil
+	}
+
+	return resourceTypeDefinition, parent
+}
+
+func (h *Handler) handleGetLocalization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resourceTypeDefinition, parent := h.getLocalizationParent(w, r)
+		if parent == nil {
+			return
+		}
+
+		item, err := h.repoGet(r.Context(), corePackageName, "Localization", localizationName(parent.Metadata.PackageName, parent.Metadata.ResourceType, parent.Metadata.Name, r.PathValue("locale")))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get localization", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		if !privilegedRead(r) {
+			item = anonymizedSnapshot(resourceTypeDefinition, item)
+		}
+
+		respond.Done(w, r, item)
+	}
+}
+
+// handlePutLocalization creates or replaces the translations of an item in a locale. The body holds the translated
+// properties, which must be localizable and keep the item valid.
+func (h *Handler) handlePutLocalization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var properties map[string]any
+
+		err := json.UnmarshalRead(r.Body, &properties)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		resourceTypeDefinition, parent := h.getLocalizationParent(w, r)
+		if parent == nil {
+			return
+		}
+
+		for _, property := range slices.Sorted(maps.Keys(properties)) {
+			if !slices.Contains(resourceTypeDefinition.Localization.Properties, property) {
+				respond.Done(w, r, problem.BadRequest(PropertyPathError{Path: "/properties/" + property, Reason: "is not localizable"}.Error(), withReason(apierrors.ReasonInvalid)))
+
+				return
+			}
+		}
+
+		localized := parent.DeepCopy()
+		maps.Copy(localized.Properties, properties)
+
+		if !h.validateResourceItem(w, r, resourceTypeDefinition, localized) {
+			return
+		}
+
+		h.writeLocalization(w, r, parent, properties)
+	}
+}
+
+func (h *Handler) writeLocalization(w http.ResponseWriter, r *http.Request, parent *Resource, properties map[string]any) {
+	locale := r.PathValue("locale")
+
+	item := Resource{
+		Metadata: Metadata{
+			UID:          uuid.NewString(),
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "Localization",
+			Name:         localizationName(parent.Metadata.PackageName, parent.Metadata.ResourceType, parent.Metadata.Name, locale),
+			Labels: map[string]string{
+				localizationParentLabel: resourceLabelValue(parent.Metadata.PackageName, parent.Metadata.ResourceType, parent.Metadata.Name),
+			},
+			CreatedAt: h.now(),
+			UpdatedAt: h.now(),
+		},
+		Properties: map[string]any{
+			"parent": map[string]any{
+				"packageName":  parent.Metadata.PackageName,
+				"resourceType": parent.Metadata.ResourceType,
+				"name":         parent.Metadata.Name,
+			},
+			"locale":     locale,
+			"properties": properties,
+		},
+	}
+
+	operation := OperationUpdate
+
+	err := h.repo.Update(r.Context(), &item)
+
+	var resourceNotFoundError ResourceNotFoundError
+	if errors.As(err, &resourceNotFoundError) {
+		operation = OperationCreate
+		err = h.repo.Create(r.Context(), &item)
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to write localization", "error", err)
+
+		var resourceExistsError ResourceExistsError
+
+		switch {
+		case errors.As(err, &resourceExistsError):
+			respond.Done(w, r, problem.Conflict(resourceExistsError.Error(), withReason(apierrors.ReasonAlreadyExists)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	h.resourceWritten(r.Context(), operation, &item)
+
+	if operation == OperationCreate {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	respond.Done(w, r, item)
+}
+
+func (h *Handler) handleDeleteLocalization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, parent := h.getLocalizationParent(w, r)
+		if parent == nil {
+			return
+		}
+
+		item := &Resource{
+			Metadata: Metadata{
+				PackageName:  corePackageName,
+				APIVersion:   "v1",
+				ResourceType: "Localization",
+				Name:         localizationName(parent.Metadata.PackageName, parent.Metadata.ResourceType, parent.Metadata.Name, r.PathValue("locale")),
+			},
+			Properties: nil,
+		}
+
+		err := h.repoDelete(r.Context(), corePackageName, "Localization", item.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to delete localization", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		h.resourceWritten(r.Context(), OperationDelete, item)
+
+		respond.Done(w, r, nil)
+	}
+}
+
+// deleteLocalizations deletes the localizations of a deleted item.
+func (h *Handler) deleteLocalizations(ctx context.Context, packageName, resourceType, name string) {
+	list, err := h.repoListByLabels(ctx, corePackageName, "v1", "Localization", map[string]string{
+		localizationParentLabel: resourceLabelValue(packageName, resourceType, name),
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list localizations of deleted resource", "error", err)
+
+		return
+	}
+
+	for _, item := range list.Items {
+		err = h.repoDelete(ctx, corePackageName, "Localization", item.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to delete localization of deleted resource", "error", err)
+
+			continue
+		}
+
+		h.resourceWritten(ctx, OperationDelete, item)
+	}
+}
@@ -0,0 +1,103 @@
+package bass
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type Maintenance = apiv1.Maintenance
+
+// writeRequest reports whether r may change data and is rejected in read-only mode. Requests with safe methods,
+// feature flag evaluations, and requests changing the maintenance mode are not.
+func (h *Handler) writeRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	_, pattern := h.mux.Handler(r)
+
+	return !strings.HasSuffix(pattern, "/evaluate") && !strings.HasSuffix(pattern, "/admin/maintenance")
+}
+
+// withMaintenance rejects writes with 503 Service Unavailable while the server is in read-only mode.
+func (h *Handler) withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maintenance := h.maintenance.Load()
+		if maintenance == nil || !maintenance.ReadOnly || !h.writeRequest(r) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		detail := "server is in read-only mode"
+		if maintenance.Reason != "" {
+			detail += ": " + maintenance.Reason
+		}
+
+		if maintenance.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(maintenance.RetryAfter))
+		}
+
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusServiceUnavailable),
+			problem.WithTitle("Service Unavailable"),
+			problem.WithDetail(detail),
+			withReason(apierrors.ReasonUnavailable),
+		))
+	})
+}
+
+// handleGetMaintenance returns the maintenance mode of the server.
+func (h *Handler) handleGetMaintenance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maintenance := h.maintenance.Load()
+		if maintenance == nil {
+			maintenance = &Maintenance{ReadOnly: false, RetryAfter: 0, Reason: ""}
+		}
+
+		respond.Done(w, r, maintenance)
+	}
+}
+
+// handlePutMaintenance sets the maintenance mode of the server, e.g. to make it read-only during a storage migration.
+func (h *Handler) handlePutMaintenance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var maintenance Maintenance
+
+		err := json.UnmarshalRead(r.Body, &maintenance)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		if maintenance.RetryAfter < 0 {
+			respond.Done(w, r, problem.BadRequest("retryAfter must not be negative", withReason(apierrors.ReasonInvalid)))
+
+			return
+		}
+
+		h.maintenance.Store(&maintenance)
+		h.logger.InfoContext(r.Context(), "maintenance mode changed", "readOnly", maintenance.ReadOnly, "reason", maintenance.Reason)
+
+		respond.Done(w, r, maintenance)
+	}
+}
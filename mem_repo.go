@@ -0,0 +1,731 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemRepo is an in-memory ResourcesRepository.
+//
+// Resources are sharded by package and resource type, and each shard has its own lock. Writes are serialized, and
+// change the shards they touch in place, holding their locks until they are done, so readers of other shards never
+// wait for them and readers of a shard never see part of a write. Failed writes are undone before their shards are
+// unlocked. Items are deep copied on the way in and out, so callers can't modify stored state through the pointers
+// they hold.
+//
+// Stored items are never changed, only replaced, so lists take a snapshot of the item pointers of a shard and deep copy
+// them after releasing its lock. The sorted items of a shard are kept as a snapshot until its next write is done, so
+// lists after the first one don't take the lock at all, and don't wait for writes in progress.
+type MemRepo struct {
+	// mu serializes writes, and guards the resource version and the outbox.
+	mu              sync.Mutex
+	resourceVersion uint64
+	outboxEvents    []OutboxEvent
+	outboxID        int64
+	outbox          bool
+
+	shardsMu sync.RWMutex
+	shards   map[memShardKey]*memShard
+}
+
+type MemRepoOption func(repo *MemRepo)
+
+// WithMemRepoOutbox makes the repository record an event of every write in its outbox, with the write, for
+// Handler.RunOutbox to dispatch.
+func WithMemRepoOutbox() MemRepoOption {
+	return func(repo *MemRepo) {
+		repo.outbox = true
+	}
+}
+
+var (
+	_ ResourcesRepository     = (*MemRepo)(nil)
+	_ TransactionalRepository = (*MemRepo)(nil)
+	_ ResourceCounter         = (*MemRepo)(nil)
+	_ StatsRepository         = (*MemRepo)(nil)
+	_ AtomicUpdater           = (*MemRepo)(nil)
+	_ LabelLister             = (*MemRepo)(nil)
+	_ RawGetter               = (*MemRepo)(nil)
+	_ OutboxRepository        = (*MemRepo)(nil)
+)
+
+type memShardKey struct {
+	packageName  string
+	resourceType string
+}
+
+type memLabel struct {
+	key   string
+	value string
+}
+
+type memShard struct {
+	mu sync.RWMutex
+	// sorted holds the items of the shard sorted by name as of its last write, or nil if it wasn't listed since.
+	sorted  atomic.Pointer[[]*Resource]
+	items   map[string]*Resource
+	encoded map[string]jsontext.Value
+	byUID   map[string]string
+	byLabel map[memLabel]map[string]struct{}
+}
+
+func NewMemRepo(opts ...MemRepoOption) *MemRepo {
+	repo := &MemRepo{
+		mu:              sync.Mutex{},
+		resourceVersion: 0,
+		outboxEvents:    nil,
+		outboxID:        0,
+		outbox:          false,
+		shardsMu:        sync.RWMutex{},
+		shards:          make(map[memShardKey]*memShard),
+	}
+
+	for _, opt := range opts {
+		opt(repo)
+	}
+
+	return repo
+}
+
+func (repo *MemRepo) List(_ context.Context, packageName, apiVersion, resourceType string) (ResourceList, error) {
+	items := repo.shard(packageName, resourceType).snapshot()
+
+	return newMemResourceList(packageName, apiVersion, resourceType, deepCopyItems(items)), nil
+}
+
+func (repo *MemRepo) ListByLabels(_ context.Context, packageName, apiVersion, resourceType string, labels map[string]string) (ResourceList, error) {
+	items := repo.shard(packageName, resourceType).snapshotByLabels(labels)
+
+	return newMemResourceList(packageName, apiVersion, resourceType, deepCopyItems(items)), nil
+}
+
+func (repo *MemRepo) Create(_ context.Context, item *Resource) error {
+	return repo.write(func(txn *memTxn) error {
+		return txn.create(item)
+	})
+}
+
+func (repo *MemRepo) Get(_ context.Context, packageName, resourceType, name string) (*Resource, error) {
+	shard := repo.shard(packageName, resourceType)
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	item, ok := shard.items[name]
+	if !ok {
+		return nil, ResourceNotFoundError{
+			PackageName:  packageName,
+			ResourceType: resourceType,
+			Name:         name,
+		}
+	}
+
+	return item.DeepCopy(), nil
+}
+
+// GetRaw returns the JSON encoding of the resource, made when it was written.
+func (repo *MemRepo) GetRaw(_ context.Context, packageName, resourceType, name string) (jsontext.Value, error) {
+	shard := repo.shard(packageName, resourceType)
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	encoded, ok := shard.encoded[name]
+	if !ok {
+		return nil, ResourceNotFoundError{
+			PackageName:  packageName,
+			ResourceType: resourceType,
+			Name:         name,
+		}
+	}
+
+	return encoded, nil
+}
+
+func (repo *MemRepo) GetByUID(_ context.Context, uid string) (*Resource, error) {
+	for _, shard := range repo.allShards() {
+		item := shard.getByUID(uid)
+		if item != nil {
+			return item, nil
+		}
+	}
+
+	return nil, ResourceUIDNotFoundError{UID: uid}
+}
+
+func (repo *MemRepo) Update(_ context.Context, item *Resource) error {
+	return repo.write(func(txn *memTxn) error {
+		return txn.update(item, Precondition{})
+	})
+}
+
+func (repo *MemRepo) Delete(_ context.Context, packageName, resourceType, name string) error {
+	return repo.write(func(txn *memTxn) error {
+		return txn.delete(packageName, resourceType, name, Precondition{})
+	})
+}
+
+func (repo *MemRepo) UpdateFunc(_ context.Context, packageName, resourceType, name string, fn func(item *Resource) error) (*Resource, error) {
+	var item *Resource
+
+	err := repo.write(func(txn *memTxn) error {
+		current, ok := txn.shard(packageName, resourceType).items[name]
+		if !ok {
+			return ResourceNotFoundError{
+				PackageName:  packageName,
+				ResourceType: resourceType,
+				Name:         name,
+			}
+		}
+
+		item = current.DeepCopy()
+
+		err := fn(item)
+		if err != nil {
+			return err
+		}
+
+		return txn.update(item, Precondition{UID: "", ResourceVersion: current.Metadata.ResourceVersion})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (repo *MemRepo) Transact(_ context.Context, ops []Operation) error {
+	return repo.write(func(txn *memTxn) error {
+		for i, op := range ops {
+			err := txn.apply(op)
+			if err != nil {
+				return TransactionError{Index: i, Err: err}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *MemRepo) CountResources(_ context.Context) ([]ResourceCount, error) {
+	shards := repo.allShards()
+	counts := make([]ResourceCount, 0, len(shards))
+
+	for key, shard := range shards {
+		shard.mu.RLock()
+		count := len(shard.items)
+		shard.mu.RUnlock()
+
+		// shards added by writes that failed are empty until they are removed
+		if count == 0 {
+			continue
+		}
+
+		counts = append(counts, ResourceCount{
+			PackageName:  key.packageName,
+			ResourceType: key.resourceType,
+			Count:        count,
+		})
+	}
+
+	return counts, nil
+}
+
+// Stats approximates the bytes of resources with the size of their JSON encoding.
+func (repo *MemRepo) Stats(_ context.Context) ([]ResourceTypeStats, error) {
+	shards := repo.allShards()
+	stats := make([]ResourceTypeStats, 0, len(shards))
+
+	for key, shard := range shards {
+		count, size := shard.size()
+		if count == 0 {
+			continue
+		}
+
+		stats = append(stats, ResourceTypeStats{
+			PackageName:  key.packageName,
+			ResourceType: key.resourceType,
+			Count:        count,
+			Bytes:        size,
+		})
+	}
+
+	return stats, nil
+}
+
+func (repo *MemRepo) PendingOutboxEvents(_ context.Context, limit int) ([]OutboxEvent, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	outbox := repo.outboxEvents
+	res := make([]OutboxEvent, 0, min(limit, len(outbox)))
+
+	for _, event := range outbox[:min(limit, len(outbox))] {
+		event.Item = event.Item.DeepCopy()
+		res = append(res, event)
+	}
+
+	return res, nil
+}
+
+func (repo *MemRepo) DeleteOutboxEvents(_ context.Context, ids []int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.outboxEvents = slices.DeleteFunc(repo.outboxEvents, func(event OutboxEvent) bool {
+		return slices.Contains(ids, event.ID)
+	})
+
+	return nil
+}
+
+func (repo *MemRepo) shard(packageName, resourceType string) *memShard {
+	repo.shardsMu.RLock()
+	defer repo.shardsMu.RUnlock()
+
+	shard, ok := repo.shards[memShardKey{packageName: packageName, resourceType: resourceType}]
+	if !ok {
+		return newMemShard()
+	}
+
+	return shard
+}
+
+// allShards returns the current shards. Their locks are taken after the shards lock is released, as writers take the
+// shards lock while holding shard locks.
+func (repo *MemRepo) allShards() map[memShardKey]*memShard {
+	repo.shardsMu.RLock()
+	defer repo.shardsMu.RUnlock()
+
+	return maps.Clone(repo.shards)
+}
+
+// write runs fn on a transaction that changes the shards it touches in place, holding their locks. If fn fails, its
+// changes are undone. Shards left empty are removed.
+func (repo *MemRepo) write(fn func(txn *memTxn) error) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	txn := &memTxn{
+		repo:            repo,
+		shards:          make(map[memShardKey]*memShard),
+		undo:            nil,
+		resourceVersion: repo.resourceVersion,
+		outbox:          nil,
+		outboxID:        repo.outboxID,
+		recordOutbox:    repo.outbox,
+	}
+
+	defer txn.unlock()
+
+	err := fn(txn)
+	if err != nil {
+		txn.rollback()
+
+		return err
+	}
+
+	repo.resourceVersion = txn.resourceVersion
+	repo.outboxEvents = append(repo.outboxEvents, txn.outbox...)
+	repo.outboxID = txn.outboxID
+
+	return nil
+}
+
+type memTxn struct {
+	repo            *MemRepo
+	shards          map[memShardKey]*memShard
+	undo            []memUndo
+	resourceVersion uint64
+	outbox          []OutboxEvent
+	outboxID        int64
+	recordOutbox    bool
+}
+
+// memUndo restores an item of a shard, or removes it if it was missing, as it was before a write.
+type memUndo struct {
+	shard   *memShard
+	name    string
+	item    *Resource
+	encoded jsontext.Value
+}
+
+// shard returns a shard for the transaction to change, locking it until the transaction is done. Missing shards are
+// added.
+func (txn *memTxn) shard(packageName, resourceType string) *memShard {
+	key := memShardKey{packageName: packageName, resourceType: resourceType}
+
+	shard, ok := txn.shards[key]
+	if ok {
+		return shard
+	}
+
+	txn.repo.shardsMu.Lock()
+
+	shard, ok = txn.repo.shards[key]
+	if !ok {
+		shard = newMemShard()
+		txn.repo.shards[key] = shard
+	}
+
+	txn.repo.shardsMu.Unlock()
+
+	shard.mu.Lock()
+
+	txn.shards[key] = shard
+
+	return shard
+}
+
+// put stores an item in a shard, remembering how to undo it.
+func (txn *memTxn) put(shard *memShard, item *Resource) error {
+	txn.remember(shard, item.Metadata.Name)
+
+	return shard.put(item)
+}
+
+// remove deletes an item from a shard, remembering how to undo it.
+func (txn *memTxn) remove(shard *memShard, name string) {
+	txn.remember(shard, name)
+
+	shard.delete(name)
+}
+
+func (txn *memTxn) remember(shard *memShard, name string) {
+	txn.undo = append(txn.undo, memUndo{shard: shard, name: name, item: shard.items[name], encoded: shard.encoded[name]})
+}
+
+// rollback undoes the writes of the transaction, latest first.
+func (txn *memTxn) rollback() {
+	for _, undo := range slices.Backward(txn.undo) {
+		undo.shard.delete(undo.name)
+
+		if undo.item != nil {
+			undo.shard.set(undo.item, undo.encoded)
+		}
+	}
+}
+
+// unlock unlocks the shards of the transaction, dropping their sorted snapshots and removing the ones left empty.
+func (txn *memTxn) unlock() {
+	for key, shard := range txn.shards {
+		if len(txn.undo) > 0 {
+			shard.sorted.Store(nil)
+		}
+
+		if len(shard.items) == 0 {
+			txn.repo.shardsMu.Lock()
+			delete(txn.repo.shards, key)
+			txn.repo.shardsMu.Unlock()
+		}
+
+		shard.mu.Unlock()
+	}
+}
+
+func (txn *memTxn) nextResourceVersion() string {
+	txn.resourceVersion++
+
+	return strconv.FormatUint(txn.resourceVersion, 10)
+}
+
+// recordWrite adds an event of a write to the outbox of the transaction, if the repository keeps one.
+func (txn *memTxn) recordWrite(operation OperationType, item *Resource) {
+	if !txn.recordOutbox {
+		return
+	}
+
+	txn.outboxID++
+	txn.outbox = append(txn.outbox, OutboxEvent{ID: txn.outboxID, Type: operation, At: time.Now(), Item: item.DeepCopy()})
+}
+
+func (txn *memTxn) apply(op Operation) error {
+	switch op.Type {
+	case OperationCreate:
+		return txn.create(op.Item)
+	case OperationUpdate:
+		return txn.update(op.Item, op.Precondition)
+	case OperationDelete:
+		return txn.delete(op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name, op.Precondition)
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+}
+
+func (txn *memTxn) create(item *Resource) error {
+	shard := txn.shard(item.Metadata.PackageName, item.Metadata.ResourceType)
+
+	_, ok := shard.items[item.Metadata.Name]
+	if ok {
+		return ResourceExistsError{
+			PackageName:  item.Metadata.PackageName,
+			ResourceType: item.Metadata.ResourceType,
+			Name:         item.Metadata.Name,
+		}
+	}
+
+	item.Metadata.ResourceVersion = txn.nextResourceVersion()
+
+	txn.recordWrite(OperationCreate, item)
+
+	return txn.put(shard, item.DeepCopy())
+}
+
+// update replaces a stored item. The UID and creation time of the stored item are kept. An item carrying a resource
+// version must match the stored one.
+func (txn *memTxn) update(item *Resource, precondition Precondition) error {
+	shard := txn.shard(item.Metadata.PackageName, item.Metadata.ResourceType)
+
+	current, ok := shard.items[item.Metadata.Name]
+	if !ok {
+		return ResourceNotFoundError{
+			PackageName:  item.Metadata.PackageName,
+			ResourceType: item.Metadata.ResourceType,
+			Name:         item.Metadata.Name,
+		}
+	}
+
+	err := CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	if item.Metadata.ResourceVersion != "" && item.Metadata.ResourceVersion != current.Metadata.ResourceVersion {
+		return ResourceVersionConflictError{
+			PackageName:     item.Metadata.PackageName,
+			ResourceType:    item.Metadata.ResourceType,
+			Name:            item.Metadata.Name,
+			ResourceVersion: item.Metadata.ResourceVersion,
+		}
+	}
+
+	item.Metadata.UID = current.Metadata.UID
+	item.Metadata.CreatedAt = current.Metadata.CreatedAt
+	item.Metadata.ResourceVersion = txn.nextResourceVersion()
+
+	txn.recordWrite(OperationUpdate, item)
+
+	return txn.put(shard, item.DeepCopy())
+}
+
+func (txn *memTxn) delete(packageName, resourceType, name string, precondition Precondition) error {
+	shard := txn.shard(packageName, resourceType)
+
+	current, ok := shard.items[name]
+	if !ok {
+		return ResourceNotFoundError{
+			PackageName:  packageName,
+			ResourceType: resourceType,
+			Name:         name,
+		}
+	}
+
+	err := CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	txn.recordWrite(OperationDelete, current)
+
+	txn.remove(shard, name)
+
+	return nil
+}
+
+func newMemShard() *memShard {
+	return &memShard{
+		mu:      sync.RWMutex{},
+		sorted:  atomic.Pointer[[]*Resource]{},
+		items:   make(map[string]*Resource),
+		encoded: make(map[string]jsontext.Value),
+		byUID:   make(map[string]string),
+		byLabel: make(map[memLabel]map[string]struct{}),
+	}
+}
+
+// put stores the item with its JSON encoding.
+func (shard *memShard) put(item *Resource) error {
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource: %w", err)
+	}
+
+	shard.delete(item.Metadata.Name)
+	shard.set(item, encoded)
+
+	return nil
+}
+
+// set indexes an item missing from the shard, with its JSON encoding.
+func (shard *memShard) set(item *Resource, encoded jsontext.Value) {
+	shard.items[item.Metadata.Name] = item
+	shard.encoded[item.Metadata.Name] = encoded
+
+	if item.Metadata.UID != "" {
+		shard.byUID[item.Metadata.UID] = item.Metadata.Name
+	}
+
+	for key, value := range item.Metadata.Labels {
+		label := memLabel{key: key, value: value}
+
+		names, ok := shard.byLabel[label]
+		if !ok {
+			names = make(map[string]struct{})
+			shard.byLabel[label] = names
+		}
+
+		names[item.Metadata.Name] = struct{}{}
+	}
+}
+
+func (shard *memShard) delete(name string) {
+	item, ok := shard.items[name]
+	if !ok {
+		return
+	}
+
+	delete(shard.items, name)
+	delete(shard.encoded, name)
+	delete(shard.byUID, item.Metadata.UID)
+
+	for key, value := range item.Metadata.Labels {
+		label := memLabel{key: key, value: value}
+
+		delete(shard.byLabel[label], name)
+
+		if len(shard.byLabel[label]) == 0 {
+			delete(shard.byLabel, label)
+		}
+	}
+}
+
+func (shard *memShard) getByUID(uid string) *Resource {
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	name, ok := shard.byUID[uid]
+	if !ok {
+		return nil
+	}
+
+	return shard.items[name].DeepCopy()
+}
+
+// size returns the number of items of the shard, and the bytes of their JSON encodings.
+func (shard *memShard) size() (int, int64) {
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	var size int64
+
+	for _, encoded := range shard.encoded {
+		size += int64(len(encoded))
+	}
+
+	return len(shard.items), size
+}
+
+// snapshot returns the stored items of the shard sorted by name, which must not be changed. They are sorted once after
+// every write, under the read lock, and shared by the lists until the next write.
+func (shard *memShard) snapshot() []*Resource {
+	sorted := shard.sorted.Load()
+	if sorted != nil {
+		return *sorted
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	items := shard.sort(slices.Collect(maps.Keys(shard.items)))
+	shard.sorted.Store(&items)
+
+	return items
+}
+
+// snapshotByLabels returns the stored items of the shard matching all the given labels sorted by name, which must not
+// be changed.
+func (shard *memShard) snapshotByLabels(labels map[string]string) []*Resource {
+	if len(labels) == 0 {
+		return shard.snapshot()
+	}
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	return shard.sort(shard.matchLabels(labels))
+}
+
+// sort returns the stored items of the given names sorted by name.
+func (shard *memShard) sort(names []string) []*Resource {
+	slices.Sort(names)
+
+	res := make([]*Resource, 0, len(names))
+
+	for _, name := range names {
+		res = append(res, shard.items[name])
+	}
+
+	return res
+}
+
+// matchLabels intersects the label index sets, starting from the smallest one.
+func (shard *memShard) matchLabels(labels map[string]string) []string {
+	sets := make([]map[string]struct{}, 0, len(labels))
+
+	for key, value := range labels {
+		names, ok := shard.byLabel[memLabel{key: key, value: value}]
+		if !ok {
+			return nil
+		}
+
+		sets = append(sets, names)
+	}
+
+	slices.SortFunc(sets, func(a, b map[string]struct{}) int {
+		return len(a) - len(b)
+	})
+
+	res := make([]string, 0, len(sets[0]))
+
+	for name := range sets[0] {
+		if !slices.ContainsFunc(sets[1:], func(set map[string]struct{}) bool {
+			_, ok := set[name]
+
+			return !ok
+		}) {
+			res = append(res, name)
+		}
+	}
+
+	return res
+}
+
+func deepCopyItems(items []*Resource) []*Resource {
+	res := make([]*Resource, 0, len(items))
+
+	for _, item := range items {
+		res = append(res, item.DeepCopy())
+	}
+
+	return res
+}
+
+func newMemResourceList(packageName, apiVersion, resourceType string, items []*Resource) ResourceList {
+	return ResourceList{
+		Metadata: ListMetadata{
+			PackageName:  packageName,
+			APIVersion:   apiVersion,
+			ResourceType: resourceType + "List",
+			Continue:     "",
+		},
+		Items: items,
+	}
+}
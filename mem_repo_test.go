@@ -0,0 +1,484 @@
+package bass_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"testing/quick"
+
+	"github.com/nasermirzaei89/bass"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemRepo(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	repo := bass.NewMemRepo()
+
+	newFoo := func(name, uid string, labels map[string]string) *bass.Resource {
+		return &bass.Resource{
+			Metadata: bass.Metadata{
+				UID:          uid,
+				PackageName:  "test",
+				APIVersion:   "v1",
+				ResourceType: "Foo",
+				Name:         name,
+				Labels:       labels,
+			},
+			Properties: map[string]any{},
+		}
+	}
+
+	// create items
+	{
+		require.NoError(t, repo.Create(ctx, newFoo("foo2", "uid2", map[string]string{"env": "prod", "tier": "web"})))
+		require.NoError(t, repo.Create(ctx, newFoo("foo1", "uid1", map[string]string{"env": "prod", "tier": "db"})))
+		require.NoError(t, repo.Create(ctx, newFoo("foo3", "uid3", map[string]string{"env": "dev", "tier": "web"})))
+
+		err := repo.Create(ctx, newFoo("foo1", "uid4", nil))
+
+		var resourceExistsError bass.ResourceExistsError
+
+		require.ErrorAs(t, err, &resourceExistsError)
+	}
+
+	// list is sorted and scoped to resource type
+	{
+		require.NoError(t, repo.Create(ctx, &bass.Resource{
+			Metadata: bass.Metadata{PackageName: "test", ResourceType: "Bar", Name: "bar1"},
+		}))
+
+		res, err := repo.List(ctx, "test", "v1", "Foo")
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 3)
+		assert.Equal(t, "foo1", res.Items[0].Metadata.Name)
+		assert.Equal(t, "foo2", res.Items[1].Metadata.Name)
+		assert.Equal(t, "foo3", res.Items[2].Metadata.Name)
+	}
+
+	// list by labels
+	{
+		res, err := repo.ListByLabels(ctx, "test", "v1", "Foo", map[string]string{"env": "prod", "tier": "web"})
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, "foo2", res.Items[0].Metadata.Name)
+
+		res, err = repo.ListByLabels(ctx, "test", "v1", "Foo", map[string]string{"env": "staging"})
+		require.NoError(t, err)
+
+		assert.NotNil(t, res.Items)
+		assert.Empty(t, res.Items)
+	}
+
+	// get by uid
+	{
+		item, err := repo.GetByUID(ctx, "uid3")
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo3", item.Metadata.Name)
+
+		_, err = repo.GetByUID(ctx, "uid404")
+
+		var resourceUIDNotFoundError bass.ResourceUIDNotFoundError
+
+		require.ErrorAs(t, err, &resourceUIDNotFoundError)
+	}
+
+	// update reindexes labels
+	{
+		require.NoError(t, repo.Update(ctx, newFoo("foo3", "uid3", map[string]string{"env": "prod"})))
+
+		res, err := repo.ListByLabels(ctx, "test", "v1", "Foo", map[string]string{"env": "prod"})
+		require.NoError(t, err)
+
+		assert.Len(t, res.Items, 3)
+
+		res, err = repo.ListByLabels(ctx, "test", "v1", "Foo", map[string]string{"tier": "web"})
+		require.NoError(t, err)
+
+		assert.Len(t, res.Items, 1)
+	}
+
+	// list snapshot is not affected by later writes
+	{
+		before, err := repo.List(ctx, "test", "v1", "Foo")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Delete(ctx, "test", "Foo", "foo1"))
+
+		after, err := repo.List(ctx, "test", "v1", "Foo")
+		require.NoError(t, err)
+
+		assert.Len(t, before.Items, 3)
+		assert.Len(t, after.Items, 2)
+
+		_, err = repo.GetByUID(ctx, "uid1")
+		require.Error(t, err)
+	}
+
+	// stored items are isolated from callers
+	{
+		item := newFoo("foo4", "uid4", map[string]string{"env": "dev"})
+		item.Properties["nested"] = map[string]any{"tags": []any{"a"}}
+
+		require.NoError(t, repo.Create(ctx, item))
+
+		item.Metadata.Labels["env"] = "prod"
+		item.Properties["nested"].(map[string]any)["tags"].([]any)[0] = "changed"
+
+		got, err := repo.Get(ctx, "test", "Foo", "foo4")
+		require.NoError(t, err)
+
+		assert.Equal(t, "dev", got.Metadata.Labels["env"])
+		assert.Equal(t, "a", got.Properties["nested"].(map[string]any)["tags"].([]any)[0])
+
+		got.Properties["nested"] = nil
+
+		again, err := repo.Get(ctx, "test", "Foo", "foo4")
+		require.NoError(t, err)
+
+		assert.NotNil(t, again.Properties["nested"])
+	}
+
+	// failed transactions are undone
+	{
+		updated := newFoo("foo4", "uid4", map[string]string{"env": "prod"})
+
+		err := repo.Transact(ctx, []bass.Operation{
+			{Type: bass.OperationCreate, Item: &bass.Resource{Metadata: bass.Metadata{PackageName: "test", ResourceType: "Baz", Name: "baz1"}}},
+			{Type: bass.OperationUpdate, Item: updated},
+			{Type: bass.OperationDelete, Item: newFoo("foo2", "", nil)},
+			{Type: bass.OperationDelete, Item: newFoo("foo404", "", nil)},
+		})
+
+		var resourceNotFoundError bass.ResourceNotFoundError
+
+		require.ErrorAs(t, err, &resourceNotFoundError)
+
+		_, err = repo.Get(ctx, "test", "Baz", "baz1")
+		require.ErrorAs(t, err, &resourceNotFoundError)
+
+		got, err := repo.Get(ctx, "test", "Foo", "foo4")
+		require.NoError(t, err)
+
+		assert.Equal(t, "dev", got.Metadata.Labels["env"])
+
+		res, err := repo.ListByLabels(ctx, "test", "v1", "Foo", map[string]string{"env": "dev"})
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, "foo4", res.Items[0].Metadata.Name)
+
+		got, err = repo.GetByUID(ctx, "uid2")
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo2", got.Metadata.Name)
+
+		counts, err := repo.CountResources(ctx)
+		require.NoError(t, err)
+
+		assert.Len(t, counts, 2)
+	}
+
+	// lists don't wait for writes in progress, and see them once they are done
+	{
+		_, err := repo.List(ctx, "test", "v1", "Foo")
+		require.NoError(t, err)
+
+		writing := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+
+		go func() {
+			_, err := repo.UpdateFunc(ctx, "test", "Foo", "foo4", func(item *bass.Resource) error {
+				item.Metadata.Labels["env"] = "staging"
+
+				close(writing)
+				<-release
+
+				return nil
+			})
+
+			done <- err
+		}()
+
+		<-writing
+
+		res, err := repo.List(ctx, "test", "v1", "Foo")
+		require.NoError(t, err)
+
+		close(release)
+		require.NoError(t, <-done)
+
+		require.Len(t, res.Items, 3)
+		assert.Equal(t, "foo4", res.Items[2].Metadata.Name)
+		assert.Equal(t, "dev", res.Items[2].Metadata.Labels["env"])
+
+		res, err = repo.List(ctx, "test", "v1", "Foo")
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 3)
+		assert.Equal(t, "staging", res.Items[2].Metadata.Labels["env"])
+	}
+}
+
+func TestMemRepoModel(t *testing.T) {
+	t.Parallel()
+
+	checkRepositoryModel(t, func() bass.ResourcesRepository { return bass.NewMemRepo() })
+}
+
+// repoModelOp is a random operation of a repository model test. Its fields are drawn from small ranges, so
+// operations collide on the same items.
+type repoModelOp struct {
+	Kind  uint8
+	Type  uint8
+	Name  uint8
+	Value int16
+	Stale bool
+}
+
+type repoModelItem struct {
+	value           float64
+	resourceVersion string
+}
+
+// repoModel is the reference model of a repository: items by resource type and name.
+type repoModel map[string]map[string]repoModelItem
+
+func (model repoModel) merge(other repoModel) {
+	for resourceType, items := range other {
+		if model[resourceType] == nil {
+			model[resourceType] = make(map[string]repoModelItem)
+		}
+
+		maps.Copy(model[resourceType], items)
+	}
+}
+
+// checkRepositoryModel applies random sequences of creates, gets, updates, deletes, and lists to repositories, and
+// checks each result against a reference map. It also runs sequences concurrently, on disjoint names of the same
+// resource types, and checks the repository holds the union of their models. It can be run against any
+// ResourcesRepository.
+func checkRepositoryModel(t *testing.T, newRepo func() bass.ResourcesRepository) {
+	t.Helper()
+
+	resourceTypes := []string{"Foo", "Bar"}
+	names := []string{"a", "b", "c", "d"}
+
+	checkRepositoryModelSequential(t, newRepo, resourceTypes, names)
+	checkRepositoryModelConcurrent(t, newRepo, resourceTypes, names)
+}
+
+func checkRepositoryModelSequential(t *testing.T, newRepo func() bass.ResourcesRepository, resourceTypes, names []string) {
+	t.Helper()
+
+	err := quick.Check(func(ops []repoModelOp) bool {
+		repo := newRepo()
+		model := repoModel{}
+
+		for i, op := range ops {
+			msg := checkRepositoryModelOp(t.Context(), repo, model, resourceTypes[int(op.Type)%len(resourceTypes)], names[int(op.Name)%len(names)], op)
+			if msg != "" {
+				t.Logf("operation %d of %+v: %s", i, ops, msg)
+
+				return false
+			}
+		}
+
+		return true
+	}, &quick.Config{MaxCount: 500})
+	require.NoError(t, err)
+}
+
+func checkRepositoryModelConcurrent(t *testing.T, newRepo func() bass.ResourcesRepository, resourceTypes, names []string) {
+	t.Helper()
+
+	err := quick.Check(func(ops [4][]repoModelOp) bool {
+		repo := newRepo()
+		models := make([]repoModel, len(ops))
+		msgs := make([]string, len(ops))
+
+		var wg sync.WaitGroup
+
+		for worker := range ops {
+			models[worker] = repoModel{}
+
+			wg.Go(func() {
+				for _, op := range ops[worker] {
+					// lists hold the items of the other workers too, so they are checked after all workers finish
+					if op.Kind%5 == 4 {
+						continue
+					}
+
+					name := fmt.Sprintf("%s-%d", names[int(op.Name)%len(names)], worker)
+
+					msgs[worker] = checkRepositoryModelOp(t.Context(), repo, models[worker], resourceTypes[int(op.Type)%len(resourceTypes)], name, op)
+					if msgs[worker] != "" {
+						return
+					}
+				}
+			})
+		}
+
+		wg.Wait()
+
+		for worker, msg := range msgs {
+			if msg != "" {
+				t.Logf("worker %d of %+v: %s", worker, ops, msg)
+
+				return false
+			}
+		}
+
+		union := repoModel{}
+
+		for _, model := range models {
+			union.merge(model)
+		}
+
+		for _, resourceType := range resourceTypes {
+			msg := checkRepositoryModelList(t.Context(), repo, union, resourceType)
+			if msg != "" {
+				t.Logf("%+v: %s", ops, msg)
+
+				return false
+			}
+		}
+
+		return true
+	}, &quick.Config{MaxCount: 100})
+	require.NoError(t, err)
+}
+
+// checkRepositoryModelOp applies an operation to a repository and its model, and returns how their results differ,
+// if they do.
+func checkRepositoryModelOp(ctx context.Context, repo bass.ResourcesRepository, model repoModel, resourceType, name string, op repoModelOp) string {
+	current, exists := model[resourceType][name]
+
+	item := &bass.Resource{
+		Metadata:   bass.Metadata{PackageName: "test", APIVersion: "v1", ResourceType: resourceType, Name: name, UID: name},
+		Properties: map[string]any{"value": float64(op.Value)},
+	}
+
+	var (
+		resourceExistsError          bass.ResourceExistsError
+		resourceNotFoundError        bass.ResourceNotFoundError
+		resourceVersionConflictError bass.ResourceVersionConflictError
+	)
+
+	switch op.Kind % 5 {
+	case 0:
+		err := repo.Create(ctx, item)
+
+		switch {
+		case exists && !errors.As(err, &resourceExistsError):
+			return fmt.Sprintf("create of existing %s/%s: got %v, want exists error", resourceType, name, err)
+		case !exists && err != nil:
+			return fmt.Sprintf("create of %s/%s: %v", resourceType, name, err)
+		case !exists:
+			if model[resourceType] == nil {
+				model[resourceType] = make(map[string]repoModelItem)
+			}
+
+			model[resourceType][name] = repoModelItem{value: float64(op.Value), resourceVersion: item.Metadata.ResourceVersion}
+		}
+	case 1:
+		got, err := repo.Get(ctx, "test", resourceType, name)
+
+		return checkRepositoryModelItem(resourceType, name, current, exists, got, err)
+	case 2:
+		if op.Stale {
+			item.Metadata.ResourceVersion = "stale"
+		}
+
+		err := repo.Update(ctx, item)
+
+		switch {
+		case !exists && !errors.As(err, &resourceNotFoundError):
+			return fmt.Sprintf("update of missing %s/%s: got %v, want not found error", resourceType, name, err)
+		case exists && op.Stale && !errors.As(err, &resourceVersionConflictError):
+			return fmt.Sprintf("stale update of %s/%s: got %v, want conflict error", resourceType, name, err)
+		case exists && !op.Stale && err != nil:
+			return fmt.Sprintf("update of %s/%s: %v", resourceType, name, err)
+		case exists && !op.Stale:
+			if item.Metadata.ResourceVersion == current.resourceVersion {
+				return fmt.Sprintf("update of %s/%s kept resource version %q", resourceType, name, current.resourceVersion)
+			}
+
+			model[resourceType][name] = repoModelItem{value: float64(op.Value), resourceVersion: item.Metadata.ResourceVersion}
+		}
+	case 3:
+		err := repo.Delete(ctx, "test", resourceType, name)
+
+		switch {
+		case !exists && !errors.As(err, &resourceNotFoundError):
+			return fmt.Sprintf("delete of missing %s/%s: got %v, want not found error", resourceType, name, err)
+		case exists && err != nil:
+			return fmt.Sprintf("delete of %s/%s: %v", resourceType, name, err)
+		case exists:
+			delete(model[resourceType], name)
+		}
+	default:
+		return checkRepositoryModelList(ctx, repo, model, resourceType)
+	}
+
+	return ""
+}
+
+func checkRepositoryModelItem(resourceType, name string, want repoModelItem, exists bool, got *bass.Resource, err error) string {
+	var resourceNotFoundError bass.ResourceNotFoundError
+
+	switch {
+	case !exists && !errors.As(err, &resourceNotFoundError):
+		return fmt.Sprintf("get of missing %s/%s: got %v, want not found error", resourceType, name, err)
+	case !exists:
+		return ""
+	case err != nil:
+		return fmt.Sprintf("get of %s/%s: %v", resourceType, name, err)
+	case got.Metadata.Name != name || got.Metadata.ResourceType != resourceType || got.Metadata.UID != name:
+		return fmt.Sprintf("get of %s/%s: got metadata %+v", resourceType, name, got.Metadata)
+	case got.Metadata.ResourceVersion != want.resourceVersion:
+		return fmt.Sprintf("get of %s/%s: got resource version %q, want %q", resourceType, name, got.Metadata.ResourceVersion, want.resourceVersion)
+	case got.Properties["value"] != want.value:
+		return fmt.Sprintf("get of %s/%s: got value %v, want %v", resourceType, name, got.Properties["value"], want.value)
+	default:
+		return ""
+	}
+}
+
+// checkRepositoryModelList checks a list of a resource type holds the items of the model in order of name.
+func checkRepositoryModelList(ctx context.Context, repo bass.ResourcesRepository, model repoModel, resourceType string) string {
+	list, err := repo.List(ctx, "test", "v1", resourceType)
+	if err != nil {
+		return fmt.Sprintf("list of %s: %v", resourceType, err)
+	}
+
+	want := slices.Sorted(maps.Keys(model[resourceType]))
+	got := make([]string, 0, len(list.Items))
+
+	for _, item := range list.Items {
+		got = append(got, item.Metadata.Name)
+	}
+
+	if !slices.Equal(got, want) {
+		return fmt.Sprintf("list of %s: got names %v, want %v", resourceType, got, want)
+	}
+
+	for _, item := range list.Items {
+		msg := checkRepositoryModelItem(resourceType, item.Metadata.Name, model[resourceType][item.Metadata.Name], true, item, nil)
+		if msg != "" {
+			return msg
+		}
+	}
+
+	return ""
+}
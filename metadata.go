@@ -1,19 +1,8 @@
 package bass
 
-import "time"
+import apiv1 "github.com/nasermirzaei89/bass/api/v1"
 
-type Metadata struct {
-	UID          string    `json:"uid"`
-	PackageName  string    `json:"packageName"`
-	APIVersion   string    `json:"apiVersion"`
-	ResourceType string    `json:"resourceType"`
-	Name         string    `json:"name"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
-}
-
-type ListMetadata struct {
-	PackageName  string `json:"packageName"`
-	APIVersion   string `json:"apiVersion"`
-	ResourceType string `json:"resourceType"`
-}
+type (
+	Metadata     = apiv1.Metadata
+	ListMetadata = apiv1.ListMetadata
+)
@@ -0,0 +1,92 @@
+package bass
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type MeterOperation string
+
+const (
+	MeterOperationRead  MeterOperation = "read"
+	MeterOperationWrite MeterOperation = "write"
+)
+
+// MeterEvent is a billable request.
+type MeterEvent struct {
+	Time time.Time
+	// Subject identifies who the request is billed to, as resolved by the function given to WithMeterSubject.
+	Subject string
+	// PackageName is empty for requests not scoped to a package, like transactions.
+	PackageName        string
+	ResourceTypePlural string
+	Operation          MeterOperation
+	Status             int
+	// EgressBytes is the size of the response body as sent, after compression.
+	EgressBytes int64
+}
+
+// MeterSink receives the events of metered requests. Record is called synchronously after the response is written,
+// so implementations should buffer or hand events off instead of blocking.
+type MeterSink interface {
+	Record(ctx context.Context, event MeterEvent)
+}
+
+// withMetering records a MeterEvent for each request to the meter sink, if any.
+func (h *Handler) withMetering(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.meterSink == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		mw := &meterResponseWriter{ResponseWriter: w, status: http.StatusOK, bytes: 0}
+
+		next.ServeHTTP(mw, r)
+
+		operation := MeterOperationWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			operation = MeterOperationRead
+		}
+
+		subject := ""
+		if h.meterSubject != nil {
+			subject = h.meterSubject(r)
+		}
+
+		h.meterSink.Record(r.Context(), MeterEvent{
+			Time:               h.now(),
+			Subject:            subject,
+			PackageName:        r.PathValue("packageName"),
+			ResourceTypePlural: r.PathValue("resourceTypePlural"),
+			Operation:          operation,
+			Status:             mw.status,
+			EgressBytes:        mw.bytes,
+		})
+	})
+}
+
+type meterResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+	bytes  int64
+}
+
+func (w *meterResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *meterResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+func (w *meterResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,153 @@
+package bass
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nasermirzaei89/respond"
+)
+
+// ResourceCount is the number of stored resources of a resource type.
+type ResourceCount struct {
+	PackageName  string
+	ResourceType string
+	Count        int
+}
+
+// ResourceCounter is implemented by repositories that can count stored resources per resource type cheaply, without
+// scanning them. It backs the resource count gauges of the metrics endpoint.
+type ResourceCounter interface {
+	CountResources(ctx context.Context) (counts []ResourceCount, err error)
+}
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+type metricsWriteKey struct {
+	packageName  string
+	resourceType string
+	operation    OperationType
+}
+
+type metricsDeprecatedUsageKey struct {
+	packageName  string
+	resourceType string
+	kind         string
+	name         string
+}
+
+type metrics struct {
+	mu              sync.Mutex
+	writes          map[metricsWriteKey]uint64
+	deprecatedUsage map[metricsDeprecatedUsageKey]uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		mu:              sync.Mutex{},
+		writes:          make(map[metricsWriteKey]uint64),
+		deprecatedUsage: make(map[metricsDeprecatedUsageKey]uint64),
+	}
+}
+
+func (m *metrics) recordWrite(packageName, resourceType string, operation OperationType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.writes[metricsWriteKey{packageName: packageName, resourceType: resourceType, operation: operation}]++
+}
+
+func (m *metrics) recordDeprecatedUsage(packageName, resourceType, kind, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deprecatedUsage[metricsDeprecatedUsageKey{packageName: packageName, resourceType: resourceType, kind: kind, name: name}]++
+}
+
+// handleMetrics exposes per resource type gauges of stored resources and counters of writes in the OpenMetrics text
+// format, and counters of deprecated usage.
+func (h *Handler) handleMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sb strings.Builder
+
+		counter, ok := h.repo.(ResourceCounter)
+		if ok {
+			counts, err := counter.CountResources(r.Context())
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "failed to count resources", "error", err)
+				respond.Done(w, r, serverError(err))
+
+				return
+			}
+
+			slices.SortFunc(counts, func(a, b ResourceCount) int {
+				return cmp.Or(cmp.Compare(a.PackageName, b.PackageName), cmp.Compare(a.ResourceType, b.ResourceType))
+			})
+
+			sb.WriteString("# TYPE bass_resources gauge\n")
+			sb.WriteString("# HELP bass_resources Number of stored resources.\n")
+
+			for _, count := range counts {
+				fmt.Fprintf(&sb, "bass_resources{package=%s,resource_type=%s} %d\n",
+					openMetricsLabelValue(count.PackageName), openMetricsLabelValue(count.ResourceType), count.Count)
+			}
+		}
+
+		h.metrics.mu.Lock()
+
+		keys := slices.SortedFunc(maps.Keys(h.metrics.writes), func(a, b metricsWriteKey) int {
+			return cmp.Or(
+				cmp.Compare(a.packageName, b.packageName),
+				cmp.Compare(a.resourceType, b.resourceType),
+				cmp.Compare(a.operation, b.operation),
+			)
+		})
+
+		sb.WriteString("# TYPE bass_resource_writes counter\n")
+		sb.WriteString("# HELP bass_resource_writes Number of resource writes.\n")
+
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "bass_resource_writes_total{package=%s,resource_type=%s,operation=%s} %d\n",
+				openMetricsLabelValue(key.packageName), openMetricsLabelValue(key.resourceType),
+				openMetricsLabelValue(string(key.operation)), h.metrics.writes[key])
+		}
+
+		deprecatedUsageKeys := slices.SortedFunc(maps.Keys(h.metrics.deprecatedUsage), func(a, b metricsDeprecatedUsageKey) int {
+			return cmp.Or(
+				cmp.Compare(a.packageName, b.packageName),
+				cmp.Compare(a.resourceType, b.resourceType),
+				cmp.Compare(a.kind, b.kind),
+				cmp.Compare(a.name, b.name),
+			)
+		})
+
+		sb.WriteString("# TYPE bass_deprecated_usage counter\n")
+		sb.WriteString("# HELP bass_deprecated_usage Number of requests using deprecated API versions or properties.\n")
+
+		for _, key := range deprecatedUsageKeys {
+			fmt.Fprintf(&sb, "bass_deprecated_usage_total{package=%s,resource_type=%s,kind=%s,name=%s} %d\n",
+				openMetricsLabelValue(key.packageName), openMetricsLabelValue(key.resourceType),
+				openMetricsLabelValue(key.kind), openMetricsLabelValue(key.name), h.metrics.deprecatedUsage[key])
+		}
+
+		h.metrics.mu.Unlock()
+
+		sb.WriteString("# EOF\n")
+
+		w.Header().Set("Content-Type", openMetricsContentType)
+
+		_, err := w.Write([]byte(sb.String()))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to write metrics", "error", err)
+		}
+	}
+}
+
+func openMetricsLabelValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
@@ -0,0 +1,297 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	ModerationPolicy  = apiv1.ModerationPolicy
+	ModerationAction  = apiv1.ModerationAction
+	ModerationFinding = apiv1.ModerationFinding
+)
+
+const (
+	ModerationActionReject     = apiv1.ModerationActionReject
+	ModerationActionQuarantine = apiv1.ModerationActionQuarantine
+	ModerationActionFlag       = apiv1.ModerationActionFlag
+)
+
+const (
+	// moderationFlaggedLabel labels items whose content a flagging moderation policy objects to.
+	moderationFlaggedLabel = "bass.moderation/flagged"
+	// moderationQuarantinedLabel labels the change requests holding quarantined writes.
+	moderationQuarantinedLabel = "bass.moderation/quarantined"
+)
+
+// ModerationClassifier classifies content for moderation policies naming it, e.g. by calling an external moderation
+// service.
+type ModerationClassifier interface {
+	// Classify returns the categories of objectionable content text falls in, if any.
+	Classify(ctx context.Context, text string) ([]string, error)
+}
+
+type ModerationClassifierNotFoundError struct {
+	Name string
+}
+
+func (err ModerationClassifierNotFoundError) Error() string {
+	return fmt.Sprintf("moderation classifier %q is not configured", err.Name)
+}
+
+type ModerationClassifierError struct {
+	Name string
+	Err  error
+}
+
+func (err ModerationClassifierError) Error() string {
+	return fmt.Sprintf("moderation classifier %q failed: %s", err.Name, err.Err)
+}
+
+func (err ModerationClassifierError) Unwrap() error {
+	return err.Err
+}
+
+// ContentRejectedError is returned for writes whose content a moderation policy objects to, unless the policy flags
+// them.
+type ContentRejectedError struct {
+	PackageName  string
+	ResourceType string
+	Action       ModerationAction
+	Findings     []ModerationFinding
+}
+
+func (err ContentRejectedError) Error() string {
+	findings := make([]string, 0, len(err.Findings))
+	for _, finding := range err.Findings {
+		findings = append(findings, fmt.Sprintf("property %q matches %s %q", finding.Property, finding.Rule, finding.Category))
+	}
+
+	if err.Action == ModerationActionQuarantine {
+		return fmt.Sprintf("content of resource type %q of package %q must be reviewed, and can't be written in a transaction: %s", err.ResourceType, err.PackageName, strings.Join(findings, "; "))
+	}
+
+	return fmt.Sprintf("content of resource type %q of package %q is rejected: %s", err.ResourceType, err.PackageName, strings.Join(findings, "; "))
+}
+
+func moderationPolicyFromProperties(name string, properties map[string]any) (*ModerationPolicy, error) {
+	value, ok := properties["moderation"]
+	if !ok {
+		return nil, nil //nolint:nilnil // not moderated
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal moderation property: %w", err)
+	}
+
+	var moderation ModerationPolicy
+
+	err = json.Unmarshal(b, &moderation)
+	if err != nil {
+		return nil, fmt.Errorf("resource type %q has invalid moderation property: %w", name, err)
+	}
+
+	return &moderation, nil
+}
+
+// moderationTexts returns the strings of a property value, including those nested in arrays and objects.
+func moderationTexts(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		texts := make([]string, 0, len(value))
+		for _, v := range value {
+			texts = append(texts, moderationTexts(v)...)
+		}
+
+		return texts
+	case map[string]any:
+		texts := make([]string, 0, len(value))
+		for _, v := range value {
+			texts = append(texts, moderationTexts(v)...)
+		}
+
+		return texts
+	default:
+		return nil
+	}
+}
+
+// moderationFindings checks the moderated properties of item against the banned words, patterns, and classifiers of
+// a moderation policy.
+func (h *Handler) moderationFindings(ctx context.Context, moderation *ModerationPolicy, item *Resource) ([]ModerationFinding, error) {
+	findings := make([]ModerationFinding, 0)
+
+	for _, property := range moderation.Properties {
+		text := strings.Join(moderationTexts(item.Properties[property]), "\n")
+		if text == "" {
+			continue
+		}
+
+		for _, word := range moderation.BannedWords {
+			if regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(word) + `\b`).MatchString(text) {
+				findings = append(findings, ModerationFinding{Property: property, Rule: "bannedWord", Category: word})
+			}
+		}
+
+		for _, pattern := range moderation.Patterns {
+			// Patterns are checked as the resource type definition is written.
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile moderation pattern: %w", err)
+			}
+
+			if re.MatchString(text) {
+				findings = append(findings, ModerationFinding{Property: property, Rule: "pattern", Category: pattern})
+			}
+		}
+
+		for _, name := range moderation.Classifiers {
+			classifier, ok := h.moderationClassifiers[name]
+			if !ok {
+				return nil, ModerationClassifierNotFoundError{Name: name}
+			}
+
+			categories, err := classifier.Classify(ctx, text)
+			if err != nil {
+				return nil, ModerationClassifierError{Name: name, Err: err}
+			}
+
+			for _, category := range categories {
+				findings = append(findings, ModerationFinding{Property: property, Rule: name, Category: category})
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// moderateItem checks item against the moderation policy of its resource type. Items a flagging policy objects to are
+// labeled as flagged, and the label is cleared from the others. Otherwise, the findings of the policy are returned.
+func (h *Handler) moderateItem(ctx context.Context, resourceTypeDefinition *ResourceTypeDefinition, item *Resource) ([]ModerationFinding, error) {
+	moderation := resourceTypeDefinition.Moderation
+	if moderation == nil {
+		return nil, nil
+	}
+
+	findings, err := h.moderationFindings(ctx, moderation, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(findings) == 0 || moderation.Action == ModerationActionFlag {
+		delete(item.Metadata.Labels, moderationFlaggedLabel)
+
+		if len(findings) > 0 {
+			if item.Metadata.Labels == nil {
+				item.Metadata.Labels = make(map[string]string)
+			}
+
+			item.Metadata.Labels[moderationFlaggedLabel] = "true"
+		}
+
+		return nil, nil
+	}
+
+	return findings, nil
+}
+
+// moderateOperation moderates the item of a transaction operation. Writes a policy would quarantine can't be held in
+// a transaction, so they are rejected too.
+func (h *Handler) moderateOperation(ctx context.Context, resourceTypeDefinition *ResourceTypeDefinition, item *Resource) error {
+	findings, err := h.moderateItem(ctx, resourceTypeDefinition, item)
+	if err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		return ContentRejectedError{
+			PackageName:  item.Metadata.PackageName,
+			ResourceType: resourceTypeDefinition.ResourceType,
+			Action:       resourceTypeDefinition.Moderation.Action,
+			Findings:     findings,
+		}
+	}
+
+	return nil
+}
+
+// moderateResourceItem moderates a write of item, and reports whether it may proceed. Rejected writes are responded
+// with 422 Unprocessable Entity, and quarantined ones are held as change requests needing a moderator's approval, and
+// the approvals of the resource type, if any.
+func (h *Handler) moderateResourceItem(w http.ResponseWriter, r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, opType OperationType, item *Resource, precondition Precondition) bool {
+	findings, err := h.moderateItem(r.Context(), resourceTypeDefinition, item)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to moderate resource item", "error", err)
+
+		var (
+			moderationClassifierNotFoundError ModerationClassifierNotFoundError
+			moderationClassifierError         ModerationClassifierError
+		)
+
+		switch {
+		case errors.As(err, &moderationClassifierNotFoundError):
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail(moderationClassifierNotFoundError.Error()),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+		case errors.As(err, &moderationClassifierError):
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusBadGateway),
+				problem.WithTitle("Bad Gateway"),
+				problem.WithDetail(moderationClassifierError.Error()),
+				withReason(apierrors.ReasonDeliveryFailed),
+			))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return false
+	}
+
+	if len(findings) == 0 {
+		return true
+	}
+
+	if resourceTypeDefinition.Moderation.Action == ModerationActionQuarantine {
+		approvals := 1
+		if resourceTypeDefinition.Approval != nil {
+			approvals = max(approvals, resourceTypeDefinition.Approval.Approvals)
+		}
+
+		h.holdChange(w, r, approvals, map[string]string{moderationQuarantinedLabel: "true"}, opType, item, precondition)
+
+		return false
+	}
+
+	err = ContentRejectedError{
+		PackageName:  item.Metadata.PackageName,
+		ResourceType: resourceTypeDefinition.ResourceType,
+		Action:       resourceTypeDefinition.Moderation.Action,
+		Findings:     findings,
+	}
+
+	respond.Done(w, r, problem.CustomError(
+		problem.WithStatus(http.StatusUnprocessableEntity),
+		problem.WithTitle("Unprocessable Entity"),
+		problem.WithDetail(err.Error()),
+		problem.WithExtension("findings", findings),
+		withReason(apierrors.ReasonContentRejected),
+	))
+
+	return false
+}
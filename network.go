@@ -0,0 +1,181 @@
+package bass
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+// NetworkPolicy restricts the client addresses allowed to access the API. Denied prefixes and countries take
+// precedence. If allowed prefixes or countries are given, clients must match one of them.
+type NetworkPolicy struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+	// AllowCountries and DenyCountries hold ISO 3166-1 alpha-2 codes, resolved with the resolver given to
+	// WithCountryResolver. Clients of unknown countries don't match any.
+	AllowCountries []string
+	DenyCountries  []string
+}
+
+// CountryResolver resolves the country of client addresses, e.g. from a GeoIP database. It returns an ISO 3166-1
+// alpha-2 code, or an empty string if the country is unknown.
+type CountryResolver interface {
+	Country(addr netip.Addr) string
+}
+
+type NetworkAccessDeniedError struct {
+	Addr        netip.Addr
+	PackageName string
+}
+
+func (err NetworkAccessDeniedError) Error() string {
+	if err.PackageName == "" {
+		return fmt.Sprintf("access from %s is not allowed", err.Addr)
+	}
+
+	return fmt.Sprintf("access from %s to package %q is not allowed", err.Addr, err.PackageName)
+}
+
+type ForwardedForInvalidError struct {
+	Value string
+}
+
+func (err ForwardedForInvalidError) Error() string {
+	return fmt.Sprintf("invalid X-Forwarded-For address %q", err.Value)
+}
+
+func (policy NetworkPolicy) allows(addr netip.Addr, country string) bool {
+	contains := func(prefix netip.Prefix) bool { return prefix.Contains(addr) }
+
+	if slices.ContainsFunc(policy.Deny, contains) || (country != "" && slices.Contains(policy.DenyCountries, country)) {
+		return false
+	}
+
+	if len(policy.Allow) > 0 && !slices.ContainsFunc(policy.Allow, contains) {
+		return false
+	}
+
+	if len(policy.AllowCountries) > 0 && (country == "" || !slices.Contains(policy.AllowCountries, country)) {
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) networkPoliciesEnabled() bool {
+	return h.networkPolicy != nil || len(h.packageNetworkPolicies) > 0
+}
+
+// clientAddr returns the address of the client of a request. If the peer is a trusted proxy, X-Forwarded-For is
+// followed from the right to the first address that isn't a trusted proxy.
+func (h *Handler) clientAddr(r *http.Request) (netip.Addr, error) {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to parse remote address: %w", err)
+	}
+
+	addr := addrPort.Addr().Unmap()
+
+	trusted := func(addr netip.Addr) bool {
+		return slices.ContainsFunc(h.trustedProxies, func(prefix netip.Prefix) bool { return prefix.Contains(addr) })
+	}
+
+	if !trusted(addr) {
+		return addr, nil
+	}
+
+	var hops []string
+
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for _, hop := range slices.Backward(hops) {
+		hopAddr, err := netip.ParseAddr(strings.TrimSpace(hop))
+		if err != nil {
+			return netip.Addr{}, ForwardedForInvalidError{Value: hop}
+		}
+
+		addr = hopAddr.Unmap()
+
+		if !trusted(addr) {
+			return addr, nil
+		}
+	}
+
+	return addr, nil
+}
+
+// checkNetworkAccess applies the global network policy, and the policy of the package if given, to the client of a
+// request.
+func (h *Handler) checkNetworkAccess(r *http.Request, packageName string) error {
+	if !h.networkPoliciesEnabled() {
+		return nil
+	}
+
+	addr, err := h.clientAddr(r)
+	if err != nil {
+		return err
+	}
+
+	country := ""
+	if h.countryResolver != nil {
+		country = h.countryResolver.Country(addr)
+	}
+
+	if h.networkPolicy != nil && !h.networkPolicy.allows(addr, country) {
+		return NetworkAccessDeniedError{Addr: addr, PackageName: ""}
+	}
+
+	packageNetworkPolicy, ok := h.packageNetworkPolicies[packageName]
+	if ok && !packageNetworkPolicy.allows(addr, country) {
+		return NetworkAccessDeniedError{Addr: addr, PackageName: packageName}
+	}
+
+	return nil
+}
+
+// withNetworkPolicy rejects requests from clients the network policies don't allow.
+func (h *Handler) withNetworkPolicy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := h.checkNetworkAccess(r, r.PathValue("packageName"))
+		if err != nil {
+			h.respondNetworkAccessDenied(w, r, err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// respondNetworkAccessDenied responds with the failure of a network access check.
+func (h *Handler) respondNetworkAccessDenied(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.ErrorContext(r.Context(), "network access denied", "error", err)
+
+	var (
+		networkAccessDeniedError NetworkAccessDeniedError
+		forwardedForInvalidError ForwardedForInvalidError
+	)
+
+	switch {
+	case errors.As(err, &networkAccessDeniedError):
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusForbidden),
+			problem.WithTitle("Forbidden"),
+			problem.WithDetail(networkAccessDeniedError.Error()),
+			withReason(apierrors.ReasonAccessDenied),
+		))
+	case errors.As(err, &forwardedForInvalidError):
+		respond.Done(w, r, problem.BadRequest(forwardedForInvalidError.Error(), withReason(apierrors.ReasonBadRequest)))
+	default:
+		respond.Done(w, r, serverError(err))
+	}
+}
@@ -0,0 +1,534 @@
+package bass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	NotificationChannel  = apiv1.NotificationChannel
+	NotificationRule     = apiv1.NotificationRule
+	NotificationTemplate = apiv1.NotificationTemplate
+	NotifyRequest        = apiv1.NotifyRequest
+)
+
+// Types of notification channels.
+const (
+	NotificationChannelEmail   = "email"
+	NotificationChannelSMS     = "sms"
+	NotificationChannelSlack   = "slack"
+	NotificationChannelDiscord = "discord"
+	NotificationChannelWebhook = "webhook"
+)
+
+// Notification is a rendered notification, ready to be delivered. Notifications routed by rules carry the operation
+// and the item as data.
+type Notification struct {
+	Title string         `json:"title"`
+	Text  string         `json:"text,omitempty"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+// Notifier delivers notifications to channels of a type.
+type Notifier interface {
+	Notify(ctx context.Context, channel *NotificationChannel, notification Notification) error
+}
+
+// NotificationChannelNotSupportedError is returned for channels of a type without a notifier.
+type NotificationChannelNotSupportedError struct {
+	Type string
+}
+
+func (err NotificationChannelNotSupportedError) Error() string {
+	return fmt.Sprintf("no notifier is configured for channel type %q", err.Type)
+}
+
+func notificationChannelResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "NotificationChannel.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "NotificationChannel",
+		Plural:       "NotificationChannels",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"type": map[string]any{
+							"type": "string",
+							"enum": []any{NotificationChannelEmail, NotificationChannelSMS, NotificationChannelSlack, NotificationChannelDiscord, NotificationChannelWebhook},
+						},
+						"to":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						"url": map[string]any{"type": "string", "format": "uri"},
+					},
+					"required": []any{"type"},
+				},
+			},
+		},
+	}
+}
+
+func notificationRuleResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "NotificationRule.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "NotificationRule",
+		Plural:       "NotificationRules",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"trigger":  resourceTriggerSchema(),
+						"channels": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1},
+						"title":    map[string]any{"type": "string", "minLength": 1},
+						"text":     map[string]any{"type": "string"},
+						"channelTemplates": map[string]any{
+							"type": "object",
+							"additionalProperties": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"title": map[string]any{"type": "string", "minLength": 1},
+									"text":  map[string]any{"type": "string"},
+								},
+								"required": []any{"title"},
+							},
+						},
+					},
+					"required": []any{"trigger", "channels", "title"},
+				},
+			},
+		},
+	}
+}
+
+func notificationChannelFromResource(item *Resource) (*NotificationChannel, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal notification channel properties: %w", err)
+	}
+
+	var channel NotificationChannel
+
+	err = json.Unmarshal(b, &channel)
+	if err != nil {
+		return nil, fmt.Errorf("notification channel %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	channel.Metadata = item.Metadata
+
+	return &channel, nil
+}
+
+func notificationRuleFromResource(item *Resource) (*NotificationRule, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal notification rule properties: %w", err)
+	}
+
+	var rule NotificationRule
+
+	err = json.Unmarshal(b, &rule)
+	if err != nil {
+		return nil, fmt.Errorf("notification rule %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	rule.Metadata = item.Metadata
+
+	return &rule, nil
+}
+
+// checkNotificationRule parses the templates of a notification rule item.
+func checkNotificationRule(item *Resource) error {
+	rule, err := notificationRuleFromResource(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = renderNotification(NotificationTemplate{Title: rule.Title, Text: rule.Text}, nil)
+	if err != nil {
+		return templateParseError(err)
+	}
+
+	for channelName, template := range rule.ChannelTemplates {
+		if !slices.Contains(rule.Channels, channelName) {
+			return ResourceInvalidError{Errors: []ValidationError{{
+				Pointer: "/properties/channelTemplates/" + jsonPointerToken(channelName),
+				Keyword: "channels",
+				Message: fmt.Sprintf("channel %q is not notified by the rule", channelName),
+				Params:  nil,
+			}}}
+		}
+
+		_, err = renderNotification(template, nil)
+		if err != nil {
+			return templateParseError(err)
+		}
+	}
+
+	return nil
+}
+
+// ruleTemplate returns the templates of the notifications of a rule to a channel.
+func ruleTemplate(rule *NotificationRule, channelName string) NotificationTemplate {
+	if template, ok := rule.ChannelTemplates[channelName]; ok {
+		return template
+	}
+
+	return NotificationTemplate{Title: rule.Title, Text: rule.Text}
+}
+
+// renderNotification executes the templates of a notification with data, and attaches the data.
+func renderNotification(template NotificationTemplate, data map[string]any) (Notification, error) {
+	notification := Notification{Title: "", Text: "", Data: data}
+
+	var err error
+
+	notification.Title, err = executeTextTemplate("title", template.Title, data)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	notification.Text, err = executeTextTemplate("text", template.Text, data)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	return notification, nil
+}
+
+// notify delivers a notification to a channel with the notifier of its type.
+func (h *Handler) notify(ctx context.Context, channel *NotificationChannel, notification Notification) error {
+	notifier, ok := h.notifiers[channel.Type]
+	if !ok {
+		return NotificationChannelNotSupportedError{Type: channel.Type}
+	}
+
+	var err error
+
+	if channel.Type == NotificationChannelWebhook {
+		_, err = h.deliverWebhook(ctx, notifier, channel, notification, "")
+	} else {
+		err = notifier.Notify(ctx, channel, notification)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to notify channel %q: %w", channel.Metadata.Name, err)
+	}
+
+	return nil
+}
+
+// triggerNotifications delivers the notifications of the rules triggered by a write. Failures are logged.
+func (h *Handler) triggerNotifications(ctx context.Context, operation OperationType, item *Resource) {
+	list, err := h.repoList(ctx, corePackageName, "v1", "NotificationRule")
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list notification rules", "error", err)
+
+		return
+	}
+
+	var data map[string]any
+
+	for _, ruleItem := range list.Items {
+		rule, err := notificationRuleFromResource(ruleItem)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to parse notification rule", "error", err)
+
+			continue
+		}
+
+		if !triggerMatches(rule.Trigger, operation, item) {
+			continue
+		}
+
+		if data == nil {
+			data, err = triggerData(operation, item)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to prepare notification data", "error", err)
+
+				return
+			}
+		}
+
+		for _, channelName := range rule.Channels {
+			notification, err := renderNotification(ruleTemplate(rule, channelName), data)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to render notification", "rule", ruleItem.Metadata.Name, "channel", channelName, "error", err)
+
+				continue
+			}
+
+			err = h.notifyChannel(ctx, channelName, notification)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to deliver triggered notification", "rule", ruleItem.Metadata.Name, "error", err)
+			}
+		}
+	}
+}
+
+func (h *Handler) notifyChannel(ctx context.Context, channelName string, notification Notification) error {
+	item, err := h.repoGet(ctx, corePackageName, "NotificationChannel", channelName)
+	if err != nil {
+		return err
+	}
+
+	channel, err := notificationChannelFromResource(item)
+	if err != nil {
+		return err
+	}
+
+	return h.notify(ctx, channel, notification)
+}
+
+// handleNotify delivers a notification to a channel, e.g. to test its configuration.
+func (h *Handler) handleNotify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req NotifyRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		if req.Title == "" {
+			respond.Done(w, r, problem.BadRequest("notification without title", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		err = h.notifyChannel(r.Context(), r.PathValue("name"), Notification{Title: req.Title, Text: req.Text, Data: nil})
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to notify channel", "error", err)
+
+			var (
+				resourceNotFoundError                ResourceNotFoundError
+				notificationChannelNotSupportedError NotificationChannelNotSupportedError
+			)
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			case errors.As(err, &notificationChannelNotSupportedError):
+				respond.Done(w, r, problem.CustomError(
+					problem.WithStatus(http.StatusNotImplemented),
+					problem.WithTitle("Not Implemented"),
+					problem.WithDetail(notificationChannelNotSupportedError.Error()),
+					withReason(apierrors.ReasonNotImplemented),
+				))
+			default:
+				respond.Done(w, r, problem.CustomError(
+					problem.WithStatus(http.StatusBadGateway),
+					problem.WithTitle("Bad Gateway"),
+					problem.WithDetail(err.Error()),
+					withReason(apierrors.ReasonDeliveryFailed),
+				))
+			}
+
+			return
+		}
+
+		respond.Done(w, r, nil)
+	}
+}
+
+// EmailNotifier delivers notifications to the recipients of email channels, with the title as the subject.
+type EmailNotifier struct {
+	sender EmailSender
+}
+
+var _ Notifier = (*EmailNotifier)(nil)
+
+func NewEmailNotifier(sender EmailSender) *EmailNotifier {
+	return &EmailNotifier{sender: sender}
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, channel *NotificationChannel, notification Notification) error {
+	err := n.sender.SendEmail(ctx, Email{To: channel.To, Subject: notification.Title, Text: notification.Text, HTML: ""})
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SMSSender delivers text messages, e.g. through the API of an SMS provider.
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, text string) error
+}
+
+// SMSNotifier delivers notifications to the recipients of SMS channels, as the title followed by the text.
+type SMSNotifier struct {
+	sender SMSSender
+}
+
+var _ Notifier = (*SMSNotifier)(nil)
+
+func NewSMSNotifier(sender SMSSender) *SMSNotifier {
+	return &SMSNotifier{sender: sender}
+}
+
+func (n *SMSNotifier) Notify(ctx context.Context, channel *NotificationChannel, notification Notification) error {
+	text := strings.TrimSpace(notification.Title + "\n" + notification.Text)
+
+	for _, to := range channel.To {
+		err := n.sender.SendSMS(ctx, to, text)
+		if err != nil {
+			return fmt.Errorf("failed to send sms to %q: %w", to, err)
+		}
+	}
+
+	return nil
+}
+
+// HTTPSMSSender sends text messages through the HTTP API of an SMS provider. It posts each message as JSON, with
+// from, to, and text fields, authenticated with a bearer API key.
+type HTTPSMSSender struct {
+	endpoint string
+	apiKey   string
+	from     string
+	client   *http.Client
+}
+
+var _ SMSSender = (*HTTPSMSSender)(nil)
+
+func NewHTTPSMSSender(endpoint, apiKey, from string) *HTTPSMSSender {
+	return &HTTPSMSSender{endpoint: endpoint, apiKey: apiKey, from: from, client: http.DefaultClient}
+}
+
+func (s *HTTPSMSSender) SendSMS(ctx context.Context, to, text string) error {
+	return postJSON(ctx, s.client, s.endpoint, s.apiKey, map[string]string{"from": s.from, "to": to, "text": text})
+}
+
+// SlackNotifier delivers notifications to the incoming webhook URLs of Slack channels.
+type SlackNotifier struct {
+	client *http.Client
+}
+
+var _ Notifier = (*SlackNotifier)(nil)
+
+func NewSlackNotifier() *SlackNotifier {
+	return &SlackNotifier{client: http.DefaultClient}
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, channel *NotificationChannel, notification Notification) error {
+	text := "*" + notification.Title + "*"
+	if notification.Text != "" {
+		text += "\n" + notification.Text
+	}
+
+	return postJSON(ctx, n.client, channel.URL, "", map[string]string{"text": text})
+}
+
+// DiscordNotifier delivers notifications to the webhook URLs of Discord channels, as an embed with the title and the
+// text as description.
+type DiscordNotifier struct {
+	client *http.Client
+}
+
+var _ Notifier = (*DiscordNotifier)(nil)
+
+func NewDiscordNotifier() *DiscordNotifier {
+	return &DiscordNotifier{client: http.DefaultClient}
+}
+
+func (n *DiscordNotifier) Notify(ctx context.Context, channel *NotificationChannel, notification Notification) error {
+	embed := map[string]string{"title": notification.Title}
+	if notification.Text != "" {
+		embed["description"] = notification.Text
+	}
+
+	return postJSON(ctx, n.client, channel.URL, "", map[string]any{"embeds": []any{embed}})
+}
+
+// WebhookNotifier delivers notifications to the URLs of webhook channels, posting them as JSON.
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+var _ StatusNotifier = (*WebhookNotifier)(nil)
+
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{client: http.DefaultClient}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, channel *NotificationChannel, notification Notification) error {
+	return postJSON(ctx, n.client, channel.URL, "", notification)
+}
+
+func (n *WebhookNotifier) NotifyStatus(ctx context.Context, channel *NotificationChannel, notification Notification) (int, error) {
+	return postJSONStatus(ctx, n.client, channel.URL, "", notification)
+}
+
+// postJSON posts body as JSON to url, authenticated with a bearer API key if given, and fails on non-2xx responses.
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, body any) error {
+	_, err := postJSONStatus(ctx, client, url, apiKey, body)
+
+	return err
+}
+
+// postJSONStatus is postJSON returning the status code of the response, or zero if there is none.
+func postJSONStatus(ctx context.Context, client *http.Client, url, apiKey string, body any) (int, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post: %w", err)
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return res.StatusCode, fmt.Errorf("failed to post: server responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,115 @@
+package bass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+)
+
+type OutboxEvent = apiv1.OutboxEvent
+
+// defaultOutboxBatchSize is the number of outbox events published per dispatch by default.
+const defaultOutboxBatchSize = 100
+
+// OutboxRepository is a repository recording an event of every write in an outbox in the same transaction as the
+// write, e.g. a table of a SQL database, so no event is lost if the process crashes after writing.
+type OutboxRepository interface {
+	// PendingOutboxEvents returns up to limit events of the outbox, oldest first.
+	PendingOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
+	// DeleteOutboxEvents removes published events from the outbox.
+	DeleteOutboxEvents(ctx context.Context, ids []int64) error
+}
+
+// OutboxPublisher publishes outbox events, e.g. to a webhook or a message bus. Events are published at least once, so
+// consumers must tolerate duplicates.
+type OutboxPublisher interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
+
+// Outbox configures the dispatch of the outbox events of the repository by Handler.RunOutbox.
+type Outbox struct {
+	Publisher OutboxPublisher
+	// Interval is the time between dispatches.
+	Interval time.Duration
+	// BatchSize is the number of events published per dispatch. It defaults to 100.
+	BatchSize int
+}
+
+var ErrOutboxUnsupported = errors.New("outbox needs a repository implementing OutboxRepository")
+
+// RunOutbox dispatches the outbox events of the repository at the outbox interval, until ctx is done. Failed dispatches
+// are logged and retried on the next tick.
+func (h *Handler) RunOutbox(ctx context.Context) {
+	if h.outbox == nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.outbox.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := h.DispatchOutbox(ctx)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to dispatch outbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOutbox publishes a batch of outbox events in order, removing them from the outbox once published, and
+// returns how many it published. It stops at the first event failing to publish, with the retry policy, to keep the
+// order. Without an outbox configured, it does nothing.
+func (h *Handler) DispatchOutbox(ctx context.Context) (int, error) {
+	if h.outbox == nil {
+		return 0, nil
+	}
+
+	outboxRepo, ok := h.repo.(OutboxRepository)
+	if !ok {
+		return 0, ErrOutboxUnsupported
+	}
+
+	batchSize := h.outbox.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultOutboxBatchSize
+	}
+
+	events, err := outboxRepo.PendingOutboxEvents(ctx, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	published := make([]int64, 0, len(events))
+
+	var publishErr error
+
+	for _, event := range events {
+		publishErr = h.retry(ctx, func() error {
+			return h.outbox.Publisher.Publish(ctx, event)
+		})
+		if publishErr != nil {
+			publishErr = fmt.Errorf("failed to publish outbox event %d: %w", event.ID, publishErr)
+
+			break
+		}
+
+		published = append(published, event.ID)
+	}
+
+	if len(published) > 0 {
+		err = outboxRepo.DeleteOutboxEvents(ctx, published)
+		if err != nil {
+			return len(published), errors.Join(publishErr, fmt.Errorf("failed to delete published outbox events: %w", err))
+		}
+	}
+
+	return len(published), publishErr
+}
@@ -1,5 +1,5 @@
 package bass
 
-type Package struct {
-	Metadata Metadata `json:"metadata"`
-}
+import apiv1 "github.com/nasermirzaei89/bass/api/v1"
+
+type Package = apiv1.Package
@@ -0,0 +1,115 @@
+package bass
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+// Naming rules of the path segments addressing resources. Resource type definitions are held to the same rules, so
+// every registered resource type is addressable. They are all lowercase, as the segments are canonicalized to
+// lowercase.
+const (
+	packageNamePattern  = `^[a-z0-9]([a-z0-9.-]{0,61}[a-z0-9])?$`
+	apiVersionPattern   = `^v[0-9]+((alpha|beta)[0-9]+)?$`
+	resourceTypePattern = `^[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`
+)
+
+var (
+	packageNameRegexp  = regexp.MustCompile(packageNamePattern)
+	apiVersionRegexp   = regexp.MustCompile(apiVersionPattern)
+	resourceTypeRegexp = regexp.MustCompile(resourceTypePattern)
+)
+
+type PathSegmentError struct {
+	Segment string
+	Value   string
+	Rule    string
+}
+
+func (err PathSegmentError) Error() string {
+	return fmt.Sprintf("path segment %s %q is invalid: it must be %s", err.Segment, err.Value, err.Rule)
+}
+
+// checkPathSegments checks the package name, API version, and resource type segments of the path of r, if it has
+// them, against their naming rules.
+func checkPathSegments(r *http.Request) error {
+	segments := []struct {
+		name   string
+		regexp *regexp.Regexp
+		rule   string
+	}{
+		{"packageName", packageNameRegexp, "lowercase letters, digits, dots, and hyphens, starting and ending with a letter or digit, at most 63 characters"},
+		{"apiVersion", apiVersionRegexp, `"v" followed by a number, optionally followed by "alpha" or "beta" and a number, e.g. "v1" or "v2beta1"`},
+		{"resourceTypePlural", resourceTypeRegexp, "lowercase letters, digits, and hyphens, starting with a letter and ending with a letter or digit, at most 63 characters"},
+	}
+
+	for _, segment := range segments {
+		value := r.PathValue(segment.name)
+		if value != "" && !segment.regexp.MatchString(value) {
+			return PathSegmentError{Segment: segment.name, Value: value, Rule: segment.rule}
+		}
+	}
+
+	return nil
+}
+
+// withPathValidation rejects requests with invalid path segments with 400 Bad Request before they reach the
+// repository, instead of failing to find them.
+func (h *Handler) withPathValidation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pathSegmentError PathSegmentError
+
+		err := checkPathSegments(r)
+		if errors.As(err, &pathSegmentError) {
+			respond.Done(w, r, problem.BadRequest(pathSegmentError.Error(), problem.WithExtension("segment", pathSegmentError.Segment), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canonicalPath returns the canonical form of a path of the API: without trailing slashes, and with the package name,
+// API version, and resource type segments in lowercase. Other paths are returned as they are.
+func (h *Handler) canonicalPath(path string) string {
+	rest, ok := strings.CutPrefix(path, h.basePath+"/api/")
+	if !ok {
+		return path
+	}
+
+	// The package name, API version, and resource type are the first segments.
+	const canonicalSegments = 3
+
+	segments := strings.SplitN(strings.TrimRight(rest, "/"), "/", canonicalSegments+1)
+	for i := range min(len(segments), canonicalSegments) {
+		segments[i] = strings.ToLower(segments[i])
+	}
+
+	return h.basePath + "/api/" + strings.Join(segments, "/")
+}
+
+// withCanonicalPath redirects requests for non-canonical paths, e.g. "/api/test/v1/Foos/", to their canonical path
+// with 308 Permanent Redirect, which keeps the method and body of the request.
+func (h *Handler) withCanonicalPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := h.canonicalPath(r.URL.Path)
+		if path == r.URL.Path {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		location := url.URL{Path: path, RawQuery: r.URL.RawQuery}
+
+		http.Redirect(w, r, location.String(), http.StatusPermanentRedirect)
+	})
+}
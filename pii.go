@@ -0,0 +1,201 @@
+package bass
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/v2"
+	"maps"
+	"net/http"
+	"strings"
+)
+
+// Anonymization strategies of properties tagged as PII, by "x-pii" in their schema. Properties tagged "drop", or with
+// other strategies, are dropped.
+const (
+	// piiHash replaces values with the hex encoded SHA-256 hash of their JSON encoding, which keeps equal values equal.
+	piiHash = "hash"
+	// piiMask replaces all but the last four characters of strings with "*". Other values are dropped.
+	piiMask = "mask"
+)
+
+// piiMaskVisible is the number of trailing characters masking keeps.
+const piiMaskVisible = 4
+
+// hasPII reports whether schema tags any value as PII, including values of nested objects, "additionalProperties"
+// schemas and array "items". References to schema definitions are resolved with the resource type definition.
+func hasPII(schema map[string]any) bool {
+	if _, ok := schema["x-pii"]; ok {
+		return true
+	}
+
+	declared, _ := schema["properties"].(map[string]any)
+
+	for _, propertySchema := range declared {
+		propertySchema, ok := propertySchema.(map[string]any)
+		if ok && hasPII(propertySchema) {
+			return true
+		}
+	}
+
+	for _, keyword := range []string{"additionalProperties", "items"} {
+		subschema, ok := schema[keyword].(map[string]any)
+		if ok && hasPII(subschema) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anonymizePII returns a copy of object with the values schema tags as PII anonymized by their strategies, including
+// values of nested objects, "additionalProperties" schemas and array "items". Untagged values are shared with object.
+func anonymizePII(schema map[string]any, object map[string]any) map[string]any {
+	declared, _ := schema["properties"].(map[string]any)
+	additionalProperties, _ := schema["additionalProperties"].(map[string]any)
+
+	res := make(map[string]any, len(object))
+
+	for key, value := range object {
+		propertySchema, ok := declared[key].(map[string]any)
+		if !ok {
+			propertySchema = additionalProperties
+		}
+
+		anonymized, keep := anonymizePIIValue(propertySchema, value)
+		if keep {
+			res[key] = anonymized
+		}
+	}
+
+	return res
+}
+
+// anonymizePIIValue anonymizes value by schema, and reports whether the anonymized value is kept. Dropped items of
+// arrays are left out.
+func anonymizePIIValue(schema map[string]any, value any) (any, bool) {
+	if strategy, ok := schema["x-pii"]; ok {
+		return anonymizeValue(strategy, value)
+	}
+
+	if !hasPII(schema) {
+		return value, true
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		return anonymizePII(schema, value), true
+	case []any:
+		items, _ := schema["items"].(map[string]any)
+		res := make([]any, 0, len(value))
+
+		for _, item := range value {
+			anonymized, keep := anonymizePIIValue(items, item)
+			if keep {
+				res = append(res, anonymized)
+			}
+		}
+
+		return res, true
+	default:
+		return value, true
+	}
+}
+
+// anonymizeValue anonymizes value by strategy, and reports whether the anonymized value is kept.
+func anonymizeValue(strategy, value any) (any, bool) {
+	switch strategy {
+	case piiHash:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, false
+		}
+
+		hash := sha256.Sum256(b)
+
+		return hex.EncodeToString(hash[:]), true
+	case piiMask:
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+
+		runes := []rune(s)
+
+		masked := len(runes)
+		if masked > piiMaskVisible {
+			masked -= piiMaskVisible
+		}
+
+		return strings.Repeat("*", masked) + string(runes[masked:]), true
+	default:
+		return nil, false
+	}
+}
+
+// anonymizedItem returns item with its PII anonymized by the schema of its resource type, or item itself if the
+// schema tags no PII.
+func anonymizedItem(resourceTypeDefinition *ResourceTypeDefinition, item *Resource) *Resource {
+	schema := resourceTypeDefinition.Versions[0].Schema
+	if !hasPII(schema) {
+		return item
+	}
+
+	return &Resource{Metadata: item.Metadata, Properties: anonymizePII(schema, item.Properties)}
+}
+
+// anonymizeItems replaces items with their PII anonymized by the schema of their resource type.
+func anonymizeItems(resourceTypeDefinition *ResourceTypeDefinition, items []*Resource) {
+	for i, item := range items {
+		items[i] = anonymizedItem(resourceTypeDefinition, item)
+	}
+}
+
+// anonymizedSnapshot returns a draft, published version or localization of an item with the PII in the item
+// properties it holds anonymized by the schema of the resource type of the item, or snapshot itself if the schema tags
+// no PII.
+func anonymizedSnapshot(resourceTypeDefinition *ResourceTypeDefinition, snapshot *Resource) *Resource {
+	schema := resourceTypeDefinition.Versions[0].Schema
+	if !hasPII(schema) {
+		return snapshot
+	}
+
+	properties, ok := snapshot.Properties["properties"].(map[string]any)
+	if !ok {
+		return snapshot
+	}
+
+	res := &Resource{Metadata: snapshot.Metadata, Properties: maps.Clone(snapshot.Properties)}
+	res.Properties["properties"] = anonymizePII(schema, properties)
+
+	return res
+}
+
+// privilegedRead reports whether the reader of r may read PII as it is. Requests with publishable API keys, meant for
+// browser and mobile apps, may not.
+func privilegedRead(r *http.Request) bool {
+	key, ok := r.Context().Value(apiKeyContextKey{}).(APIKey)
+
+	return !ok || key.Type != APIKeyPublishable
+}
+
+// itemAnonymizer returns how items of any resource type are presented to a reader, as in watches of all resource types
+// and syncs, resolving their resource types as they come. It returns nil for privileged readers, who get them as they
+// are.
+func (h *Handler) itemAnonymizer(ctx context.Context, privileged bool) func(item *Resource) *Resource {
+	if privileged {
+		return nil
+	}
+
+	return func(item *Resource) *Resource {
+		resourceTypeDefinition, err := h.getResourceTypeDefinition(ctx, item.Metadata.PackageName, strings.ToLower(item.Metadata.ResourceType))
+		if err != nil {
+			// Items of resource types that can't be resolved are sent without properties, rather than with their PII.
+			h.logger.ErrorContext(ctx, "failed to get resource type definition of item", "error", err)
+
+			return &Resource{Metadata: item.Metadata, Properties: map[string]any{}}
+		}
+
+		return anonymizedItem(resourceTypeDefinition, item)
+	}
+}
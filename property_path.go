@@ -0,0 +1,50 @@
+package bass
+
+import (
+	"fmt"
+	"strings"
+)
+
+type PropertyPathError struct {
+	Path   string
+	Reason string
+}
+
+func (err PropertyPathError) Error() string {
+	return fmt.Sprintf("invalid property path %q: %s", err.Path, err.Reason)
+}
+
+// parsePropertyPath splits a JSON pointer to a property, like "/properties/stock/level", into its unescaped
+// segments below properties.
+func parsePropertyPath(path string) ([]string, error) {
+	rest, ok := strings.CutPrefix(path, "/properties/")
+	if !ok {
+		return nil, PropertyPathError{Path: path, Reason: `must start with "/properties/"`}
+	}
+
+	segments := strings.Split(rest, "/")
+	for i, segment := range segments {
+		segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+	}
+
+	return segments, nil
+}
+
+// propertyParent returns the object holding the property at the segments of path, and the key of the property in it.
+func propertyParent(properties map[string]any, path string, segments []string) (map[string]any, string, error) {
+	parent := properties
+
+	for _, segment := range segments[:len(segments)-1] {
+		value, ok := parent[segment]
+		if !ok {
+			return nil, "", PropertyPathError{Path: path, Reason: fmt.Sprintf("property %q not found", segment)}
+		}
+
+		parent, ok = value.(map[string]any)
+		if !ok {
+			return nil, "", PropertyPathError{Path: path, Reason: fmt.Sprintf("property %q is not an object", segment)}
+		}
+	}
+
+	return parent, segments[len(segments)-1], nil
+}
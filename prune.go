@@ -0,0 +1,50 @@
+package bass
+
+// pruneValue removes object fields not declared in the schema from the value, recursing into declared properties,
+// "additionalProperties" schemas and array "items". Only objects whose schema declares "properties" are pruned, and a
+// schema with "x-preserve-unknown-fields: true" keeps everything below it.
+func pruneValue(value any, schema map[string]any) {
+	if preserve, _ := schema["x-preserve-unknown-fields"].(bool); preserve {
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		pruneObject(value, schema)
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return
+		}
+
+		for _, item := range value {
+			pruneValue(item, items)
+		}
+	}
+}
+
+func pruneObject(object map[string]any, schema map[string]any) {
+	properties, hasProperties := schema["properties"].(map[string]any)
+
+	for key, value := range object {
+		propertySchema, ok := properties[key].(map[string]any)
+		if ok {
+			pruneValue(value, propertySchema)
+
+			continue
+		}
+
+		switch additionalProperties := schema["additionalProperties"].(type) {
+		case map[string]any:
+			pruneValue(value, additionalProperties)
+		case bool:
+			if !additionalProperties && hasProperties {
+				delete(object, key)
+			}
+		default:
+			if hasProperties {
+				delete(object, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,586 @@
+package bass
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	DeviceToken      = apiv1.DeviceToken
+	PushTemplate     = apiv1.PushTemplate
+	PushSendRequest  = apiv1.PushSendRequest
+	PushSendResponse = apiv1.PushSendResponse
+)
+
+// Push platforms of device tokens.
+const (
+	PushPlatformAPNs = "apns"
+	PushPlatformFCM  = "fcm"
+)
+
+// PushNotification is a rendered push notification, ready to be sent.
+type PushNotification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body,omitempty"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// PushSender delivers push notifications to devices of a platform.
+type PushSender interface {
+	SendPush(ctx context.Context, token string, notification PushNotification) error
+}
+
+// DeviceTokenInvalidError is returned by push senders for tokens the platform no longer accepts, e.g. of uninstalled
+// apps. Device tokens failing with it are deleted.
+type DeviceTokenInvalidError struct {
+	Reason string
+}
+
+func (err DeviceTokenInvalidError) Error() string {
+	return "device token is invalid: " + err.Reason
+}
+
+func deviceTokenResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "DeviceToken.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "DeviceToken",
+		Plural:       "DeviceTokens",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"platform": map[string]any{"type": "string", "enum": []any{PushPlatformAPNs, PushPlatformFCM}},
+						"token":    map[string]any{"type": "string", "minLength": 1},
+						"subject":  map[string]any{"type": "string"},
+					},
+					"required": []any{"platform", "token"},
+				},
+			},
+		},
+	}
+}
+
+func pushTemplateResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "PushTemplate.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "PushTemplate",
+		Plural:       "PushTemplates",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"title":   map[string]any{"type": "string", "minLength": 1},
+						"body":    map[string]any{"type": "string"},
+						"subject": map[string]any{"type": "string"},
+						"data":    map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+						"trigger": resourceTriggerSchema(),
+					},
+					"required": []any{"title"},
+				},
+			},
+		},
+	}
+}
+
+func deviceTokenFromResource(item *Resource) (*DeviceToken, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal device token properties: %w", err)
+	}
+
+	var deviceToken DeviceToken
+
+	err = json.Unmarshal(b, &deviceToken)
+	if err != nil {
+		return nil, fmt.Errorf("device token %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	deviceToken.Metadata = item.Metadata
+
+	return &deviceToken, nil
+}
+
+func pushTemplateFromResource(item *Resource) (*PushTemplate, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal push template properties: %w", err)
+	}
+
+	var pushTemplate PushTemplate
+
+	err = json.Unmarshal(b, &pushTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("push template %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	pushTemplate.Metadata = item.Metadata
+
+	return &pushTemplate, nil
+}
+
+// checkPushTemplate parses the templates of a push template item.
+func checkPushTemplate(item *Resource) error {
+	pushTemplate, err := pushTemplateFromResource(item)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = renderPush(pushTemplate, nil)
+
+	return templateParseError(err)
+}
+
+// renderPush executes the templates of a push template with data. It returns the subject and the notification.
+func renderPush(pushTemplate *PushTemplate, data any) (string, PushNotification, error) {
+	notification := PushNotification{Title: "", Body: "", Data: nil}
+
+	subject, err := executeTextTemplate("subject", pushTemplate.Subject, data)
+	if err != nil {
+		return "", PushNotification{}, err
+	}
+
+	notification.Title, err = executeTextTemplate("title", pushTemplate.Title, data)
+	if err != nil {
+		return "", PushNotification{}, err
+	}
+
+	notification.Body, err = executeTextTemplate("body", pushTemplate.Body, data)
+	if err != nil {
+		return "", PushNotification{}, err
+	}
+
+	for key, text := range pushTemplate.Data {
+		value, err := executeTextTemplate("data."+key, text, data)
+		if err != nil {
+			return "", PushNotification{}, err
+		}
+
+		if notification.Data == nil {
+			notification.Data = make(map[string]string, len(pushTemplate.Data))
+		}
+
+		notification.Data[key] = value
+	}
+
+	return subject, notification, nil
+}
+
+// sendPush sends a notification to the devices of a subject. Device tokens reported invalid are deleted.
+func (h *Handler) sendPush(ctx context.Context, subject string, notification PushNotification) (PushSendResponse, error) {
+	res := PushSendResponse{Sent: 0, Failed: 0}
+
+	list, err := h.repoList(ctx, corePackageName, "v1", "DeviceToken")
+	if err != nil {
+		return res, fmt.Errorf("failed to list device tokens: %w", err)
+	}
+
+	for _, item := range list.Items {
+		deviceToken, err := deviceTokenFromResource(item)
+		if err != nil {
+			return res, err
+		}
+
+		if deviceToken.Subject != subject {
+			continue
+		}
+
+		sender, ok := h.pushSenders[deviceToken.Platform]
+		if !ok {
+			h.logger.ErrorContext(ctx, "no push sender for platform", "platform", deviceToken.Platform)
+
+			res.Failed++
+
+			continue
+		}
+
+		err = sender.SendPush(ctx, deviceToken.Token, notification)
+		if err == nil {
+			res.Sent++
+
+			continue
+		}
+
+		h.logger.ErrorContext(ctx, "failed to send push notification", "deviceToken", item.Metadata.Name, "error", err)
+
+		res.Failed++
+
+		var deviceTokenInvalidError DeviceTokenInvalidError
+		if !errors.As(err, &deviceTokenInvalidError) {
+			continue
+		}
+
+		err = h.repoDelete(ctx, corePackageName, "DeviceToken", item.Metadata.Name)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to delete invalid device token", "deviceToken", item.Metadata.Name, "error", err)
+
+			continue
+		}
+
+		h.resourceWritten(ctx, OperationDelete, item)
+	}
+
+	return res, nil
+}
+
+// triggerPush sends the notifications of the templates triggered by a write. Failures are logged.
+func (h *Handler) triggerPush(ctx context.Context, operation OperationType, item *Resource) {
+	list, err := h.repoList(ctx, corePackageName, "v1", "PushTemplate")
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list push templates", "error", err)
+
+		return
+	}
+
+	var data map[string]any
+
+	for _, templateItem := range list.Items {
+		pushTemplate, err := pushTemplateFromResource(templateItem)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to parse push template", "error", err)
+
+			continue
+		}
+
+		if !triggerMatches(pushTemplate.Trigger, operation, item) {
+			continue
+		}
+
+		if data == nil {
+			data, err = triggerData(operation, item)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to prepare push template data", "error", err)
+
+				return
+			}
+		}
+
+		subject, notification, err := renderPush(pushTemplate, data)
+		if err == nil {
+			_, err = h.sendPush(ctx, subject, notification)
+		}
+
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to send triggered push notification", "template", templateItem.Metadata.Name, "error", err)
+		}
+	}
+}
+
+// handleSendPush sends a push notification rendered from a template with the data of the request.
+func (h *Handler) handleSendPush() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(h.pushSenders) == 0 {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("no push sender is configured"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		var req PushSendRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		item, err := h.repoGet(r.Context(), corePackageName, "PushTemplate", r.PathValue("name"))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get push template", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		pushTemplate, err := pushTemplateFromResource(item)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to parse push template", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		subject, notification, err := renderPush(pushTemplate, req.Data)
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		if req.Subject != "" {
+			subject = req.Subject
+		}
+
+		if subject == "" {
+			respond.Done(w, r, problem.BadRequest("push notification without subject", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		res, err := h.sendPush(r.Context(), subject, notification)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to send push notification", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		respond.Done(w, r, res)
+	}
+}
+
+// Endpoints of push platforms.
+const (
+	APNsProductionEndpoint  = "https://api.push.apple.com"
+	APNsDevelopmentEndpoint = "https://api.sandbox.push.apple.com"
+	FCMEndpoint             = "https://fcm.googleapis.com"
+)
+
+// apnsTokenLifetime is how long an APNs provider token is reused. APNs rejects tokens older than an hour.
+const apnsTokenLifetime = 50 * time.Minute
+
+// es256CoordinateSize is the size of each of the r and s values of an ES256 signature.
+const es256CoordinateSize = 32
+
+// APNsPushSender sends push notifications through the Apple Push Notification service, authenticated with a
+// token-based provider key.
+type APNsPushSender struct {
+	endpoint string
+	teamID   string
+	keyID    string
+	key      *ecdsa.PrivateKey
+	topic    string
+	client   *http.Client
+	now      func() time.Time
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+var _ PushSender = (*APNsPushSender)(nil)
+
+// NewAPNsPushSender returns a sender using the APNs endpoint with the P-256 key of keyID of the team. The topic is
+// the bundle ID of the app.
+func NewAPNsPushSender(endpoint, teamID, keyID string, key *ecdsa.PrivateKey, topic string) *APNsPushSender {
+	return &APNsPushSender{
+		endpoint: endpoint,
+		teamID:   teamID,
+		keyID:    keyID,
+		key:      key,
+		topic:    topic,
+		client:   http.DefaultClient,
+		now:      time.Now,
+		mu:       sync.Mutex{},
+		token:    "",
+		issuedAt: time.Time{},
+	}
+}
+
+func (s *APNsPushSender) SendPush(ctx context.Context, token string, notification PushNotification) error {
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]any{"title": notification.Title, "body": notification.Body},
+		},
+	}
+
+	for key, value := range notification.Data {
+		if key != "aps" {
+			payload[key] = value
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	providerToken, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/3/device/"+url.PathEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("Apns-Topic", s.topic)
+	req.Header.Set("Apns-Push-Type", "alert")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send push notification: %w", err)
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var resBody struct {
+		Reason string `json:"reason"`
+	}
+
+	_ = json.UnmarshalRead(res.Body, &resBody)
+
+	if res.StatusCode == http.StatusGone || resBody.Reason == "BadDeviceToken" {
+		return DeviceTokenInvalidError{Reason: resBody.Reason}
+	}
+
+	return fmt.Errorf("failed to send push notification: apns responded with status %d: %s", res.StatusCode, resBody.Reason)
+}
+
+// providerToken returns the signed JWT authenticating requests, issuing a new one when it gets old.
+func (s *APNsPushSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token != "" && now.Sub(s.issuedAt) < apnsTokenLifetime {
+		return s.token, nil
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": s.keyID})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal token header: %w", err)
+	}
+
+	claims, err := json.Marshal(map[string]any{"iss": s.teamID, "iat": now.Unix()})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal token claims: %w", err)
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	signature := make([]byte, 2*es256CoordinateSize) //nolint:mnd // r and s
+	r.FillBytes(signature[:es256CoordinateSize])
+	ss.FillBytes(signature[es256CoordinateSize:])
+
+	s.token = unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	s.issuedAt = now
+
+	return s.token, nil
+}
+
+// FCMPushSender sends push notifications through the HTTP v1 API of Firebase Cloud Messaging.
+type FCMPushSender struct {
+	endpoint    string
+	projectID   string
+	accessToken func(ctx context.Context) (string, error)
+	client      *http.Client
+}
+
+var _ PushSender = (*FCMPushSender)(nil)
+
+// NewFCMPushSender returns a sender using the FCM endpoint for the project. The access token is requested per
+// notification, e.g. from an OAuth 2.0 token source of a service account.
+func NewFCMPushSender(endpoint, projectID string, accessToken func(ctx context.Context) (string, error)) *FCMPushSender {
+	return &FCMPushSender{endpoint: endpoint, projectID: projectID, accessToken: accessToken, client: http.DefaultClient}
+}
+
+func (s *FCMPushSender) SendPush(ctx context.Context, token string, notification PushNotification) error {
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        token,
+			"notification": map[string]any{"title": notification.Title, "body": notification.Body},
+			"data":         notification.Data,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	accessToken, err := s.accessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	endpoint := s.endpoint + "/v1/projects/" + url.PathEscape(s.projectID) + "/messages:send"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send push notification: %w", err)
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return DeviceTokenInvalidError{Reason: "UNREGISTERED"}
+	case res.StatusCode >= http.StatusMultipleChoices:
+		return fmt.Errorf("failed to send push notification: fcm responded with status %d", res.StatusCode)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,466 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisRepoMaxRetries bounds the retries of a write whose keys other writes modified in between.
+const redisRepoMaxRetries = 16
+
+// redisPruneScript removes a name from an index set, unless its resource exists, e.g. as it was created again since
+// it expired.
+const redisPruneScript = `if redis.call("EXISTS", KEYS[2]) == 0 then return redis.call("SREM", KEYS[1], ARGV[1]) end return 0`
+
+// ErrRedisWriteConflict is returned for writes that kept conflicting with other writes of the same resources.
+var ErrRedisWriteConflict = errors.New("write conflicted with concurrent writes too many times")
+
+// RedisRepo is a ResourcesRepository storing resources in Redis.
+//
+// Every resource is a hash of its JSON encoding and resource version, and the names of the resources of a resource
+// type are kept in a set for listing. Writes are optimistic transactions, watching the hashes they read. All keys share
+// a hash tag, so transactions work on Redis Cluster too.
+type RedisRepo struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+	prune  *redis.Script
+}
+
+type RedisRepoOption func(repo *RedisRepo)
+
+// WithRedisRepoKeyPrefix prefixes the keys of the repository, "bass" by default, to share a Redis database.
+func WithRedisRepoKeyPrefix(prefix string) RedisRepoOption {
+	return func(repo *RedisRepo) {
+		repo.prefix = prefix
+	}
+}
+
+// WithRedisRepoTTL expires resources once they haven't been written for ttl, for cache-style deployments. Every write
+// of a resource renews its TTL. Items under legal hold don't expire until their hold is released.
+func WithRedisRepoTTL(ttl time.Duration) RedisRepoOption {
+	return func(repo *RedisRepo) {
+		repo.ttl = ttl
+	}
+}
+
+var (
+	_ ResourcesRepository     = (*RedisRepo)(nil)
+	_ TransactionalRepository = (*RedisRepo)(nil)
+	_ AtomicUpdater           = (*RedisRepo)(nil)
+	_ RawGetter               = (*RedisRepo)(nil)
+	_ ExpiringRepository      = (*RedisRepo)(nil)
+)
+
+func NewRedisRepo(client redis.UniversalClient, opts ...RedisRepoOption) *RedisRepo {
+	repo := &RedisRepo{
+		client: client,
+		prefix: "bass",
+		ttl:    0,
+		prune:  redis.NewScript(redisPruneScript),
+	}
+
+	for _, opt := range opts {
+		opt(repo)
+	}
+
+	return repo
+}
+
+func (repo *RedisRepo) List(ctx context.Context, packageName, apiVersion, resourceType string) (ResourceList, error) {
+	indexKey := repo.indexKey(packageName, resourceType)
+
+	names, err := repo.client.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return ResourceList{}, fmt.Errorf("failed to list resource names: %w", err)
+	}
+
+	slices.Sort(names)
+
+	cmds := make([]*redis.StringCmd, 0, len(names))
+
+	_, err = repo.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, name := range names {
+			cmds = append(cmds, pipe.HGet(ctx, repo.resourceKey(packageName, resourceType, name), "data"))
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return ResourceList{}, fmt.Errorf("failed to get resources: %w", err)
+	}
+
+	items := make([]*Resource, 0, len(cmds))
+
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if errors.Is(err, redis.Nil) {
+			// The resource expired, so its name is dropped from the index.
+			err = repo.prune.Run(ctx, repo.client, []string{indexKey, repo.resourceKey(packageName, resourceType, names[i])}, names[i]).Err()
+			if err != nil {
+				return ResourceList{}, fmt.Errorf("failed to prune resource name: %w", err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return ResourceList{}, fmt.Errorf("failed to get resource: %w", err)
+		}
+
+		item, err := decodeRedisResource(data)
+		if err != nil {
+			return ResourceList{}, err
+		}
+
+		items = append(items, item)
+	}
+
+	return newMemResourceList(packageName, apiVersion, resourceType, items), nil
+}
+
+func (repo *RedisRepo) Create(ctx context.Context, item *Resource) error {
+	return repo.write(ctx, []string{repo.resourceKey(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)}, func(txn *redisTxn) error {
+		return txn.create(item)
+	})
+}
+
+func (repo *RedisRepo) Get(ctx context.Context, packageName, resourceType, name string) (*Resource, error) {
+	data, err := repo.GetRaw(ctx, packageName, resourceType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRedisResource(data)
+}
+
+// GetRaw returns the JSON encoding of the resource, as it is stored.
+func (repo *RedisRepo) GetRaw(ctx context.Context, packageName, resourceType, name string) (jsontext.Value, error) {
+	data, err := repo.client.HGet(ctx, repo.resourceKey(packageName, resourceType, name), "data").Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ResourceNotFoundError{
+			PackageName:  packageName,
+			ResourceType: resourceType,
+			Name:         name,
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource: %w", err)
+	}
+
+	return data, nil
+}
+
+func (repo *RedisRepo) Update(ctx context.Context, item *Resource) error {
+	return repo.write(ctx, []string{repo.resourceKey(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)}, func(txn *redisTxn) error {
+		return txn.update(item, Precondition{})
+	})
+}
+
+func (repo *RedisRepo) Delete(ctx context.Context, packageName, resourceType, name string) error {
+	return repo.write(ctx, []string{repo.resourceKey(packageName, resourceType, name)}, func(txn *redisTxn) error {
+		return txn.delete(packageName, resourceType, name, Precondition{})
+	})
+}
+
+func (repo *RedisRepo) UpdateFunc(ctx context.Context, packageName, resourceType, name string, fn func(item *Resource) error) (*Resource, error) {
+	var item *Resource
+
+	err := repo.write(ctx, []string{repo.resourceKey(packageName, resourceType, name)}, func(txn *redisTxn) error {
+		var err error
+
+		item, err = txn.get(packageName, resourceType, name)
+		if err != nil {
+			return err
+		}
+
+		resourceVersion := item.Metadata.ResourceVersion
+
+		err = fn(item)
+		if err != nil {
+			return err
+		}
+
+		return txn.update(item, Precondition{UID: "", ResourceVersion: resourceVersion})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (repo *RedisRepo) Transact(ctx context.Context, ops []Operation) error {
+	keys := make([]string, 0, len(ops))
+	for _, op := range ops {
+		keys = append(keys, repo.resourceKey(op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name))
+	}
+
+	return repo.write(ctx, keys, func(txn *redisTxn) error {
+		for i, op := range ops {
+			err := txn.apply(op)
+			if err != nil {
+				return TransactionError{Index: i, Err: err}
+			}
+		}
+
+		return nil
+	})
+}
+
+// SetExpiring removes the TTL of the resource, or renews it if expiring. Without TTL, resources don't expire anyway.
+func (repo *RedisRepo) SetExpiring(ctx context.Context, packageName, resourceType, name string, expiring bool) error {
+	if repo.ttl == 0 {
+		return nil
+	}
+
+	key := repo.resourceKey(packageName, resourceType, name)
+
+	var err error
+
+	if expiring {
+		err = repo.client.Expire(ctx, key, repo.ttl).Err()
+	} else {
+		err = repo.client.Persist(ctx, key).Err()
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set expiry of resource: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepo) resourceKey(packageName, resourceType, name string) string {
+	return "{" + repo.prefix + "}:resource:" + packageName + ":" + resourceType + ":" + name
+}
+
+func (repo *RedisRepo) indexKey(packageName, resourceType string) string {
+	return "{" + repo.prefix + "}:index:" + packageName + ":" + resourceType
+}
+
+func (repo *RedisRepo) resourceVersionKey() string {
+	return "{" + repo.prefix + "}:resourceVersion"
+}
+
+// write runs fn on a transaction watching keys, and applies the writes of fn at once if fn succeeds. If other writes
+// modify the keys in between, it starts over.
+func (repo *RedisRepo) write(ctx context.Context, keys []string, fn func(txn *redisTxn) error) error {
+	for range redisRepoMaxRetries {
+		err := repo.client.Watch(ctx, func(tx *redis.Tx) error {
+			txn := &redisTxn{repo: repo, ctx: ctx, tx: tx, items: make(map[string]*Resource), writes: nil}
+
+			err := fn(txn)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, write := range txn.writes {
+					write(pipe)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to write resources: %w", err)
+			}
+
+			return nil
+		}, keys...)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+
+		return err //nolint:wrapcheck // errors of fn are typed or wrapped already
+	}
+
+	return ErrRedisWriteConflict
+}
+
+type redisTxn struct {
+	repo *RedisRepo
+	ctx  context.Context //nolint:containedctx // scoped to a single write
+	tx   *redis.Tx
+	// items holds the resources written in the transaction so far by key, and nil for deleted ones.
+	items  map[string]*Resource
+	writes []func(pipe redis.Pipeliner)
+}
+
+func (txn *redisTxn) get(packageName, resourceType, name string) (*Resource, error) {
+	notFound := ResourceNotFoundError{
+		PackageName:  packageName,
+		ResourceType: resourceType,
+		Name:         name,
+	}
+
+	key := txn.repo.resourceKey(packageName, resourceType, name)
+
+	item, ok := txn.items[key]
+	if ok {
+		if item == nil {
+			return nil, notFound
+		}
+
+		return item.DeepCopy(), nil
+	}
+
+	data, err := txn.tx.HGet(txn.ctx, key, "data").Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, notFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource: %w", err)
+	}
+
+	return decodeRedisResource(data)
+}
+
+func (txn *redisTxn) nextResourceVersion() (string, error) {
+	// The counter isn't watched, as versions only need to increase, not to be contiguous.
+	seq, err := txn.tx.Incr(txn.ctx, txn.repo.resourceVersionKey()).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get next resource version: %w", err)
+	}
+
+	return strconv.FormatInt(seq, 10), nil
+}
+
+func (txn *redisTxn) apply(op Operation) error {
+	switch op.Type {
+	case OperationCreate:
+		return txn.create(op.Item)
+	case OperationUpdate:
+		return txn.update(op.Item, op.Precondition)
+	case OperationDelete:
+		return txn.delete(op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name, op.Precondition)
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+}
+
+func (txn *redisTxn) create(item *Resource) error {
+	_, err := txn.get(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err == nil {
+		return ResourceExistsError{
+			PackageName:  item.Metadata.PackageName,
+			ResourceType: item.Metadata.ResourceType,
+			Name:         item.Metadata.Name,
+		}
+	}
+
+	var resourceNotFoundError ResourceNotFoundError
+	if !errors.As(err, &resourceNotFoundError) {
+		return err
+	}
+
+	item.Metadata.ResourceVersion, err = txn.nextResourceVersion()
+	if err != nil {
+		return err
+	}
+
+	return txn.put(item)
+}
+
+// update replaces a stored item. The UID and creation time of the stored item are kept. An item carrying a resource
+// version must match the stored one.
+func (txn *redisTxn) update(item *Resource, precondition Precondition) error {
+	current, err := txn.get(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err != nil {
+		return err
+	}
+
+	err = CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	if item.Metadata.ResourceVersion != "" && item.Metadata.ResourceVersion != current.Metadata.ResourceVersion {
+		return ResourceVersionConflictError{
+			PackageName:     item.Metadata.PackageName,
+			ResourceType:    item.Metadata.ResourceType,
+			Name:            item.Metadata.Name,
+			ResourceVersion: item.Metadata.ResourceVersion,
+		}
+	}
+
+	item.Metadata.UID = current.Metadata.UID
+	item.Metadata.CreatedAt = current.Metadata.CreatedAt
+
+	item.Metadata.ResourceVersion, err = txn.nextResourceVersion()
+	if err != nil {
+		return err
+	}
+
+	return txn.put(item)
+}
+
+func (txn *redisTxn) delete(packageName, resourceType, name string, precondition Precondition) error {
+	current, err := txn.get(packageName, resourceType, name)
+	if err != nil {
+		return err
+	}
+
+	err = CheckPrecondition(current, precondition)
+	if err != nil {
+		return err
+	}
+
+	key := txn.repo.resourceKey(packageName, resourceType, name)
+	indexKey := txn.repo.indexKey(packageName, resourceType)
+
+	txn.items[key] = nil
+	txn.writes = append(txn.writes, func(pipe redis.Pipeliner) {
+		pipe.Del(txn.ctx, key)
+		pipe.SRem(txn.ctx, indexKey, name)
+	})
+
+	return nil
+}
+
+// put stores the hash of the item and adds its name to the index, renewing its TTL, if any.
+func (txn *redisTxn) put(item *Resource) error {
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resource: %w", err)
+	}
+
+	key := txn.repo.resourceKey(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	indexKey := txn.repo.indexKey(item.Metadata.PackageName, item.Metadata.ResourceType)
+	name := item.Metadata.Name
+	resourceVersion := item.Metadata.ResourceVersion
+
+	txn.items[key] = item.DeepCopy()
+	txn.writes = append(txn.writes, func(pipe redis.Pipeliner) {
+		pipe.HSet(txn.ctx, key, "data", encoded, "resourceVersion", resourceVersion)
+
+		if txn.repo.ttl > 0 {
+			pipe.Expire(txn.ctx, key, txn.repo.ttl)
+		}
+
+		pipe.SAdd(txn.ctx, indexKey, name)
+	})
+
+	return nil
+}
+
+func decodeRedisResource(data []byte) (*Resource, error) {
+	var item Resource
+
+	err := json.Unmarshal(data, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resource: %w", err)
+	}
+
+	return &item, nil
+}
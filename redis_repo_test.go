@@ -0,0 +1,130 @@
+package bass_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nasermirzaei89/bass"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRepo(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	repo := bass.NewRedisRepo(client, bass.WithRedisRepoTTL(time.Hour))
+
+	newFoo := func(name string) *bass.Resource {
+		return &bass.Resource{
+			Metadata:   bass.Metadata{UID: "uid-" + name, PackageName: "test", APIVersion: "v1", ResourceType: "Foo", Name: name},
+			Properties: map[string]any{"n": 1.0},
+		}
+	}
+
+	// resources are hashes indexed by resource type
+	{
+		require.NoError(t, repo.Create(ctx, newFoo("foo1")))
+
+		assert.Equal(t, "1", server.HGet("{bass}:resource:test:Foo:foo1", "resourceVersion"))
+
+		members, err := server.Members("{bass}:index:test:Foo")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"foo1"}, members)
+	}
+
+	// failed transactions write nothing
+	{
+		err := repo.Transact(ctx, []bass.Operation{
+			{Type: bass.OperationCreate, Item: newFoo("foo2")},
+			{Type: bass.OperationCreate, Item: newFoo("foo1")},
+		})
+
+		var transactionError bass.TransactionError
+
+		require.ErrorAs(t, err, &transactionError)
+		assert.Equal(t, 1, transactionError.Index)
+
+		assert.False(t, server.Exists("{bass}:resource:test:Foo:foo2"))
+	}
+
+	// writes within a transaction see each other
+	{
+		require.NoError(t, repo.Transact(ctx, []bass.Operation{
+			{Type: bass.OperationCreate, Item: newFoo("foo2")},
+			{Type: bass.OperationUpdate, Item: &bass.Resource{Metadata: bass.Metadata{PackageName: "test", ResourceType: "Foo", Name: "foo2"}, Properties: map[string]any{"n": 2.0}}},
+		}))
+
+		item, err := repo.Get(ctx, "test", "Foo", "foo2")
+		require.NoError(t, err)
+
+		assert.Equal(t, "uid-foo2", item.Metadata.UID)
+		assert.Equal(t, map[string]any{"n": 2.0}, item.Properties)
+	}
+
+	// resources expire once they aren't written for the TTL
+	{
+		server.FastForward(30 * time.Minute)
+
+		_, err := repo.UpdateFunc(ctx, "test", "Foo", "foo1", func(item *bass.Resource) error {
+			item.Properties["n"] = 3.0
+
+			return nil
+		})
+		require.NoError(t, err)
+
+		server.FastForward(45 * time.Minute)
+
+		_, err = repo.Get(ctx, "test", "Foo", "foo2")
+
+		var resourceNotFoundError bass.ResourceNotFoundError
+
+		require.ErrorAs(t, err, &resourceNotFoundError)
+
+		res, err := repo.List(ctx, "test", "v1", "Foo")
+		require.NoError(t, err)
+
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, "foo1", res.Items[0].Metadata.Name)
+
+		members, err := server.Members("{bass}:index:test:Foo")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"foo1"}, members)
+	}
+
+	// resources can be kept from expiring, e.g. while they are under legal hold
+	{
+		require.NoError(t, repo.SetExpiring(ctx, "test", "Foo", "foo1", false))
+		assert.Zero(t, server.TTL("{bass}:resource:test:Foo:foo1"))
+
+		require.NoError(t, repo.SetExpiring(ctx, "test", "Foo", "foo1", true))
+		assert.Equal(t, time.Hour, server.TTL("{bass}:resource:test:Foo:foo1"))
+	}
+}
+
+func TestRedisRepoModel(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	n := 0
+
+	checkRepositoryModel(t, func() bass.ResourcesRepository {
+		n++
+
+		return bass.NewRedisRepo(client, bass.WithRedisRepoKeyPrefix(strconv.Itoa(n)))
+	})
+}
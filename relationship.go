@@ -0,0 +1,288 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	ResourceReference = apiv1.ResourceReference
+	Relationship      = apiv1.Relationship
+)
+
+// maxRelatedDepth limits how many edges a traversal may follow from the start resource.
+const maxRelatedDepth = 10
+
+func resourceReferenceSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"packageName":  map[string]any{"type": "string"},
+			"resourceType": map[string]any{"type": "string"},
+			"name":         map[string]any{"type": "string"},
+		},
+		"required": []any{"packageName", "resourceType", "name"},
+	}
+}
+
+func relationshipResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "Relationship.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "Relationship",
+		Plural:       "Relationships",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"edge": map[string]any{"type": "string", "minLength": 1},
+						"from": resourceReferenceSchema(),
+						"to":   resourceReferenceSchema(),
+					},
+					"required": []any{"edge", "from", "to"},
+				},
+			},
+		},
+	}
+}
+
+func relationshipFromResource(item *Resource) (*Relationship, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal relationship properties: %w", err)
+	}
+
+	var relationship Relationship
+
+	err = json.Unmarshal(b, &relationship)
+	if err != nil {
+		return nil, fmt.Errorf("relationship %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	relationship.Metadata = item.Metadata
+
+	return &relationship, nil
+}
+
+// relatedGraph maps resources to the resources their relationships of the edge type point to, or from with inverse.
+// An empty edge type matches all relationships.
+func (h *Handler) relatedGraph(r *http.Request, edge string, inverse bool) (map[ResourceReference][]ResourceReference, error) {
+	list, err := h.repoList(r.Context(), corePackageName, "v1", "Relationship")
+	if err != nil {
+		return nil, err
+	}
+
+	graph := make(map[ResourceReference][]ResourceReference)
+
+	for _, item := range list.Items {
+		relationship, err := relationshipFromResource(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if edge != "" && relationship.Edge != edge {
+			continue
+		}
+
+		from, to := relationship.From, relationship.To
+		if inverse {
+			from, to = to, from
+		}
+
+		graph[from] = append(graph[from], to)
+	}
+
+	return graph, nil
+}
+
+// handleListRelatedResources lists the resources reachable from an item over relationships, breadth first, up to
+// the depth query parameter (1 by default). The edge query parameter limits the relationships to an edge type, and
+// direction=in follows relationships backwards.
+func (h *Handler) handleListRelatedResources() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := r.PathValue("packageName")
+		apiVersion := r.PathValue("apiVersion")
+		resourceTypePlural := r.PathValue("resourceTypePlural")
+		name := r.PathValue("name")
+
+		depth := 1
+
+		if value := r.URL.Query().Get("depth"); value != "" {
+			var err error
+
+			depth, err = strconv.Atoi(value)
+			if err != nil || depth < 1 || depth > maxRelatedDepth {
+				respond.Done(w, r, problem.BadRequest(fmt.Sprintf("depth query parameter must be between 1 and %d", maxRelatedDepth), withReason(apierrors.ReasonInvalid)))
+
+				return
+			}
+		}
+
+		direction := r.URL.Query().Get("direction")
+		if direction != "" && direction != "out" && direction != "in" {
+			respond.Done(w, r, problem.BadRequest(`direction query parameter must be "out" or "in"`, withReason(apierrors.ReasonInvalid)))
+
+			return
+		}
+
+		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		_, err = h.repoGet(r.Context(), packageName, resourceTypeDefinition.ResourceType, name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get resource", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		graph, err := h.relatedGraph(r, r.URL.Query().Get("edge"), direction == "in")
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to list relationships", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		start := ResourceReference{PackageName: packageName, ResourceType: resourceTypeDefinition.ResourceType, Name: name}
+
+		items, err := h.traverseRelated(r, graph, start, depth)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get related resource", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		if present := h.itemAnonymizer(r.Context(), privilegedRead(r)); present != nil {
+			for i, item := range items {
+				items[i] = present(item)
+			}
+		}
+
+		res := ResourceList{
+			Metadata: ListMetadata{
+				PackageName:  packageName,
+				APIVersion:   apiVersion,
+				ResourceType: "List",
+				Continue:     "",
+			},
+			Items: items,
+		}
+
+		respond.Done(w, r, res)
+	}
+}
+
+// traverseRelated returns the resources reachable from start in the graph within depth edges, breadth first.
+// Resources the API key of the request, if any, may not get are left out, and not traversed.
+func (h *Handler) traverseRelated(r *http.Request, graph map[ResourceReference][]ResourceReference, start ResourceReference, depth int) ([]*Resource, error) {
+	items := make([]*Resource, 0)
+	visited := map[ResourceReference]bool{start: true}
+	frontier := []ResourceReference{start}
+	readable := make(map[ResourceReference]bool)
+
+	for range depth {
+		var next []ResourceReference
+
+		for _, from := range frontier {
+			for _, to := range graph[from] {
+				if visited[to] {
+					continue
+				}
+
+				visited[to] = true
+
+				ok, err := h.relatedReadable(r.Context(), readable, to)
+				if err != nil {
+					return nil, err
+				}
+
+				if !ok {
+					continue
+				}
+
+				item, err := h.repoGet(r.Context(), to.PackageName, to.ResourceType, to.Name)
+				if err != nil {
+					var resourceNotFoundError ResourceNotFoundError
+					if errors.As(err, &resourceNotFoundError) {
+						// relationships may outlive the resources they point to
+						continue
+					}
+
+					return nil, err
+				}
+
+				items = append(items, item)
+				next = append(next, to)
+			}
+		}
+
+		frontier = next
+	}
+
+	return items, nil
+}
+
+// relatedReadable reports whether the API key of ctx, if any, may get a related resource. Results are cached in
+// readable by resource type, as references without names.
+func (h *Handler) relatedReadable(ctx context.Context, readable map[ResourceReference]bool, ref ResourceReference) (bool, error) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(APIKey)
+	if !ok {
+		return true, nil
+	}
+
+	resourceType := ResourceReference{PackageName: ref.PackageName, ResourceType: ref.ResourceType, Name: ""}
+
+	allowed, checked := readable[resourceType]
+	if checked {
+		return allowed, nil
+	}
+
+	err := h.checkAPIKeyAccess(ctx, key, APIKeyVerbGet, ref.PackageName, strings.ToLower(ref.ResourceType))
+
+	var apiKeyPermissionError APIKeyPermissionError
+
+	switch {
+	case err == nil:
+		allowed = true
+	case errors.As(err, &apiKeyPermissionError):
+		allowed = false
+	default:
+		return false, err
+	}
+
+	readable[resourceType] = allowed
+
+	return allowed, nil
+}
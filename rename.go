@@ -0,0 +1,340 @@
+package bass
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type RenameRequest = apiv1.RenameRequest
+
+type ResourceReferencedError struct {
+	PackageName   string
+	ResourceType  string
+	Name          string
+	Relationships []string
+}
+
+func (err ResourceReferencedError) Error() string {
+	return fmt.Sprintf("resource with name %q and resource type %q and package %q is referenced by relationships %q", err.Name, err.ResourceType, err.PackageName, err.Relationships)
+}
+
+// handleRenameResource renames an item and optionally moves it to another package, keeping its UID, creation time
+// and activity. The old item is deleted and the new one created in a single transaction.
+func (h *Handler) handleRenameResource(w http.ResponseWriter, r *http.Request, name string) {
+	packageName := r.PathValue("packageName")
+	apiVersion := r.PathValue("apiVersion")
+	resourceTypePlural := r.PathValue("resourceTypePlural")
+
+	transactionalRepo, ok := h.repo.(TransactionalRepository)
+	if !ok {
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusNotImplemented),
+			problem.WithTitle("Not Implemented"),
+			problem.WithDetail("repository does not support transactions"),
+			withReason(apierrors.ReasonNotImplemented),
+		))
+
+		return
+	}
+
+	var req RenameRequest
+
+	err := json.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+		default:
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+		}
+
+		return
+	}
+
+	if req.NewName == "" {
+		req.NewName = name
+	}
+
+	if req.NewPackageName == "" {
+		req.NewPackageName = packageName
+	}
+
+	if req.NewName == name && req.NewPackageName == packageName {
+		respond.Done(w, r, problem.BadRequest("rename without new name or package", withReason(apierrors.ReasonBadRequest)))
+
+		return
+	}
+
+	if !h.checkRenameTarget(w, r, packageName, resourceTypePlural, req) {
+		return
+	}
+
+	ops, item, err := h.renameOperations(r, packageName, resourceTypePlural, name, req)
+	if err == nil {
+		err = transactionalRepo.Transact(r.Context(), ops)
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to rename resource", "error", err)
+
+		var (
+			resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
+			resourceNotFoundError               ResourceNotFoundError
+			resourceExistsError                 ResourceExistsError
+			resourceReferencedError             ResourceReferencedError
+			preconditionFailedError             PreconditionFailedError
+			unknownFieldsError                  UnknownFieldsError
+			resourceInvalidError                ResourceInvalidError
+			contentRejectedError                ContentRejectedError
+		)
+
+		switch {
+		case errors.As(err, &resourceTypeDefinitionNotFoundError):
+			respond.Done(w, r, problem.BadRequest(resourceTypeDefinitionNotFoundError.Error(), withReason(apierrors.ReasonBadRequest)))
+		case errors.As(err, &resourceNotFoundError):
+			h.resourceNotFound(w, r, resourceNotFoundError)
+		case errors.As(err, &resourceExistsError):
+			respond.Done(w, r, problem.Conflict(resourceExistsError.Error(), withReason(apierrors.ReasonAlreadyExists)))
+		case errors.As(err, &resourceReferencedError):
+			respond.Done(w, r, problem.Conflict(resourceReferencedError.Error(), problem.WithExtension("relationships", resourceReferencedError.Relationships), withReason(apierrors.ReasonConflict)))
+		case errors.As(err, &preconditionFailedError):
+			respond.Done(w, r, problem.Conflict(preconditionFailedError.Error(), withReason(apierrors.ReasonConflict)))
+		case errors.As(err, &unknownFieldsError):
+			respond.Done(w, r, problem.BadRequest(unknownFieldsError.Error(), problem.WithExtension("fields", unknownFieldsError.Fields), withReason(apierrors.ReasonUnknownFields)))
+		case errors.As(err, &resourceInvalidError):
+			respond.Done(w, r, problem.BadRequest(resourceInvalidError.Error(), problem.WithExtension("errors", resourceInvalidError.Errors), withReason(apierrors.ReasonInvalid)))
+		case errors.As(err, &contentRejectedError):
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusUnprocessableEntity),
+				problem.WithTitle("Unprocessable Entity"),
+				problem.WithDetail(contentRejectedError.Error()),
+				problem.WithExtension("findings", contentRejectedError.Findings),
+				withReason(apierrors.ReasonContentRejected),
+			))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	for _, op := range ops {
+		h.resourceWritten(r.Context(), op.Type, op.Item)
+	}
+
+	w.Header().Set("Location", h.resourcePath(req.NewPackageName, apiVersion, resourceTypePlural, req.NewName))
+	respond.Done(w, r, item)
+}
+
+// checkRenameTarget checks that a rename may delete the item, and create the renamed one, as if they were requested
+// separately: the API key must allow both, and items moved to another package are subject to the network and approval
+// policies of that package. If not, it responds with the failure and returns false.
+func (h *Handler) checkRenameTarget(w http.ResponseWriter, r *http.Request, packageName, resourceTypePlural string, req RenameRequest) bool {
+	if key, ok := r.Context().Value(apiKeyContextKey{}).(APIKey); ok {
+		err := h.checkAPIKeyAccess(r.Context(), key, APIKeyVerbDelete, packageName, resourceTypePlural)
+		if err == nil {
+			err = h.checkAPIKeyAccess(r.Context(), key, APIKeyVerbCreate, req.NewPackageName, resourceTypePlural)
+		}
+
+		if err != nil {
+			h.respondAPIKeyDenied(w, r, key, err)
+
+			return false
+		}
+	}
+
+	if req.NewPackageName == packageName {
+		return true
+	}
+
+	err := h.checkNetworkAccess(r, req.NewPackageName)
+	if err != nil {
+		h.respondNetworkAccessDenied(w, r, err)
+
+		return false
+	}
+
+	// Failures to resolve the resource type in the package are left to renameOperations.
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), req.NewPackageName, resourceTypePlural)
+	if err == nil && resourceTypeDefinition.Approval != nil {
+		respond.Done(w, r, approvalRequired(ApprovalRequiredError{
+			PackageName:  resourceTypeDefinition.Package,
+			ResourceType: resourceTypeDefinition.ResourceType,
+		}))
+
+		return false
+	}
+
+	return true
+}
+
+// renameOperations builds the transaction renaming an item: the deletion of the old item, the creation of the
+// renamed one, and the updates of its relationships and activity. All of them are conditioned on the state read.
+func (h *Handler) renameOperations(r *http.Request, packageName, resourceTypePlural, name string, req RenameRequest) ([]Operation, *Resource, error) {
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targetResourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), req.NewPackageName, resourceTypePlural)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if targetResourceTypeDefinition.ResourceType != resourceTypeDefinition.ResourceType {
+		return nil, nil, ResourceTypeDefinitionNotFoundError{PackageName: req.NewPackageName, ResourceTypePlural: resourceTypePlural}
+	}
+
+	current, err := h.repoGet(r.Context(), packageName, resourceTypeDefinition.ResourceType, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	item := current.DeepCopy()
+	item.Metadata.PackageName = req.NewPackageName
+	item.Metadata.Name = req.NewName
+	item.Metadata.ResourceVersion = ""
+	item.Metadata.UpdatedAt = h.now()
+
+	err = h.checkResourceItem(r.Context(), targetResourceTypeDefinition, item)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Items moved to another package are moderated as they would be if created there. Writes that would be
+	// quarantined are rejected, like in transactions.
+	if req.NewPackageName != packageName {
+		err = h.moderateOperation(r.Context(), targetResourceTypeDefinition, item)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ops := []Operation{
+		{Type: OperationDelete, Item: current, Precondition: Precondition{UID: current.Metadata.UID, ResourceVersion: current.Metadata.ResourceVersion}},
+		{Type: OperationCreate, Item: item, Precondition: Precondition{UID: "", ResourceVersion: ""}},
+	}
+
+	from := ResourceReference{PackageName: packageName, ResourceType: resourceTypeDefinition.ResourceType, Name: name}
+	to := ResourceReference{PackageName: req.NewPackageName, ResourceType: resourceTypeDefinition.ResourceType, Name: req.NewName}
+
+	relationshipOps, err := h.renameRelationshipOperations(r, from, to, req.UpdateReferences)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	activityOps, err := h.renameActivityOperations(r, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aliasOps, err := h.renameAliasOperations(r.Context(), from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return slices.Concat(ops, relationshipOps, activityOps, aliasOps), item, nil
+}
+
+func (h *Handler) renameRelationshipOperations(r *http.Request, from, to ResourceReference, updateReferences bool) ([]Operation, error) {
+	list, err := h.repoList(r.Context(), corePackageName, "v1", "Relationship")
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]Operation, 0)
+
+	for _, item := range list.Items {
+		relationship, err := relationshipFromResource(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if relationship.From != from && relationship.To != from {
+			continue
+		}
+
+		updated := item.DeepCopy()
+		updated.Metadata.UpdatedAt = h.now()
+
+		for _, key := range []string{"from", "to"} {
+			if relationshipReference(updated.Properties[key]) == from {
+				updated.Properties[key] = map[string]any{
+					"packageName":  to.PackageName,
+					"resourceType": to.ResourceType,
+					"name":         to.Name,
+				}
+			}
+		}
+
+		ops = append(ops, Operation{
+			Type:         OperationUpdate,
+			Item:         updated,
+			Precondition: Precondition{UID: item.Metadata.UID, ResourceVersion: item.Metadata.ResourceVersion},
+		})
+	}
+
+	if len(ops) > 0 && !updateReferences {
+		referenced := make([]string, 0, len(ops))
+		for _, op := range ops {
+			referenced = append(referenced, op.Item.Metadata.Name)
+		}
+
+		return nil, ResourceReferencedError{
+			PackageName:   from.PackageName,
+			ResourceType:  from.ResourceType,
+			Name:          from.Name,
+			Relationships: referenced,
+		}
+	}
+
+	return ops, nil
+}
+
+func relationshipReference(value any) ResourceReference {
+	m, _ := value.(map[string]any)
+	packageName, _ := m["packageName"].(string)
+	resourceType, _ := m["resourceType"].(string)
+	name, _ := m["name"].(string)
+
+	return ResourceReference{PackageName: packageName, ResourceType: resourceType, Name: name}
+}
+
+func (h *Handler) renameActivityOperations(r *http.Request, from, to ResourceReference) ([]Operation, error) {
+	list, err := h.listActivity(r.Context(), from.PackageName, from.ResourceType, from.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]Operation, 0, len(list.Items))
+
+	for _, item := range list.Items {
+		updated := item.DeepCopy()
+		updated.Metadata.Labels[activityParentLabel] = resourceLabelValue(to.PackageName, to.ResourceType, to.Name)
+		updated.Properties["parent"] = map[string]any{
+			"packageName":  to.PackageName,
+			"resourceType": to.ResourceType,
+			"name":         to.Name,
+		}
+
+		ops = append(ops, Operation{
+			Type:         OperationUpdate,
+			Item:         updated,
+			Precondition: Precondition{UID: item.Metadata.UID, ResourceVersion: item.Metadata.ResourceVersion},
+		})
+	}
+
+	return ops, nil
+}
@@ -0,0 +1,305 @@
+package bass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	ReplicationEvent = apiv1.ReplicationEvent
+	ConflictPolicy   = apiv1.ConflictPolicy
+)
+
+const (
+	ConflictLastWriterWins = apiv1.ConflictLastWriterWins
+	ConflictReject         = apiv1.ConflictReject
+)
+
+// replicationQueueSize is the number of writes buffered for replication. Writes beyond it are dropped, and logged.
+const replicationQueueSize = 1024
+
+// Replication configures the asynchronous forwarding of the writes made through the handler to replicas, other bass
+// instances receiving them on "POST /admin/replication".
+type Replication struct {
+	// Targets are the base URLs of the replicas.
+	Targets []string
+	// ConflictPolicy is how replicas handle writes conflicting with their own. It defaults to last writer wins.
+	ConflictPolicy ConflictPolicy
+	// Client sends the writes. It defaults to http.DefaultClient.
+	Client *http.Client
+	// SigningKeyID and SigningKey sign the writes, for replicas verifying signed requests.
+	SigningKeyID string
+	SigningKey   []byte
+}
+
+type ReplicationConflictError struct {
+	PackageName  string
+	ResourceType string
+	Name         string
+}
+
+func (err ReplicationConflictError) Error() string {
+	return fmt.Sprintf("replicated write of resource with name %q and resource type %q and package %q conflicts with a later write", err.Name, err.ResourceType, err.PackageName)
+}
+
+// ReplicaError is a failure of a replica to apply a replicated write. Failures of the replica itself, rather than
+// of the write, are transient.
+type ReplicaError struct {
+	Target string
+	Err    error
+}
+
+func (err ReplicaError) Error() string {
+	return fmt.Sprintf("failed to replicate to %s: %s", err.Target, err.Err)
+}
+
+func (err ReplicaError) Unwrap() error {
+	return err.Err
+}
+
+func (err ReplicaError) Transient() bool {
+	var statusError *apierrors.StatusError
+	if errors.As(err.Err, &statusError) {
+		return statusError.Status >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+type replicatedContextKey struct{}
+
+// isReplicated reports whether ctx is of applying a replicated write, which isn't forwarded again.
+func isReplicated(ctx context.Context) bool {
+	replicated, _ := ctx.Value(replicatedContextKey{}).(bool)
+
+	return replicated
+}
+
+// replicate queues a write for replication, unless it is replicated itself.
+func (h *Handler) replicate(ctx context.Context, operation OperationType, item *Resource) {
+	if h.replication == nil || isReplicated(ctx) {
+		return
+	}
+
+	event := ReplicationEvent{Type: operation, At: h.now(), Item: item.DeepCopy(), ConflictPolicy: h.replication.ConflictPolicy}
+
+	select {
+	case h.replicationQueue <- event:
+	default:
+		h.logger.ErrorContext(ctx, "replication queue is full, dropping write", "package", item.Metadata.PackageName, "resourceType", item.Metadata.ResourceType, "name", item.Metadata.Name)
+	}
+}
+
+// RunReplication forwards the queued writes to the replicas, in order, until ctx is done. Writes failing transiently
+// are retried with the retry policy; writes still failing are logged and dropped.
+func (h *Handler) RunReplication(ctx context.Context) {
+	if h.replication == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-h.replicationQueue:
+			for _, target := range h.replication.Targets {
+				err := h.retry(ctx, func() error {
+					return h.sendReplicationEvent(ctx, target, event)
+				})
+				if err != nil {
+					h.logger.ErrorContext(ctx, "failed to replicate write", "target", target, "name", event.Item.Metadata.Name, "error", err)
+				}
+			}
+		}
+	}
+}
+
+func (h *Handler) sendReplicationEvent(ctx context.Context, target string, event ReplicationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replication event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target+"/admin/replication", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if h.replication.SigningKeyID != "" {
+		err = SignRequest(req, h.replication.SigningKeyID, h.replication.SigningKey, h.now())
+		if err != nil {
+			return err
+		}
+	}
+
+	client := h.replication.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return ReplicaError{Target: target, Err: err}
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	err = apierrors.FromResponse(res)
+	if err != nil {
+		return ReplicaError{Target: target, Err: err}
+	}
+
+	return nil
+}
+
+// handleReplication applies a write replicated from another bass instance, resolving conflicts with the policy of
+// the event. Replicated writes skip validation, as the source validated them.
+func (h *Handler) handleReplication() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var event ReplicationEvent
+
+		err := json.UnmarshalRead(r.Body, &event)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		if event.Item == nil || event.Item.Metadata.Name == "" {
+			respond.Done(w, r, problem.BadRequest("replication event without item", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		ctx := contextWithWriteOrigin(context.WithValue(r.Context(), replicatedContextKey{}, true), originReplication)
+
+		err = h.applyReplicationEvent(ctx, event)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to apply replicated write", "error", err)
+
+			var (
+				replicationConflictError ReplicationConflictError
+				invalidOperationError    InvalidOperationError
+				legalHoldError           LegalHoldError
+			)
+
+			switch {
+			case errors.As(err, &replicationConflictError):
+				respond.Done(w, r, problem.Conflict(replicationConflictError.Error(), withReason(apierrors.ReasonConflict)))
+			case errors.As(err, &legalHoldError):
+				respond.Done(w, r, legalHold(legalHoldError))
+			case errors.As(err, &invalidOperationError):
+				respond.Done(w, r, problem.BadRequest(invalidOperationError.Error(), withReason(apierrors.ReasonBadRequest)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		respond.Done(w, r, nil)
+	}
+}
+
+func (h *Handler) applyReplicationEvent(ctx context.Context, event ReplicationEvent) error {
+	metadata := event.Item.Metadata
+
+	current, err := h.repoGet(ctx, metadata.PackageName, metadata.ResourceType, metadata.Name)
+	if err != nil {
+		var resourceNotFoundError ResourceNotFoundError
+		if !errors.As(err, &resourceNotFoundError) {
+			return err
+		}
+
+		current = nil
+	}
+
+	// Sends of writes are retried, so a write may be sent again after it was applied, if its response was lost.
+	replayed := current != nil && event.Type != OperationDelete && current.Metadata.UID != "" &&
+		current.Metadata.UID == metadata.UID && current.Metadata.UpdatedAt.Equal(metadata.UpdatedAt)
+	if replayed {
+		return nil
+	}
+
+	writtenAt := event.Item.Metadata.UpdatedAt
+	if event.Type == OperationDelete {
+		writtenAt = event.At
+	}
+
+	conflict := current != nil && (current.Metadata.UpdatedAt.After(writtenAt) ||
+		event.Type == OperationCreate && event.ConflictPolicy == ConflictReject)
+	if conflict {
+		if event.ConflictPolicy == ConflictReject {
+			return ReplicationConflictError{PackageName: metadata.PackageName, ResourceType: metadata.ResourceType, Name: metadata.Name}
+		}
+
+		// The local write is later, so it wins.
+		return nil
+	}
+
+	return h.writeReplicatedItem(ctx, event.Type, current, event.Item)
+}
+
+func (h *Handler) writeReplicatedItem(ctx context.Context, operation OperationType, current, item *Resource) error {
+	item.Metadata.ResourceVersion = ""
+
+	// Legal holds are replicated like other items, but replicated writes may not modify the items they hold.
+	if current != nil && !isLegalHold(item.Metadata.PackageName, item.Metadata.ResourceType) {
+		err := h.checkLegalHold(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch operation {
+	case OperationCreate, OperationUpdate:
+		var err error
+
+		if current == nil {
+			operation = OperationCreate
+			err = h.repo.Create(ctx, item)
+		} else {
+			operation = OperationUpdate
+			err = h.repo.Update(ctx, item)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write replicated resource: %w", err)
+		}
+	case OperationDelete:
+		if current == nil {
+			return nil
+		}
+
+		err := h.repoDelete(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+		if err != nil {
+			return err
+		}
+	default:
+		return InvalidOperationError{Op: operation, Name: item.Metadata.Name}
+	}
+
+	h.resourceWritten(ctx, operation, item)
+
+	return nil
+}
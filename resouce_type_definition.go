@@ -2,21 +2,20 @@ package bass
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
 )
 
-type ResourceTypeDefinition struct {
-	Metadata     Metadata                        `json:"metadata"`
-	Package      string                          `json:"package"`
-	Versions     []ResourceTypeDefinitionVersion `json:"versions"`
-	ResourceType string                          `json:"resourceType"`
-	Plural       string                          `json:"plural"`
-}
+const corePackageName = "core"
 
-type ResourceTypeDefinitionVersion struct {
-	Name   string         `json:"name"`
-	Schema map[string]any `json:"schema"`
-}
+type (
+	ResourceTypeDefinition        = apiv1.ResourceTypeDefinition
+	ResourceTypeDefinitionVersion = apiv1.ResourceTypeDefinitionVersion
+)
 
 type ResourceTypeDefinitionNotFoundError struct {
 	PackageName        string
@@ -27,10 +26,10 @@ func (err ResourceTypeDefinitionNotFoundError) Error() string {
 	return fmt.Sprintf("resource type definition not found for package %q and resource type %q", err.PackageName, err.ResourceTypePlural)
 }
 
+// getResourceTypeDefinition resolves the resource type definition addressed by a URL segment. Besides the plural,
+// the segment can be the singular name, a short name, or a singular the pluralize client turns into the plural.
 func (h *Handler) getResourceTypeDefinition(ctx context.Context, packageName, resourceTypePlural string) (*ResourceTypeDefinition, error) {
-	name := resourceTypePlural + "." + packageName
-
-	if packageName == "core" {
+	if packageName == corePackageName {
 		resourceTypeDefinition, err := h.getCoreResourceTypeDefinition(ctx, resourceTypePlural)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get core resource type definition: %w", err)
@@ -39,14 +38,110 @@ func (h *Handler) getResourceTypeDefinition(ctx context.Context, packageName, re
 		return resourceTypeDefinition, nil
 	}
 
-	item, err := h.repo.Get(ctx, "core", "ResourceTypeDefinition", name)
+	notFoundErr := ResourceTypeDefinitionNotFoundError{
+		PackageName:        packageName,
+		ResourceTypePlural: resourceTypePlural,
+	}
+
+	candidates := []string{resourceTypePlural}
+
+	plural := h.pluralizeClient.Plural(resourceTypePlural)
+	if plural != resourceTypePlural {
+		candidates = append(candidates, plural)
+	}
+
+	for _, candidate := range candidates {
+		item, err := h.repoGet(ctx, corePackageName, "ResourceTypeDefinition", candidate+"."+packageName)
+		if err == nil {
+			return h.parseResourceTypeDefinition(ctx, item)
+		}
+
+		var resourceNotFoundError ResourceNotFoundError
+		if !errors.As(err, &resourceNotFoundError) {
+			return nil, fmt.Errorf("failed to get resource type definition: %w", err)
+		}
+	}
+
+	resourceTypeDefinitions, err := h.listResourceTypeDefinitions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resourceTypeDefinition := range resourceTypeDefinitions {
+		if slices.Contains(resourceTypeDefinitionNames(resourceTypeDefinition), resourceTypePlural) {
+			return resourceTypeDefinition, nil
+		}
+	}
+
+	return nil, notFoundErr
+}
+
+// listResourceTypeDefinitions returns the resource type definitions registered for the package.
+func (h *Handler) listResourceTypeDefinitions(ctx context.Context, packageName string) ([]*ResourceTypeDefinition, error) {
+	list, err := h.repoList(ctx, corePackageName, "v1", "ResourceTypeDefinition")
 	if err != nil {
-		return nil, ResourceTypeDefinitionNotFoundError{
-			PackageName:        packageName,
-			ResourceTypePlural: resourceTypePlural,
+		return nil, fmt.Errorf("failed to list resource type definitions: %w", err)
+	}
+
+	res := make([]*ResourceTypeDefinition, 0, len(list.Items))
+
+	for _, item := range list.Items {
+		if item.Properties["package"] != packageName {
+			continue
 		}
+
+		resourceTypeDefinition, err := h.parseResourceTypeDefinition(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, resourceTypeDefinition)
+	}
+
+	return res, nil
+}
+
+// listResourceTypeDefinitionsInCategory returns the resource type definitions of the package in the category.
+func (h *Handler) listResourceTypeDefinitionsInCategory(ctx context.Context, packageName, category string) ([]*ResourceTypeDefinition, error) {
+	resourceTypeDefinitions, err := h.listResourceTypeDefinitions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(resourceTypeDefinitions, func(resourceTypeDefinition *ResourceTypeDefinition) bool {
+		return !slices.Contains(resourceTypeDefinition.Categories, category)
+	}), nil
+}
+
+// resourceTypeDefinitionNames returns all names the resource type can be addressed with in URLs.
+func resourceTypeDefinitionNames(resourceTypeDefinition *ResourceTypeDefinition) []string {
+	singular := resourceTypeDefinition.Singular
+	if singular == "" {
+		singular = strings.ToLower(resourceTypeDefinition.ResourceType)
+	}
+
+	return append([]string{resourceTypeDefinition.Plural, singular}, resourceTypeDefinition.ShortNames...)
+}
+
+// parseResourceTypeDefinition parses a resource type definition and resolves the schema definitions its schemas
+// refer to.
+func (h *Handler) parseResourceTypeDefinition(ctx context.Context, item *Resource) (*ResourceTypeDefinition, error) {
+	resourceTypeDefinition, err := resourceTypeDefinitionFromResource(item)
+	if err != nil {
+		return nil, err
 	}
 
+	err = h.resolveResourceTypeDefinition(ctx, resourceTypeDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	return resourceTypeDefinition, nil
+}
+
+func resourceTypeDefinitionFromResource(item *Resource) (*ResourceTypeDefinition, error) {
+	name := item.Metadata.Name
+
 	resourceTypeDefinition := &ResourceTypeDefinition{
 		Metadata:     item.Metadata,
 		Package:      item.Properties["package"].(string),
@@ -54,6 +149,75 @@ func (h *Handler) getResourceTypeDefinition(ctx context.Context, packageName, re
 		Plural:       item.Properties["plural"].(string),
 	}
 
+	if singular, ok := item.Properties["singular"].(string); ok {
+		resourceTypeDefinition.Singular = singular
+	}
+
+	if shortNames, ok := item.Properties["shortNames"].([]any); ok {
+		for _, shortName := range shortNames {
+			shortName, ok := shortName.(string)
+			if !ok {
+				return nil, fmt.Errorf("resource type %q has invalid short names property", name)
+			}
+
+			resourceTypeDefinition.ShortNames = append(resourceTypeDefinition.ShortNames, shortName)
+		}
+	}
+
+	if categories, ok := item.Properties["categories"].([]any); ok {
+		for _, category := range categories {
+			category, ok := category.(string)
+			if !ok {
+				return nil, fmt.Errorf("resource type %q has invalid categories property", name)
+			}
+
+			resourceTypeDefinition.Categories = append(resourceTypeDefinition.Categories, category)
+		}
+	}
+
+	timeSeries, err := timeSeriesFromProperties(name, item.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceTypeDefinition.TimeSeries = timeSeries
+
+	stateMachine, err := stateMachineFromProperties(name, item.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceTypeDefinition.StateMachine = stateMachine
+
+	approval, err := approvalPolicyFromProperties(name, item.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceTypeDefinition.Approval = approval
+
+	if drafts, ok := item.Properties["drafts"].(bool); ok {
+		resourceTypeDefinition.Drafts = drafts
+	}
+
+	localization, err := localizationPolicyFromProperties(name, item.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceTypeDefinition.Localization = localization
+
+	moderation, err := moderationPolicyFromProperties(name, item.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceTypeDefinition.Moderation = moderation
+
+	if erasure, ok := item.Properties["erasure"].(string); ok {
+		resourceTypeDefinition.Erasure = ErasureAction(erasure)
+	}
+
 	versions, ok := item.Properties["versions"].([]any)
 	if !ok {
 		return nil, fmt.Errorf("resource type %q has invalid versions property", name)
@@ -70,9 +234,20 @@ func (h *Handler) getResourceTypeDefinition(ctx context.Context, packageName, re
 			return nil, fmt.Errorf("resource type %q has invalid schema property in version", name)
 		}
 
-		resourceTypeDefinition.Versions = append(resourceTypeDefinition.Versions, ResourceTypeDefinitionVersion{
-			Schema: schema,
-		})
+		resourceTypeDefinitionVersion := ResourceTypeDefinitionVersion{
+			Name:               "",
+			Schema:             schema,
+			Deprecated:         false,
+			DeprecationWarning: "",
+			Sunset:             "",
+		}
+
+		resourceTypeDefinitionVersion.Name, _ = versionMap["name"].(string)
+		resourceTypeDefinitionVersion.Deprecated, _ = versionMap["deprecated"].(bool)
+		resourceTypeDefinitionVersion.DeprecationWarning, _ = versionMap["deprecationWarning"].(string)
+		resourceTypeDefinitionVersion.Sunset, _ = versionMap["sunset"].(string)
+
+		resourceTypeDefinition.Versions = append(resourceTypeDefinition.Versions, resourceTypeDefinitionVersion)
 	}
 
 	return resourceTypeDefinition, nil
@@ -80,27 +255,142 @@ func (h *Handler) getResourceTypeDefinition(ctx context.Context, packageName, re
 
 func (h *Handler) getCoreResourceTypeDefinition(_ context.Context, resourceTypePlural string) (*ResourceTypeDefinition, error) {
 	switch resourceTypePlural {
-	case "resourcetypedefinitions":
+	case "resourcetypedefinitions", "resourcetypedefinition", "rtd", "rtds":
 		return &ResourceTypeDefinition{
 			Metadata: Metadata{
-				PackageName:  "core",
+				PackageName:  corePackageName,
 				APIVersion:   "v1",
 				ResourceType: "ResourceTypeDefinition",
 				Name:         "ResourceTypeDefinition.core",
 			},
-			Package:      "core",
+			Package:      corePackageName,
 			ResourceType: "ResourceTypeDefinition",
 			Plural:       "ResourceTypeDefinitions",
+			ShortNames:   []string{"rtd", "rtds"},
 			Versions: []ResourceTypeDefinitionVersion{
 				{
 					Name: "v1",
 					Schema: map[string]any{
-						"type":       "object",
-						"properties": map[string]any{},
+						"type": "object",
+						"properties": map[string]any{
+							"package":      map[string]any{"type": "string", "pattern": packageNamePattern},
+							"resourceType": map[string]any{"type": "string"},
+							"plural":       map[string]any{"type": "string", "pattern": resourceTypePattern},
+							"singular":     map[string]any{"type": "string", "pattern": resourceTypePattern},
+							"shortNames":   map[string]any{"type": "array", "items": map[string]any{"type": "string", "pattern": resourceTypePattern}},
+							"categories":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+							"timeSeries": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"timeProperty": map[string]any{"type": "string"},
+									"retention":    map[string]any{"type": "string"},
+								},
+								"required": []any{"timeProperty"},
+							},
+							"stateMachine": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"property": map[string]any{"type": "string"},
+									"transitions": map[string]any{
+										"type":                 "object",
+										"additionalProperties": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+									},
+								},
+								"required": []any{"property", "transitions"},
+							},
+							"drafts": map[string]any{"type": "boolean"},
+							"localization": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"properties": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+									"fallbacks": map[string]any{
+										"type":                 "object",
+										"additionalProperties": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+									},
+								},
+								"required": []any{"properties"},
+							},
+							"moderation": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"properties":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1},
+									"bannedWords": map[string]any{"type": "array", "items": map[string]any{"type": "string", "minLength": 1}},
+									"patterns":    map[string]any{"type": "array", "items": map[string]any{"type": "string", "format": "regex"}},
+									"classifiers": map[string]any{"type": "array", "items": map[string]any{"type": "string", "minLength": 1}},
+									"action":      map[string]any{"type": "string", "enum": []any{"reject", "quarantine", "flag"}},
+								},
+								"required": []any{"properties", "action"},
+							},
+							"erasure": map[string]any{"type": "string", "enum": []any{"delete", "anonymize"}},
+							"approval": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"approvals": map[string]any{"type": "integer", "minimum": 1},
+								},
+								"required": []any{"approvals"},
+							},
+							"versions": map[string]any{
+								"type": "array",
+								"items": map[string]any{
+									"type": "object",
+									"properties": map[string]any{
+										"name":               map[string]any{"type": "string", "pattern": apiVersionPattern},
+										"schema":             map[string]any{"type": "object"},
+										"deprecated":         map[string]any{"type": "boolean"},
+										"deprecationWarning": map[string]any{"type": "string"},
+										"sunset":             map[string]any{"type": "string", "format": "date-time"},
+									},
+									"required": []any{"schema"},
+								},
+							},
+						},
+						"required": []any{"package", "resourceType", "plural", "versions"},
 					},
 				},
 			},
 		}, nil
+	case "featureflags", "featureflag":
+		return featureFlagResourceTypeDefinition(), nil
+	case "settings", "setting":
+		return settingResourceTypeDefinition(), nil
+	case "relationships", "relationship":
+		return relationshipResourceTypeDefinition(), nil
+	case "resourcealiases", "resourcealias":
+		return resourceAliasResourceTypeDefinition(), nil
+	case "erasurereports", "erasurereport":
+		return erasureReportResourceTypeDefinition(), nil
+	case "legalholds", "legalhold":
+		return legalHoldResourceTypeDefinition(), nil
+	case "activities", "activity":
+		return activityResourceTypeDefinition(), nil
+	case "changerequests", "changerequest":
+		return changeRequestResourceTypeDefinition(), nil
+	case "drafts", "draft":
+		return draftResourceTypeDefinition(), nil
+	case "publishedversions", "publishedversion":
+		return publishedVersionResourceTypeDefinition(), nil
+	case "emailtemplates", "emailtemplate":
+		return emailTemplateResourceTypeDefinition(), nil
+	case "localizations", "localization":
+		return localizationResourceTypeDefinition(), nil
+	case "devicetokens", "devicetoken":
+		return deviceTokenResourceTypeDefinition(), nil
+	case "pushtemplates", "pushtemplate":
+		return pushTemplateResourceTypeDefinition(), nil
+	case "views", "view":
+		return viewResourceTypeDefinition(), nil
+	case "automations", "automation":
+		return automationResourceTypeDefinition(), nil
+	case "webhookdeliveries", "webhookdelivery":
+		return webhookDeliveryResourceTypeDefinition(), nil
+	case "notificationchannels", "notificationchannel":
+		return notificationChannelResourceTypeDefinition(), nil
+	case "notificationrules", "notificationrule":
+		return notificationRuleResourceTypeDefinition(), nil
+	case "serverconfigs", "serverconfig":
+		return serverConfigResourceTypeDefinition(), nil
+	case "schemadefinitions", "schemadefinition":
+		return schemaDefinitionResourceTypeDefinition(), nil
 	default:
 		return nil, fmt.Errorf("unknown core resource type %q", resourceTypePlural)
 	}
@@ -2,21 +2,24 @@ package bass
 
 import (
 	"context"
+	"encoding/json/jsontext"
 	"fmt"
-	"maps"
-	"slices"
-	"sync"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
 )
 
-type Resource struct {
-	Metadata   Metadata       `json:"metadata"`
-	Properties map[string]any `json:",inline"`
-}
+type (
+	Resource      = apiv1.Resource
+	ResourceList  = apiv1.ResourceList
+	Precondition  = apiv1.Precondition
+	OperationType = apiv1.OperationType
+)
 
-type ResourceList struct {
-	Metadata ListMetadata `json:"metadata"`
-	Items    []*Resource  `json:"items"`
-}
+const (
+	OperationCreate = apiv1.OperationCreate
+	OperationUpdate = apiv1.OperationUpdate
+	OperationDelete = apiv1.OperationDelete
+)
 
 type ResourcesRepository interface {
 	List(ctx context.Context, packageName, apiVersion, resourceType string) (list ResourceList, err error)
@@ -26,148 +29,151 @@ type ResourcesRepository interface {
 	Delete(ctx context.Context, packageName, resourceTypePlural, name string) (err error)
 }
 
-type ResourceExistsError struct {
-	PackageName  string
-	ResourceType string
-	Name         string
+// RawGetter is implemented by repositories keeping the JSON encoding of the resources they store, which the handler
+// responds to reads with as is, without decoding and encoding them again.
+type RawGetter interface {
+	// GetRaw returns the JSON encoding of the resource. Callers must not modify it.
+	GetRaw(ctx context.Context, packageName, resourceType, name string) (raw jsontext.Value, err error)
 }
 
-func (err ResourceExistsError) Error() string {
-	return fmt.Sprintf("resource with name %q and resource type %q and package %q already exists", err.Name, err.ResourceType, err.PackageName)
+// LabelLister is implemented by repositories that can list resources by labels without scanning all of them.
+type LabelLister interface {
+	// ListByLabels lists the resources having all the labels.
+	ListByLabels(ctx context.Context, packageName, apiVersion, resourceType string, labels map[string]string) (list ResourceList, err error)
 }
 
-type ResourceNotFoundError struct {
-	PackageName  string
-	ResourceType string
-	Name         string
+// Operation is a single write of a transaction. Delete operations only use the package, resource type, and name in
+// the item metadata.
+type Operation struct {
+	Type         OperationType
+	Item         *Resource
+	Precondition Precondition
 }
 
-func (err ResourceNotFoundError) Error() string {
-	return fmt.Sprintf("resource with name %q and resource type %q and package %q not found", err.Name, err.ResourceType, err.PackageName)
+// TransactionalRepository is implemented by repositories that can apply several writes atomically.
+type TransactionalRepository interface {
+	// Transact applies either all operations, in order, or none of them. A failing operation is reported as
+	// TransactionError.
+	Transact(ctx context.Context, ops []Operation) (err error)
 }
 
-type MemRepo struct {
-	sync.Mutex
-
-	db map[string]*Resource
+// AtomicUpdater is implemented by repositories that can apply a read-modify-write to a stored resource atomically.
+type AtomicUpdater interface {
+	// UpdateFunc calls fn with the current item and stores the item as modified by fn, unless fn fails. No other write
+	// of the item may happen in between.
+	UpdateFunc(ctx context.Context, packageName, resourceType, name string, fn func(item *Resource) error) (item *Resource, err error)
 }
 
-var _ ResourcesRepository = (*MemRepo)(nil)
+// ExpiringRepository is implemented by repositories expiring resources, like RedisRepo with a TTL. Legal holds keep
+// the items they hold from expiring.
+type ExpiringRepository interface {
+	// SetExpiring stops the resource from expiring until it is written again, or, if expiring, makes it expire as if
+	// it was written now.
+	SetExpiring(ctx context.Context, packageName, resourceType, name string, expiring bool) (err error)
+}
 
-func NewMemRepo() *MemRepo {
-	return &MemRepo{
-		db:    make(map[string]*Resource),
-		Mutex: sync.Mutex{},
-	}
+// RepositoryEvent is a write of a resource observed by a RepositoryWatcher. Items of deletions are the deleted items,
+// with the resource version of the deletion.
+type RepositoryEvent struct {
+	Type OperationType
+	Item *Resource
 }
 
-func (repo *MemRepo) List(_ context.Context, packageName, apiVersion, resourceType string) (ResourceList, error) {
-	allItems := slices.Collect(maps.Values(repo.db))
+// RepositoryWatcher is implemented by repositories that can stream the writes of their resources natively, including
+// writes of other handlers sharing them.
+type RepositoryWatcher interface {
+	// Watch streams the writes of resources of the package, and of the resource type unless it is empty, made after
+	// the resource version, or from now on if it is empty. The channel is closed once ctx is done, or the watch fails,
+	// e.g. as the resource version is compacted.
+	Watch(ctx context.Context, packageName, resourceType, resourceVersion string) (events <-chan RepositoryEvent, err error)
+}
 
-	resourceItems := slices.Collect(func(yield func(*Resource) bool) {
-		for _, item := range allItems {
-			if item.Metadata.ResourceType == resourceType && item.Metadata.PackageName == packageName {
-				yield(item)
-			}
-		}
-	})
+type TransactionError struct {
+	Index int
+	Err   error
+}
 
-	if resourceItems == nil {
-		resourceItems = make([]*Resource, 0)
-	}
+func (err TransactionError) Error() string {
+	return fmt.Sprintf("operation %d of transaction failed: %s", err.Index, err.Err)
+}
 
-	res := ResourceList{
-		Metadata: ListMetadata{
-			PackageName:  packageName,
-			APIVersion:   apiVersion,
-			ResourceType: resourceType + "List",
-		},
-		Items: resourceItems,
-	}
+func (err TransactionError) Unwrap() error {
+	return err.Err
+}
 
-	return res, nil
+type ResourceExistsError struct {
+	PackageName  string
+	ResourceType string
+	Name         string
 }
 
-func (repo *MemRepo) Create(_ context.Context, item *Resource) error {
-	_, ok := repo.get(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
-	if ok {
-		return ResourceExistsError{
-			PackageName:  item.Metadata.PackageName,
-			ResourceType: item.Metadata.ResourceType,
-			Name:         item.Metadata.Name,
-		}
-	}
+func (err ResourceExistsError) Error() string {
+	return fmt.Sprintf("resource with name %q and resource type %q and package %q already exists", err.Name, err.ResourceType, err.PackageName)
+}
 
-	repo.put(item)
+type ResourceNotFoundError struct {
+	PackageName  string
+	ResourceType string
+	Name         string
+}
 
-	return nil
+func (err ResourceNotFoundError) Error() string {
+	return fmt.Sprintf("resource with name %q and resource type %q and package %q not found", err.Name, err.ResourceType, err.PackageName)
 }
 
-func (repo *MemRepo) Get(_ context.Context, packageName, resourceType, name string) (*Resource, error) {
-	item, ok := repo.get(packageName, resourceType, name)
-	if !ok {
-		return nil, ResourceNotFoundError{
-			PackageName:  packageName,
-			ResourceType: resourceType,
-			Name:         name,
-		}
-	}
+type ResourceVersionConflictError struct {
+	PackageName     string
+	ResourceType    string
+	Name            string
+	ResourceVersion string
+}
 
-	return item, nil
+func (err ResourceVersionConflictError) Error() string {
+	return fmt.Sprintf("resource with name %q and resource type %q and package %q has been modified since version %q", err.Name, err.ResourceType, err.PackageName, err.ResourceVersion)
 }
 
-func (repo *MemRepo) Update(_ context.Context, item *Resource) error {
-	_, ok := repo.get(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
-	if !ok {
-		return ResourceNotFoundError{
-			PackageName:  item.Metadata.PackageName,
-			ResourceType: item.Metadata.ResourceType,
-			Name:         item.Metadata.Name,
+type PreconditionFailedError struct {
+	PackageName  string
+	ResourceType string
+	Name         string
+	Field        string
+	Expected     string
+	Actual       string
+}
+
+func (err PreconditionFailedError) Error() string {
+	return fmt.Sprintf("precondition failed for resource with name %q and resource type %q and package %q: %s is %q, expected %q", err.Name, err.ResourceType, err.PackageName, err.Field, err.Actual, err.Expected)
+}
+
+// CheckPrecondition returns PreconditionFailedError if the current state of a resource doesn't satisfy the
+// precondition.
+func CheckPrecondition(current *Resource, precondition Precondition) error {
+	newErr := func(field, expected, actual string) error {
+		return PreconditionFailedError{
+			PackageName:  current.Metadata.PackageName,
+			ResourceType: current.Metadata.ResourceType,
+			Name:         current.Metadata.Name,
+			Field:        field,
+			Expected:     expected,
+			Actual:       actual,
 		}
 	}
 
-	repo.put(item)
-
-	return nil
-}
-
-func (repo *MemRepo) Delete(_ context.Context, packageName, resourceType, name string) error {
-	_, ok := repo.get(packageName, resourceType, name)
-	if !ok {
-		return ResourceNotFoundError{
-			PackageName:  packageName,
-			ResourceType: resourceType,
-			Name:         name,
-		}
+	if precondition.UID != "" && precondition.UID != current.Metadata.UID {
+		return newErr("uid", precondition.UID, current.Metadata.UID)
 	}
 
-	repo.delete(packageName, resourceType, name)
+	if precondition.ResourceVersion != "" && precondition.ResourceVersion != current.Metadata.ResourceVersion {
+		return newErr("resourceVersion", precondition.ResourceVersion, current.Metadata.ResourceVersion)
+	}
 
 	return nil
 }
 
-func (repo *MemRepo) put(item *Resource) {
-	repo.Lock()
-	defer repo.Unlock()
-
-	key := item.Metadata.PackageName + "/" + item.Metadata.ResourceType + "/" + item.Metadata.Name
-
-	repo.db[key] = item
-}
-
-func (repo *MemRepo) delete(packageName, resourceType, name string) {
-	repo.Lock()
-	defer repo.Unlock()
-
-	key := packageName + "/" + resourceType + "/" + name
-
-	delete(repo.db, key)
+type ResourceUIDNotFoundError struct {
+	UID string
 }
 
-func (repo *MemRepo) get(packageName, resourceType, name string) (*Resource, bool) {
-	key := packageName + "/" + resourceType + "/" + name
-
-	item, ok := repo.db[key]
-
-	return item, ok
+func (err ResourceUIDNotFoundError) Error() string {
+	return fmt.Sprintf("resource with uid %q not found", err.UID)
 }
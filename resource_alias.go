@@ -0,0 +1,189 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type ResourceAlias = apiv1.ResourceAlias
+
+// resourceAliasFromLabel labels resource aliases with the old name they keep.
+const resourceAliasFromLabel = "bass.alias/from"
+
+func resourceAliasResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "ResourceAlias.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "ResourceAlias",
+		Plural:       "ResourceAliases",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"from":      resourceReferenceSchema(),
+						"to":        resourceReferenceSchema(),
+						"expiresAt": map[string]any{"type": "string", "format": "date-time"},
+					},
+					"required": []any{"from", "to", "expiresAt"},
+				},
+			},
+		},
+	}
+}
+
+func resourceAliasFromResource(item *Resource) (*ResourceAlias, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource alias properties: %w", err)
+	}
+
+	var alias ResourceAlias
+
+	err = json.Unmarshal(b, &alias)
+	if err != nil {
+		return nil, fmt.Errorf("resource alias %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	alias.Metadata = item.Metadata
+
+	return &alias, nil
+}
+
+// renameAliasOperations builds the operations of a rename keeping an alias of the old name, and dropping the aliases
+// of the new name, which is taken now. Without rename aliases, there are none.
+func (h *Handler) renameAliasOperations(ctx context.Context, from, to ResourceReference) ([]Operation, error) {
+	if h.renameAliasPeriod <= 0 {
+		return nil, nil
+	}
+
+	list, err := h.repoListByLabels(ctx, corePackageName, "v1", "ResourceAlias", map[string]string{
+		resourceAliasFromLabel: resourceLabelValue(to.PackageName, to.ResourceType, to.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]Operation, 0, len(list.Items)+1)
+
+	for _, item := range list.Items {
+		ops = append(ops, Operation{
+			Type:         OperationDelete,
+			Item:         item,
+			Precondition: Precondition{UID: item.Metadata.UID, ResourceVersion: item.Metadata.ResourceVersion},
+		})
+	}
+
+	now := h.now()
+	uid := uuid.NewString()
+
+	ops = append(ops, Operation{
+		Type: OperationCreate,
+		Item: &Resource{
+			Metadata: Metadata{
+				UID:          uid,
+				PackageName:  corePackageName,
+				APIVersion:   "v1",
+				ResourceType: "ResourceAlias",
+				Name:         uid,
+				Labels:       map[string]string{resourceAliasFromLabel: resourceLabelValue(from.PackageName, from.ResourceType, from.Name)},
+				CreatedAt:    now,
+				UpdatedAt:    now,
+			},
+			Properties: map[string]any{
+				"from":      map[string]any{"packageName": from.PackageName, "resourceType": from.ResourceType, "name": from.Name},
+				"to":        map[string]any{"packageName": to.PackageName, "resourceType": to.ResourceType, "name": to.Name},
+				"expiresAt": now.Add(h.renameAliasPeriod).Format(time.RFC3339Nano),
+			},
+		},
+		Precondition: Precondition{UID: "", ResourceVersion: ""},
+	})
+
+	return ops, nil
+}
+
+// getResourceAlias returns the latest unexpired alias of an old name, if any. Expired aliases are deleted.
+func (h *Handler) getResourceAlias(ctx context.Context, from ResourceReference) (*ResourceAlias, error) {
+	list, err := h.repoListByLabels(ctx, corePackageName, "v1", "ResourceAlias", map[string]string{
+		resourceAliasFromLabel: resourceLabelValue(from.PackageName, from.ResourceType, from.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *ResourceAlias
+
+	for _, item := range list.Items {
+		alias, err := resourceAliasFromResource(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if !h.now().Before(alias.ExpiresAt) {
+			err = h.repoDelete(ctx, corePackageName, "ResourceAlias", item.Metadata.Name)
+			if err != nil {
+				h.logger.ErrorContext(ctx, "failed to delete expired resource alias", "name", item.Metadata.Name, "error", err)
+			}
+
+			continue
+		}
+
+		if latest == nil || alias.Metadata.CreatedAt.After(latest.Metadata.CreatedAt) {
+			latest = alias
+		}
+	}
+
+	return latest, nil
+}
+
+// resourceNotFound responds that the item of a request is not found. If the item was renamed and its alias hasn't
+// expired, the request is redirected to its new name instead, with 301 Moved Permanently for reads and 308 Permanent
+// Redirect for writes, which keeps their method and body.
+func (h *Handler) resourceNotFound(w http.ResponseWriter, r *http.Request, err ResourceNotFoundError) {
+	// The name of the request may carry an action, as "{name}:{action}".
+	suffix, ok := strings.CutPrefix(r.PathValue("name"), err.Name)
+	if h.renameAliasPeriod <= 0 || !ok || (suffix != "" && !strings.HasPrefix(suffix, ":")) || err.PackageName != r.PathValue("packageName") {
+		respond.Done(w, r, problem.NotFound(err.Error(), withReason(apierrors.ReasonNotFound)))
+
+		return
+	}
+
+	alias, aliasErr := h.getResourceAlias(r.Context(), ResourceReference{PackageName: err.PackageName, ResourceType: err.ResourceType, Name: err.Name})
+	if aliasErr != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get resource alias", "error", aliasErr)
+	}
+
+	if alias == nil {
+		respond.Done(w, r, problem.NotFound(err.Error(), withReason(apierrors.ReasonNotFound)))
+
+		return
+	}
+
+	location := h.resourcePath(alias.To.PackageName, r.PathValue("apiVersion"), r.PathValue("resourceTypePlural"), alias.To.Name) + suffix
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	status := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+
+	http.Redirect(w, r, location, status)
+}
@@ -0,0 +1,350 @@
+package bass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type RestoreResult = apiv1.RestoreResult
+
+type RestoreRangeError struct {
+	At    time.Time
+	Since time.Time
+}
+
+func (err RestoreRangeError) Error() string {
+	return fmt.Sprintf("cannot restore at %s: revision history starts at %s", err.At.Format(time.RFC3339), err.Since.Format(time.RFC3339))
+}
+
+// restoreKey identifies an item within a package, as "{resourceType}/{name}".
+func restoreKey(item *Resource) string {
+	return item.Metadata.ResourceType + "/" + item.Metadata.Name
+}
+
+// stateAt reconstructs the items of a package at a time from their current state and the revisions of the package.
+// Items without revisions are unchanged since then. Items first updated after the time, without earlier revisions,
+// were last written before the history started, so their state is unknown; they are returned as unrecoverable and
+// left as they are.
+func stateAt(current map[string]*Resource, revisions []revision, at time.Time) (map[string]*Resource, []string) {
+	state := maps.Clone(current)
+	settled := make(map[string]bool)
+	unrecoverable := make([]string, 0)
+
+	for _, rev := range revisions {
+		key := restoreKey(rev.Item)
+
+		if !rev.At.After(at) {
+			if rev.Type == OperationDelete {
+				delete(state, key)
+			} else {
+				state[key] = rev.Item
+			}
+
+			settled[key] = true
+
+			continue
+		}
+
+		if settled[key] {
+			continue
+		}
+
+		settled[key] = true
+
+		// The first write after the time tells what was there before it.
+		switch rev.Type {
+		case OperationCreate:
+			delete(state, key)
+		case OperationDelete:
+			state[key] = rev.Item
+		case OperationUpdate:
+			unrecoverable = append(unrecoverable, key)
+		}
+	}
+
+	slices.Sort(unrecoverable)
+
+	return state, unrecoverable
+}
+
+// handleRestore reconstructs the items of a package at the time of the "at" query parameter from the revision history.
+// They overwrite the package, or are created in the "target" package, registering its missing resource types.
+func (h *Handler) handleRestore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.revisions == nil {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("revision history is not enabled"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		packageName := r.URL.Query().Get("package")
+		if packageName == "" || packageName == corePackageName {
+			respond.Done(w, r, problem.BadRequest("package query parameter must name a non-core package", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			target = packageName
+		}
+
+		at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest("at query parameter must be an RFC 3339 time", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		if since := h.revisions.start(); at.Before(since) {
+			respond.Done(w, r, problem.BadRequest(RestoreRangeError{At: at, Since: since}.Error(), withReason(apierrors.ReasonInvalid)))
+
+			return
+		}
+
+		res, err := h.restorePackage(r.Context(), packageName, target, at)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to restore package", "package", packageName, "error", err)
+
+			var resourceExistsError ResourceExistsError
+
+			switch {
+			case errors.As(err, &resourceExistsError):
+				respond.Done(w, r, problem.Conflict(resourceExistsError.Error(), withReason(apierrors.ReasonAlreadyExists)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		respond.Done(w, r, res)
+	}
+}
+
+func (h *Handler) restorePackage(ctx context.Context, packageName, target string, at time.Time) (RestoreResult, error) {
+	res := RestoreResult{
+		PackageName:   packageName,
+		Target:        target,
+		At:            at,
+		Created:       0,
+		Updated:       0,
+		Deleted:       0,
+		Unrecoverable: nil,
+		Held:          nil,
+	}
+
+	resourceTypeDefinitions, err := h.listResourceTypeDefinitions(ctx, packageName)
+	if err != nil {
+		return res, err
+	}
+
+	current := make(map[string]*Resource)
+
+	for _, resourceTypeDefinition := range resourceTypeDefinitions {
+		list, err := h.repoList(ctx, packageName, resourceTypeDefinition.Versions[0].Name, resourceTypeDefinition.ResourceType)
+		if err != nil {
+			return res, err
+		}
+
+		for _, item := range list.Items {
+			current[restoreKey(item)] = item
+		}
+	}
+
+	// Only items of the resource types registered now are restored.
+	revisions := slices.DeleteFunc(h.revisions.packageRevisions(packageName), func(rev revision) bool {
+		return !slices.ContainsFunc(resourceTypeDefinitions, func(resourceTypeDefinition *ResourceTypeDefinition) bool {
+			return resourceTypeDefinition.ResourceType == rev.Item.Metadata.ResourceType
+		})
+	})
+
+	state, unrecoverable := stateAt(current, revisions, at)
+	res.Unrecoverable = unrecoverable
+
+	if target == packageName {
+		err = h.restoreInPlace(ctx, current, state, &res)
+
+		return res, err
+	}
+
+	err = h.registerRestoreTarget(ctx, target, resourceTypeDefinitions)
+	if err != nil {
+		return res, err
+	}
+
+	err = h.restoreInto(ctx, target, state, &res)
+
+	return res, err
+}
+
+// restoreInPlace writes the difference between the current items of a package and their restored state.
+func (h *Handler) restoreInPlace(ctx context.Context, current, state map[string]*Resource, res *RestoreResult) error {
+	for _, key := range slices.Sorted(maps.Keys(current)) {
+		if _, ok := state[key]; ok {
+			continue
+		}
+
+		item := current[key]
+
+		held, err := h.skipHeld(ctx, item, res)
+		if err != nil {
+			return err
+		}
+
+		if held {
+			continue
+		}
+
+		err = h.repoDelete(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+		if err != nil {
+			return err
+		}
+
+		h.resourceWritten(ctx, OperationDelete, item)
+
+		res.Deleted++
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(state)) {
+		existing, ok := current[key]
+		if ok && existing.Metadata.ResourceVersion == state[key].Metadata.ResourceVersion {
+			continue
+		}
+
+		if ok {
+			held, err := h.skipHeld(ctx, existing, res)
+			if err != nil {
+				return err
+			}
+
+			if held {
+				continue
+			}
+		}
+
+		item := state[key].DeepCopy()
+		item.Metadata.ResourceVersion = ""
+		item.Metadata.UpdatedAt = h.now()
+
+		operation := OperationCreate
+
+		var err error
+
+		if ok {
+			operation = OperationUpdate
+			err = h.repo.Update(ctx, item)
+		} else {
+			err = h.repo.Create(ctx, item)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write restored resource: %w", err)
+		}
+
+		h.resourceWritten(ctx, operation, item)
+
+		if ok {
+			res.Updated++
+		} else {
+			res.Created++
+		}
+	}
+
+	return nil
+}
+
+// skipHeld reports whether an item is under legal hold, and records it as held in the result if it is, for restores
+// to leave it as it is.
+func (h *Handler) skipHeld(ctx context.Context, item *Resource, res *RestoreResult) (bool, error) {
+	err := h.checkLegalHold(ctx, item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+	if err == nil {
+		return false, nil
+	}
+
+	var legalHoldError LegalHoldError
+	if errors.As(err, &legalHoldError) {
+		res.Held = append(res.Held, restoreKey(item))
+
+		return true, nil
+	}
+
+	return false, err
+}
+
+// registerRestoreTarget registers the resource types of a package in the target package of a restore, unless the
+// target has them already.
+func (h *Handler) registerRestoreTarget(ctx context.Context, target string, resourceTypeDefinitions []*ResourceTypeDefinition) error {
+	for _, resourceTypeDefinition := range resourceTypeDefinitions {
+		_, err := h.getResourceTypeDefinition(ctx, target, resourceTypeDefinition.Plural)
+		if err == nil {
+			continue
+		}
+
+		var resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
+		if !errors.As(err, &resourceTypeDefinitionNotFoundError) {
+			return err
+		}
+
+		source, err := h.repoGet(ctx, corePackageName, "ResourceTypeDefinition", resourceTypeDefinition.Metadata.Name)
+		if err != nil {
+			return err
+		}
+
+		item := source.DeepCopy()
+		item.Metadata.UID = uuid.NewString()
+		item.Metadata.Name = resourceTypeDefinition.Plural + "." + target
+		item.Metadata.ResourceVersion = ""
+		item.Metadata.CreatedAt = h.now()
+		item.Metadata.UpdatedAt = item.Metadata.CreatedAt
+		item.Properties["package"] = target
+
+		err = h.repo.Create(ctx, item)
+		if err != nil {
+			return fmt.Errorf("failed to create resource type definition: %w", err)
+		}
+
+		h.resourceWritten(ctx, OperationCreate, item)
+	}
+
+	return nil
+}
+
+// restoreInto creates the restored items of a package in the target package.
+func (h *Handler) restoreInto(ctx context.Context, target string, state map[string]*Resource, res *RestoreResult) error {
+	for _, key := range slices.Sorted(maps.Keys(state)) {
+		item := state[key].DeepCopy()
+		item.Metadata.UID = uuid.NewString()
+		item.Metadata.PackageName = target
+		item.Metadata.ResourceVersion = ""
+		item.Metadata.CreatedAt = h.now()
+		item.Metadata.UpdatedAt = item.Metadata.CreatedAt
+
+		err := h.repo.Create(ctx, item)
+		if err != nil {
+			return fmt.Errorf("failed to create restored resource: %w", err)
+		}
+
+		h.resourceWritten(ctx, OperationCreate, item)
+
+		res.Created++
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// TransientError is implemented by repository errors that may succeed on retry, such as lost connections or
+// timeouts of the underlying database.
+type TransientError interface {
+	error
+	Transient() bool
+}
+
+// IsTransient reports whether any error in the chain of err is a TransientError that reports itself transient.
+func IsTransient(err error) bool {
+	var transientError TransientError
+
+	return errors.As(err, &transientError) && transientError.Transient()
+}
+
+// RetryPolicy configures how the handler retries idempotent repository operations that fail with transient errors.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the upper bound of the wait before the first retry. It doubles on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the upper bound of the wait between retries.
+	MaxDelay time.Duration
+}
+
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = 50 * time.Millisecond
+	defaultRetryMaxDelay    = time.Second
+	retryBackoffFactor      = 2
+)
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: defaultRetryMaxAttempts,
+		BaseDelay:   defaultRetryBaseDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+	}
+}
+
+// retry calls fn until it succeeds, fails with a non-transient error, runs out of attempts, or ctx is done. Waits
+// between attempts use full jitter exponential backoff.
+func (h *Handler) retry(ctx context.Context, fn func() error) error {
+	delay := h.retryPolicy.BaseDelay
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= h.retryPolicy.MaxAttempts || !IsTransient(err) {
+			return err
+		}
+
+		timer := time.NewTimer(rand.N(delay + 1)) //nolint:gosec // jitter doesn't need a secure source
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("retry canceled after %d attempts: %w", attempt, errors.Join(err, ctx.Err()))
+		case <-timer.C:
+		}
+
+		delay = min(delay*retryBackoffFactor, h.retryPolicy.MaxDelay)
+	}
+}
+
+// conflictAttempts is how many times retryConflicts attempts writes that lose races to concurrent writes.
+const conflictAttempts = 10
+
+// retryConflicts calls fn until it doesn't fail with a ResourceVersionConflictError, runs out of attempts, or ctx is
+// done. fn is to read the item it writes again on every attempt.
+func retryConflicts(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+
+		var resourceVersionConflictError ResourceVersionConflictError
+		if !errors.As(err, &resourceVersionConflictError) || attempt >= conflictAttempts {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("retry canceled after %d attempts: %w", attempt, errors.Join(err, ctx.Err()))
+		}
+	}
+}
+
+func (h *Handler) repoList(ctx context.Context, packageName, apiVersion, resourceType string) (ResourceList, error) {
+	var res ResourceList
+
+	err := h.retry(ctx, func() error {
+		var err error
+
+		res, err = h.repo.List(ctx, packageName, apiVersion, resourceType)
+		if err != nil {
+			return fmt.Errorf("failed to list resources: %w", err)
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+// repoListByLabels lists the resources having all the labels, filtering a full list if the repository is not a
+// LabelLister.
+func (h *Handler) repoListByLabels(ctx context.Context, packageName, apiVersion, resourceType string, labels map[string]string) (ResourceList, error) { //nolint:unparam // mirrors LabelLister
+	labelLister, ok := h.repo.(LabelLister)
+	if !ok {
+		list, err := h.repoList(ctx, packageName, apiVersion, resourceType)
+		if err != nil {
+			return list, err
+		}
+
+		list.Items = slices.DeleteFunc(list.Items, func(item *Resource) bool {
+			for key, value := range labels {
+				if v, ok := item.Metadata.Labels[key]; !ok || v != value {
+					return true
+				}
+			}
+
+			return false
+		})
+
+		return list, nil
+	}
+
+	var res ResourceList
+
+	err := h.retry(ctx, func() error {
+		var err error
+
+		res, err = labelLister.ListByLabels(ctx, packageName, apiVersion, resourceType, labels)
+		if err != nil {
+			return fmt.Errorf("failed to list resources by labels: %w", err)
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+func (h *Handler) repoGet(ctx context.Context, packageName, resourceType, name string) (*Resource, error) {
+	var res *Resource
+
+	err := h.retry(ctx, func() error {
+		var err error
+
+		res, err = h.repo.Get(ctx, packageName, resourceType, name)
+		if err != nil {
+			return fmt.Errorf("failed to get resource: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return h.rehydrate(ctx, res)
+}
+
+func (h *Handler) repoGetRaw(ctx context.Context, rawGetter RawGetter, packageName, resourceType, name string) (jsontext.Value, error) {
+	var res jsontext.Value
+
+	err := h.retry(ctx, func() error {
+		var err error
+
+		res, err = rawGetter.GetRaw(ctx, packageName, resourceType, name)
+		if err != nil {
+			return fmt.Errorf("failed to get raw resource: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return h.rehydrateRaw(ctx, res)
+}
+
+// repoDelete deletes an item. Retries that find the item missing succeed, as the attempt that failed may have deleted
+// it.
+func (h *Handler) repoDelete(ctx context.Context, packageName, resourceType, name string) error {
+	attempt := 0
+
+	return h.retry(ctx, func() error {
+		attempt++
+
+		err := h.repo.Delete(ctx, packageName, resourceType, name)
+
+		var resourceNotFoundError ResourceNotFoundError
+		if attempt > 1 && errors.As(err, &resourceNotFoundError) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to delete resource: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,362 @@
+package bass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	RevisionDiff = apiv1.RevisionDiff
+	RevisionInfo = apiv1.RevisionInfo
+)
+
+// revision is a write of a resource through the handler. The item of deletes is the deleted item, as far as known.
+type revision struct {
+	Type OperationType
+	At   time.Time
+	// By is the name of the API key of the write, if any.
+	By string
+	// Origin is the request path of the write, or the handler actor making it.
+	Origin string
+	Item   *Resource
+	// Erased is set on the revisions of items erased for a data subject, which keep no properties. They aren't
+	// restored or diffed, and only their deletes are synced.
+	Erased bool
+}
+
+// Origins of writes made by the handler itself, for revisions. Other writes have the path of their request.
+const (
+	originAutomation  = "automation"
+	originSync        = "sync"
+	originRestore     = "restore"
+	originReplication = "replication"
+	originErasure     = "erasure"
+)
+
+type writeOriginContextKey struct{}
+
+// contextWithWriteOrigin sets the origin of the writes made with ctx.
+func contextWithWriteOrigin(ctx context.Context, origin string) context.Context {
+	return context.WithValue(ctx, writeOriginContextKey{}, origin)
+}
+
+// withWriteOrigin sets the origin of the writes of requests to next.
+func (h *Handler) withWriteOrigin(origin string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(contextWithWriteOrigin(r.Context(), origin)))
+	})
+}
+
+func writeOriginFromContext(ctx context.Context) string {
+	origin, _ := ctx.Value(writeOriginContextKey{}).(string)
+
+	return origin
+}
+
+type RevisionNotFoundError struct {
+	PackageName     string
+	ResourceType    string
+	Name            string
+	ResourceVersion string
+}
+
+func (err RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("revision %q of resource with name %q and resource type %q and package %q not found", err.ResourceVersion, err.Name, err.ResourceType, err.PackageName)
+}
+
+// FirstRevisionError is returned for diffs of the first recorded revision of an item against no other revision.
+type FirstRevisionError struct {
+	ResourceVersion string
+}
+
+func (err FirstRevisionError) Error() string {
+	return fmt.Sprintf("revision %q is the first recorded revision of the item, so the against query parameter is required", err.ResourceVersion)
+}
+
+// revisionLog records the writes of resources through the handler since it started, oldest first, for point in time
+// restores and syncs of offline clients. Like watches, only writes of this handler are observed. Beyond its limit, the
+// oldest revisions are dropped, and the history starts later. Positions of revisions count the dropped ones too.
+type revisionLog struct {
+	mu sync.Mutex
+	// started identifies the log, for sync tokens.
+	started time.Time
+	// since is when the history starts: when the last dropped revision was written, or when the log started.
+	since     time.Time
+	limit     int
+	dropped   int
+	revisions []revision
+}
+
+func newRevisionLog(since time.Time, limit int) *revisionLog {
+	return &revisionLog{
+		mu:        sync.Mutex{},
+		started:   since,
+		since:     since,
+		limit:     limit,
+		dropped:   0,
+		revisions: nil,
+	}
+}
+
+func (log *revisionLog) record(rev revision) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	log.revisions = append(log.revisions, rev)
+
+	if log.limit > 0 && len(log.revisions) > log.limit {
+		n := len(log.revisions) - log.limit
+		log.since = log.revisions[n-1].At
+
+		clear(log.revisions[:n])
+		log.revisions = log.revisions[n:]
+		log.dropped += n
+	}
+}
+
+// start returns when the history starts. Restores can't go back before it.
+func (log *revisionLog) start() time.Time {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return log.since
+}
+
+// erase removes the properties of the revisions of an item erased for a data subject, and marks them erased.
+func (log *revisionLog) erase(packageName, resourceType, name string) {
+	log.redact(packageName, resourceType, name, func(rev *revision) {
+		rev.Item.Properties = make(map[string]any)
+		rev.Erased = true
+	})
+}
+
+// anonymize removes properties from the revisions of an item anonymized for a data subject.
+func (log *revisionLog) anonymize(packageName, resourceType, name string, properties []string) {
+	log.redact(packageName, resourceType, name, func(rev *revision) {
+		for _, property := range properties {
+			delete(rev.Item.Properties, property)
+		}
+	})
+}
+
+// redact applies fn to the revisions of an item. Their items are replaced rather than modified, as revisions returned
+// earlier share them.
+func (log *revisionLog) redact(packageName, resourceType, name string, fn func(rev *revision)) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	for i, rev := range log.revisions {
+		metadata := rev.Item.Metadata
+		if metadata.PackageName != packageName || metadata.ResourceType != resourceType || metadata.Name != name {
+			continue
+		}
+
+		rev.Item = rev.Item.DeepCopy()
+		fn(&rev)
+		log.revisions[i] = rev
+	}
+}
+
+// packageRevisions returns the revisions of the resources of the package other than erased ones, oldest first.
+func (log *revisionLog) packageRevisions(packageName string) []revision {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	var res []revision
+
+	for _, rev := range log.revisions {
+		if !rev.Erased && rev.Item.Metadata.PackageName == packageName {
+			res = append(res, rev)
+		}
+	}
+
+	return res
+}
+
+// itemRevisions returns the revisions of an item other than deletes and erased ones, oldest first. Deletes carry the
+// item as last written, so they add no revision of it.
+func (log *revisionLog) itemRevisions(packageName, resourceType, name string) []revision {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	var res []revision
+
+	for _, rev := range log.revisions {
+		metadata := rev.Item.Metadata
+		if rev.Type != OperationDelete && !rev.Erased && metadata.PackageName == packageName && metadata.ResourceType == resourceType && metadata.Name == name {
+			res = append(res, rev)
+		}
+	}
+
+	return res
+}
+
+// position returns the number of revisions recorded, the position of the next revision.
+func (log *revisionLog) position() int {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return log.dropped + len(log.revisions)
+}
+
+// retained reports whether the revisions from a position on are all kept.
+func (log *revisionLog) retained(position int) bool {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return position >= log.dropped && position <= log.dropped+len(log.revisions)
+}
+
+// packageRevisionsBetween returns the revisions of the resources of the package from position from up to, but not
+// including, position to, oldest first. Of erased items, only deletes are returned, for clients to drop them. It
+// reports false if revisions from position from on were dropped.
+func (log *revisionLog) packageRevisionsBetween(packageName string, from, to int) ([]revision, bool) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if from < log.dropped {
+		return nil, false
+	}
+
+	var res []revision
+
+	for _, rev := range log.revisions[from-log.dropped : to-log.dropped] {
+		if rev.Item.Metadata.PackageName == packageName && (!rev.Erased || rev.Type == OperationDelete) {
+			res = append(res, rev)
+		}
+	}
+
+	return res, true
+}
+
+// revisionDiff returns the diff of an item from its revision against, or the revision before if against is empty, to
+// its revision resourceVersion.
+func revisionDiff(revisions []revision, packageName, resourceType, name, resourceVersion, against string) (RevisionDiff, revision, revision, error) {
+	find := func(resourceVersion string) (int, error) {
+		for i := len(revisions) - 1; i >= 0; i-- {
+			if revisions[i].Item.Metadata.ResourceVersion == resourceVersion {
+				return i, nil
+			}
+		}
+
+		return 0, RevisionNotFoundError{PackageName: packageName, ResourceType: resourceType, Name: name, ResourceVersion: resourceVersion}
+	}
+
+	to, err := find(resourceVersion)
+	if err != nil {
+		return RevisionDiff{}, revision{}, revision{}, err
+	}
+
+	from := to - 1
+
+	if against != "" {
+		from, err = find(against)
+		if err != nil {
+			return RevisionDiff{}, revision{}, revision{}, err
+		}
+	} else if from < 0 {
+		return RevisionDiff{}, revision{}, revision{}, FirstRevisionError{ResourceVersion: resourceVersion}
+	}
+
+	diff := RevisionDiff{From: revisionInfo(revisions[from]), To: revisionInfo(revisions[to]), Patch: nil}
+
+	return diff, revisions[from], revisions[to], nil
+}
+
+func revisionInfo(rev revision) RevisionInfo {
+	return RevisionInfo{ResourceVersion: rev.Item.Metadata.ResourceVersion, Type: rev.Type, At: rev.At, By: rev.By, Origin: rev.Origin}
+}
+
+// handleRevisionDiff responds with the JSON Patch between two recorded revisions of an item, from the revision of the
+// "against" query parameter, or the revision before, to the revision of the path, as "{resourceVersion}:diff", with
+// who wrote them and when. Items deleted since are diffed too.
+func (h *Handler) handleRevisionDiff() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resourceVersion, ok := strings.CutSuffix(r.PathValue("revision"), ":diff")
+		if !ok {
+			respond.Done(w, r, problem.NotFound("revisions only support diffs, as \"{resourceVersion}:diff\"", withReason(apierrors.ReasonNotFound)))
+
+			return
+		}
+
+		if h.revisions == nil {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("revision history is not enabled"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		packageName := r.PathValue("packageName")
+		name := r.PathValue("name")
+
+		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, r.PathValue("resourceTypePlural"))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+
+			var resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
+
+			switch {
+			case errors.As(err, &resourceTypeDefinitionNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceTypeDefinitionNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		revisions := h.revisions.itemRevisions(packageName, resourceTypeDefinition.ResourceType, name)
+
+		res, from, to, err := revisionDiff(revisions, packageName, resourceTypeDefinition.ResourceType, name, resourceVersion, r.URL.Query().Get("against"))
+		if err != nil {
+			var (
+				revisionNotFoundError RevisionNotFoundError
+				firstRevisionError    FirstRevisionError
+			)
+
+			switch {
+			case errors.As(err, &revisionNotFoundError):
+				respond.Done(w, r, problem.NotFound(revisionNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			case errors.As(err, &firstRevisionError):
+				respond.Done(w, r, problem.BadRequest(firstRevisionError.Error(), withReason(apierrors.ReasonBadRequest)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		fromItem, toItem := from.Item, to.Item
+		if !privilegedRead(r) {
+			fromItem, toItem = anonymizedItem(resourceTypeDefinition, fromItem), anonymizedItem(resourceTypeDefinition, toItem)
+		}
+
+		res.Patch, err = resourcePatch(fromItem, toItem)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to diff revisions", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		if res.Patch == nil {
+			res.Patch = []JSONPatchOperation{}
+		}
+
+		respond.Done(w, r, res)
+	}
+}
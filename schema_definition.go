@@ -0,0 +1,236 @@
+package bass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type SchemaDefinition = apiv1.SchemaDefinition
+
+// schemaDefinitionRefPrefix prefixes the names of schema definitions in "$ref" of schemas. Other references are left
+// to the schema validator.
+const schemaDefinitionRefPrefix = "schemadefinitions/"
+
+type SchemaReferenceError struct {
+	Ref    string
+	Reason string
+}
+
+func (err SchemaReferenceError) Error() string {
+	return fmt.Sprintf("schema reference %q %s", err.Ref, err.Reason)
+}
+
+type ResourceTypeSchemaError struct {
+	Version string
+	Err     error
+}
+
+func (err ResourceTypeSchemaError) Error() string {
+	return fmt.Sprintf("schema of version %q is invalid: %s", err.Version, err.Err)
+}
+
+func (err ResourceTypeSchemaError) Unwrap() error {
+	return err.Err
+}
+
+func schemaDefinitionResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "SchemaDefinition.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "SchemaDefinition",
+		Plural:       "SchemaDefinitions",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"schema": map[string]any{"type": "object"},
+					},
+					"required": []any{"schema"},
+				},
+			},
+		},
+	}
+}
+
+// schemaDefinitionCache holds the resolved schemas of schema definitions by name. It is cleared whenever a schema
+// definition is written through the handler, since others may embed it.
+type schemaDefinitionCache struct {
+	mu      sync.Mutex
+	schemas map[string]map[string]any
+}
+
+func newSchemaDefinitionCache() *schemaDefinitionCache {
+	return &schemaDefinitionCache{
+		mu:      sync.Mutex{},
+		schemas: make(map[string]map[string]any),
+	}
+}
+
+func (cache *schemaDefinitionCache) get(name string) (map[string]any, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	schema, ok := cache.schemas[name]
+
+	return schema, ok
+}
+
+func (cache *schemaDefinitionCache) set(name string, schema map[string]any) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.schemas[name] = schema
+}
+
+func (cache *schemaDefinitionCache) clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	clear(cache.schemas)
+}
+
+// resolveResourceTypeDefinition replaces references to schema definitions in the schemas of the resource type
+// definition with the schemas they refer to.
+func (h *Handler) resolveResourceTypeDefinition(ctx context.Context, resourceTypeDefinition *ResourceTypeDefinition) error {
+	for i, version := range resourceTypeDefinition.Versions {
+		schema, err := h.resolveSchemaRefs(ctx, version.Schema, nil, true)
+		if err != nil {
+			return fmt.Errorf("failed to resolve schema of resource type %q: %w", resourceTypeDefinition.ResourceType, err)
+		}
+
+		resourceTypeDefinition.Versions[i].Schema, _ = schema.(map[string]any)
+	}
+
+	return nil
+}
+
+// resolveSchemaRefs returns a copy of value with objects referring to a schema definition replaced by its resolved
+// schema. As in JSON schema, other keywords next to such a "$ref" are ignored. resolving holds the names of the schema
+// definitions being resolved, to reject circular references. Resolved schema definitions are shared through the
+// cache if cached is true, so they must not be modified.
+func (h *Handler) resolveSchemaRefs(ctx context.Context, value any, resolving []string, cached bool) (any, error) {
+	switch value := value.(type) {
+	case map[string]any:
+		ref, _ := value["$ref"].(string)
+
+		name, ok := strings.CutPrefix(ref, schemaDefinitionRefPrefix)
+		if ok {
+			return h.resolveSchemaDefinition(ctx, ref, name, resolving, cached)
+		}
+
+		res := make(map[string]any, len(value))
+
+		for key, child := range value {
+			resolved, err := h.resolveSchemaRefs(ctx, child, resolving, cached)
+			if err != nil {
+				return nil, err
+			}
+
+			res[key] = resolved
+		}
+
+		return res, nil
+	case []any:
+		res := make([]any, 0, len(value))
+
+		for _, child := range value {
+			resolved, err := h.resolveSchemaRefs(ctx, child, resolving, cached)
+			if err != nil {
+				return nil, err
+			}
+
+			res = append(res, resolved)
+		}
+
+		return res, nil
+	default:
+		return value, nil
+	}
+}
+
+func (h *Handler) resolveSchemaDefinition(ctx context.Context, ref, name string, resolving []string, cached bool) (map[string]any, error) {
+	if slices.Contains(resolving, name) {
+		return nil, SchemaReferenceError{Ref: ref, Reason: "is circular"}
+	}
+
+	if cached {
+		schema, ok := h.schemaDefinitions.get(name)
+		if ok {
+			return schema, nil
+		}
+	}
+
+	item, err := h.repoGet(ctx, corePackageName, "SchemaDefinition", name)
+	if err != nil {
+		var resourceNotFoundError ResourceNotFoundError
+		if errors.As(err, &resourceNotFoundError) {
+			return nil, SchemaReferenceError{Ref: ref, Reason: "refers to a missing schema definition"}
+		}
+
+		return nil, fmt.Errorf("failed to get schema definition: %w", err)
+	}
+
+	definition, ok := item.Properties["schema"].(map[string]any)
+	if !ok {
+		return nil, SchemaReferenceError{Ref: ref, Reason: "refers to a schema definition without schema"}
+	}
+
+	resolved, err := h.resolveSchemaRefs(ctx, definition, append(slices.Clone(resolving), name), cached)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, _ := resolved.(map[string]any)
+
+	if cached {
+		h.schemaDefinitions.set(name, schema)
+	}
+
+	return schema, nil
+}
+
+// checkSchemaDefinition checks that the references of the schema of a schema definition resolve. The cache is
+// bypassed, since it may embed the schema definition being written.
+func (h *Handler) checkSchemaDefinition(ctx context.Context, item *Resource) error {
+	_, err := h.resolveSchemaRefs(ctx, item.Properties["schema"], []string{item.Metadata.Name}, false)
+
+	return err
+}
+
+// checkResourceTypeDefinitionSchemas checks that the references of the schemas of the versions of a resource type
+// definition resolve, and that the resolved schemas compile, so items are never validated against a broken schema.
+func (h *Handler) checkResourceTypeDefinitionSchemas(ctx context.Context, item *Resource) error {
+	versions, _ := item.Properties["versions"].([]any)
+
+	for _, version := range versions {
+		versionMap, _ := version.(map[string]any)
+
+		schema, err := h.resolveSchemaRefs(ctx, versionMap["schema"], nil, false)
+		if err != nil {
+			return err
+		}
+
+		_, err = gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+		if err != nil {
+			name, _ := versionMap["name"].(string)
+
+			return ResourceTypeSchemaError{Version: name, Err: err}
+		}
+	}
+
+	return nil
+}
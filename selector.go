@@ -0,0 +1,193 @@
+package bass
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type SelectorError struct {
+	Selector string
+	Reason   string
+}
+
+func (err SelectorError) Error() string {
+	return fmt.Sprintf("invalid selector %q: %s", err.Selector, err.Reason)
+}
+
+type selectorOperator string
+
+const (
+	selectorEquals    selectorOperator = "="
+	selectorNotEquals selectorOperator = "!="
+	selectorExists    selectorOperator = "exists"
+	selectorNotExists selectorOperator = "!exists"
+)
+
+// selectorRequirement is a requirement of a selector on a label, or on a property given by the segments of its path.
+type selectorRequirement struct {
+	key      string
+	segments []string
+	operator selectorOperator
+	value    string
+}
+
+func (requirement selectorRequirement) matches(value string, ok bool) bool {
+	switch requirement.operator {
+	case selectorEquals:
+		return ok && value == requirement.value
+	case selectorNotEquals:
+		return !ok || value != requirement.value
+	case selectorExists:
+		return ok
+	case selectorNotExists:
+		return !ok
+	default:
+		return false
+	}
+}
+
+// resourceSelector selects items by name, labels, and properties, from the name, labelSelector, and fieldSelector
+// query parameters. An empty selector selects all items.
+type resourceSelector struct {
+	names  []string
+	labels []selectorRequirement
+	fields []selectorRequirement
+}
+
+// parseResourceSelector reads the selector of a request. Names are given as repeated name query parameters. Label
+// selectors are comma separated requirements like "env=prod", "tier!=db", "team", or "!legacy". Field selectors are
+// comma separated requirements on property paths, like "/properties/status=open", comparing the property as a string,
+// or else as JSON.
+func parseResourceSelector(r *http.Request) (resourceSelector, error) {
+	res := resourceSelector{
+		names:  r.URL.Query()["name"],
+		labels: nil,
+		fields: nil,
+	}
+
+	var err error
+
+	res.labels, err = parseSelector(r.URL.Query().Get("labelSelector"), false)
+	if err != nil {
+		return res, err
+	}
+
+	res.fields, err = parseSelector(r.URL.Query().Get("fieldSelector"), true)
+	if err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+func parseSelector(selector string, fields bool) ([]selectorRequirement, error) {
+	if selector == "" {
+		return nil, nil
+	}
+
+	res := make([]selectorRequirement, 0, strings.Count(selector, ",")+1)
+
+	for term := range strings.SplitSeq(selector, ",") {
+		term = strings.TrimSpace(term)
+
+		requirement := selectorRequirement{key: "", segments: nil, operator: selectorExists, value: ""}
+
+		switch {
+		case strings.Contains(term, "!="):
+			requirement.key, requirement.value, _ = strings.Cut(term, "!=")
+			requirement.operator = selectorNotEquals
+		case strings.Contains(term, "="):
+			requirement.key, requirement.value, _ = strings.Cut(term, "=")
+			requirement.value = strings.TrimPrefix(requirement.value, "=")
+			requirement.operator = selectorEquals
+		case strings.HasPrefix(term, "!"):
+			requirement.key = strings.TrimPrefix(term, "!")
+			requirement.operator = selectorNotExists
+		default:
+			requirement.key = term
+		}
+
+		requirement.key = strings.TrimSpace(requirement.key)
+		requirement.value = strings.TrimSpace(requirement.value)
+
+		if requirement.key == "" {
+			return nil, SelectorError{Selector: selector, Reason: fmt.Sprintf("requirement %q has no key", term)}
+		}
+
+		if fields {
+			segments, err := parsePropertyPath(requirement.key)
+			if err != nil {
+				return nil, SelectorError{Selector: selector, Reason: err.Error()}
+			}
+
+			requirement.segments = segments
+		}
+
+		res = append(res, requirement)
+	}
+
+	return res, nil
+}
+
+func (selector resourceSelector) matches(item *Resource) bool {
+	if len(selector.names) > 0 && !slices.Contains(selector.names, item.Metadata.Name) {
+		return false
+	}
+
+	for _, requirement := range selector.labels {
+		value, ok := item.Metadata.Labels[requirement.key]
+		if !requirement.matches(value, ok) {
+			return false
+		}
+	}
+
+	for _, requirement := range selector.fields {
+		value, ok := propertyString(item.Properties, requirement.segments)
+		if !requirement.matches(value, ok) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// propertyValue returns the property at the segments of a path, and whether it exists.
+func propertyValue(properties map[string]any, segments []string) (any, bool) {
+	var value any = properties
+
+	for _, segment := range segments {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = object[segment]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// propertyString returns the property at the segments of a path as a string, or else as JSON, and whether it exists.
+func propertyString(properties map[string]any, segments []string) (string, bool) {
+	value, ok := propertyValue(properties, segments)
+	if !ok {
+		return "", false
+	}
+
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+
+	return string(b), true
+}
@@ -0,0 +1,154 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+)
+
+type ServerConfig = apiv1.ServerConfig
+
+// serverConfigName is the name of the server config item that is applied.
+const serverConfigName = "default"
+
+type ServerConfigNameError struct {
+	Name string
+}
+
+func (err ServerConfigNameError) Error() string {
+	return fmt.Sprintf("server config must be named %q, not %q", serverConfigName, err.Name)
+}
+
+func serverConfigResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "ServerConfig.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "ServerConfig",
+		Plural:       "ServerConfigs",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"logLevel": map[string]any{"type": "string", "enum": []any{"debug", "info", "warn", "error"}},
+						"features": map[string]any{
+							"type":                 "object",
+							"propertyNames":        map[string]any{"enum": featureNames()},
+							"additionalProperties": map[string]any{"type": "boolean"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func serverConfigFromResource(item *Resource) (*ServerConfig, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal server config properties: %w", err)
+	}
+
+	var serverConfig ServerConfig
+
+	err = json.Unmarshal(b, &serverConfig)
+	if err != nil {
+		return nil, fmt.Errorf("server config %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	serverConfig.Metadata = item.Metadata
+
+	return &serverConfig, nil
+}
+
+func checkServerConfig(item *Resource) error {
+	if item.Metadata.Name != serverConfigName {
+		return ServerConfigNameError{Name: item.Metadata.Name}
+	}
+
+	return nil
+}
+
+// applyServerConfig applies the log level and feature overrides of a server config. A nil config restores the
+// settings the server started with.
+func (h *Handler) applyServerConfig(ctx context.Context, serverConfig *ServerConfig) error {
+	level := h.initialLogLevel
+	featureOverrides := make(map[Feature]bool)
+
+	if serverConfig != nil {
+		if serverConfig.LogLevel != "" {
+			err := level.UnmarshalText([]byte(serverConfig.LogLevel))
+			if err != nil {
+				return fmt.Errorf("server config has invalid log level: %w", err)
+			}
+		}
+
+		for name, enabled := range serverConfig.Features {
+			featureOverrides[Feature(name)] = enabled
+		}
+	}
+
+	if h.logLevel != nil {
+		h.logLevel.Set(level)
+	}
+
+	h.featureOverrides.Store(&featureOverrides)
+	h.logger.InfoContext(ctx, "server config applied", "logLevel", level, "features", featureOverrides)
+
+	return nil
+}
+
+// serverConfigWritten applies a server config written through the handler.
+func (h *Handler) serverConfigWritten(ctx context.Context, operation OperationType, item *Resource) {
+	if item.Metadata.Name != serverConfigName {
+		return
+	}
+
+	var serverConfig *ServerConfig
+
+	if operation != OperationDelete {
+		var err error
+
+		serverConfig, err = serverConfigFromResource(item)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to parse server config", "error", err)
+
+			return
+		}
+	}
+
+	err := h.applyServerConfig(ctx, serverConfig)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to apply server config", "error", err)
+	}
+}
+
+// ReloadServerConfig applies the stored server config. Configs written through the handler are applied right away,
+// so this is for picking up configs written through other replicas, e.g. on SIGHUP or periodically.
+func (h *Handler) ReloadServerConfig(ctx context.Context) error {
+	item, err := h.repoGet(ctx, corePackageName, "ServerConfig", serverConfigName)
+	if err != nil {
+		var resourceNotFoundError ResourceNotFoundError
+		if errors.As(err, &resourceNotFoundError) {
+			return h.applyServerConfig(ctx, nil)
+		}
+
+		return fmt.Errorf("failed to get server config: %w", err)
+	}
+
+	serverConfig, err := serverConfigFromResource(item)
+	if err != nil {
+		return err
+	}
+
+	return h.applyServerConfig(ctx, serverConfig)
+}
@@ -0,0 +1,212 @@
+package bass
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type (
+	Setting      = apiv1.Setting
+	SettingValue = apiv1.SettingValue
+)
+
+type SettingSchemaError struct {
+	Err error
+}
+
+func (err SettingSchemaError) Error() string {
+	return fmt.Sprintf("setting schema is invalid: %s", err.Err)
+}
+
+func (err SettingSchemaError) Unwrap() error {
+	return err.Err
+}
+
+func settingResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "Setting.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "Setting",
+		Plural:       "Settings",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"schema":       map[string]any{"type": "object"},
+						"value":        map[string]any{},
+						"environments": map[string]any{"type": "object"},
+					},
+					"required": []any{"value"},
+				},
+			},
+		},
+	}
+}
+
+// checkSetting validates the value of a setting and its environment overlays against the schema of the setting.
+func checkSetting(item *Resource) error {
+	schema, ok := item.Properties["schema"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	if err != nil {
+		return SettingSchemaError{Err: err}
+	}
+
+	values := []any{item.Properties["value"]}
+	pointers := []string{"/properties/value"}
+
+	if environments, ok := item.Properties["environments"].(map[string]any); ok {
+		for _, environment := range slices.Sorted(maps.Keys(environments)) {
+			values = append(values, environments[environment])
+			pointers = append(pointers, "/properties/environments/"+escapeJSONPointer(environment))
+		}
+	}
+
+	for i, value := range values {
+		result, err := compiled.Validate(gojsonschema.NewGoLoader(value))
+		if err != nil {
+			return fmt.Errorf("failed to validate setting value: %w", err)
+		}
+
+		if !result.Valid() {
+			return ResourceInvalidError{Errors: validationErrors(pointers[i], result.Errors())}
+		}
+	}
+
+	return nil
+}
+
+// settingValue resolves the value of a setting in an environment, falling back to the default value if the
+// environment doesn't overlay it.
+func settingValue(item *Resource, environment string) SettingValue {
+	res := SettingValue{
+		Name:            item.Metadata.Name,
+		Environment:     environment,
+		Value:           item.Properties["value"],
+		ResourceVersion: item.Metadata.ResourceVersion,
+	}
+
+	if environments, ok := item.Properties["environments"].(map[string]any); ok {
+		if value, ok := environments[environment]; ok {
+			res.Value = value
+		}
+	}
+
+	return res
+}
+
+// handleGetSettingValue responds with the effective value of a setting in the environment given by the environment
+// query parameter. With watch=true, it streams the value as newline delimited JSON, once now and again on every
+// change, until the setting is deleted.
+func (h *Handler) handleGetSettingValue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		environment := r.URL.Query().Get("environment")
+
+		watch, err := parseBoolQuery(r, "watch")
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		// subscribe before reading, so no change between the read and the watch is missed
+		events, stop := h.watchHub.subscribe()
+		defer stop()
+
+		item, err := h.repoGet(r.Context(), corePackageName, "Setting", name)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get setting", "error", err)
+
+			var resourceNotFoundError ResourceNotFoundError
+
+			switch {
+			case errors.As(err, &resourceNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		if !watch {
+			respond.Done(w, r, settingValue(item, environment))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		rc := http.NewResponseController(w)
+		enc := jsontext.NewEncoder(w)
+
+		for {
+			err = json.MarshalEncode(enc, settingValue(item, environment))
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "failed to write setting value", "error", err)
+
+				return
+			}
+
+			err = rc.Flush()
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "failed to flush setting value", "error", err)
+
+				return
+			}
+
+			item = h.nextSetting(r, events, name)
+			if item == nil {
+				return
+			}
+		}
+	}
+}
+
+// nextSetting waits for the next write of the named setting. It returns nil if the setting is deleted, the watch
+// falls behind, or the request is done.
+func (h *Handler) nextSetting(r *http.Request, events <-chan resourceEvent, name string) *Resource {
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			metadata := event.Item.Metadata
+			if metadata.PackageName != corePackageName || metadata.ResourceType != "Setting" || metadata.Name != name {
+				continue
+			}
+
+			if event.Type == OperationDelete {
+				return nil
+			}
+
+			return event.Item
+		}
+	}
+}
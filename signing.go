@@ -0,0 +1,176 @@
+package bass
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+// Headers of signed requests.
+const (
+	SignatureKeyIDHeader     = "X-Bass-Key-Id"
+	SignatureTimestampHeader = "X-Bass-Timestamp"
+	SignatureHeader          = "X-Bass-Signature"
+)
+
+// DefaultSignatureMaxSkew is the maximum age of signed requests used when RequestSigning has no MaxSkew.
+const DefaultSignatureMaxSkew = 5 * time.Minute
+
+// RequestSigning configures the verification of HMAC-signed requests. A request is signed with the key of its key
+// ID header, over its timestamp header, method, URI, and body, as described in SignRequest.
+type RequestSigning struct {
+	// Keys holds the shared secrets by key ID, one per integration.
+	Keys map[string][]byte
+	// MaxSkew bounds the difference between the timestamp of a request and the clock of the handler.
+	MaxSkew time.Duration
+}
+
+type RequestSignatureError struct {
+	Reason string
+}
+
+func (err RequestSignatureError) Error() string {
+	return "request signature is invalid: " + err.Reason
+}
+
+type signingKeyIDContextKey struct{}
+
+// SigningKeyIDFromContext returns the key ID of the verified signature of a request, e.g. to resolve the meter
+// subject, or an empty string if the request isn't signed.
+func SigningKeyIDFromContext(ctx context.Context) string {
+	keyID, _ := ctx.Value(signingKeyIDContextKey{}).(string)
+
+	return keyID
+}
+
+// requestSignature returns the hex encoded HMAC-SHA256 of "{timestamp}.{method}.{requestURI}.{body}" with key.
+func requestSignature(key []byte, timestamp, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = io.WriteString(mac, timestamp+"."+method+"."+requestURI+".")
+	_, _ = mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs req with the key of keyID at now, for handlers verifying signatures with RequestSigning. The body
+// of req is read and replaced.
+func SignRequest(req *http.Request, keyID string, key []byte, now time.Time) error {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req.Header.Set(SignatureKeyIDHeader, keyID)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+requestSignature(key, timestamp, req.Method, req.URL.RequestURI(), body))
+
+	return nil
+}
+
+// verifyRequestSignature checks the signature of r and returns its key ID. The body of r is read and replaced.
+func (h *Handler) verifyRequestSignature(r *http.Request) (string, error) {
+	keyID := r.Header.Get(SignatureKeyIDHeader)
+
+	key, ok := h.requestSigning.Keys[keyID]
+	if !ok {
+		return "", RequestSignatureError{Reason: "unknown key id"}
+	}
+
+	timestamp := r.Header.Get(SignatureTimestampHeader)
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", RequestSignatureError{Reason: "invalid timestamp"}
+	}
+
+	maxSkew := h.requestSigning.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = DefaultSignatureMaxSkew
+	}
+
+	skew := h.now().Sub(time.Unix(seconds, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return "", RequestSignatureError{Reason: "timestamp out of range"}
+	}
+
+	signature, ok := strings.CutPrefix(r.Header.Get(SignatureHeader), "sha256=")
+	if !ok {
+		return "", RequestSignatureError{Reason: "missing sha256 signature"}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	expected := requestSignature(key, timestamp, r.Method, r.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", RequestSignatureError{Reason: "signature mismatch"}
+	}
+
+	return keyID, nil
+}
+
+// withRequestSigning rejects requests without a valid signature if request signing is configured.
+func (h *Handler) withRequestSigning(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.requestSigning == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		keyID, err := h.verifyRequestSignature(r)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to verify request signature", "error", err)
+
+			var (
+				requestSignatureError RequestSignatureError
+				maxBytesError         *http.MaxBytesError
+			)
+
+			switch {
+			case errors.As(err, &requestSignatureError):
+				respond.Done(w, r, problem.CustomError(
+					problem.WithStatus(http.StatusUnauthorized),
+					problem.WithTitle("Unauthorized"),
+					problem.WithDetail(requestSignatureError.Error()),
+					withReason(apierrors.ReasonUnauthorized),
+				))
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signingKeyIDContextKey{}, keyID)))
+	})
+}
@@ -0,0 +1,112 @@
+package bass
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type StateMachine = apiv1.StateMachine
+
+type StateTransitionError struct {
+	Property string
+	From     string
+	To       string
+}
+
+func (err StateTransitionError) Error() string {
+	return fmt.Sprintf("transition of %q from %q to %q is not allowed", err.Property, err.From, err.To)
+}
+
+func stateTransitionInvalid(err StateTransitionError) problem.Problem {
+	return problem.CustomError(
+		problem.WithStatus(http.StatusUnprocessableEntity),
+		problem.WithTitle("Unprocessable Entity"),
+		problem.WithDetail(err.Error()),
+		problem.WithExtension("property", err.Property),
+		problem.WithExtension("from", err.From),
+		problem.WithExtension("to", err.To),
+		withReason(apierrors.ReasonInvalidTransition),
+	)
+}
+
+func stateMachineFromProperties(name string, properties map[string]any) (*StateMachine, error) {
+	value, ok := properties["stateMachine"]
+	if !ok {
+		return nil, nil //nolint:nilnil // no state machine
+	}
+
+	stateMachineMap, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("resource type %q has invalid stateMachine property", name)
+	}
+
+	stateMachine := &StateMachine{Property: "", Transitions: make(map[string][]string)}
+
+	stateMachine.Property, ok = stateMachineMap["property"].(string)
+	if !ok {
+		return nil, fmt.Errorf("resource type %q has invalid state property", name)
+	}
+
+	transitions, ok := stateMachineMap["transitions"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("resource type %q has invalid transitions property", name)
+	}
+
+	for from, tos := range transitions {
+		tos, ok := tos.([]any)
+		if !ok {
+			return nil, fmt.Errorf("resource type %q has invalid transitions from %q", name, from)
+		}
+
+		for _, to := range tos {
+			to, ok := to.(string)
+			if !ok {
+				return nil, fmt.Errorf("resource type %q has invalid transitions from %q", name, from)
+			}
+
+			stateMachine.Transitions[from] = append(stateMachine.Transitions[from], to)
+		}
+	}
+
+	return stateMachine, nil
+}
+
+// checkTransition returns StateTransitionError if updating current to item changes the state in a way the state
+// machine of the resource type doesn't allow. Keeping the state is always allowed.
+func checkTransition(resourceTypeDefinition *ResourceTypeDefinition, current, item *Resource) error {
+	stateMachine := resourceTypeDefinition.StateMachine
+	if stateMachine == nil {
+		return nil
+	}
+
+	from, _ := current.Properties[stateMachine.Property].(string)
+	to, _ := item.Properties[stateMachine.Property].(string)
+
+	if from == to || slices.Contains(stateMachine.Transitions[from], to) {
+		return nil
+	}
+
+	return StateTransitionError{Property: stateMachine.Property, From: from, To: to}
+}
+
+// validateTransition checks the state change from current to item with checkTransition. If the change is not
+// allowed, it responds with the failure and returns false.
+func (h *Handler) validateTransition(w http.ResponseWriter, r *http.Request, resourceTypeDefinition *ResourceTypeDefinition, current, item *Resource) bool {
+	var stateTransitionError StateTransitionError
+
+	if errors.As(checkTransition(resourceTypeDefinition, current, item), &stateTransitionError) {
+		h.logger.ErrorContext(r.Context(), "state transition is not allowed", "error", stateTransitionError)
+		respond.Done(w, r, stateTransitionInvalid(stateTransitionError))
+
+		return false
+	}
+
+	return true
+}
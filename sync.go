@@ -0,0 +1,360 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	SyncRequest      = apiv1.SyncRequest
+	SyncChange       = apiv1.SyncChange
+	SyncResponse     = apiv1.SyncResponse
+	SyncResult       = apiv1.SyncResult
+	SyncServerChange = apiv1.SyncServerChange
+)
+
+type SyncTokenError struct {
+	Token string
+}
+
+func (err SyncTokenError) Error() string {
+	return fmt.Sprintf("sync token %q is invalid or expired; sync without token to start over", err.Token)
+}
+
+// syncToken encodes a position in the revision log. It names the start of the log, so tokens of an earlier log, e.g.
+// before a restart, are rejected. Tokens expire once the revisions after their position are dropped.
+func (h *Handler) syncToken(position int) string {
+	return strconv.FormatInt(h.revisions.started.UnixNano(), 10) + "." + strconv.Itoa(position)
+}
+
+func (h *Handler) parseSyncToken(token string) (int, error) {
+	started, position, ok := strings.Cut(token, ".")
+	if !ok || started != strconv.FormatInt(h.revisions.started.UnixNano(), 10) {
+		return 0, SyncTokenError{Token: token}
+	}
+
+	n, err := strconv.Atoi(position)
+	if err != nil || !h.revisions.retained(n) {
+		return 0, SyncTokenError{Token: token}
+	}
+
+	return n, nil
+}
+
+// handleSync applies the offline changes of a client to a package one by one, reporting conflicts instead of
+// applying them, and responds with the changes of the server since the sync token of the client.
+func (h *Handler) handleSync() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		transactionalRepo, ok := h.repo.(TransactionalRepository)
+		if h.revisions == nil || !ok {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("sync needs the revision history and a repository supporting transactions"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		var req SyncRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var maxBytesError *http.MaxBytesError
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		if req.PackageName == "" || req.PackageName == corePackageName {
+			respond.Done(w, r, problem.BadRequest("sync must name a non-core package", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		err = h.checkNetworkAccess(r, req.PackageName)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "network access denied", "error", err)
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusForbidden),
+				problem.WithTitle("Forbidden"),
+				problem.WithDetail(err.Error()),
+				withReason(apierrors.ReasonAccessDenied),
+			))
+
+			return
+		}
+
+		key, hasKey := r.Context().Value(apiKeyContextKey{}).(APIKey)
+		if hasKey && key.PackageName != req.PackageName {
+			h.respondAPIKeyDenied(w, r, key, APIKeyPermissionError{Name: key.Name, Verb: "sync", PackageName: req.PackageName, ResourceTypePlural: ""})
+
+			return
+		}
+
+		h.sync(w, r, transactionalRepo, req)
+	}
+}
+
+// syncChangeVerb returns the verb of API key permissions a change of a client needs.
+func syncChangeVerb(op OperationType) string {
+	switch op {
+	case OperationCreate:
+		return APIKeyVerbCreate
+	case OperationDelete:
+		return APIKeyVerbDelete
+	default:
+		return APIKeyVerbUpdate
+	}
+}
+
+// syncReadable returns the changes of the server the API key of a sync, if any, may list.
+func (h *Handler) syncReadable(ctx context.Context, changes []SyncServerChange) ([]SyncServerChange, error) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(APIKey)
+	if !ok {
+		return changes, nil
+	}
+
+	allowed := make(map[string]bool)
+	res := make([]SyncServerChange, 0, len(changes))
+
+	for _, change := range changes {
+		resourceType := strings.ToLower(change.Item.Metadata.ResourceType)
+
+		readable, checked := allowed[resourceType]
+		if !checked {
+			err := h.checkAPIKeyAccess(ctx, key, APIKeyVerbList, change.Item.Metadata.PackageName, resourceType)
+
+			var apiKeyPermissionError APIKeyPermissionError
+
+			switch {
+			case err == nil:
+				readable = true
+			case errors.As(err, &apiKeyPermissionError):
+				readable = false
+			default:
+				return nil, err
+			}
+
+			allowed[resourceType] = readable
+		}
+
+		if readable {
+			res = append(res, change)
+		}
+	}
+
+	return res, nil
+}
+
+func (h *Handler) sync(w http.ResponseWriter, r *http.Request, transactionalRepo TransactionalRepository, req SyncRequest) {
+	ctx := r.Context()
+
+	from := 0
+
+	if req.SyncToken != "" {
+		var err error
+
+		from, err = h.parseSyncToken(req.SyncToken)
+		if err != nil {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusGone),
+				problem.WithTitle("Gone"),
+				problem.WithDetail(err.Error()),
+				withReason(apierrors.ReasonSyncTokenExpired),
+			))
+
+			return
+		}
+	}
+
+	res := SyncResponse{SyncToken: "", Results: make([]SyncResult, 0, len(req.Changes)), Changes: make([]SyncServerChange, 0)}
+	applied := make(map[string]bool)
+
+	for _, change := range req.Changes {
+		result, op := h.applySyncChange(ctx, transactionalRepo, req.PackageName, change)
+		if op != nil {
+			applied[syncRevisionKey(op.Type, op.Item)] = true
+		}
+
+		res.Results = append(res.Results, result)
+	}
+
+	to := h.revisions.position()
+	res.SyncToken = h.syncToken(to)
+
+	if req.SyncToken == "" {
+		changes, err := h.syncSnapshot(ctx, req.PackageName)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "failed to list package items", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		res.Changes = changes
+	} else {
+		revisions, ok := h.revisions.packageRevisionsBetween(req.PackageName, from, to)
+		if !ok {
+			// The revisions after the token were dropped while the changes of the client were applied.
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusGone),
+				problem.WithTitle("Gone"),
+				problem.WithDetail(SyncTokenError{Token: req.SyncToken}.Error()),
+				withReason(apierrors.ReasonSyncTokenExpired),
+			))
+
+			return
+		}
+
+		for _, rev := range revisions {
+			if applied[syncRevisionKey(rev.Type, rev.Item)] {
+				continue
+			}
+
+			res.Changes = append(res.Changes, SyncServerChange{Type: rev.Type, Item: rev.Item})
+		}
+	}
+
+	changes, err := h.syncReadable(ctx, res.Changes)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to check API key permissions", "error", err)
+		respond.Done(w, r, serverError(err))
+
+		return
+	}
+
+	res.Changes = changes
+
+	if present := h.itemAnonymizer(ctx, privilegedRead(r)); present != nil {
+		anonymizeSyncResponse(&res, present)
+	}
+
+	respond.Done(w, r, res)
+}
+
+// anonymizeSyncResponse replaces the items of a sync response as present returns them.
+func anonymizeSyncResponse(res *SyncResponse, present func(item *Resource) *Resource) {
+	for i, change := range res.Changes {
+		res.Changes[i].Item = present(change.Item)
+	}
+
+	for i, result := range res.Results {
+		if result.Item != nil {
+			res.Results[i].Item = present(result.Item)
+		}
+
+		if result.Current != nil {
+			res.Results[i].Current = present(result.Current)
+		}
+	}
+}
+
+// syncRevisionKey identifies the revision of a write, to leave the writes of a sync out of its changes.
+func syncRevisionKey(operation OperationType, item *Resource) string {
+	return string(operation) + "/" + restoreKey(item) + "/" + item.Metadata.ResourceVersion
+}
+
+// applySyncChange applies a change of a client, and returns its result and, if it was applied, its operation.
+func (h *Handler) applySyncChange(ctx context.Context, transactionalRepo TransactionalRepository, packageName string, change SyncChange) (SyncResult, *Operation) {
+	if key, ok := ctx.Value(apiKeyContextKey{}).(APIKey); ok {
+		err := h.checkAPIKeyAccess(ctx, key, syncChangeVerb(change.Op), packageName, change.ResourceTypePlural)
+		if err != nil {
+			return SyncResult{Status: transactionErrorReason(err).Status(), Item: nil, Conflict: false, Current: nil, Error: err.Error()}, nil
+		}
+	}
+
+	op, err := h.transactionOperation(ctx, TransactionOperation{
+		Op:                 change.Op,
+		PackageName:        packageName,
+		APIVersion:         change.APIVersion,
+		ResourceTypePlural: change.ResourceTypePlural,
+		Name:               change.Name,
+		Labels:             change.Labels,
+		Properties:         change.Properties,
+		Precondition:       Precondition{UID: "", ResourceVersion: change.BaseResourceVersion},
+	}, false)
+	if err != nil {
+		return SyncResult{Status: transactionErrorReason(err).Status(), Item: nil, Conflict: false, Current: nil, Error: err.Error()}, nil
+	}
+
+	err = transactionalRepo.Transact(ctx, []Operation{op})
+	if err != nil {
+		var transactionError TransactionError
+		if errors.As(err, &transactionError) {
+			err = transactionError.Err
+		}
+
+		var (
+			resourceExistsError          ResourceExistsError
+			resourceVersionConflictError ResourceVersionConflictError
+			preconditionFailedError      PreconditionFailedError
+		)
+
+		if errors.As(err, &resourceExistsError) || errors.As(err, &resourceVersionConflictError) || errors.As(err, &preconditionFailedError) {
+			current, _ := h.repoGet(ctx, op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name)
+
+			return SyncResult{Status: http.StatusConflict, Item: nil, Conflict: true, Current: current, Error: err.Error()}, nil
+		}
+
+		return SyncResult{Status: transactionErrorReason(err).Status(), Item: nil, Conflict: false, Current: nil, Error: err.Error()}, nil
+	}
+
+	h.resourceWritten(ctx, op.Type, op.Item)
+
+	switch op.Type {
+	case OperationCreate:
+		return SyncResult{Status: http.StatusCreated, Item: op.Item, Conflict: false, Current: nil, Error: ""}, &op
+	case OperationUpdate:
+		return SyncResult{Status: http.StatusOK, Item: op.Item, Conflict: false, Current: nil, Error: ""}, &op
+	case OperationDelete:
+		h.deleteActivity(ctx, op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name)
+		h.deleteLocalizations(ctx, op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name)
+
+		return SyncResult{Status: http.StatusNoContent, Item: nil, Conflict: false, Current: nil, Error: ""}, &op
+	default:
+		return SyncResult{Status: http.StatusBadRequest, Item: nil, Conflict: false, Current: nil, Error: "unknown operation"}, nil
+	}
+}
+
+// syncSnapshot returns all items of a package as creates, for the first sync of a client.
+func (h *Handler) syncSnapshot(ctx context.Context, packageName string) ([]SyncServerChange, error) {
+	resourceTypeDefinitions, err := h.listResourceTypeDefinitions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]SyncServerChange, 0)
+
+	for _, resourceTypeDefinition := range resourceTypeDefinitions {
+		list, err := h.repoList(ctx, packageName, resourceTypeDefinition.Versions[0].Name, resourceTypeDefinition.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range list.Items {
+			changes = append(changes, SyncServerChange{Type: OperationCreate, Item: item})
+		}
+	}
+
+	return changes, nil
+}
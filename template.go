@@ -0,0 +1,95 @@
+package bass
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+)
+
+type ResourceTrigger = apiv1.ResourceTrigger
+
+// TemplateError reports a template field of an item that fails to parse or execute.
+type TemplateError struct {
+	Field string
+	Err   error
+}
+
+func (err TemplateError) Error() string {
+	return fmt.Sprintf("template %s is invalid: %s", err.Field, err.Err)
+}
+
+func (err TemplateError) Unwrap() error {
+	return err.Err
+}
+
+var errTemplateParse = errors.New("parse error")
+
+// templateParseError returns err if it is a TemplateError for a template that doesn't parse. Execution errors are
+// ignored, since they depend on the data.
+func templateParseError(err error) error {
+	var templateError TemplateError
+	if errors.As(err, &templateError) && errors.Is(templateError.Err, errTemplateParse) {
+		return templateError
+	}
+
+	return nil
+}
+
+// executeTextTemplate executes text as a text template with data. Missing keys are rendered empty.
+func executeTextTemplate(field, text string, data any) (string, error) {
+	t, err := template.New(field).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", TemplateError{Field: field, Err: fmt.Errorf("%w: %w", errTemplateParse, err)}
+	}
+
+	var buf strings.Builder
+
+	err = t.Execute(&buf, data)
+	if err != nil {
+		return "", TemplateError{Field: field, Err: err}
+	}
+
+	return buf.String(), nil
+}
+
+func resourceTriggerSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"packageName":  map[string]any{"type": "string"},
+			"resourceType": map[string]any{"type": "string"},
+			"operations": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string", "enum": []any{"create", "update", "delete"}},
+			},
+		},
+		"required": []any{"packageName", "resourceType"},
+	}
+}
+
+func triggerMatches(trigger *ResourceTrigger, operation OperationType, item *Resource) bool {
+	return trigger != nil && trigger.PackageName == item.Metadata.PackageName && trigger.ResourceType == item.Metadata.ResourceType &&
+		(len(trigger.Operations) == 0 || slices.Contains(trigger.Operations, operation))
+}
+
+// triggerData is the template data of a triggered write: the operation, and the item as it is encoded in JSON.
+func triggerData(operation OperationType, item *Resource) (map[string]any, error) {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal item: %w", err)
+	}
+
+	var itemMap map[string]any
+
+	err = json.Unmarshal(b, &itemMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+
+	return map[string]any{"operation": string(operation), "item": itemMap}, nil
+}
@@ -0,0 +1,256 @@
+package bass
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	TimeSeries           = apiv1.TimeSeries
+	TimeSeriesBucket     = apiv1.TimeSeriesBucket
+	TimeSeriesBucketList = apiv1.TimeSeriesBucketList
+)
+
+type ResourceImmutableError struct {
+	PackageName  string
+	ResourceType string
+}
+
+func (err ResourceImmutableError) Error() string {
+	return fmt.Sprintf("resources of resource type %q and package %q are immutable", err.ResourceType, err.PackageName)
+}
+
+func resourceImmutable(err ResourceImmutableError) problem.Problem {
+	return problem.CustomError(
+		problem.WithStatus(http.StatusMethodNotAllowed),
+		problem.WithTitle("Method Not Allowed"),
+		problem.WithDetail(err.Error()),
+		withReason(apierrors.ReasonMethodNotAllowed),
+	)
+}
+
+// checkUpdatable returns ResourceImmutableError if items of the resource type can't be updated. Items of time series
+// are never updated, and items with drafts only by publishing.
+func checkUpdatable(resourceTypeDefinition *ResourceTypeDefinition) error {
+	if resourceTypeDefinition.TimeSeries != nil || resourceTypeDefinition.Drafts {
+		return ResourceImmutableError{
+			PackageName:  resourceTypeDefinition.Package,
+			ResourceType: resourceTypeDefinition.ResourceType,
+		}
+	}
+
+	return nil
+}
+
+func timeSeriesFromProperties(name string, properties map[string]any) (*TimeSeries, error) {
+	value, ok := properties["timeSeries"]
+	if !ok {
+		return nil, nil //nolint:nilnil // not a time series
+	}
+
+	timeSeriesMap, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("resource type %q has invalid timeSeries property", name)
+	}
+
+	timeSeries := &TimeSeries{TimeProperty: "", Retention: ""}
+
+	timeSeries.TimeProperty, ok = timeSeriesMap["timeProperty"].(string)
+	if !ok {
+		return nil, fmt.Errorf("resource type %q has invalid time property", name)
+	}
+
+	if retention, ok := timeSeriesMap["retention"].(string); ok {
+		timeSeries.Retention = retention
+	}
+
+	return timeSeries, nil
+}
+
+// checkTimeSeriesDefinition validates the time series settings of a resource type definition item.
+func checkTimeSeriesDefinition(item *Resource) error {
+	timeSeries, ok := item.Properties["timeSeries"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	retention, ok := timeSeries["retention"].(string)
+	if !ok {
+		return nil
+	}
+
+	d, err := time.ParseDuration(retention)
+	if err != nil || d <= 0 {
+		return PropertyPathError{Path: "/properties/timeSeries/retention", Reason: "must be a positive duration"}
+	}
+
+	return nil
+}
+
+// checkTimeSeriesItem validates the time property of a time series item.
+func checkTimeSeriesItem(timeSeries *TimeSeries, item *Resource) error {
+	_, ok := timeSeriesTime(timeSeries, item)
+	if !ok {
+		return PropertyPathError{Path: "/properties/" + timeSeries.TimeProperty, Reason: "must be an RFC 3339 timestamp"}
+	}
+
+	return nil
+}
+
+func timeSeriesTime(timeSeries *TimeSeries, item *Resource) (time.Time, bool) {
+	value, ok := item.Properties[timeSeries.TimeProperty].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+func parseTimeQuery(r *http.Request, key string) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s query parameter %q: %w", key, value, err)
+	}
+
+	return t, nil
+}
+
+// respondTimeSeries responds with the items of a time series list in time order, leaving out items past the
+// retention and outside the range given by the from and to query parameters. With the downsample and property query
+// parameters, it responds with aggregates of the property per downsample interval instead.
+func (h *Handler) respondTimeSeries(w http.ResponseWriter, r *http.Request, timeSeries *TimeSeries, list ResourceList) {
+	from, err := parseTimeQuery(r, "from")
+	if err != nil {
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return
+	}
+
+	to, err := parseTimeQuery(r, "to")
+	if err != nil {
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return
+	}
+
+	if timeSeries.Retention != "" {
+		retention, err := time.ParseDuration(timeSeries.Retention)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "invalid time series retention", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		if cutoff := h.now().Add(-retention); from.Before(cutoff) {
+			from = cutoff
+		}
+	}
+
+	type timedItem struct {
+		time time.Time
+		item *Resource
+	}
+
+	timedItems := make([]timedItem, 0, len(list.Items))
+
+	for _, item := range list.Items {
+		t, ok := timeSeriesTime(timeSeries, item)
+		if !ok || t.Before(from) || (!to.IsZero() && !t.Before(to)) {
+			continue
+		}
+
+		timedItems = append(timedItems, timedItem{time: t, item: item})
+	}
+
+	slices.SortStableFunc(timedItems, func(a, b timedItem) int { return a.time.Compare(b.time) })
+
+	list.Items = make([]*Resource, 0, len(timedItems))
+	for _, timedItem := range timedItems {
+		list.Items = append(list.Items, timedItem.item)
+	}
+
+	if r.URL.Query().Get("downsample") == "" {
+		respond.Done(w, r, list)
+
+		return
+	}
+
+	res, err := downsample(timeSeries, list, r.URL.Query().Get("downsample"), r.URL.Query().Get("property"))
+	if err != nil {
+		respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+		return
+	}
+
+	respond.Done(w, r, res)
+}
+
+// downsample aggregates a numeric property of time ordered items per interval. Items without a numeric value are
+// left out.
+func downsample(timeSeries *TimeSeries, list ResourceList, interval, property string) (TimeSeriesBucketList, error) {
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return TimeSeriesBucketList{}, fmt.Errorf("invalid downsample query parameter %q: must be a positive duration", interval)
+	}
+
+	if property == "" {
+		return TimeSeriesBucketList{}, errors.New("property query parameter is required to downsample")
+	}
+
+	res := TimeSeriesBucketList{
+		Metadata: list.Metadata,
+		Property: property,
+		Interval: interval,
+		Buckets:  make([]TimeSeriesBucket, 0),
+	}
+
+	for _, item := range list.Items {
+		value, ok := item.Properties[property].(float64)
+		if !ok {
+			continue
+		}
+
+		t, _ := timeSeriesTime(timeSeries, item)
+		start := t.Truncate(d).UTC()
+
+		if len(res.Buckets) == 0 || !res.Buckets[len(res.Buckets)-1].Start.Equal(start) {
+			res.Buckets = append(res.Buckets, TimeSeriesBucket{
+				Start: start,
+				Count: 0,
+				Sum:   0,
+				Min:   math.Inf(1),
+				Max:   math.Inf(-1),
+				Avg:   0,
+			})
+		}
+
+		bucket := &res.Buckets[len(res.Buckets)-1]
+		bucket.Count++
+		bucket.Sum += value
+		bucket.Min = min(bucket.Min, value)
+		bucket.Max = max(bucket.Max, value)
+		bucket.Avg = bucket.Sum / float64(bucket.Count)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,316 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	TransactionRequest   = apiv1.TransactionRequest
+	TransactionOperation = apiv1.TransactionOperation
+	TransactionResponse  = apiv1.TransactionResponse
+	TransactionResult    = apiv1.TransactionResult
+)
+
+type InvalidOperationError struct {
+	Op   OperationType
+	Name string
+}
+
+func (err InvalidOperationError) Error() string {
+	if err.Name == "" {
+		return fmt.Sprintf("operation %q without name", err.Op)
+	}
+
+	return fmt.Sprintf("invalid operation %q for %q", err.Op, err.Name)
+}
+
+// handleTransaction applies a list of operations atomically. The response reports a result per operation; if one
+// fails, it carries the status of that failure and the others are reported as failed dependency.
+func (h *Handler) handleTransaction() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		transactionalRepo, ok := h.repo.(TransactionalRepository)
+		if !ok {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("repository does not support transactions"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		var req TransactionRequest
+
+		err := json.UnmarshalRead(r.Body, &req)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to decode request body", "error", err)
+
+			var (
+				semanticError *json.SemanticError
+				maxBytesError *http.MaxBytesError
+			)
+
+			switch {
+			case errors.As(err, &maxBytesError):
+				respond.Done(w, r, requestEntityTooLarge(maxBytesError))
+			case errors.As(err, &semanticError):
+				respond.Done(w, r, problem.BadRequest(semanticError.Error(), withReason(apierrors.ReasonBadRequest)))
+			default:
+				respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+			}
+
+			return
+		}
+
+		ops := make([]Operation, 0, len(req.Operations))
+
+		for i, reqOp := range req.Operations {
+			err := h.checkNetworkAccess(r, reqOp.PackageName)
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "network access denied", "index", i, "error", err)
+				h.respondTransactionFailure(w, r, len(req.Operations), i, err)
+
+				return
+			}
+
+			op, err := h.transactionOperation(r.Context(), reqOp, false)
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "invalid transaction operation", "index", i, "error", err)
+				h.respondTransactionFailure(w, r, len(req.Operations), i, err)
+
+				return
+			}
+
+			ops = append(ops, op)
+		}
+
+		err = transactionalRepo.Transact(r.Context(), ops)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to apply transaction", "error", err)
+
+			var transactionError TransactionError
+
+			switch {
+			case errors.As(err, &transactionError):
+				h.respondTransactionFailure(w, r, len(ops), transactionError.Index, transactionError.Err)
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		for _, op := range ops {
+			h.resourceWritten(r.Context(), op.Type, op.Item)
+
+			if op.Type == OperationDelete {
+				h.deleteActivity(r.Context(), op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name)
+				h.deleteLocalizations(r.Context(), op.Item.Metadata.PackageName, op.Item.Metadata.ResourceType, op.Item.Metadata.Name)
+			}
+		}
+
+		res := TransactionResponse{Results: make([]TransactionResult, 0, len(ops))}
+
+		for _, op := range ops {
+			switch op.Type {
+			case OperationCreate:
+				res.Results = append(res.Results, TransactionResult{Status: http.StatusCreated, Item: op.Item, Error: ""})
+			case OperationUpdate:
+				res.Results = append(res.Results, TransactionResult{Status: http.StatusOK, Item: op.Item, Error: ""})
+			case OperationDelete:
+				res.Results = append(res.Results, TransactionResult{Status: http.StatusNoContent, Item: nil, Error: ""})
+			}
+		}
+
+		respond.Done(w, r, res)
+	}
+}
+
+// transactionOperation resolves and validates a requested operation into a repository operation. Operations on
+// protected resource types are only allowed if approved.
+func (h *Handler) transactionOperation(ctx context.Context, reqOp TransactionOperation, approved bool) (Operation, error) {
+	if reqOp.Name == "" {
+		return Operation{}, InvalidOperationError{Op: reqOp.Op, Name: ""}
+	}
+
+	resourceTypeDefinition, err := h.getResourceTypeDefinition(ctx, reqOp.PackageName, reqOp.ResourceTypePlural)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	if resourceTypeDefinition.Approval != nil && !approved {
+		return Operation{}, ApprovalRequiredError{PackageName: reqOp.PackageName, ResourceType: resourceTypeDefinition.ResourceType}
+	}
+
+	// Creates can't hit held items, which exist, so they only check the resource type.
+	heldName := reqOp.Name
+	if reqOp.Op == OperationCreate {
+		heldName = ""
+	}
+
+	err = h.checkLegalHold(ctx, reqOp.PackageName, resourceTypeDefinition.ResourceType, heldName)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	item := &Resource{
+		Metadata: Metadata{
+			PackageName:  reqOp.PackageName,
+			APIVersion:   reqOp.APIVersion,
+			ResourceType: resourceTypeDefinition.ResourceType,
+			Name:         reqOp.Name,
+			Labels:       reqOp.Labels,
+		},
+		Properties: reqOp.Properties,
+	}
+
+	op := Operation{
+		Type:         reqOp.Op,
+		Item:         item,
+		Precondition: reqOp.Precondition,
+	}
+
+	switch reqOp.Op {
+	case OperationCreate:
+		item.Metadata.UID = uuid.NewString()
+		item.Metadata.CreatedAt = h.now()
+		item.Metadata.UpdatedAt = item.Metadata.CreatedAt
+	case OperationUpdate:
+		err = checkUpdatable(resourceTypeDefinition)
+		if err != nil {
+			return Operation{}, err
+		}
+
+		item.Metadata.UpdatedAt = h.now()
+	case OperationDelete:
+		return op, nil
+	default:
+		return Operation{}, InvalidOperationError{Op: reqOp.Op, Name: reqOp.Name}
+	}
+
+	err = h.checkResourceItem(ctx, resourceTypeDefinition, item)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	// Approved operations of quarantined writes were reviewed already.
+	if !approved {
+		err = h.moderateOperation(ctx, resourceTypeDefinition, item)
+		if err != nil {
+			return Operation{}, err
+		}
+	}
+
+	if reqOp.Op == OperationUpdate && resourceTypeDefinition.StateMachine != nil {
+		current, err := h.repoGet(ctx, reqOp.PackageName, resourceTypeDefinition.ResourceType, reqOp.Name)
+		if err != nil {
+			return Operation{}, err
+		}
+
+		err = checkTransition(resourceTypeDefinition, current, item)
+		if err != nil {
+			return Operation{}, err
+		}
+	}
+
+	return op, nil
+}
+
+func (h *Handler) respondTransactionFailure(w http.ResponseWriter, r *http.Request, count, index int, err error) {
+	reason := transactionErrorReason(err)
+	status := reason.Status()
+
+	results := make([]TransactionResult, count)
+
+	for i := range results {
+		results[i] = TransactionResult{Status: http.StatusFailedDependency, Item: nil, Error: ""}
+	}
+
+	results[index] = TransactionResult{Status: status, Item: nil, Error: err.Error()}
+
+	respond.Done(w, r, problem.CustomError(
+		problem.WithStatus(status),
+		problem.WithTitle("Transaction Failed"),
+		problem.WithDetail(fmt.Sprintf("operation %d failed: %s", index, err)),
+		problem.WithExtension("index", index),
+		problem.WithExtension("results", results),
+		withReason(reason),
+	))
+}
+
+func transactionErrorReason(err error) apierrors.Reason {
+	var (
+		resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
+		resourceNotFoundError               ResourceNotFoundError
+		resourceExistsError                 ResourceExistsError
+		resourceVersionConflictError        ResourceVersionConflictError
+		preconditionFailedError             PreconditionFailedError
+		invalidOperationError               InvalidOperationError
+		unknownFieldsError                  UnknownFieldsError
+		resourceInvalidError                ResourceInvalidError
+		settingSchemaError                  SettingSchemaError
+		propertyPathError                   PropertyPathError
+		resourceImmutableError              ResourceImmutableError
+		stateTransitionError                StateTransitionError
+		approvalRequiredError               ApprovalRequiredError
+		networkAccessDeniedError            NetworkAccessDeniedError
+		serverConfigNameError               ServerConfigNameError
+		schemaReferenceError                SchemaReferenceError
+		resourceTypeSchemaError             ResourceTypeSchemaError
+		contentRejectedError                ContentRejectedError
+		moderationClassifierNotFoundError   ModerationClassifierNotFoundError
+		moderationClassifierError           ModerationClassifierError
+		legalHoldError                      LegalHoldError
+		apiKeyPermissionError               APIKeyPermissionError
+	)
+
+	switch {
+	case errors.As(err, &resourceTypeDefinitionNotFoundError), errors.As(err, &resourceNotFoundError):
+		return apierrors.ReasonNotFound
+	case errors.As(err, &resourceExistsError):
+		return apierrors.ReasonAlreadyExists
+	case errors.As(err, &resourceVersionConflictError):
+		return apierrors.ReasonConflict
+	case errors.As(err, &preconditionFailedError):
+		return apierrors.ReasonPreconditionFailed
+	case errors.As(err, &invalidOperationError):
+		return apierrors.ReasonBadRequest
+	case errors.As(err, &unknownFieldsError):
+		return apierrors.ReasonUnknownFields
+	case errors.As(err, &resourceInvalidError), errors.As(err, &settingSchemaError), errors.As(err, &propertyPathError),
+		errors.As(err, &serverConfigNameError), errors.As(err, &schemaReferenceError),
+		errors.As(err, &resourceTypeSchemaError):
+		return apierrors.ReasonInvalid
+	case errors.As(err, &resourceImmutableError):
+		return apierrors.ReasonMethodNotAllowed
+	case errors.As(err, &stateTransitionError):
+		return apierrors.ReasonInvalidTransition
+	case errors.As(err, &approvalRequiredError):
+		return apierrors.ReasonApprovalRequired
+	case errors.As(err, &contentRejectedError):
+		return apierrors.ReasonContentRejected
+	case errors.As(err, &moderationClassifierNotFoundError):
+		return apierrors.ReasonNotImplemented
+	case errors.As(err, &moderationClassifierError):
+		return apierrors.ReasonDeliveryFailed
+	case errors.As(err, &legalHoldError):
+		return apierrors.ReasonLegalHold
+	case errors.As(err, &networkAccessDeniedError), errors.As(err, &apiKeyPermissionError):
+		return apierrors.ReasonAccessDenied
+	case errors.Is(err, context.DeadlineExceeded):
+		return apierrors.ReasonTimeout
+	default:
+		return apierrors.ReasonInternal
+	}
+}
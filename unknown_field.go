@@ -0,0 +1,62 @@
+package bass
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// UnknownFieldPolicy decides what happens to top-level properties of a resource item that are not declared in the
+// "properties" of its resource type schema, regardless of the schema's own "additionalProperties".
+type UnknownFieldPolicy string
+
+const (
+	// UnknownFieldPolicyPreserve keeps unknown fields and leaves the decision to the schema.
+	UnknownFieldPolicyPreserve UnknownFieldPolicy = "Preserve"
+	// UnknownFieldPolicyStrip drops unknown fields before validation and storage.
+	UnknownFieldPolicyStrip UnknownFieldPolicy = "Strip"
+	// UnknownFieldPolicyReject responds bad request if the item has unknown fields.
+	UnknownFieldPolicyReject UnknownFieldPolicy = "Reject"
+)
+
+type UnknownFieldsError struct {
+	Fields []string
+}
+
+func (err UnknownFieldsError) Error() string {
+	return "resource item has unknown fields " + strings.Join(err.Fields, ", ")
+}
+
+// applyUnknownFieldPolicy applies the unknown field policy of the handler to the item. Schemas without "properties"
+// declare no fields, so nothing is unknown to them.
+func (h *Handler) applyUnknownFieldPolicy(schema map[string]any, item *Resource) error {
+	if h.unknownFieldPolicy == UnknownFieldPolicyPreserve {
+		return nil
+	}
+
+	declared, ok := schema["properties"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	unknown := slices.Sorted(maps.Keys(item.Properties))
+	unknown = slices.DeleteFunc(unknown, func(field string) bool {
+		_, ok := declared[field]
+
+		return ok
+	})
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	if h.unknownFieldPolicy == UnknownFieldPolicyReject {
+		return UnknownFieldsError{Fields: unknown}
+	}
+
+	for _, field := range unknown {
+		delete(item.Properties, field)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package bass
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	Usage             = apiv1.Usage
+	PackageUsage      = apiv1.PackageUsage
+	ResourceTypeUsage = apiv1.ResourceTypeUsage
+)
+
+// ResourceTypeStats is the storage used by the resources of a resource type.
+type ResourceTypeStats struct {
+	PackageName  string
+	ResourceType string
+	Count        int
+	// Bytes is the approximate size of the stored resources.
+	Bytes int64
+}
+
+// StatsRepository is implemented by repositories that can report their storage usage. It backs the usage endpoint.
+type StatsRepository interface {
+	Stats(ctx context.Context) (stats []ResourceTypeStats, err error)
+}
+
+// handleUsage reports resource counts and approximate bytes per package and resource type.
+func (h *Handler) handleUsage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statsRepo, ok := h.repo.(StatsRepository)
+		if !ok {
+			respond.Done(w, r, problem.CustomError(
+				problem.WithStatus(http.StatusNotImplemented),
+				problem.WithTitle("Not Implemented"),
+				problem.WithDetail("repository does not support usage stats"),
+				withReason(apierrors.ReasonNotImplemented),
+			))
+
+			return
+		}
+
+		stats, err := statsRepo.Stats(r.Context())
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get repository stats", "error", err)
+			respond.Done(w, r, serverError(err))
+
+			return
+		}
+
+		respond.Done(w, r, newUsage(stats))
+	}
+}
+
+func newUsage(stats []ResourceTypeStats) Usage {
+	slices.SortFunc(stats, func(a, b ResourceTypeStats) int {
+		return cmp.Or(cmp.Compare(a.PackageName, b.PackageName), cmp.Compare(a.ResourceType, b.ResourceType))
+	})
+
+	usage := Usage{Count: 0, Bytes: 0, Packages: make([]PackageUsage, 0)}
+
+	for _, stat := range stats {
+		if len(usage.Packages) == 0 || usage.Packages[len(usage.Packages)-1].PackageName != stat.PackageName {
+			usage.Packages = append(usage.Packages, PackageUsage{
+				PackageName:   stat.PackageName,
+				Count:         0,
+				Bytes:         0,
+				ResourceTypes: make([]ResourceTypeUsage, 0),
+			})
+		}
+
+		packageUsage := &usage.Packages[len(usage.Packages)-1]
+		packageUsage.ResourceTypes = append(packageUsage.ResourceTypes, ResourceTypeUsage{
+			ResourceType: stat.ResourceType,
+			Count:        stat.Count,
+			Bytes:        stat.Bytes,
+		})
+		packageUsage.Count += stat.Count
+		packageUsage.Bytes += stat.Bytes
+		usage.Count += stat.Count
+		usage.Bytes += stat.Bytes
+	}
+
+	return usage
+}
@@ -0,0 +1,194 @@
+package bass
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/http"
+	"strings"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type ValidationError = apiv1.ValidationError
+
+// softConstraintsKeyword is the schema keyword of soft constraints. Its value holds keywords that are checked like
+// the others of the schema, e.g. {"maxLength": 80}, but are reported as warnings instead of rejecting the item.
+const softConstraintsKeyword = "x-warn"
+
+// validationKeyword returns the JSON schema keyword of a validation error type of gojsonschema.
+func validationKeyword(errorType string) string {
+	switch errorType {
+	case "invalid_type":
+		return "type"
+	case "number_any_of":
+		return "anyOf"
+	case "number_one_of":
+		return "oneOf"
+	case "number_all_of":
+		return "allOf"
+	case "number_not":
+		return "not"
+	case "missing_dependency":
+		return "dependencies"
+	case "array_no_additional_items":
+		return "additionalItems"
+	case "array_min_items":
+		return "minItems"
+	case "array_max_items":
+		return "maxItems"
+	case "unique":
+		return "uniqueItems"
+	case "array_min_properties":
+		return "minProperties"
+	case "array_max_properties":
+		return "maxProperties"
+	case "additional_property_not_allowed":
+		return "additionalProperties"
+	case "invalid_property_pattern":
+		return "patternProperties"
+	case "invalid_property_name":
+		return "propertyNames"
+	case "string_gte":
+		return "minLength"
+	case "string_lte":
+		return "maxLength"
+	case "multiple_of":
+		return "multipleOf"
+	case "number_gte":
+		return "minimum"
+	case "number_gt":
+		return "exclusiveMinimum"
+	case "number_lte":
+		return "maximum"
+	case "number_lt":
+		return "exclusiveMaximum"
+	case "condition_then":
+		return "then"
+	case "condition_else":
+		return "else"
+	default:
+		// "required", "enum", "const", "pattern", "format", "contains", and "false" are named after their keyword.
+		return errorType
+	}
+}
+
+// validationErrors converts the errors of validating the value at pointer in a resource item, e.g. its properties, to
+// validation errors with JSON pointers into the item. Errors of missing or additional properties point to the property itself rather than
+// its parent.
+func validationErrors(pointer string, resultErrors []gojsonschema.ResultError) []ValidationError {
+	res := make([]ValidationError, 0, len(resultErrors))
+
+	for _, resultError := range resultErrors {
+		// The context is "(root)" followed by the keys and indexes of the field.
+		segments := strings.Split(resultError.Context().String("\x00"), "\x00")[1:]
+
+		params := make(map[string]any)
+
+		for key, value := range resultError.Details() {
+			switch key {
+			case "field", "context":
+				continue
+			case "property":
+				if resultError.Type() == "required" || resultError.Type() == "additional_property_not_allowed" {
+					segments = append(segments, fmt.Sprint(value))
+
+					continue
+				}
+			}
+
+			params[key] = validationParam(value)
+		}
+
+		if len(params) == 0 {
+			params = nil
+		}
+
+		fieldPointer := pointer
+		for _, segment := range segments {
+			fieldPointer += "/" + escapeJSONPointer(segment)
+		}
+
+		res = append(res, ValidationError{
+			Pointer: fieldPointer,
+			Keyword: validationKeyword(resultError.Type()),
+			Message: resultError.Description(),
+			Params:  params,
+		})
+	}
+
+	return res
+}
+
+// escapeJSONPointer escapes a reference token of an RFC 6901 JSON pointer.
+func escapeJSONPointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// validationParam converts the details of gojsonschema errors to JSON friendly values.
+func validationParam(value any) any {
+	switch value := value.(type) {
+	case *big.Float:
+		f, _ := value.Float64()
+
+		return f
+	case string, bool, int, float64:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// softConstraintsSchema returns the schema checking the soft constraints of schema, and of the properties and items
+// it declares, or false if it has none.
+func softConstraintsSchema(schema map[string]any) (map[string]any, bool) {
+	res := make(map[string]any)
+
+	if softConstraints, ok := schema[softConstraintsKeyword].(map[string]any); ok {
+		maps.Copy(res, softConstraints)
+	}
+
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		softProperties := make(map[string]any)
+
+		for key, value := range properties {
+			propertySchema, _ := value.(map[string]any)
+			if softPropertySchema, ok := softConstraintsSchema(propertySchema); ok {
+				softProperties[key] = softPropertySchema
+			}
+		}
+
+		if len(softProperties) > 0 {
+			res["properties"] = softProperties
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		if softItems, ok := softConstraintsSchema(items); ok {
+			res["items"] = softItems
+		}
+	}
+
+	return res, len(res) > 0
+}
+
+// warnSoftConstraints sets a Warning header for every soft constraint of the schema the item violates.
+func (h *Handler) warnSoftConstraints(ctx context.Context, w http.ResponseWriter, resourceTypeDefinition *ResourceTypeDefinition, item *Resource) {
+	schema, ok := softConstraintsSchema(resourceTypeDefinition.Versions[0].Schema)
+	if !ok {
+		return
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(item.Properties))
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to check soft constraints", "error", err)
+
+		return
+	}
+
+	for _, validationError := range validationErrors("/properties", result.Errors()) {
+		w.Header().Add("Warning", warningHeaderValue(fmt.Sprintf("%s: %s", validationError.Pointer, validationError.Message)))
+	}
+}
@@ -0,0 +1,617 @@
+package bass
+
+import (
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	View                  = apiv1.View
+	ViewAggregate         = apiv1.ViewAggregate
+	ViewAggregateFunction = apiv1.ViewAggregateFunction
+	ViewPrivacy           = apiv1.ViewPrivacy
+	ViewResult            = apiv1.ViewResult
+)
+
+const (
+	ViewAggregateCount = apiv1.ViewAggregateCount
+	ViewAggregateSum   = apiv1.ViewAggregateSum
+	ViewAggregateAvg   = apiv1.ViewAggregateAvg
+	ViewAggregateMin   = apiv1.ViewAggregateMin
+	ViewAggregateMax   = apiv1.ViewAggregateMax
+)
+
+type ViewNotFoundError struct {
+	PackageName string
+	Name        string
+}
+
+func (err ViewNotFoundError) Error() string {
+	return fmt.Sprintf("view %q not found in package %q", err.Name, err.PackageName)
+}
+
+func viewResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "View.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "View",
+		Plural:       "Views",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"package":       map[string]any{"type": "string", "minLength": 1},
+						"resourceTypes": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1},
+						"fields":        map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+						"groupBy":       map[string]any{"type": "string"},
+						"aggregates": map[string]any{
+							"type": "object",
+							"additionalProperties": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"function": map[string]any{
+										"type": "string",
+										"enum": []any{ViewAggregateCount, ViewAggregateSum, ViewAggregateAvg, ViewAggregateMin, ViewAggregateMax},
+									},
+									"field": map[string]any{"type": "string"},
+									"bound": map[string]any{"type": "number", "exclusiveMinimum": 0},
+								},
+								"required": []any{"function"},
+							},
+						},
+						"privacy": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"minGroupSize": map[string]any{"type": "integer", "minimum": 1},
+								"epsilon":      map[string]any{"type": "number", "exclusiveMinimum": 0},
+							},
+						},
+					},
+					"required": []any{"package", "resourceTypes"},
+				},
+			},
+		},
+	}
+}
+
+func viewFromResource(item *Resource) (*View, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal view properties: %w", err)
+	}
+
+	var view View
+
+	err = json.Unmarshal(b, &view)
+	if err != nil {
+		return nil, fmt.Errorf("view %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	view.Metadata = item.Metadata
+
+	return &view, nil
+}
+
+// checkView checks that a view item is either a projection or an aggregation, and parses its property paths.
+func checkView(item *Resource) error {
+	view, err := viewFromResource(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = compileView(view)
+
+	return err
+}
+
+// viewQuery is a view with its property paths parsed.
+type viewQuery struct {
+	fields     map[string][]string
+	groupBy    []string
+	aggregates map[string]viewAggregate
+}
+
+type viewAggregate struct {
+	function ViewAggregateFunction
+	field    []string
+	bound    float64
+}
+
+func compileView(view *View) (viewQuery, error) {
+	query := viewQuery{fields: make(map[string][]string), groupBy: nil, aggregates: make(map[string]viewAggregate)}
+
+	switch {
+	case len(view.Fields) > 0 && (len(view.Aggregates) > 0 || view.GroupBy != ""):
+		return viewQuery{}, PropertyPathError{Path: "/properties/fields", Reason: "must not be combined with groupBy and aggregates"}
+	case view.GroupBy != "" && len(view.Aggregates) == 0:
+		return viewQuery{}, PropertyPathError{Path: "/properties/groupBy", Reason: "needs aggregates"}
+	}
+
+	for column, path := range view.Fields {
+		segments, err := parsePropertyPath(path)
+		if err != nil {
+			return viewQuery{}, err
+		}
+
+		query.fields[column] = segments
+	}
+
+	if view.GroupBy != "" {
+		segments, err := parsePropertyPath(view.GroupBy)
+		if err != nil {
+			return viewQuery{}, err
+		}
+
+		query.groupBy = segments
+	}
+
+	for column, aggregate := range view.Aggregates {
+		if aggregate.Function == ViewAggregateCount {
+			query.aggregates[column] = viewAggregate{function: aggregate.Function, field: nil, bound: 0}
+
+			continue
+		}
+
+		segments, err := parsePropertyPath(aggregate.Field)
+		if err != nil {
+			return viewQuery{}, PropertyPathError{Path: "/properties/aggregates/" + jsonPointerToken(column) + "/field", Reason: err.Error()}
+		}
+
+		query.aggregates[column] = viewAggregate{function: aggregate.Function, field: segments, bound: aggregate.Bound}
+	}
+
+	if view.Privacy != nil {
+		err := checkViewPrivacy(view)
+		if err != nil {
+			return viewQuery{}, err
+		}
+	}
+
+	return query, nil
+}
+
+// checkViewPrivacy checks that a private view only has aggregates it can protect. Min and max are values of single
+// items, and noisy sums and averages need bounds.
+func checkViewPrivacy(view *View) error {
+	if len(view.Aggregates) == 0 {
+		return PropertyPathError{Path: "/properties/privacy", Reason: "needs aggregates"}
+	}
+
+	for _, column := range slices.Sorted(maps.Keys(view.Aggregates)) {
+		aggregate := view.Aggregates[column]
+		path := "/properties/aggregates/" + jsonPointerToken(column)
+
+		switch {
+		case aggregate.Function == ViewAggregateMin || aggregate.Function == ViewAggregateMax:
+			return PropertyPathError{Path: path + "/function", Reason: "min and max are values of single items, and can't be private"}
+		case view.Privacy.Epsilon > 0 && aggregate.Function != ViewAggregateCount && aggregate.Bound <= 0:
+			return PropertyPathError{Path: path + "/bound", Reason: "is needed to add noise"}
+		}
+	}
+
+	return nil
+}
+
+// viewRow is the projection of an item, or its contribution to a group of an aggregation: its group and the numeric
+// values of the aggregated fields it has.
+type viewRow struct {
+	item   *Resource
+	fields map[string]any
+	group  string
+	values map[string]float64
+}
+
+// viewGroup keeps the rows of a group of an aggregation, with the running sums and counts of their values.
+type viewGroup struct {
+	value  any
+	rows   map[string]viewRow
+	sums   map[string]float64
+	counts map[string]int
+}
+
+// materializedView keeps the rows of a view, updated on every write of the items of its resource types.
+type materializedView struct {
+	view   *View
+	query  viewQuery
+	rows   map[string]viewRow
+	groups map[string]*viewGroup
+}
+
+func newMaterializedView(view *View, query viewQuery) *materializedView {
+	return &materializedView{
+		view:   view,
+		query:  query,
+		rows:   make(map[string]viewRow),
+		groups: make(map[string]*viewGroup),
+	}
+}
+
+func (m *materializedView) selects(item *Resource) bool {
+	return item.Metadata.PackageName == m.view.Package && slices.Contains(m.view.ResourceTypes, item.Metadata.ResourceType)
+}
+
+// apply updates the view with a write. Applying a write more than once has no further effect.
+func (m *materializedView) apply(operation OperationType, item *Resource) {
+	key := restoreKey(item)
+
+	m.remove(key)
+
+	if operation != OperationDelete {
+		m.add(key, item)
+	}
+}
+
+func (m *materializedView) remove(key string) {
+	row, ok := m.rows[key]
+	if !ok {
+		return
+	}
+
+	delete(m.rows, key)
+
+	if len(m.query.aggregates) == 0 {
+		return
+	}
+
+	group := m.groups[row.group]
+	delete(group.rows, key)
+
+	if len(group.rows) == 0 {
+		delete(m.groups, row.group)
+
+		return
+	}
+
+	for column, value := range row.values {
+		group.sums[column] -= value
+		group.counts[column]--
+	}
+}
+
+func (m *materializedView) add(key string, item *Resource) {
+	row := viewRow{item: item, fields: nil, group: "", values: nil}
+
+	if len(m.query.aggregates) == 0 {
+		row.fields = m.project(item)
+		m.rows[key] = row
+
+		return
+	}
+
+	var groupValue any
+
+	if m.query.groupBy != nil {
+		groupValue, _ = propertyValue(item.Properties, m.query.groupBy)
+	}
+
+	b, _ := json.Marshal(groupValue)
+	row.group = string(b)
+	row.values = make(map[string]float64)
+
+	for column, aggregate := range m.query.aggregates {
+		if aggregate.field == nil {
+			continue
+		}
+
+		value, _ := propertyValue(item.Properties, aggregate.field)
+		if number, ok := value.(float64); ok {
+			row.values[column] = number
+		}
+	}
+
+	group, ok := m.groups[row.group]
+	if !ok {
+		group = &viewGroup{value: groupValue, rows: make(map[string]viewRow), sums: make(map[string]float64), counts: make(map[string]int)}
+		m.groups[row.group] = group
+	}
+
+	group.rows[key] = row
+
+	for column, value := range row.values {
+		group.sums[column] += value
+		group.counts[column]++
+	}
+
+	m.rows[key] = row
+}
+
+// project returns the fields of the projection of an item.
+func (m *materializedView) project(item *Resource) map[string]any {
+	fields := make(map[string]any, len(m.query.fields))
+
+	for column, segments := range m.query.fields {
+		fields[column], _ = propertyValue(item.Properties, segments)
+	}
+
+	return fields
+}
+
+// result returns the rows of the view, projections ordered by resource type and name, and groups by value. Aggregates
+// of groups without values are null. Private views leave out small groups, and add noise keyed by noiseKey.
+// Projections are of the items as present returns them, if given, such as with their PII anonymized.
+func (m *materializedView) result(noiseKey []byte, present func(item *Resource) *Resource) ViewResult {
+	res := ViewResult{Name: m.view.Metadata.Name, Rows: make([]map[string]any, 0)}
+
+	if len(m.query.aggregates) == 0 {
+		for _, key := range slices.Sorted(maps.Keys(m.rows)) {
+			row := m.rows[key]
+
+			values := maps.Clone(row.fields)
+			if present != nil {
+				values = m.project(present(row.item))
+			}
+
+			values["resourceType"] = row.item.Metadata.ResourceType
+			values["name"] = row.item.Metadata.Name
+
+			res.Rows = append(res.Rows, values)
+		}
+
+		return res
+	}
+
+	privacy := m.view.Privacy
+
+	for _, key := range slices.Sorted(maps.Keys(m.groups)) {
+		group := m.groups[key]
+		if privacy != nil && len(group.rows) < privacy.MinGroupSize {
+			continue
+		}
+
+		values := make(map[string]any, len(m.query.aggregates)+1)
+
+		if m.query.groupBy != nil {
+			values["group"] = group.value
+		}
+
+		for column, aggregate := range m.query.aggregates {
+			if privacy != nil && privacy.Epsilon > 0 {
+				values[column] = group.noisyAggregate(column, aggregate, privacy.Epsilon, group.noiseSource(noiseKey, m.view.Metadata.UID, key, column))
+			} else {
+				values[column] = group.aggregate(column, aggregate.function)
+			}
+		}
+
+		res.Rows = append(res.Rows, values)
+	}
+
+	return res
+}
+
+func (group *viewGroup) aggregate(column string, function ViewAggregateFunction) any {
+	count := group.counts[column]
+
+	switch {
+	case function == ViewAggregateCount:
+		return len(group.rows)
+	case count == 0:
+		return nil
+	case function == ViewAggregateSum:
+		return group.sums[column]
+	case function == ViewAggregateAvg:
+		return group.sums[column] / float64(count)
+	}
+
+	values := make([]float64, 0, count)
+
+	for _, row := range group.rows {
+		if value, ok := row.values[column]; ok {
+			values = append(values, value)
+		}
+	}
+
+	if function == ViewAggregateMin {
+		return slices.Min(values)
+	}
+
+	return slices.Max(values)
+}
+
+// noisyAggregate returns the aggregate of a column with Laplace noise for epsilon, drawn from rng. Values are clamped
+// to the bound of the aggregate, so a single item changes a sum by at most the bound, and a count by one.
+func (group *viewGroup) noisyAggregate(column string, aggregate viewAggregate, epsilon float64, rng *rand.Rand) float64 {
+	if aggregate.function == ViewAggregateCount {
+		return max(0, math.Round(float64(len(group.rows))+laplaceNoise(rng, 1/epsilon)))
+	}
+
+	sum := 0.0
+	count := 0
+
+	for _, row := range group.rows {
+		if value, ok := row.values[column]; ok {
+			sum += min(max(value, -aggregate.bound), aggregate.bound)
+			count++
+		}
+	}
+
+	if aggregate.function == ViewAggregateSum {
+		return sum + laplaceNoise(rng, aggregate.bound/epsilon)
+	}
+
+	// Averages spend half of epsilon on the sum, and half on the count.
+	half := epsilon / 2 //nolint:mnd // halves epsilon
+	noisySum := sum + laplaceNoise(rng, aggregate.bound/half)
+	noisyCount := max(1, float64(count)+laplaceNoise(rng, 1/half))
+
+	return min(max(noisySum/noisyCount, -aggregate.bound), aggregate.bound)
+}
+
+// noiseSource returns the source of the noise of an aggregate of the group, seeded by a keyed hash of the view, the
+// group, the column, and the items of the group with their resource versions. The noise only changes with them, and
+// can't be told without the key.
+func (group *viewGroup) noiseSource(key []byte, viewUID, groupKey, column string) *rand.Rand {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(viewUID + "\x00" + groupKey + "\x00" + column))
+
+	for _, rowKey := range slices.Sorted(maps.Keys(group.rows)) {
+		mac.Write([]byte("\x00" + rowKey + "\x00" + group.rows[rowKey].item.Metadata.ResourceVersion))
+	}
+
+	sum := mac.Sum(nil)
+
+	return rand.New(rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16]))) //nolint:gosec // seeded by a keyed hash
+}
+
+// laplaceNoise draws from the Laplace distribution centered at zero with scale.
+func laplaceNoise(rng *rand.Rand, scale float64) float64 {
+	u := rng.Float64() - 0.5 //nolint:mnd // centers the uniform draw
+
+	return -scale * math.Copysign(math.Log(1-2*math.Abs(u)), u)
+}
+
+// viewCache keeps the materialized views by name. Views are materialized when they are first read, and dropped when
+// they are written.
+type viewCache struct {
+	mu    sync.Mutex
+	views map[string]*materializedView
+	// noiseKey keys the noise of private views. It is random per process, so their noise can't be computed from the
+	// items.
+	noiseKey []byte
+}
+
+func newViewCache() *viewCache {
+	noiseKey := make([]byte, sha256.Size)
+	_, _ = crand.Read(noiseKey)
+
+	return &viewCache{
+		mu:       sync.Mutex{},
+		views:    make(map[string]*materializedView),
+		noiseKey: noiseKey,
+	}
+}
+
+func (cache *viewCache) apply(operation OperationType, item *Resource) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for _, m := range cache.views {
+		if m.selects(item) {
+			m.apply(operation, item)
+		}
+	}
+}
+
+func (cache *viewCache) drop(name string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.views, name)
+}
+
+// viewResult returns the rows of a view of a package, materializing it if it isn't yet. Projections are of the items
+// as present returns them, if given.
+func (h *Handler) viewResult(ctx context.Context, packageName, name string, present func(item *Resource) *Resource) (ViewResult, error) {
+	item, err := h.repoGet(ctx, corePackageName, "View", name)
+	if err != nil {
+		var resourceNotFoundError ResourceNotFoundError
+		if errors.As(err, &resourceNotFoundError) {
+			return ViewResult{}, ViewNotFoundError{PackageName: packageName, Name: name}
+		}
+
+		return ViewResult{}, err
+	}
+
+	view, err := viewFromResource(item)
+	if err != nil {
+		return ViewResult{}, err
+	}
+
+	if view.Package != packageName {
+		return ViewResult{}, ViewNotFoundError{PackageName: packageName, Name: name}
+	}
+
+	h.views.mu.Lock()
+	defer h.views.mu.Unlock()
+
+	m, ok := h.views.views[name]
+	if ok && m.view.Metadata.ResourceVersion == view.Metadata.ResourceVersion {
+		return m.result(h.views.noiseKey, present), nil
+	}
+
+	query, err := compileView(view)
+	if err != nil {
+		return ViewResult{}, err
+	}
+
+	m = newMaterializedView(view, query)
+
+	for _, resourceType := range view.ResourceTypes {
+		list, err := h.repoList(ctx, view.Package, "", resourceType)
+		if err != nil {
+			return ViewResult{}, err
+		}
+
+		for _, listItem := range list.Items {
+			m.apply(OperationCreate, listItem)
+		}
+	}
+
+	h.views.views[name] = m
+
+	return m.result(h.views.noiseKey, present), nil
+}
+
+// handleGetResourceOrView serves views at "/api/{packageName}/{apiVersion}/views/{name}", which shadows resource types
+// named views, and resources otherwise.
+func (h *Handler) handleGetResourceOrView() http.HandlerFunc {
+	getResource := h.handleGetResource()
+	getView := h.handleGetView()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("resourceTypePlural") == "views" {
+			getView(w, r)
+
+			return
+		}
+
+		getResource(w, r)
+	}
+}
+
+// handleGetView responds with the rows of a view, materialized from the items of its resource types and kept up to
+// date with their writes. Readers that may not read PII get projections of the items with their PII anonymized.
+func (h *Handler) handleGetView() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.viewResult(r.Context(), r.PathValue("packageName"), r.PathValue("name"), h.itemAnonymizer(r.Context(), privilegedRead(r)))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get view", "error", err)
+
+			var viewNotFoundError ViewNotFoundError
+
+			switch {
+			case errors.As(err, &viewNotFoundError):
+				respond.Done(w, r, problem.NotFound(viewNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		respond.Done(w, r, res)
+	}
+}
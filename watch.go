@@ -0,0 +1,584 @@
+package bass
+
+import (
+	"cmp"
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	WatchEvent         = apiv1.WatchEvent
+	JSONPatchOperation = apiv1.JSONPatchOperation
+)
+
+// watchBufferSize is the number of events buffered per subscriber. Subscribers falling further behind are dropped,
+// and their watches end.
+const watchBufferSize = 64
+
+type resourceEvent struct {
+	Type OperationType
+	Item *Resource
+	// Position is the position of the event in the event log, or zero if it isn't logged.
+	Position int64
+}
+
+// watchHub fans out events of resources written through the handler to watchers. Only writes of this handler are
+// observed.
+type watchHub struct {
+	mu          sync.Mutex
+	subscribers map[chan resourceEvent]struct{}
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{
+		mu:          sync.Mutex{},
+		subscribers: make(map[chan resourceEvent]struct{}),
+	}
+}
+
+// subscribe returns a channel of events published from now on, and a function to stop them. The channel is closed
+// when the subscription stops, or when the subscriber falls behind.
+func (hub *watchHub) subscribe() (<-chan resourceEvent, func()) {
+	events := make(chan resourceEvent, watchBufferSize)
+
+	hub.mu.Lock()
+	hub.subscribers[events] = struct{}{}
+	hub.mu.Unlock()
+
+	return events, func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+
+		hub.unsubscribe(events)
+	}
+}
+
+func (hub *watchHub) unsubscribe(events chan resourceEvent) {
+	_, ok := hub.subscribers[events]
+	if ok {
+		delete(hub.subscribers, events)
+		close(events)
+	}
+}
+
+func (hub *watchHub) publish(event resourceEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.send(event)
+}
+
+// publishLogged publishes an event with its position from log, logging it while publishing is locked, so events are
+// published in the order of their positions.
+func (hub *watchHub) publishLogged(event resourceEvent, log func(event resourceEvent) int64) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	event.Position = log(event)
+
+	hub.send(event)
+}
+
+func (hub *watchHub) send(event resourceEvent) {
+	for events := range hub.subscribers {
+		select {
+		case events <- event:
+		default:
+			hub.unsubscribe(events)
+		}
+	}
+}
+
+// resourceWritten records a successful write for metrics, watchers, the event log, the revision history, and replicas,
+// applies server configs, invalidates resolved schema definitions, and triggers emails, push notifications, and
+// notification rules in the background.
+func (h *Handler) resourceWritten(ctx context.Context, operation OperationType, item *Resource) {
+	h.metrics.recordWrite(item.Metadata.PackageName, item.Metadata.ResourceType, operation)
+
+	event := resourceEvent{Type: operation, Item: item.DeepCopy(), Position: 0}
+	if h.eventLogging != nil {
+		h.watchHub.publishLogged(event, func(event resourceEvent) int64 {
+			return h.logEvent(ctx, event)
+		})
+	} else {
+		h.watchHub.publish(event)
+	}
+
+	if h.revisions != nil {
+		h.revisions.record(revision{
+			Type:   operation,
+			At:     h.now(),
+			By:     APIKeyNameFromContext(ctx),
+			Origin: writeOriginFromContext(ctx),
+			Item:   item.DeepCopy(),
+			Erased: false,
+		})
+	}
+
+	h.replicate(ctx, operation, item)
+
+	if item.Metadata.PackageName == corePackageName && item.Metadata.ResourceType == "ServerConfig" {
+		h.serverConfigWritten(ctx, operation, item)
+	}
+
+	if item.Metadata.PackageName == corePackageName && item.Metadata.ResourceType == "SchemaDefinition" {
+		h.schemaDefinitions.clear()
+	}
+
+	if item.Metadata.PackageName == corePackageName && item.Metadata.ResourceType == "View" {
+		h.views.drop(item.Metadata.Name)
+	} else {
+		h.views.apply(operation, item.DeepCopy())
+	}
+
+	// Replicated writes were notified about and automated by the instance they were made through.
+	if item.Metadata.PackageName == corePackageName || isReplicated(ctx) {
+		return
+	}
+
+	if h.featureEnabled(FeatureAutomations) {
+		go h.runAutomations(context.WithoutCancel(ctx), operation, item.DeepCopy())
+	}
+
+	if !h.featureEnabled(FeatureNotifications) {
+		return
+	}
+
+	if h.emailSender != nil {
+		go h.triggerEmails(context.WithoutCancel(ctx), operation, item.DeepCopy())
+	}
+
+	if len(h.pushSenders) > 0 {
+		go h.triggerPush(context.WithoutCancel(ctx), operation, item.DeepCopy())
+	}
+
+	if len(h.notifiers) > 0 {
+		go h.triggerNotifications(context.WithoutCancel(ctx), operation, item.DeepCopy())
+	}
+}
+
+// handleWatchResources streams the resources of a type as newline delimited JSON watch events: the current items as
+// creates, then every write until the request is done or the watch falls behind. With delta=true, updates are JSON
+// Patches from the item as last streamed, and deletes carry only the name. Watches may select items by name, labels,
+// and properties, as parseResourceSelector reads them; items updated into the selection are sent as creates, and items
+// updated out of it as deletes.
+func (h *Handler) handleWatchResources() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := r.PathValue("packageName")
+		apiVersion := r.PathValue("apiVersion")
+		resourceTypePlural := r.PathValue("resourceTypePlural")
+
+		delta, err := parseBoolQuery(r, "delta")
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		selector, err := parseResourceSelector(r)
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		resourceTypeDefinition, err := h.getResourceTypeDefinition(r.Context(), packageName, resourceTypePlural)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to get resource type definition", "error", err)
+
+			var resourceTypeDefinitionNotFoundError ResourceTypeDefinitionNotFoundError
+
+			switch {
+			case errors.As(err, &resourceTypeDefinitionNotFoundError):
+				respond.Done(w, r, problem.NotFound(resourceTypeDefinitionNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+			default:
+				respond.Done(w, r, serverError(err))
+			}
+
+			return
+		}
+
+		resourceType := resourceTypeDefinition.ResourceType
+
+		// subscribe before listing, so no write between the list and the watch is missed
+		events, stop := h.watchHub.subscribe()
+		defer stop()
+
+		items, replay, ok := h.watchBacklog(w, r, func(ctx context.Context) ([]*Resource, error) {
+			list, err := h.repoList(ctx, packageName, apiVersion, resourceType)
+			if err != nil {
+				return nil, err
+			}
+
+			return list.Items, nil
+		})
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		var present func(item *Resource) *Resource
+		if !privilegedRead(r) {
+			present = func(item *Resource) *Resource { return anonymizedItem(resourceTypeDefinition, item) }
+		}
+
+		h.streamWatch(w, r, delta, items, replay, events, func(item *Resource) bool {
+			return item.Metadata.PackageName == packageName && item.Metadata.ResourceType == resourceType && selector.matches(item)
+		}, present)
+	}
+}
+
+// handleWatchPackage streams the resources of all types of a package like handleWatchResources, or of all packages
+// but core without a package in the path. Core resources are configuration, and aren't streamed.
+func (h *Handler) handleWatchPackage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		packageName := r.PathValue("packageName")
+		if packageName == corePackageName {
+			respond.Done(w, r, problem.BadRequest("core package can't be watched", withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		delta, err := parseBoolQuery(r, "delta")
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		selector, err := parseResourceSelector(r)
+		if err != nil {
+			respond.Done(w, r, problem.BadRequest(err.Error(), withReason(apierrors.ReasonBadRequest)))
+
+			return
+		}
+
+		// subscribe before listing, so no write between the list and the watch is missed
+		events, stop := h.watchHub.subscribe()
+		defer stop()
+
+		items, replay, ok := h.watchBacklog(w, r, func(ctx context.Context) ([]*Resource, error) {
+			return h.listWatchedResources(ctx, packageName, r.PathValue("apiVersion"))
+		})
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		h.streamWatch(w, r, delta, items, replay, events, func(item *Resource) bool {
+			if packageName != "" && item.Metadata.PackageName != packageName || item.Metadata.PackageName == corePackageName {
+				return false
+			}
+
+			// Watching all packages, items of packages the client may not access are left out.
+			return selector.matches(item) && (packageName != "" || h.checkNetworkAccess(r, item.Metadata.PackageName) == nil)
+		}, h.itemAnonymizer(r.Context(), privilegedRead(r)))
+	}
+}
+
+// listWatchedResources lists the items of all resource types of a package, or of all packages but core if the package
+// name is empty. Items are listed in the API version given, or else in the first version of their resource type.
+func (h *Handler) listWatchedResources(ctx context.Context, packageName, apiVersion string) ([]*Resource, error) {
+	list, err := h.repoList(ctx, corePackageName, "v1", "ResourceTypeDefinition")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource type definitions: %w", err)
+	}
+
+	var res []*Resource
+
+	for _, definition := range list.Items {
+		if packageName != "" && definition.Properties["package"] != packageName {
+			continue
+		}
+
+		resourceTypeDefinition, err := h.parseResourceTypeDefinition(ctx, definition)
+		if err != nil {
+			return nil, err
+		}
+
+		if resourceTypeDefinition.Package == corePackageName {
+			continue
+		}
+
+		items, err := h.repoList(ctx, resourceTypeDefinition.Package, cmp.Or(apiVersion, resourceTypeDefinition.Versions[0].Name), resourceTypeDefinition.ResourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, items.Items...)
+	}
+
+	return res, nil
+}
+
+// streamWatch sends the items matching as creates, or replays the logged events of a resuming watch, then sends the
+// events of the items matching until the request is done or the watch falls behind.
+func (h *Handler) streamWatch(w http.ResponseWriter, r *http.Request, delta bool, items []*Resource, replay []resourceEvent, events <-chan resourceEvent, match func(item *Resource) bool, present func(item *Resource) *Resource) {
+	watcher := &resourceWatcher{
+		rc:       http.NewResponseController(w),
+		enc:      jsontext.NewEncoder(w),
+		delta:    delta,
+		resumed:  replay != nil,
+		position: 0,
+		latest:   make(map[string]*Resource),
+	}
+
+	backlog := make([]resourceEvent, 0, len(items)+len(replay))
+	for _, item := range items {
+		backlog = append(backlog, resourceEvent{Type: OperationCreate, Item: item, Position: 0})
+	}
+
+	backlog = append(backlog, replay...)
+
+	for _, event := range backlog {
+		err := watcher.handle(presentWatchEvent(event, present), match(event.Item))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to write watch event", "error", err)
+
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			err := watcher.handle(presentWatchEvent(event, present), match(event.Item))
+			if err != nil {
+				h.logger.ErrorContext(r.Context(), "failed to write watch event", "error", err)
+
+				return
+			}
+		}
+	}
+}
+
+// presentWatchEvent returns event with its item as present returns it, e.g. with its PII anonymized, or as it is if
+// present is nil.
+func presentWatchEvent(event resourceEvent, present func(item *Resource) *Resource) resourceEvent {
+	if present != nil {
+		event.Item = present(event.Item)
+	}
+
+	return event
+}
+
+// resourceWatcher writes the events of a watch, keeping the items last written to follow the selection of the watch
+// and to patch them for delta watches.
+type resourceWatcher struct {
+	rc    *http.ResponseController
+	enc   *jsontext.Encoder
+	delta bool
+	// resumed watches replay the event log instead of starting with the current items, so the items the client has
+	// are unknown.
+	resumed bool
+	// position is the position of the last logged event handled, to skip live events replayed already.
+	position int64
+	latest   map[string]*Resource
+}
+
+// handle sends an event, as it is for the watcher, if the watcher gets it.
+func (watcher *resourceWatcher) handle(event resourceEvent, matched bool) error {
+	if event.Position != 0 {
+		if event.Position <= watcher.position {
+			return nil
+		}
+
+		watcher.position = event.Position
+	}
+
+	event, ok := watcher.selected(event, matched)
+	if !ok {
+		return nil
+	}
+
+	return watcher.send(event)
+}
+
+// selected returns the event as it is for the watcher, and whether the watcher gets it at all. Items the watcher got
+// are deleted once they don't match anymore, and items it didn't get are created once they match. Resumed watches get
+// the events of items they didn't get as they are, as the client may have them.
+func (watcher *resourceWatcher) selected(event resourceEvent, matched bool) (resourceEvent, bool) {
+	_, got := watcher.latest[watchKey(event.Item)]
+
+	switch {
+	case !got && watcher.resumed:
+		return event, matched
+	case !got && (!matched || event.Type == OperationDelete):
+		return event, false
+	case !got:
+		event.Type = OperationCreate
+	case !matched:
+		event.Type = OperationDelete
+	}
+
+	return event, true
+}
+
+func (watcher *resourceWatcher) send(event resourceEvent) error {
+	res, err := watcher.watchEvent(event)
+	if err != nil {
+		return err
+	}
+
+	err = json.MarshalEncode(watcher.enc, res)
+	if err != nil {
+		return fmt.Errorf("failed to encode watch event: %w", err)
+	}
+
+	err = watcher.rc.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to flush watch event: %w", err)
+	}
+
+	return nil
+}
+
+func (watcher *resourceWatcher) watchEvent(event resourceEvent) (WatchEvent, error) {
+	res := WatchEvent{Type: event.Type, Position: event.Position, Item: event.Item, PackageName: "", ResourceType: "", Name: "", ResourceVersion: "", Patch: nil}
+
+	key := watchKey(event.Item)
+	previous := watcher.latest[key]
+
+	if event.Type == OperationDelete {
+		delete(watcher.latest, key)
+	} else {
+		watcher.latest[key] = event.Item
+	}
+
+	if !watcher.delta {
+		return res, nil
+	}
+
+	switch event.Type {
+	case OperationCreate:
+	case OperationUpdate:
+		if previous == nil {
+			// Resumed watches send updates of items they didn't get whole.
+			break
+		}
+
+		patch, err := resourcePatch(previous, event.Item)
+		if err != nil {
+			return res, err
+		}
+
+		res.Item = nil
+		res.PackageName = event.Item.Metadata.PackageName
+		res.ResourceType = event.Item.Metadata.ResourceType
+		res.Name = event.Item.Metadata.Name
+		res.ResourceVersion = event.Item.Metadata.ResourceVersion
+		res.Patch = patch
+	case OperationDelete:
+		res.Item = nil
+		res.PackageName = event.Item.Metadata.PackageName
+		res.ResourceType = event.Item.Metadata.ResourceType
+		res.Name = event.Item.Metadata.Name
+	}
+
+	return res, nil
+}
+
+// watchKey identifies the item of an event among the items of a watch.
+func watchKey(item *Resource) string {
+	return resourceLabelValue(item.Metadata.PackageName, item.Metadata.ResourceType, item.Metadata.Name)
+}
+
+// resourcePatch returns the JSON Patch turning the encoding of a resource into the encoding of its next version.
+func resourcePatch(from, to *Resource) ([]JSONPatchOperation, error) {
+	var fromValue, toValue any
+
+	for _, item := range []struct {
+		resource *Resource
+		value    *any
+	}{{from, &fromValue}, {to, &toValue}} {
+		b, err := json.Marshal(item.resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal resource: %w", err)
+		}
+
+		err = json.Unmarshal(b, item.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal resource: %w", err)
+		}
+	}
+
+	return jsonPatch(nil, "", fromValue, toValue)
+}
+
+// jsonPatch appends the operations turning the JSON value at path from one value into another to patch. Objects are
+// compared by member; anything else, including arrays, is replaced as a whole.
+func jsonPatch(patch []JSONPatchOperation, path string, from, to any) ([]JSONPatchOperation, error) {
+	fromObject, fromOK := from.(map[string]any)
+	toObject, toOK := to.(map[string]any)
+
+	if !fromOK || !toOK {
+		if reflect.DeepEqual(from, to) {
+			return patch, nil
+		}
+
+		value, err := json.Marshal(to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal patch value: %w", err)
+		}
+
+		return append(patch, JSONPatchOperation{Op: "replace", Path: path, Value: value}), nil
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(fromObject)) {
+		if _, ok := toObject[key]; !ok {
+			patch = append(patch, JSONPatchOperation{Op: "remove", Path: path + "/" + jsonPointerToken(key), Value: nil})
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(toObject)) {
+		memberPath := path + "/" + jsonPointerToken(key)
+
+		fromMember, ok := fromObject[key]
+		if !ok {
+			value, err := json.Marshal(toObject[key])
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal patch value: %w", err)
+			}
+
+			patch = append(patch, JSONPatchOperation{Op: "add", Path: memberPath, Value: value})
+
+			continue
+		}
+
+		var err error
+
+		patch, err = jsonPatch(patch, memberPath, fromMember, toObject[key])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return patch, nil
+}
+
+// jsonPointerToken escapes a key as a reference token of a JSON Pointer (RFC 6901).
+func jsonPointerToken(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
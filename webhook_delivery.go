@@ -0,0 +1,275 @@
+package bass
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	apiv1 "github.com/nasermirzaei89/bass/api/v1"
+	"github.com/nasermirzaei89/bass/apierrors"
+	"github.com/nasermirzaei89/problem"
+	"github.com/nasermirzaei89/respond"
+)
+
+type (
+	WebhookDelivery       = apiv1.WebhookDelivery
+	WebhookDeliveryStatus = apiv1.WebhookDeliveryStatus
+)
+
+const (
+	WebhookDeliverySucceeded = apiv1.WebhookDeliverySucceeded
+	WebhookDeliveryFailed    = apiv1.WebhookDeliveryFailed
+)
+
+// Labels of webhook deliveries, to select them by channel and status.
+const (
+	webhookDeliveryChannelLabel = "bass.webhook/channel"
+	webhookDeliveryStatusLabel  = "bass.webhook/status"
+)
+
+// StatusNotifier is a Notifier reporting the HTTP status code the receiving server responded with, or zero if it
+// didn't respond, for webhook deliveries to record it.
+type StatusNotifier interface {
+	Notifier
+	NotifyStatus(ctx context.Context, channel *NotificationChannel, notification Notification) (int, error)
+}
+
+func webhookDeliveryResourceTypeDefinition() *ResourceTypeDefinition {
+	return &ResourceTypeDefinition{
+		Metadata: Metadata{
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "ResourceTypeDefinition",
+			Name:         "WebhookDelivery.core",
+		},
+		Package:      corePackageName,
+		ResourceType: "WebhookDelivery",
+		Plural:       "WebhookDeliveries",
+		Versions: []ResourceTypeDefinitionVersion{
+			{
+				Name: "v1",
+				Schema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"channel":      map[string]any{"type": "string", "minLength": 1},
+						"url":          map[string]any{"type": "string"},
+						"payload":      map[string]any{"type": "object"},
+						"status":       map[string]any{"type": "string", "enum": []any{WebhookDeliverySucceeded, WebhookDeliveryFailed}},
+						"statusCode":   map[string]any{"type": "integer"},
+						"latencyMs":    map[string]any{"type": "integer", "minimum": 0},
+						"error":        map[string]any{"type": "string"},
+						"redeliveryOf": map[string]any{"type": "string"},
+					},
+					"required": []any{"channel", "url", "payload", "status", "latencyMs"},
+				},
+			},
+		},
+	}
+}
+
+func webhookDeliveryFromResource(item *Resource) (*WebhookDelivery, error) {
+	b, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook delivery properties: %w", err)
+	}
+
+	var delivery WebhookDelivery
+
+	err = json.Unmarshal(b, &delivery)
+	if err != nil {
+		return nil, fmt.Errorf("webhook delivery %q has invalid properties: %w", item.Metadata.Name, err)
+	}
+
+	delivery.Metadata = item.Metadata
+
+	return &delivery, nil
+}
+
+// deliverWebhook delivers a notification to a webhook channel with notifier, and records the delivery. Failing to
+// record it is logged.
+func (h *Handler) deliverWebhook(ctx context.Context, notifier Notifier, channel *NotificationChannel, notification Notification, redeliveryOf string) (*Resource, error) {
+	payload, err := notificationPayload(notification)
+	if err != nil {
+		return nil, err
+	}
+
+	start := h.now()
+
+	var statusCode int
+
+	if statusNotifier, ok := notifier.(StatusNotifier); ok {
+		statusCode, err = statusNotifier.NotifyStatus(ctx, channel, notification)
+	} else {
+		err = notifier.Notify(ctx, channel, notification)
+	}
+
+	latency := h.now().Sub(start)
+
+	properties := map[string]any{
+		"channel":   channel.Metadata.Name,
+		"url":       channel.URL,
+		"payload":   payload,
+		"status":    string(WebhookDeliverySucceeded),
+		"latencyMs": latency.Milliseconds(),
+	}
+
+	if statusCode != 0 {
+		properties["statusCode"] = statusCode
+	}
+
+	if err != nil {
+		properties["status"] = string(WebhookDeliveryFailed)
+		properties["error"] = err.Error()
+		err = fmt.Errorf("failed to deliver webhook: %w", err)
+	}
+
+	if redeliveryOf != "" {
+		properties["redeliveryOf"] = redeliveryOf
+	}
+
+	item := &Resource{
+		Metadata: Metadata{
+			UID:          uuid.NewString(),
+			PackageName:  corePackageName,
+			APIVersion:   "v1",
+			ResourceType: "WebhookDelivery",
+			Name:         uuid.NewString(),
+			Labels: map[string]string{
+				webhookDeliveryChannelLabel: channel.Metadata.Name,
+				webhookDeliveryStatusLabel:  properties["status"].(string), //nolint:forcetypeassert // set above
+			},
+			ResourceVersion: "",
+			CreatedAt:       start,
+			UpdatedAt:       start,
+		},
+		Properties: properties,
+	}
+
+	createErr := h.repo.Create(ctx, item)
+	if createErr != nil {
+		h.logger.ErrorContext(ctx, "failed to record webhook delivery", "channel", channel.Metadata.Name, "error", createErr)
+
+		return nil, err
+	}
+
+	h.resourceWritten(ctx, OperationCreate, item)
+
+	return item, err
+}
+
+// notificationPayload returns a notification as the JSON object a webhook delivery posts.
+func notificationPayload(notification Notification) (map[string]any, error) {
+	b, err := json.Marshal(notification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	var payload map[string]any
+
+	err = json.Unmarshal(b, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notification: %w", err)
+	}
+
+	return payload, nil
+}
+
+// handleRedeliverWebhook delivers the payload of a webhook delivery again, to the channel as it is configured now, and
+// responds with the new delivery, whether it succeeded or not.
+func (h *Handler) handleRedeliverWebhook(w http.ResponseWriter, r *http.Request, name string) {
+	if r.PathValue("packageName") != corePackageName || r.PathValue("resourceTypePlural") != "webhookdeliveries" {
+		respond.Done(w, r, problem.NotFound(`action "redeliver" is only available for webhook deliveries`, withReason(apierrors.ReasonNotFound)))
+
+		return
+	}
+
+	delivery, channel, err := h.getWebhookDelivery(r.Context(), name)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get webhook delivery", "error", err)
+
+		var resourceNotFoundError ResourceNotFoundError
+
+		switch {
+		case errors.As(err, &resourceNotFoundError):
+			respond.Done(w, r, problem.NotFound(resourceNotFoundError.Error(), withReason(apierrors.ReasonNotFound)))
+		default:
+			respond.Done(w, r, serverError(err))
+		}
+
+		return
+	}
+
+	b, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		respond.Done(w, r, serverError(fmt.Errorf("failed to marshal webhook delivery payload: %w", err)))
+
+		return
+	}
+
+	var notification Notification
+
+	err = json.Unmarshal(b, &notification)
+	if err != nil {
+		respond.Done(w, r, problem.BadRequest(fmt.Sprintf("webhook delivery %q has an invalid payload: %s", name, err), withReason(apierrors.ReasonInvalid)))
+
+		return
+	}
+
+	notifier, ok := h.notifiers[NotificationChannelWebhook]
+	if !ok {
+		respond.Done(w, r, problem.CustomError(
+			problem.WithStatus(http.StatusNotImplemented),
+			problem.WithTitle("Not Implemented"),
+			problem.WithDetail(NotificationChannelNotSupportedError{Type: NotificationChannelWebhook}.Error()),
+			withReason(apierrors.ReasonNotImplemented),
+		))
+
+		return
+	}
+
+	item, err := h.deliverWebhook(r.Context(), notifier, channel, notification, name)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to redeliver webhook", "delivery", name, "error", err)
+	}
+
+	if item == nil {
+		respond.Done(w, r, serverError(errors.New("failed to record webhook delivery")))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.Done(w, r, item)
+}
+
+// getWebhookDelivery returns a webhook delivery and its channel, which must still be a webhook channel.
+func (h *Handler) getWebhookDelivery(ctx context.Context, name string) (*WebhookDelivery, *NotificationChannel, error) {
+	item, err := h.repoGet(ctx, corePackageName, "WebhookDelivery", name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	delivery, err := webhookDeliveryFromResource(item)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channelItem, err := h.repoGet(ctx, corePackageName, "NotificationChannel", delivery.Channel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channel, err := notificationChannelFromResource(channelItem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if channel.Type != NotificationChannelWebhook {
+		return nil, nil, fmt.Errorf("notification channel %q is not a webhook channel anymore", channel.Metadata.Name)
+	}
+
+	return delivery, channel, nil
+}